@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// Exit codes of the gomark command. Scripts rely on these values, so they
+// must never be renumbered; new failure kinds get a new code.
+const (
+	exitOK         = 0 // success
+	exitRuntime    = 1 // I/O failure, corrupt model, anything unexpected
+	exitUsage      = 2 // bad command line
+	exitEmptyModel = 3 // the model cannot produce any text
+	exitConstraint = 4 // a generation constraint could not be satisfied
+)
+
+// ErrInvalidModel is returned by the validate command when problems were found.
+var ErrInvalidModel = errors.New("model is invalid")
+
+// UsageError reports a malformed command line.
+type UsageError struct {
+	Msg string
+}
+
+func (e *UsageError) Error() string { return e.Msg }
+
+// usagef returns a *UsageError with a formatted message.
+func usagef(format string, args ...interface{}) error {
+	return &UsageError{fmt.Sprintf(format, args...)}
+}
+
+// exitCode maps an error returned by a command to the process exit code.
+// It is the only place that decides exit codes. flag.ErrHelp, returned
+// after -h printed the flags of a command, is not a failure.
+func exitCode(err error) int {
+	var usage *UsageError
+	var preflight *markov.PreflightError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usage), errors.As(err, &preflight):
+		// Options the model has no data for are a command line to fix.
+		return exitUsage
+	case errors.Is(err, markov.ErrEmptyModel), errors.Is(err, markov.ErrDeadEnd):
+		return exitEmptyModel
+	case errors.Is(err, markov.ErrUnsatisfiable), errors.Is(err, markov.ErrUnknownPrefix), errors.Is(err, markov.ErrNotContinuation):
+		return exitConstraint
+	default:
+		return exitRuntime
+	}
+}
+
+// errorKind names the class of err in -print-error-json output.
+func errorKind(code int) string {
+	switch code {
+	case exitUsage:
+		return "usage"
+	case exitEmptyModel:
+		return "empty_model"
+	case exitConstraint:
+		return "unsatisfiable"
+	default:
+		return "runtime"
+	}
+}
+
+// ErrorReport is the object written to stderr by -print-error-json.
+type ErrorReport struct {
+	Code    int    `json:"code"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// printErrorJSON selects the structured form of error reporting.
+var printErrorJSON bool
+
+// reportError writes err to w, as JSON if -print-error-json was given,
+// and returns the exit code for it. Errors that are no failure, such as
+// flag.ErrHelp, are not written.
+func reportError(w io.Writer, err error) int {
+	code := exitCode(err)
+	if code == exitOK {
+		return code
+	}
+	if printErrorJSON {
+		writeJSON(w, ErrorReport{Code: code, Kind: errorKind(code), Message: err.Error()})
+		return code
+	}
+	var usage *UsageError
+	var checksum *markov.ChecksumError
+	switch {
+	case errors.As(err, &usage):
+		fmt.Fprintln(w, "Sorry:", err)
+	case errors.As(err, &checksum):
+		fmt.Fprintf(w, "Error: %v\nRegenerate the model with gomark read.\n", err)
+	default:
+		fmt.Fprintln(w, "Error:", err)
+	}
+	return code
+}
+
+// parseFlags parses args into fs. A malformed command line is returned as
+// a *UsageError, so that it exits like any other and -print-error-json
+// applies to it; -h prints the flags of the command to stderr and returns
+// flag.ErrHelp.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fs.SetOutput(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Flags of gomark %s:\n", fs.Name())
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+		return err
+	}
+	if err != nil {
+		return &UsageError{err.Error() + "."}
+	}
+	return nil
+}
+
+// warnChecksum passes on what a model loader returned, but for the
+// *markov.ChecksumError of a model file too old to have a checksum, which
+// is printed as a warning instead, the model being loaded all the same.
+func warnChecksum[T any](v T, err error) (T, error) {
+	var checksum *markov.ChecksumError
+	if errors.As(err, &checksum) && checksum.Missing {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+		return v, nil
+	}
+	return v, err
+}
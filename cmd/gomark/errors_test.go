@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// TestExitCode lists every typed error the commands return, so that a new
+// one cannot fall back to exitRuntime unnoticed.
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		kind string
+	}{
+		{nil, exitOK, "runtime"},
+		{flag.ErrHelp, exitOK, "runtime"},
+		{usagef("bad flag."), exitUsage, "usage"},
+		{fmt.Errorf("generate: %w", &UsageError{"wrapped"}), exitUsage, "usage"},
+		{markov.ErrEmptyModel, exitEmptyModel, "empty_model"},
+		{fmt.Errorf("m.txt: %w", markov.ErrDeadEnd), exitEmptyModel, "empty_model"},
+		{markov.ErrUnsatisfiable, exitConstraint, "unsatisfiable"},
+		{fmt.Errorf("m.txt: %w", markov.ErrUnknownPrefix), exitConstraint, "unsatisfiable"},
+		{fmt.Errorf(`"dog" after "the": %w`, markov.ErrNotContinuation), exitConstraint, "unsatisfiable"},
+		{fmt.Errorf("m.txt: %w", &markov.PreflightError{Missing: []markov.MissingCapability{{Option: "-backoff"}}}), exitUsage, "usage"},
+		{markov.ErrUnsupportedVersion, exitRuntime, "runtime"},
+		{&markov.ChecksumError{Name: "m.txt", Problem: "checksum mismatch"}, exitRuntime, "runtime"},
+		{&markov.BuildError{Files: []*markov.FileError{{Name: "a.txt", Err: errors.New("a.txt: missing")}}}, exitRuntime, "runtime"},
+		{ErrInvalidModel, exitRuntime, "runtime"},
+		{errors.New("disk full"), exitRuntime, "runtime"},
+	}
+	for _, tt := range tests {
+		code := exitCode(tt.err)
+		if code != tt.code {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, code, tt.code)
+		}
+		if kind := errorKind(code); code != exitOK && kind != tt.kind {
+			t.Errorf("errorKind(%d) = %q, want %q", code, kind, tt.kind)
+		}
+	}
+}
+
+func TestParseFlagsUsageError(t *testing.T) {
+	fs := newFlagSet("stats")
+	fs.Bool("json", false, "")
+	err := parseFlags(fs, []string{"-bogus"})
+	var usage *UsageError
+	if !errors.As(err, &usage) || exitCode(err) != exitUsage {
+		t.Fatalf("parseFlags(-bogus) = %v, want a *UsageError", err)
+	}
+	if err := parseFlags(newFlagSet("stats"), []string{"-h"}); !errors.Is(err, flag.ErrHelp) || exitCode(err) != exitOK {
+		t.Errorf("parseFlags(-h) = %v, want flag.ErrHelp exiting with 0", err)
+	}
+}
+
+func TestReportErrorJSON(t *testing.T) {
+	defer func(old bool) { printErrorJSON = old }(printErrorJSON)
+	fs := newFlagSet("stats")
+	printErrorJSON = true
+	var buf bytes.Buffer
+	code := reportError(&buf, parseFlags(fs, []string{"-bogus"}))
+	var rep ErrorReport
+	if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	if code != exitUsage || rep.Code != exitUsage || rep.Kind != "usage" || !strings.Contains(rep.Message, "-bogus") {
+		t.Errorf("reportError = %d, %+v", code, rep)
+	}
+
+	buf.Reset()
+	if code := reportError(&buf, flag.ErrHelp); code != exitOK || buf.Len() != 0 {
+		t.Errorf("reportError(flag.ErrHelp) = %d, wrote %q", code, buf.String())
+	}
+}
+
+// TestReportChecksum generates from a model cut short, which fails with a
+// message telling to regenerate it, and from a model too old to have a
+// checksum, which only warns.
+func TestReportChecksum(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	if err := os.WriteFile(at("in.txt"), []byte(goldenCorpus), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	capture(t, &os.Stderr, func() error {
+		capture(t, &os.Stdout, func() error { return readCmd([]string{"2", at("m.txt"), at("in.txt")}) })
+		return nil
+	})
+	model, err := os.ReadFile(at("m.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(at("cut.txt"), model[:len(model)/2], 0o666); err != nil {
+		t.Fatal(err)
+	}
+	var genErr error
+	capture(t, &os.Stdout, func() error {
+		genErr = generateCmd([]string{at("cut.txt"), "10"})
+		return nil
+	})
+	var buf bytes.Buffer
+	if code := reportError(&buf, genErr); code != exitRuntime || !strings.Contains(buf.String(), "truncated or corrupt") || !strings.Contains(buf.String(), "Regenerate the model") {
+		t.Errorf("generate from a model cut short reported %d, %q", code, buf.String())
+	}
+
+	old := filepath.Join("..", "..", "markov", "testdata", "versions", "v3.model")
+	var out string
+	warning := capture(t, &os.Stderr, func() error {
+		out = capture(t, &os.Stdout, func() error { return generateCmd([]string{old, "10"}) })
+		return nil
+	})
+	if out == "" || !strings.Contains(warning, "has no checksum") {
+		t.Errorf("generate from a model without a checksum printed %q and warned %q", out, warning)
+	}
+}
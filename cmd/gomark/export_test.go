@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// TestExportDOT checks that export draws the graph of a model read from
+// the example of the package comment as the golden files of the markov
+// package have it.
+func TestExportDOT(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	if err := os.WriteFile(at("number.txt"), []byte("I am not a number! I am a free man!"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	capture(t, &os.Stderr, func() error {
+		capture(t, &os.Stdout, func() error { return readCmd([]string{"2", at("m.txt"), at("number.txt")}) })
+		return nil
+	})
+	for _, tt := range []struct {
+		args   []string
+		golden string
+	}{
+		{[]string{at("m.txt")}, "number.dot.golden"},
+		{[]string{"-format", "dot", "-min-count", "1", "-max-nodes", "4", at("m.txt")}, "number.pruned.dot.golden"},
+	} {
+		want, err := os.ReadFile(filepath.Join("..", "..", "markov", "testdata", tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := capture(t, &os.Stdout, func() error { return exportCmd(tt.args) }); got != string(want) {
+			t.Errorf("export %v:\n%s\nwant\n%s", tt.args, got, want)
+		}
+	}
+}
+
+// TestExportProbabilities checks that export -format probabilities writes
+// what WriteProbabilities does, as a model generate can read, and refuses
+// decimal places it cannot write.
+func TestExportProbabilities(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	c := markov.TinyModel()
+	if err := c.WriteFreTable(at("m.txt")); err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	if err := c.WriteProbabilities(&want, 2); err != nil {
+		t.Fatal(err)
+	}
+	got := capture(t, &os.Stdout, func() error {
+		return exportCmd([]string{"-format", "probabilities", "-decimals", "2", at("m.txt")})
+	})
+	if got != want.String() {
+		t.Errorf("export -format probabilities:\n%s\nwant\n%s", got, want.String())
+	}
+	if err := os.WriteFile(at("p.txt"), []byte(got), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	read, err := markov.ReadFreTable(at("p.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := read.Generate(50); text == "" {
+		t.Error("the exported model generates nothing")
+	}
+
+	for _, decimals := range []string{"0", "10"} {
+		if err := exportCmd([]string{"-format", "probabilities", "-decimals", decimals, at("m.txt")}); err == nil {
+			t.Errorf("export -decimals %s succeeded", decimals)
+		}
+	}
+}
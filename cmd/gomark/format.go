@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// formatCount renders n with thousands separators, e.g. 1234567 -> "1,234,567".
+// It is meant for human-facing output only; JSON output uses plain integers.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= 3 {
+		return sign + s
+	}
+	out := make([]byte, 0, len(s)+len(s)/3)
+	head := len(s) % 3
+	if head > 0 {
+		out = append(out, s[:head]...)
+	}
+	for i := head; i < len(s); i += 3 {
+		if len(out) > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, s[i:i+3]...)
+	}
+	return sign + string(out)
+}
+
+// formatBytes renders a byte size in human-readable binary units, e.g. "1.4 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// writeJSON writes v to w as indented JSON followed by a newline.
+// Every command that supports -json goes through here so that the
+// encoding settings stay the same everywhere, and match those of the
+// JSON written by package markov.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// sortedKeys returns the keys of m in increasing order, so that output
+// never depends on map iteration order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
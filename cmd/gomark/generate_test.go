@@ -0,0 +1,194 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGeneratePretty covers the four combinations of a model with and
+// without punctuation tokens and generate with and without -pretty: the
+// joining follows the model unless the flag says otherwise, and overriding
+// it is warned about.
+func TestGeneratePretty(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{"punct": "the cat sat , then ran .", "plain": "the cat sat, then ran."} {
+		if err := os.WriteFile(filepath.Join(dir, name+".txt"), []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		capture(t, &os.Stderr, func() error {
+			capture(t, &os.Stdout, func() error {
+				return readCmd([]string{"1", filepath.Join(dir, name+".model"), filepath.Join(dir, name+".txt")})
+			})
+			return nil
+		})
+	}
+	for _, tt := range []struct {
+		model, flag string
+		want, warn  string
+	}{
+		{"punct", "", "the cat sat, then ran.", ""},
+		{"punct", "-pretty", "the cat sat, then ran.", ""},
+		{"punct", "-pretty=false", "the cat sat , then ran .", "without -pretty they are joined with spaces"},
+		{"plain", "", "the cat sat, then ran.", ""},
+		{"plain", "-pretty", "the cat sat, then ran.", "-pretty changes nothing"},
+		{"plain", "-pretty=false", "the cat sat, then ran.", ""},
+	} {
+		args := []string{"-seed", "1", filepath.Join(dir, tt.model+".model"), "20"}
+		if tt.flag != "" {
+			args = append([]string{tt.flag}, args...)
+		}
+		var out string
+		warn := capture(t, &os.Stderr, func() error {
+			out = capture(t, &os.Stdout, func() error { return generateCmd(args) })
+			return nil
+		})
+		if strings.TrimSpace(out) != tt.want {
+			t.Errorf("%s model, %q: %q, want %q", tt.model, tt.flag, out, tt.want)
+		}
+		if tt.warn == "" && warn != "" || !strings.Contains(warn, tt.warn) {
+			t.Errorf("%s model, %q: warned %q, want %q", tt.model, tt.flag, warn, tt.warn)
+		}
+	}
+}
+
+// TestGeneratePreflight checks that generate fails naming an option the
+// model has no data for, and only warns about it with -lenient.
+func TestGeneratePreflight(t *testing.T) {
+	dir := t.TempDir()
+	model, input := filepath.Join(dir, "m.txt"), filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(input, []byte("one line.\n\nanother line."), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	capture(t, &os.Stderr, func() error {
+		capture(t, &os.Stdout, func() error { return readCmd([]string{"1", model, input}) })
+		return nil
+	})
+	var err error
+	capture(t, &os.Stdout, func() error {
+		err = generateCmd([]string{"-paragraph-lengths", model, "5"})
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "-paragraph-lengths needs") || !strings.Contains(err.Error(), "read -paragraphs") {
+		t.Errorf("generate -paragraph-lengths = %v, want an error naming the option and the fix", err)
+	}
+	var out string
+	warn := capture(t, &os.Stderr, func() error {
+		out = capture(t, &os.Stdout, func() error {
+			err = generateCmd([]string{"-lenient", "-paragraph-lengths", model, "5"})
+			return nil
+		})
+		return nil
+	})
+	if err != nil || out == "" || !strings.Contains(warn, "-paragraph-lengths needs") {
+		t.Errorf("generate -lenient -paragraph-lengths = %v, %q, warning %q; want text and a warning", err, out, warn)
+	}
+}
+
+// TestGeneratePreset stores a preset in a model and checks that generate
+// -preset applies it, and that flags given on the command line win.
+func TestGeneratePreset(t *testing.T) {
+	dir := t.TempDir()
+	model, input := filepath.Join(dir, "m.txt"), filepath.Join(dir, "in.txt")
+	if err := os.WriteFile(input, []byte(goldenCorpus), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	run := func(cmd func([]string) error, args ...string) (string, error) {
+		var out string
+		var err error
+		capture(t, &os.Stderr, func() error {
+			out = capture(t, &os.Stdout, func() error {
+				err = cmd(args)
+				return nil
+			})
+			return nil
+		})
+		return out, err
+	}
+	run(readCmd, "2", model, input)
+	if _, err := run(presetCmd, "set", model, "casual", "-seed=3", "-output-format=tokens-json"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run(presetCmd, "list", model); err != nil || out != "casual\t-seed=3 -output-format=tokens-json\n" {
+		t.Errorf("preset list = %q, %v", out, err)
+	}
+
+	var outs []string
+	for _, tt := range []struct {
+		args, same []string
+	}{
+		{[]string{"-preset", "casual"}, []string{"-seed", "3", "-output-format", "tokens-json"}},
+		{[]string{"-preset", "casual", "-seed", "4"}, []string{"-seed", "4", "-output-format", "tokens-json"}},
+		{[]string{"-output-format", "text", "-preset", "casual"}, []string{"-seed", "3"}},
+	} {
+		got, err := run(generateCmd, append(tt.args, model, "15")...)
+		if err != nil {
+			t.Fatalf("generate %q: %v", tt.args, err)
+		}
+		want, _ := run(generateCmd, append(tt.same, model, "15")...)
+		if got != want {
+			t.Errorf("generate %q printed\n%s\nwant, as generate %q,\n%s", tt.args, got, tt.same, want)
+		}
+		outs = append(outs, got)
+	}
+	if outs[0] == outs[1] || outs[0] == outs[2] {
+		t.Errorf("the overriding flags changed nothing; pick other seeds")
+	}
+
+	// Presets are checked against the model when they are set.
+	if _, err := run(presetCmd, "set", model, "paras", "-paragraph-lengths"); err == nil || !strings.Contains(err.Error(), "-paragraph-lengths") {
+		t.Errorf("setting a preset the model has no data for: %v, want an error naming the option", err)
+	}
+	if _, err := run(generateCmd, "-preset", "missing", model, "5"); err == nil {
+		t.Errorf("generate -preset missing succeeded")
+	}
+	// Set without flags, a preset is removed.
+	run(presetCmd, "set", model, "casual")
+	if out, _ := run(presetCmd, "list", model); out != "" {
+		t.Errorf("preset list after removing casual = %q", out)
+	}
+}
+
+// TestGenerateFormats writes the model of a corpus of unicode and
+// punctuation in every format, picked by -format or by the extension, and
+// checks that generate reads each back to the same text.
+func TestGenerateFormats(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	corpus := "«Ünïcödé» says 日本語 — \"quoted\" {braces} 50% off! ¡Hola! ... «Ünïcödé» says no.\n"
+	if err := os.WriteFile(at("in.txt"), []byte(corpus), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	run := func(cmd func([]string) error, args ...string) string {
+		var out string
+		capture(t, &os.Stderr, func() error {
+			out = capture(t, &os.Stdout, func() error { return cmd(args) })
+			return nil
+		})
+		return out
+	}
+	for _, args := range [][]string{
+		{"1", at("m.txt"), at("in.txt")},
+		{"1", at("m.json"), at("in.txt")},
+		{"1", at("m.gob"), at("in.txt")},
+		{"1", at("m.msgpack"), at("in.txt")},
+		{"-format", "json", "1", at("json.model"), at("in.txt")},
+	} {
+		run(readCmd, args...)
+	}
+	want := run(generateCmd, "-seed", "3", at("m.txt"), "40")
+	if !strings.Contains(want, "日本語") {
+		t.Fatalf("generated %q from the text model", want)
+	}
+	for _, args := range [][]string{
+		{at("m.json")},
+		{at("m.gob")},
+		{at("m.msgpack")},
+		{"-format", "json", at("json.model")},
+	} {
+		if got := run(generateCmd, append(append([]string{"-seed", "3"}, args...), "40")...); got != want {
+			t.Errorf("generate %s: %q, want %q as from the text model", strings.Join(args, " "), got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the -json reports")
+
+// goldenCorpus is the input of the commands whose reports are compared with
+// the golden files.
+const goldenCorpus = `The cat sat on the mat. The dog sat on the log.
+The cat saw the dog, and the dog saw the cat.
+`
+
+// capture returns what fn writes to *file, os.Stdout or os.Stderr.
+func capture(t *testing.T, file **os.File, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *file
+	*file = w
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	err = fn()
+	*file = saved
+	w.Close()
+	data := <-out
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// goldenDir holds the golden files, found before the tests change directory.
+var goldenDir, _ = filepath.Abs("testdata")
+
+// checkGolden compares got with testdata/name.golden, or rewrites the file
+// with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join(goldenDir, name+".golden")
+	if *update {
+		if err := os.WriteFile(file, got, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed; if that was meant, rerun with -update and review the diff.\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// TestJSONReports runs every command printing a report with -json on a
+// fixed corpus and compares the output with golden files, so that a field
+// renamed or dropped by accident, or human formatting such as "1,234" or
+// "2.0 KB" leaking into JSON, fails here. The commands run in a temporary
+// directory with relative file names, as models record the names of their
+// sources and the reports depend on their sizes.
+func TestJSONReports(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.WriteFile("corpus.txt", []byte(goldenCorpus), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("other.txt", []byte("The dog sat on the cat.\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cmd  func([]string) error
+		args []string
+	}{
+		{"read", readCmd, []string{"-json", "2", "m.txt", "corpus.txt", "missing.txt"}},
+		{"read-gob", readCmd, []string{"-json", "-format", "gob", "2", "m.gob", "corpus.txt"}},
+		{"read-dry-run", readCmd, []string{"-json", "-dry-run", "-lowercase", "2", "dry.txt", "corpus.txt"}},
+		{"other", readCmd, []string{"-json", "2", "o.txt", "other.txt"}},
+		{"stats", statsCmd, []string{"-json", "-top", "2", "m.txt"}},
+		{"metadata", metadataCmd, []string{"-json", "m.txt"}},
+		{"vocab", vocabCmd, []string{"-json", "m.txt"}},
+		{"sentinels", sentinelsCmd, []string{"-json", "m.txt"}},
+		{"score", scoreCmd, []string{"-json", "other.txt", "m.txt", "o.txt"}},
+		{"diff", diffCmd, []string{"-json", "m.txt", "o.txt"}},
+		{"validate", validateCmd, []string{"-json", "m.txt"}},
+		{"repair", repairCmd, []string{"-json", "m.txt", "r.txt"}},
+		{"prune", pruneCmd, []string{"-json", "-min-count", "2", "m.txt", "p.txt"}},
+		{"merge", mergeCmd, []string{"-json", "merged.txt", "m.txt", "-weight", "2", "o.txt"}},
+		{"migrate", migrateCmd, []string{"-json", "m.txt", "migrated.txt"}},
+		{"inspect", inspectCmd, []string{"-json", "m.txt", "on", "the"}},
+	}
+	for _, tt := range tests {
+		out := capture(t, &os.Stdout, func() error { return tt.cmd(tt.args) })
+		checkGolden(t, tt.name, []byte(out))
+	}
+
+	// The self-test, but for the time its steps took.
+	out := capture(t, &os.Stdout, func() error { return selftestCmd([]string{"-json"}) })
+	var self markov.SelfTestReport
+	if err := json.Unmarshal([]byte(out), &self); err != nil {
+		t.Fatal(err)
+	}
+	for i := range self.Steps {
+		self.Steps[i].Elapsed = 0
+	}
+	var b bytes.Buffer
+	writeJSON(&b, self)
+	checkGolden(t, "selftest", b.Bytes())
+
+	// The error report and the answers of serve, from fixed values.
+	b.Reset()
+	printErrorJSON = true
+	reportError(&b, &markov.BuildError{Files: []*markov.FileError{{Name: "a.txt", Err: errors.New("a.txt: missing")}}})
+	printErrorJSON = false
+	writeJSON(&b, serverStatus{State: "ready", BytesRead: 1234567, BytesTotal: 1234567, Entries: 1000, Elapsed: 1.5, Prefixes: 900})
+	writeJSON(&b, serverStatus{State: "failed", BytesRead: 10, BytesTotal: -1, Elapsed: 0.25, Error: "model.txt: unexpected EOF"})
+	writeJSON(&b, trainResult{Tokens: 1234})
+	writeJSON(&b, saveResult{Path: "model.txt", Hash: "0123abcd", Prefixes: 900, SuffixEntries: 1200})
+	checkGolden(t, "fixed", b.Bytes())
+}
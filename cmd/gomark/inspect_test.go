@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// TestInspectJSONUnknown checks that inspect -json of a prefix the model
+// does not know fails, after printing the prefixes most like it.
+func TestInspectJSONUnknown(t *testing.T) {
+	model := filepath.Join(t.TempDir(), "m.txt")
+	if err := markov.TinyModel().WriteFreTable(model); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	out := capture(t, &os.Stdout, func() error {
+		err = inspectCmd([]string{"-json", model, "the", "cta"})
+		return nil
+	})
+	var rep InspectReport
+	if jerr := json.Unmarshal([]byte(out), &rep); jerr != nil {
+		t.Fatalf("%v: %q", jerr, out)
+	}
+	if err == nil || len(rep.Suffixes) != 0 || len(rep.Nearest) == 0 || rep.Nearest[0].Prefix != "the cat" {
+		t.Errorf("inspect -json of an unknown prefix = %v, %+v; want an error and the nearest prefixes", err, rep)
+	}
+}
@@ -0,0 +1,1843 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command gomark is a Markov chain text generator built on package markov.
+
+Our version of this program reads text from standard input, parsing it into a
+Markov chain, and writes generated text to standard output.
+
+Usage:
+
+	gomark read [-json] [-format text|json|gob|msgpack] [-seed n] [-max-prefix n] [-write-index file [-index-max-n n]] [-dry-run] [-strict] [-update] [-stamp] [-positions] [-sentence-starts] [-paragraphs] [-no-end-token | -end-paragraphs] [-skip-lines n] [-skip-tokens n] [-strip-header-until regexp] [-lowercase] [-filter-cmd command] [-classify url,email,name] [-unigram-prior freq.tsv] prefixLen model input...
+	gomark generate [-format text|json|gob|msgpack] [-seed n] [-output-format text|ssml|tokens-json|annotated-json] [-pretty] [-start-weight w] [-sentence-start] [-fold-case-on-load] [-preset name] [-temperature t|auto[:bits] | -greedy] [-temperature-min t] [-temperature-max t] [-top-k k] [-top-p p] [-rules file] [-repeat-limit n [-repeat-window n] [-repeat-action stop|resample|restart]] [-complete-sentence | -trim-sentence] [-max-bytes n] [-max-runes n] [-lenient] [-require-word words] [-match regexp] [-attempts n] [-parallel-chunks k | -samples n] [-paragraph-lengths] [-max-model-bytes n] [-mmap] [-avoid-dead-ends] [-backoff] [-smoothing alpha] model words
+	gomark inspect [-json] [-smoothing alpha] model word...
+	gomark validate [-stream] model
+	gomark repair [-json] model newmodel
+	gomark migrate [-json] model [newmodel]
+	gomark prune [-json] [-min-count n] [-min-share p] model [newmodel]
+	gomark merge [-json] newmodel [-weight n] model...
+	gomark diff [-metric js] [-json] model1 model2
+	gomark remap [-lowercase] model newmodel
+	gomark demo [-seed n] [-model file] [words]
+	gomark selftest [-json]
+	gomark sentinels [-json] [-all] model
+	gomark stats [-json] [-top n] model
+	gomark metadata [-json] model
+	gomark vocab [-json] model [word...]
+	gomark export [-format dot|probabilities] [-decimals d] [-min-count n] [-max-nodes n] model
+	gomark score [-json] [-smoothing alpha] text model...
+	gomark preset set model name [flag...]
+	gomark preset list model
+	gomark synth [-tokens n] [-vocab n] [-zipf s] [-seed n] [-doc-len n] [-punct p] output
+	gomark serve [-addr addr] [-format text|json|gob|msgpack] [-max-download-bytes n] [-max-model-bytes n] [-progress-interval d] [-train [-save-dir dir]] model
+	gomark tail [-prefix n] [-poll d] [-save-interval d] log model
+
+read builds a chain from the input files, writes it to the model file and
+prints a summary of the build; with -json the summary is printed as a
+markov.BuildReport JSON object instead. -classify replaces URLs, e-mail addresses
+or capitalized names by placeholders such as <url>, which generate fills in
+again from a sample of the originals stored in the model. -unigram-prior
+attaches a word frequency list that generate samples from whenever it
+reaches a prefix the chain does not know. -lowercase folds the corpus to
+lower case; generate restores capitalization from statistics kept in the
+model, so sentence starts and names such as "London" come out capitalized.
+-filter-cmd pipes the words of each input file through an external command,
+one word per line; the command must answer every line with one line, the
+replacement word or an empty line to drop it. -write-index also writes a
+compact n-gram index of the corpus (a Bloom filter with a 1% false-positive
+rate) covering n-grams of prefixLen+1 up to -index-max-n words.
+Inputs may be glob patterns and directories, which are read recursively;
+archives and binary files are skipped. -dry-run lists the files a build
+would read and estimates the size of the model from a sample of them,
+without writing anything.
+Inputs that cannot be read are left out of the model and listed in a
+table at the end; -strict fails instead, before writing anything. The
+model is never written when no input could be read.
+-update loads the model file and adds the inputs to its counts, for
+training a model further as new text arrives; the prefix length must be
+that of the model. See markov.Chain.AddText.
+-skip-lines, -strip-header-until and -skip-tokens drop boilerplate from
+the start of every input file, in this order, before anything is counted;
+-strip-header-until 'START OF' drops the license header of Project
+Gutenberg texts through the line containing "START OF".
+-paragraphs keeps blank lines as paragraph breaks in the chain and records
+how long the paragraphs of the corpus are. -positions records for every
+prefix in which tenths of the documents it occurs, which inspect shows.
+-sentence-starts records how often every prefix opened a sentence.
+read records where every input file ends, so that generated text can end
+where the corpus does rather than stop mid-sentence at the word count;
+-end-paragraphs also ends a document at every blank line, and
+-no-end-token builds a model without ends, as earlier versions did.
+Model files start with a "GOMARK v4 prefix=n" header giving the version of
+their format; files from before it, which start with the bare prefix
+length, still load as version 1, and stats shows the version of a model.
+From version 3 on, percent signs and white space within words are written
+as %XX, so that "50%" is "50%25" in the file, and a word "" of the corpus
+is written %22%22, apart from the "" that fills the empty prefix slots
+before the first words of a document; older files are read as they are,
+and migrate rewrites them. From version 4 on, model files end with a
+checksum record, and a model file cut short or damaged fails to load with
+a message to regenerate it; older files load with a warning that they
+cannot be checked.
+
+generate -mmap maps the model file into memory and only parses the parts
+generation visits, which makes the first words of a huge model appear
+almost at once.
+
+read -format json writes the model as JSON, for other tools to consume:
+the prefix length, the extension records and every prefix with its
+suffixes and their frequencies; see markov.JSONModel. read -format gob
+writes it in binary with encoding/gob, which generate loads several times
+faster than text; runs of suffixes seen once are stored without their
+counts, and read prints how much smaller that makes the model. read -format msgpack writes it in MessagePack, compact
+and readable by front ends in other languages; see
+markov.Chain.WriteMsgpack. generate -format reads any of them; without
+-format, read and generate take model files named *.json, *.gob or
+*.msgpack to be in those formats and all others to be text. The other commands read text models only. Model
+files named *.gz, such as model.txt.gz or model.gob.gz, are written
+compressed with gzip, and every command reads compressed models whatever
+their names.
+
+generate -output-format ssml wraps the generated sentences in SSML <s>
+elements for speech synthesis, and tokens-json prints a markov.TokenList with the
+class of every token; annotated-json adds the probability each token was
+drawn with and the entropy of the distribution it came from, for showing
+where the model was unsure. Models whose punctuation was split off into tokens of
+its own are printed with the punctuation attached to the words again;
+-pretty=false turns that off, -pretty forces it.
+
+generate refuses options the model has no data for, such as
+-paragraph-lengths for a model built without -paragraphs, naming the read
+flag that would provide it; -lenient only warns.
+
+generate -fold-case-on-load merges the words of a model built without
+-lowercase that differ only by case into their most frequent form, which
+makes big models smaller in memory; see markov.Chain.FoldCaseVariants.
+
+generate -start-weight w starts at the start of the corpus with
+probability w only, and otherwise at a random prefix of the model, so that
+outputs do not all open with the same words. generate -sentence-start
+instead starts at the first words of a sentence of the corpus, drawn by
+how many sentences began with them, for models built with read
+-sentence-starts.
+
+generate -paragraph-lengths draws a target length for every paragraph from
+the lengths recorded by read -paragraphs and makes a paragraph break more
+likely the closer the paragraph gets to it, so that long texts are divided
+into paragraphs much like the corpus.
+
+generate -temperature t raises the weights of every draw to the power 1/t:
+below 1 the output sticks to the most frequent paths, above 1 it wanders;
+0 always takes the most frequent word. -top-k k only ever draws from the k
+most likely words, which tames the noise of messy corpora; -top-p p then
+keeps only the most likely of those that together make up a share p of
+the probability.
+-temperature auto[:bits] picks the temperature anew at every step so that
+the entropy of the choice comes close to bits (2 by default), within
+-temperature-min and -temperature-max: prefixes with one dominant
+continuation are warmed, against copying the corpus verbatim, and prefixes
+with many even ones are cooled, to stay coherent. annotated-json reports
+the temperature used for every token.
+
+generate -greedy, like -temperature 0, always takes the most likely word,
+the first in byte order among equals, so that a model always yields the
+same text, as snapshot tests want. Greedy text often goes round in a loop;
+add -repeat-limit with -repeat-action resample to break it and stay
+deterministic.
+
+generate -rules file applies style rules without retraining, one per line:
+"FORBID very unique" never lets "unique" follow "very", "BOOST very good 3"
+makes "good" three times as likely after "very", and "REPLACE utilize use"
+prints "use" wherever "utilize" was drawn. Rules naming words the model
+never generates are warned about once. See markov.Rules.
+
+generate -repeat-limit n breaks loops such as "of the terms of the terms":
+when a word would lead to a prefix already seen n times within the last
+-repeat-window words, -repeat-action stop ends the text, resample draws
+another word and restart goes on from a random prefix.
+
+generate -complete-sentence goes on past the requested number of words
+until a sentence or paragraph ends, by at most 50 words;
+-trim-sentence instead drops the words after the last sentence end.
+
+generate -max-bytes n and -max-runes n stop before the word that would make
+the text longer than n bytes of UTF-8 or n characters, spaces included,
+for outputs that must fit a field such as a tweet or an SMS. The text
+ends at whichever limit, or the word count, comes first.
+
+generate -backoff goes on past dead ends, which otherwise end the text:
+it drops the oldest words of the prefix until some prefix of the model
+ends in the words left and goes on from one of those. Models of small
+corpora produce much longer texts with it.
+
+generate -smoothing alpha counts every word of the model alpha more times
+after every prefix than the corpus had it there, so that now and then a word
+comes out that never followed the words before it; -smoothing 1 is add-one
+(Laplace) smoothing. On tiny corpora this makes the text more varied.
+
+generate -samples n writes n independent texts of the given length, one
+per line, generated in parallel; with -seed the batch is the same every
+time. Use it to produce candidates to pick from, for example by their
+score.
+
+generate -require-word and -match generate texts until one contains all
+the given words or matches the regexp, trying at most -attempts texts; if
+none does, generate fails with exit status 4.
+
+generate -parallel-chunks k splits long outputs into k chunks generated at
+the same time from random places of the model and stitched together with
+short bridges, or paragraph breaks where no bridge is found. This is much
+faster for bulk text, but it is not the same as sampling the whole text in
+one go.
+
+inspect prints the words following a prefix in the model with their
+probabilities; for a prefix the model does not know it suggests the most
+similar ones, tolerating misspelled words. With -smoothing alpha it prints
+the smoothed probabilities of every word of the model instead, see
+generate -smoothing. With -json it prints an InspectReport.
+
+validate checks a model file and lists its problems with their byte
+offsets. With -stream it only parses the file line by line in bounded
+memory, checking its checksum as it goes; otherwise it also loads the
+chain and checks that every prefix can be reached from the start state.
+
+repair reads a damaged model as leniently as it can, see markov.RepairFreTable,
+and writes what it could salvage to a new model, listing every repair.
+
+migrate loads a model written by any version of this program and writes it back,
+in place unless a new file is given, in the current format. With -json it
+prints a MigrateReport of the versions.
+
+prune drops the suffix entries of a model seen fewer than -min-count times
+or making up less than -min-share of their prefix's total, and the prefixes
+left without any, writing the model back in place unless a new file is
+given; see markov.Chain.Prune.
+
+merge sums the counts of text models into a new model without retraining,
+for models built from parts of a corpus, such as one per month. The
+models are read one after another into the merged model, so that memory
+use grows with the merged model only; they must all have the same prefix
+length. -weight n multiplies the counts of the model following it, so that
+a small curated corpus can outweigh a large scraped one; see
+markov.MergeFreTables. With -json it prints a MergeReport of the models
+merged and the size of the result.
+
+diff compares two models. The only -metric so far is js, the
+Jensen-Shannon divergence between the suffix distributions of every
+prefix, weighted by how common the prefix is; see markov.Divergence.
+
+remap rewrites a model with every word transformed, merging the entries
+that collide; -lowercase turns a case-sensitive model into a folded one.
+
+synth writes a reproducible Zipf-distributed corpus, so that builds and
+benchmarks at scale can be compared across machines without shipping big
+text files around.
+
+demo generates a few words from -model, or without it from the built-in
+markov.TinyModel, to try out the program without any corpus at hand.
+
+selftest builds, saves, reloads in every format, validates and samples
+the tiny model in a temporary directory and prints PASS or FAIL for every
+step, telling a broken installation from bad data; with -json it prints
+a markov.SelfTestReport.
+
+sentinels lists the reserved tokens a model uses, such as the start token
+and the placeholders of read -classify, with how often it uses them; with
+-all it lists every reserved token, see markov.ReservedTokens. Corpus words that
+are spelled like a reserved token are warned about by read and reported
+as problems by validate.
+
+preset set stores a list of generate flags in the model under a name, and
+generate -preset name applies them, with any flag given on the command line
+taking precedence; set checks the flags against the model just like
+generate does, and a preset set without flags is removed. preset list
+prints the presets of a model.
+
+	gomark preset set model.txt casual -start-weight 0.5 -pretty
+	gomark generate -preset casual model.txt 100
+
+stats prints the size of a model: its prefixes, suffix entries and tokens,
+the mean number of suffixes per prefix and the prefix with the most; see
+markov.Chain.Stats. -top n also lists the n prefixes followed most often,
+which dominate the output of a repetitive model.
+
+metadata prints where a text model came from, as read recorded it: the
+input files with the words read from each, the total, and for models built
+with read -stamp when and by which version of this program it was built.
+Without -stamp, building the same corpus twice gives the same model file. Only the start of the file is
+read, so it is quick for any model; models written before metadata
+existed show only their prefix length. For models trained by tail it also
+prints how far into the log they have read. See markov.Metadata.
+
+vocab lists the words of a model, most frequent first; with words given it
+lists only those the model knows, for checking that a name or slur did not
+make it into a model before publishing text generated from it. See
+markov.Chain.Vocabulary.
+
+export writes the transition graph of a model to standard output in the
+DOT language of GraphViz, prefixes as nodes and words as edges drawn wider
+the more often they were seen; -min-count leaves out rare transitions and
+-max-nodes keeps the most used prefixes, for models too big to draw whole.
+See markov.Chain.WriteDOT.
+
+	gomark export -max-nodes 50 model.txt | dot -Tsvg > model.svg
+
+export -format probabilities writes the model as a text model whose
+suffixes carry the probability of following their prefix, with -decimals
+places, instead of their counts; the probabilities of every prefix sum to
+exactly 1. generate and the other commands read such a model like any
+other. See markov.Chain.WriteProbabilities.
+
+score tells how well a text fits each of the models: the log-probability of
+its words, the perplexity, lower for a closer fit, and the number of words
+the model gives no probability and that are left out. Without -smoothing,
+every transition the model never saw is left out; with it only unknown
+words are. See markov.Chain.Score.
+
+serve answers GET /generate?n=words&seed=s over HTTP with text generated
+from a model file, or a model downloaded from an http or https URL; a
+-max-download-bytes budget refuses bigger models, even when the server
+does not announce their size, and a -max-model-bytes budget refuses
+models estimated to need more memory: text models on disk before they
+are read, other models once decoded, before they serve a request. With &annotate=1 it answers with the tokens
+as -output-format annotated-json writes them. With &prompt=text it
+continues the text, feeding all of it through a markov.Session, and
+answers with JSON giving the words generated, the end of the prompt they
+follow, and how many words of the prompt came after a context the model
+did not know. &sentence_start=1 and &backoff=1 work as the generate
+flags; a model with no data for them answers 400 Bad Request naming
+what it lacks. The server listens at once
+and loads the model in the background, logging the progress every
+-progress-interval:
+/healthz always answers 200, and /status and /healthz report the state,
+starting, ready or failed, with the bytes read, the lines of text and JSON
+models parsed, the estimated memory of the model, its hash once loaded
+(see markov.Origin) and the time taken; /status also counts the requests to
+/generate and the heap allocations per request since the model loaded,
+which should stay flat on a long-running server. /readyz answers 503 until the model has
+loaded and generated a test text without errors, and 200 from then on;
+/generate answers 503 until then, with a Retry-After while loading.
+With -train, POST /train adds the request body to the model as one more
+document, and POST /save writes the model back to its file, or with
+?path=name to the file name within -save-dir, answering with its hash and
+size; generation and training go on while it is written, from a snapshot
+of the model, and saves requested while one waits for its snapshot share
+it. On SIGINT or SIGTERM serve answers the requests under way, closes
+the model and exits.
+
+tail trains a model on a log as lines are appended to it, every line a
+document of its own, creating the model with -prefix words per prefix if
+it does not exist. It saves the model every -save-interval while lines
+come in, and when interrupted. The model records how far into the log it
+has read, and tail goes on from there when restarted, so that every line
+is counted exactly once in the saved model even if tail was killed
+between reading lines and saving them. The offset is also written to
+model.offset for other programs to read; tail itself ignores that file.
+A log that shrinks was rotated and is read again from its start.
+
+Both read and generate take -seed: runs with the same seed, input and
+options produce identical models and text.
+
+The exit status is 0 on success, 1 on runtime errors (I/O, corrupt model),
+2 on usage errors, 3 when the model is empty or cannot produce any text and
+4 when a generation constraint, such as -require-word or -match, cannot be
+satisfied. Bad flags are usage errors too, and so are options the model
+has no data for; -h lists the flags of a command and exits with 0. With -print-error-json errors are written to stderr as an
+ErrorReport JSON object.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"strconv"
+
+	"github.com/xiaoxulv/go_mark/internal/synthcorpus"
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+func main() {
+
+	rand.Seed(time.Now().UnixNano()) // Seed the random number generator.
+
+	if len(os.Args) < 2 {
+		os.Exit(reportError(os.Stderr, usagef("choose read, generate, inspect, validate, repair, migrate, merge, diff, remap, synth, demo, selftest, sentinels, preset, prune, stats, metadata, vocab, score, export, serve or tail for command option for 1st parameter.")))
+	}
+	var err error
+	cmd, args := os.Args[1], os.Args[2:]
+	if cmd == "read"{
+		err = readCmd(args)
+	}else if cmd == "generate" {
+		err = generateCmd(args)
+	}else if cmd == "inspect" {
+		err = inspectCmd(args)
+	}else if cmd == "validate" {
+		err = validateCmd(args)
+	}else if cmd == "repair" {
+		err = repairCmd(args)
+	}else if cmd == "migrate" {
+		err = migrateCmd(args)
+	}else if cmd == "merge" {
+		err = mergeCmd(args)
+	}else if cmd == "diff" {
+		err = diffCmd(args)
+	}else if cmd == "remap" {
+		err = remapCmd(args)
+	}else if cmd == "synth" {
+		err = synthCmd(args)
+	}else if cmd == "demo" {
+		err = demoCmd(args)
+	}else if cmd == "selftest" {
+		err = selftestCmd(args)
+	}else if cmd == "sentinels" {
+		err = sentinelsCmd(args)
+	}else if cmd == "preset" {
+		err = presetCmd(args)
+	}else if cmd == "prune" {
+		err = pruneCmd(args)
+	}else if cmd == "stats" {
+		err = statsCmd(args)
+	}else if cmd == "metadata" {
+		err = metadataCmd(args)
+	}else if cmd == "vocab" {
+		err = vocabCmd(args)
+	}else if cmd == "score" {
+		err = scoreCmd(args)
+	}else if cmd == "export" {
+		err = exportCmd(args)
+	}else if cmd == "serve" {
+		err = serveCmd(args)
+	}else if cmd == "tail" {
+		err = tailCmd(args)
+	}else{
+		err = usagef("choose read, generate, inspect, validate, repair, migrate, merge, diff, remap, synth, demo, selftest, sentinels, preset, prune, stats, metadata, vocab, score, export, serve or tail for command option for 1st parameter.")
+	}
+	if err != nil {
+		os.Exit(reportError(os.Stderr, err))
+	}
+}
+
+// newRand returns a generator seeded with seed, or nil for seed 0, meaning
+// "not reproducible"; it is used for the -seed flags.
+func newRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		return nil
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// newFlagSet returns a FlagSet for the named command with the flags shared
+// by every command already registered. It reports nothing itself: parse it
+// with parseFlags or parseInterspersed, which return what went wrong.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&printErrorJSON, "print-error-json", false, "report errors on stderr as a JSON object")
+	return fs
+}
+
+// readCmd implements "read [-json] [-format text|json|gob|msgpack] [-dry-run] [-strict] [-update] [-stamp] [-positions] [-sentence-starts] [-paragraphs] [-no-end-token | -end-paragraphs] [-skip-lines n] [-skip-tokens n] [-strip-header-until regexp] [-lowercase] [-filter-cmd command] [-classify classes] [-unigram-prior file] prefixLen output input...".
+func readCmd(args []string) error {
+	fs := newFlagSet("read")
+	jsonOut := fs.Bool("json", false, "print the build report as JSON")
+	lowercase := fs.Bool("lowercase", false, "fold words to lower case, remembering their capitalization")
+	filterCmd := fs.String("filter-cmd", "", "external command filtering the words, one per line")
+	seed := fs.Int64("seed", 0, "seed for reproducible builds (0 picks a random one)")
+	fs.IntVar(&markov.MaxPrefixLen, "max-prefix", markov.MaxPrefixLen, "longest prefix length accepted")
+	filterTimeout := fs.Duration("filter-timeout", markov.DefaultFilterTimeout, "time limit for -filter-cmd per input file")
+	priorFile := fs.String("unigram-prior", "", "word<TAB>count file used as a prior for unknown prefixes")
+	indexFile := fs.String("write-index", "", "also write an n-gram index of the corpus to this file")
+	indexMaxN := fs.Int("index-max-n", 0, "longest n-gram in the index (default prefix length + 1)")
+	classify := fs.String("classify", "", "comma-separated token classes to replace by placeholders (url, email, name)")
+	paragraphs := fs.Bool("paragraphs", false, "keep blank lines as paragraph breaks and record paragraph lengths")
+	noEnd := fs.Bool("no-end-token", false, "do not record where the input files end")
+	endParagraphs := fs.Bool("end-paragraphs", false, "also record an end at every blank line, making every paragraph a document")
+	positions := fs.Bool("positions", false, "record where in the documents every prefix occurs")
+	starts := fs.Bool("sentence-starts", false, "record the prefixes sentences start with, for generate -sentence-start")
+	skipLines := fs.Int("skip-lines", 0, "drop the first n lines of every input file")
+	skipTokens := fs.Int("skip-tokens", 0, "drop the first n words of every input file")
+	stripUntil := fs.String("strip-header-until", "", "drop every input file up to and including the first line matching this regexp")
+	dryRun := fs.Bool("dry-run", false, "list the inputs and estimate the model, without building or writing anything")
+	strict := fs.Bool("strict", false, "fail without writing the model if any input cannot be read")
+	update := fs.Bool("update", false, "add the inputs to the existing model file instead of building a new one")
+	stamp := fs.Bool("stamp", false, "record the time of the build and the version of gomark in the model")
+	codecFlag := fs.String("format", "", "format of the model file: text, json, gob or msgpack (default by extension: .json, .gob, .msgpack, otherwise text)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) < 2 {
+		return usagef("read needs a prefix length and an output file.")
+	}
+	outputFile := args[1]
+	num, err := strconv.Atoi(args[0])
+	if err != nil || num <= 0 {
+		return usagef("number of prefix should be positive.")
+	}
+	inputs, err := markov.ResolveInputs(args[2:], func(msg string) { fmt.Fprintln(os.Stderr, "warning:", msg) })
+	if err != nil {
+		return err
+	}
+	inputFile := markov.InputNames(inputs)//inputfile into a slice
+
+	codec, err := modelFormat(*codecFlag, outputFile)
+	if err != nil {
+		return err
+	}
+	if *noEnd && *endParagraphs {
+		return usagef("-no-end-token and -end-paragraphs cannot be combined.")
+	}
+	opts := markov.BuildOptions{Lowercase: *lowercase, Paragraphs: *paragraphs, NoEndToken: *noEnd, EndParagraphs: *endParagraphs, FilterTimeout: *filterTimeout, Rand: newRand(*seed)}
+	opts.SkipLines, opts.SkipTokens = *skipLines, *skipTokens
+	opts.Positions, opts.SentenceStarts = *positions, *starts
+	opts.Stamp = *stamp
+	if *stripUntil != "" {
+		re, err := regexp.Compile(*stripUntil)
+		if err != nil {
+			return usagef("bad -strip-header-until: %v", err)
+		}
+		opts.StripHeaderUntil = re
+	}
+	if strings.TrimSpace(*filterCmd) != "" {
+		opts.Filter = markov.ShellFilter(*filterCmd)
+	}
+	if *classify != "" {
+		for _, name := range strings.Split(*classify, ",") {
+			cl, ok := markov.Classifiers[name]
+			if !ok {
+				return usagef("unknown token class %q.", name)
+			}
+			opts.Classifiers = append(opts.Classifiers, cl)
+		}
+	}
+	c, err := markov.NewChain(num)//initialize a new Chain with given prefix length
+	if err != nil {
+		return &UsageError{err.Error()}
+	}
+	if *update {
+		m, err := warnChecksum(markov.OpenModel(outputFile, markov.OpenOptions{Format: codec}))
+		if err != nil {
+			return err
+		}
+		c = m.Chain
+		if c.PrefixLen() != num {
+			return usagef("%s has prefixes of %d words, not %d.", outputFile, c.PrefixLen(), num)
+		}
+	}
+	if *dryRun {
+		rep := DryRunReport{PrefixLen: num, Inputs: inputs, Options: make(map[string]string)}
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != "dry-run" && f.Name != "json" {
+				rep.Options[f.Name] = f.Value.String()
+			}
+		})
+		if rep.Estimate, err = markov.EstimateBuild(inputs, num); err != nil {
+			return err
+		}
+		if *jsonOut {
+			return writeJSON(os.Stdout, rep)
+		}
+		printDryRun(os.Stdout, rep)
+		return nil
+	}
+	if *indexFile != "" {
+		maxN := *indexMaxN
+		if maxN <= num {
+			maxN = num + 1
+		}
+		opts.Index = markov.NewIndexBuilder(num+1, maxN)
+	}
+	report, err := c.BuildOpts(inputFile, opts)//build chain with given input files
+	var buildErr *markov.BuildError
+	if errors.As(err, &buildErr) {
+		printFailedInputs(os.Stderr, buildErr, len(report.Files))
+		if *strict || len(buildErr.Files) == len(report.Files) {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if *priorFile != "" {
+		prior, err := markov.ReadWordFrequencyFile(*priorFile)
+		if err != nil {
+			return err
+		}
+		c.SetUnigramPrior(prior)
+	}
+	write := c.WriteFreTable
+	switch codec {
+	case markov.FormatJSON:
+		write = c.WriteJSONFile
+	case markov.FormatGob:
+		write = c.WriteGobFile
+	case markov.FormatMsgpack:
+		write = c.WriteMsgpackFile
+	}
+	if err := write(outputFile); err != nil {//write chain to the output file
+		return err
+	}
+	if opts.Index != nil {
+		if err := markov.WriteIndexFile(*indexFile, opts.Index.Finish(markov.DefaultIndexFPRate)); err != nil {
+			return err
+		}
+	}
+	if info, err := os.Stat(outputFile); err == nil {
+		report.ModelBytes = info.Size()
+	}
+	if codec == markov.FormatGob {
+		if report.RunSavings, err = c.GobRunSavings(); err != nil {
+			return err
+		}
+	}
+
+	if *jsonOut {
+		return writeJSON(os.Stdout, report)
+	}
+	printBuildReport(os.Stdout, outputFile, report)
+	return nil
+}
+
+// modelFormat checks the -format flag of the model file name and returns
+// the format it selects, by default the one the extension suggests.
+func modelFormat(format, name string) (string, error) {
+	switch format {
+	case "":
+		return markov.FormatOf(name), nil
+	case markov.FormatText, markov.FormatJSON, markov.FormatGob, markov.FormatMsgpack:
+		return format, nil
+	}
+	return "", usagef("unknown model format %q; expected text, json, gob or msgpack.", format)
+}
+
+// printFailedInputs writes the inputs a build could not read as a table.
+func printFailedInputs(w io.Writer, err *markov.BuildError, inputs int) {
+	width := 0
+	for _, f := range err.Files {
+		if len(f.Name) > width {
+			width = len(f.Name)
+		}
+	}
+	fmt.Fprintf(w, "could not read %s of %s inputs:\n", formatCount(len(err.Files)), formatCount(inputs))
+	for _, f := range err.Files {
+		fmt.Fprintf(w, "  %-*s  %v\n", width, f.Name, reason(f))
+	}
+}
+
+// reason returns the failure of f without the file name it starts with.
+func reason(f *markov.FileError) string {
+	var pathErr *fs.PathError
+	if errors.As(f.Err, &pathErr) && pathErr.Path == f.Name {
+		return pathErr.Op + ": " + pathErr.Err.Error()
+	}
+	return strings.TrimPrefix(f.Err.Error(), f.Name+": ")
+}
+
+// printBuildReport writes the human-readable form of a BuildReport.
+func printBuildReport(w io.Writer, outputFile string, r markov.BuildReport) {
+	var bytes int64
+	read := 0
+	for _, f := range r.Files {
+		bytes += f.Bytes
+		if f.Error == "" {
+			read++
+		}
+	}
+	fmt.Fprintf(w, "read %s files (%s): %s words\n", formatCount(read), formatBytes(bytes), formatCount(r.Tokens))
+	for _, f := range r.Files {
+		if f.Stripped > 0 {
+			fmt.Fprintf(w, "stripped %s header words from %s\n", formatCount(f.Stripped), f.Name)
+		}
+	}
+	fmt.Fprintf(w, "built %s prefixes with %s suffix entries\n", formatCount(r.Prefixes), formatCount(r.SuffixEntries))
+	if r.RunSavings > 0 {
+		fmt.Fprintf(w, "wrote %s (%s, %s less for storing no counts of suffixes seen once)\n", outputFile, formatBytes(r.ModelBytes), formatBytes(r.RunSavings))
+	} else {
+		fmt.Fprintf(w, "wrote %s (%s)\n", outputFile, formatBytes(r.ModelBytes))
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+}
+
+// DryRunReport is what read -dry-run prints, as JSON with -json.
+type DryRunReport struct {
+	PrefixLen int               `json:"prefix_len"`
+	Inputs    []markov.InputFile       `json:"inputs"`
+	Options   map[string]string `json:"options"` // the flags given, by name
+	Estimate  markov.BuildEstimate     `json:"estimate"`
+}
+
+// printDryRun writes the human-readable form of a DryRunReport.
+func printDryRun(w io.Writer, r DryRunReport) {
+	var bytes int64
+	for _, f := range r.Inputs {
+		fmt.Fprintf(w, "%10s  %s\n", formatBytes(f.Bytes), f.Name)
+		bytes += f.Bytes
+	}
+	fmt.Fprintf(w, "%s files, %s\n", formatCount(len(r.Inputs)), formatBytes(bytes))
+	fmt.Fprintf(w, "prefix length %d", r.PrefixLen)
+	for _, name := range sortedKeys(r.Options) {
+		fmt.Fprintf(w, ", -%s=%s", name, r.Options[name])
+	}
+	fmt.Fprintln(w)
+	e := r.Estimate
+	fmt.Fprintf(w, "estimated from %s sampled: about %s words, %s prefixes, %s suffix entries, %s in memory\n",
+		formatBytes(e.SampledBytes), formatCount(e.Tokens), formatCount(e.Prefixes), formatCount(e.SuffixEntries), formatBytes(e.MemoryBytes))
+}
+
+// generateFlags are the flags of generate that a preset may store.
+type generateFlags struct {
+	opts        markov.GenerateOptions
+	startWeight *float64
+	seed        *int64
+	format      *string
+	chunks      *int
+	pretty      *bool
+	temperature *string
+	rules       *string
+	smoothing   *float64
+	repeat      markov.RepeatGuard
+}
+
+// defineGenerateFlags defines the generate flags a preset may store on fs.
+func defineGenerateFlags(fs *flag.FlagSet) *generateFlags {
+	g := new(generateFlags)
+	fs.BoolVar(&g.opts.AvoidDeadEnds, "avoid-dead-ends", false, "avoid words that lead straight to a dead end")
+	fs.BoolVar(&g.opts.Backoff, "backoff", false, "at a dead end, go on from a prefix ending in the same last words")
+	g.smoothing = fs.Float64("smoothing", 0, "count every word this many more times after every prefix, 1 for add-one smoothing (0 means none)")
+	fs.BoolVar(&g.opts.ParagraphLengths, "paragraph-lengths", false, "make paragraph lengths follow those of the corpus")
+	fs.IntVar(&g.opts.TopK, "top-k", 0, "draw only from the k most likely words (0 means all)")
+	fs.Float64Var(&g.opts.TopP, "top-p", 1, "draw only from the most likely words making up this share of the probability, after -top-k")
+	fs.IntVar(&g.repeat.Limit, "repeat-limit", 0, "act when a prefix comes up more often than this within -repeat-window words (0 means never)")
+	fs.IntVar(&g.repeat.Window, "repeat-window", markov.DefaultRepeatWindow, "number of recent words -repeat-limit looks at")
+	fs.StringVar((*string)(&g.repeat.Action), "repeat-action", string(markov.RepeatStop), "what to do about a loop: stop, resample or restart")
+	fs.BoolVar(&g.opts.CompleteSentence, "complete-sentence", false, "keep going past the word count until the sentence ends")
+	fs.BoolVar(&g.opts.TrimSentence, "trim-sentence", false, "cut the text back to the end of its last complete sentence")
+	fs.IntVar(&g.opts.MaxBytes, "max-bytes", 0, "stop before the text grows past this many bytes (0 means no limit)")
+	fs.IntVar(&g.opts.MaxRunes, "max-runes", 0, "stop before the text grows past this many characters (0 means no limit)")
+	g.startWeight = fs.Float64("start-weight", 1, "probability of starting where the corpus starts rather than at a random prefix")
+	fs.BoolVar(&g.opts.SentenceStart, "sentence-start", false, "start where a sentence of the corpus starts, see read -sentence-starts")
+	g.seed = fs.Int64("seed", 0, "seed for reproducible output (0 picks a random one)")
+	g.format = fs.String("output-format", "text", "output format: text, ssml, tokens-json or annotated-json")
+	g.chunks = fs.Int("parallel-chunks", 0, "generate in this many chunks in parallel (approximate, see below)")
+	g.pretty = fs.Bool("pretty", false, "attach punctuation tokens to the preceding word (default: when the model has them)")
+	g.temperature = fs.String("temperature", "", "sampling temperature, or auto[:bits] to aim every draw at an entropy of bits (default 2)")
+	fs.BoolVar(&g.opts.Greedy, "greedy", false, "always pick the most likely word, the same text for every seed (same as -temperature 0)")
+	fs.Float64Var(&g.opts.MinTemperature, "temperature-min", markov.DefaultMinTemperature, "lowest temperature -temperature auto may pick")
+	g.rules = fs.String("rules", "", "apply the FORBID, BOOST and REPLACE rules of this file")
+	fs.Float64Var(&g.opts.MaxTemperature, "temperature-max", markov.DefaultMaxTemperature, "highest temperature -temperature auto may pick")
+	return g
+}
+
+// options checks the flags and returns the GenerateOptions they select.
+func (g *generateFlags) options() (markov.GenerateOptions, error) {
+	opts := g.opts
+	if _, ok := markov.OutputFormats[*g.format]; !ok && *g.format != "annotated-json" {
+		return opts, usagef("unknown output format %q.", *g.format)
+	}
+	opts.Rand = newRand(*g.seed)
+	if *g.startWeight < 0 || *g.startWeight > 1 {
+		return opts, usagef("-start-weight must be between 0 and 1.")
+	}
+	opts.RandomStart = 1 - *g.startWeight
+	if opts.TopK < 0 {
+		return opts, usagef("-top-k must not be negative.")
+	}
+	if *g.smoothing < 0 {
+		return opts, usagef("-smoothing must not be negative.")
+	}
+	opts.Smooth = *g.smoothing > 0
+	if opts.MaxBytes < 0 || opts.MaxRunes < 0 {
+		return opts, usagef("-max-bytes and -max-runes must not be negative.")
+	}
+	if g.repeat.Limit > 0 {
+		switch g.repeat.Action {
+		case markov.RepeatStop, markov.RepeatResample, markov.RepeatRestart:
+		default:
+			return opts, usagef("unknown -repeat-action %q, want stop, resample or restart.", g.repeat.Action)
+		}
+		if g.repeat.Window <= 0 {
+			return opts, usagef("-repeat-window must be positive.")
+		}
+		repeat := g.repeat
+		opts.Repeat = &repeat
+	}
+	if opts.TopP <= 0 || opts.TopP > 1 {
+		return opts, usagef("-top-p must be above 0 and at most 1.")
+	}
+	if t := *g.temperature; t == "auto" || strings.HasPrefix(t, "auto:") {
+		if opts.Greedy {
+			return opts, usagef("-greedy cannot be combined with -temperature %s.", t)
+		}
+		opts.TargetEntropy = 2
+		if bits := strings.TrimPrefix(t, "auto"); bits != "" {
+			v, err := strconv.ParseFloat(bits[1:], 64)
+			if err != nil || v <= 0 {
+				return opts, usagef("-temperature auto:bits needs a positive number of bits.")
+			}
+			opts.TargetEntropy = v
+		}
+		if opts.MinTemperature <= 0 || opts.MaxTemperature < opts.MinTemperature {
+			return opts, usagef("-temperature-min must be positive and at most -temperature-max.")
+		}
+	}else if t != "" {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil || v < 0 {
+			return opts, usagef("-temperature must be a non-negative number or auto[:bits].")
+		}
+		if opts.Greedy && v != 0 {
+			return opts, usagef("-greedy cannot be combined with -temperature %s.", t)
+		}
+		opts.Temperature = v
+		opts.Greedy = v == 0
+	}
+	if *g.rules != "" {
+		rules, err := markov.ReadRulesFile(*g.rules)
+		if err != nil {
+			return opts, err
+		}
+		opts.Rules = rules
+	}
+	return opts, nil
+}
+
+// parsePreset parses the flags of the preset name as generate would.
+func parsePreset(name string, flags []string) (*flag.FlagSet, *generateFlags, error) {
+	fs := flag.NewFlagSet("preset "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	g := defineGenerateFlags(fs)
+	if err := fs.Parse(flags); err != nil {
+		return nil, nil, fmt.Errorf("preset %s: %v", name, err)
+	}
+	if fs.NArg() > 0 {
+		return nil, nil, fmt.Errorf("preset %s: %q is not a flag", name, fs.Arg(0))
+	}
+	return fs, g, nil
+}
+
+// generateCmd implements "generate [-format text|json|gob|msgpack] [-max-model-bytes n] [-mmap] [-avoid-dead-ends] model n".
+func generateCmd(args []string) error {
+	fs := newFlagSet("generate")
+	maxBytes := fs.Int64("max-model-bytes", 0, "refuse models estimated to need more memory than this (0 means no limit)")
+	mmap := fs.Bool("mmap", false, "map the model into memory and parse it lazily")
+	foldOnLoad := fs.Bool("fold-case-on-load", false, "merge words differing only by case into their most frequent form")
+	lenient := fs.Bool("lenient", false, "only warn about options the model has no data for")
+	preset := fs.String("preset", "", "use the flags stored in the model under this name; flags given override them")
+	samples := fs.Int("samples", 1, "generate this many independent texts, one per line")
+	require := fs.String("require-word", "", "comma-separated words the text must contain")
+	match := fs.String("match", "", "regexp the text must match")
+	attempts := fs.Int("attempts", markov.DefaultAttempts, "texts to try before giving up on -require-word and -match")
+	codecFlag := fs.String("format", "", "format of the model file: text, json, gob or msgpack (default by extension: .json, .gob, .msgpack, otherwise text)")
+	g := defineGenerateFlags(fs)
+	format, chunks, pretty := g.format, g.chunks, g.pretty
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	explicit := args
+	args = fs.Args()
+
+	if len(args) != 2{
+		return usagef("generate needs a model file and a number of words, after the flags.")
+	}
+	model := args[0]
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n <= 0 {
+		return usagef("number of words should be positive.")
+	}
+	con := markov.Constraint{Attempts: *attempts}
+	if *require != "" {
+		con.Require = strings.Split(*require, ",")
+	}
+	if *match != "" {
+		if con.Match, err = regexp.Compile(*match); err != nil {
+			return usagef("bad -match: %v", err)
+		}
+	}
+	codec, err := modelFormat(*codecFlag, model)
+	if err != nil {
+		return err
+	}
+	if codec != markov.FormatText && (*mmap || *maxBytes > 0) {
+		return usagef("-format %s cannot be combined with -mmap or -max-model-bytes.", codec)
+	}
+	if err := markov.CheckModelBudget(model, *maxBytes); err != nil {
+		return err
+	}
+	m, err := warnChecksum(markov.OpenModel(model, markov.OpenOptions{Mmap: *mmap, Format: codec}))//read from model file to initialize a chain
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	c := m.Chain
+	if *foldOnLoad {
+		rep := c.FoldCaseVariants()
+		fmt.Fprintf(os.Stderr, "folded %s case variants: %s prefixes and %s suffix entries merged\n",
+			formatCount(rep.Words), formatCount(rep.Prefixes), formatCount(rep.SuffixEntries))
+	}
+	if *preset != "" {
+		flags, ok := c.Preset(*preset)
+		if !ok {
+			return usagef("%s has no preset %q.", model, *preset)
+		}
+		gs, _, err := parsePreset(*preset, flags)
+		if err != nil {
+			return fmt.Errorf("%s: %v", model, err)
+		}
+		gs.Visit(func(f *flag.Flag) { fs.Set(f.Name, f.Value.String()) })
+		//flags given on the command line win
+		if err := parseFlags(fs, explicit); err != nil {
+			return err
+		}
+	}
+	prettySet := false
+	fs.Visit(func(f *flag.Flag) { prettySet = prettySet || f.Name == "pretty" })
+	opts, err := g.options()
+	if err != nil {
+		return err
+	}
+	if err := c.SetSmoothing(*g.smoothing); err != nil {
+		return usagef("%v.", err)
+	}
+	encode := markov.OutputFormats[*format]
+	if opts.Rules != nil {
+		if unknown := opts.Rules.UnknownWords(c); len(unknown) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s: rules name words the model never generates: %s\n", *g.rules, strings.Join(unknown, " "))
+		}
+	}
+	if c.IsEmpty() {
+		return fmt.Errorf("%s: %w", model, markov.ErrEmptyModel)
+	}
+	if *lenient {
+		c.PreflightLenient(opts, func(msg string) { fmt.Fprintf(os.Stderr, "warning: %s: %s\n", model, msg) })
+	} else if err := c.Preflight(opts); err != nil {
+		return fmt.Errorf("%s: %w", model, err)
+	}
+	if *format == "text" {
+		punct := c.HasPunctTokens()
+		switch {
+		case !prettySet:
+			*pretty = punct
+		case *pretty && !punct:
+			fmt.Fprintf(os.Stderr, "warning: %s has no separate punctuation tokens; -pretty changes nothing\n", model)
+		case !*pretty && punct:
+			fmt.Fprintf(os.Stderr, "warning: %s has separate punctuation tokens; without -pretty they are joined with spaces\n", model)
+		}
+		if *pretty {
+			encode = markov.WritePretty
+			opts.Pretty = true // for -max-bytes and -max-runes
+		}
+	}
+	if con.Require != nil || con.Match != nil {
+		if *format == "annotated-json" || *chunks > 1 || *samples > 1 {
+			return usagef("-require-word and -match cannot be combined with -output-format annotated-json, -parallel-chunks or -samples.")
+		}
+		words, err := c.GenerateSatisfying(n, opts, con)
+		if err != nil {
+			return fmt.Errorf("%s: %w", model, err)
+		}
+		return encode(os.Stdout, words)
+	}
+	if *format == "annotated-json" {
+		if *chunks > 1 {
+			return usagef("-output-format annotated-json cannot be combined with -parallel-chunks.")
+		}
+		tokens := c.GenerateAnnotated(n, opts)
+		if err := c.Err(); err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("%s: %w", model, markov.ErrEmptyModel)
+		}
+		return writeJSON(os.Stdout, markov.AnnotatedList{Tokens: tokens})
+	}
+	if *samples < 1 {
+		return usagef("-samples must be positive.")
+	}
+	if *samples > 1 {
+		if *format != "text" || *chunks > 1 {
+			return usagef("-samples needs -output-format text and cannot be combined with -parallel-chunks.")
+		}
+		batch := c.GenerateNWords(*samples, n, runtime.GOMAXPROCS(0), opts)
+		if err := c.Err(); err != nil {
+			return err
+		}
+		empty := 0
+		for _, words := range batch {
+			if len(words) == 0 {
+				empty++
+				continue
+			}
+			if err := encode(os.Stdout, words); err != nil {
+				return err
+			}
+		}
+		if empty == len(batch) {
+			return fmt.Errorf("%s: %w", model, markov.ErrDeadEnd)
+		}
+		return nil
+	}
+	var words []string
+	why := "a dead end"
+	stop := markov.StopDeadEnd
+	if *chunks > 1 && opts.MaxBytes == 0 && opts.MaxRunes == 0 {
+		words = c.GenerateParallel(n, *chunks, opts)
+		if opts.Repeat != nil {
+			why = "a dead end or loop"
+		}
+	}else{
+		var res markov.GenerateResult
+		words, res = c.GenerateWordsChecked(n, opts)//use the chain to generate n words
+		if stop = res.Stop; stop == markov.StopRepeat {
+			why = "a loop"
+		}
+	}
+	if err := c.Err(); err != nil {
+		return err
+	}
+	if len(words) == 0 && stop == markov.StopBudget {
+		return fmt.Errorf("-max-bytes or -max-runes leaves no room for a single word")
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("%s: %w", model, markov.ErrDeadEnd)
+	}
+	// Budgets are asked for, and ends are where the corpus ends too.
+	if len(words) < n && stop != markov.StopBudget && stop != markov.StopEnd {
+		fmt.Fprintf(os.Stderr, "warning: stopped at %s after %s of %s words\n", why, formatCount(len(words)), formatCount(n))
+	}
+	return encode(os.Stdout, words)
+}
+
+// demoCmd implements "demo [-seed n] [-model file] [words]".
+func demoCmd(args []string) error {
+	fs := newFlagSet("demo")
+	seed := fs.Int64("seed", 0, "seed for reproducible output (0 picks a random one)")
+	model := fs.String("model", "", "model file to use instead of the built-in tiny model")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	n := 20
+	if len(args) > 1 {
+		return usagef("demo takes at most a number of words.")
+	}
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+			return usagef("number of words should be positive.")
+		}
+	}
+	c := markov.TinyModel()
+	if *model != "" {
+		var err error
+		if c, err = warnChecksum(markov.ReadFreTable(*model)); err != nil {
+			return err
+		}
+	} else if h := c.Hash(); h != markov.TinyModelHash {
+		fmt.Fprintf(os.Stderr, "warning: the tiny model hashes to %s, not %s: the model file format changed\n", h, markov.TinyModelHash)
+	}
+	return markov.WriteText(os.Stdout, c.GenerateWords(n, markov.GenerateOptions{Rand: newRand(*seed)}))
+}
+
+// selftestCmd implements "selftest [-json]".
+func selftestCmd(args []string) error {
+	fs := newFlagSet("selftest")
+	jsonOut := fs.Bool("json", false, "print the steps as a markov.SelfTestReport JSON object")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("selftest takes no arguments.")
+	}
+	if !*jsonOut {
+		return markov.SelfTest(os.Stdout)
+	}
+	rep, err := markov.RunSelfTest()
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(os.Stdout, rep); err != nil {
+		return err
+	}
+	return rep.Err()
+}
+
+// sentinelsCmd implements "sentinels [-json] [-all] model".
+func sentinelsCmd(args []string) error {
+	fs := newFlagSet("sentinels")
+	jsonOut := fs.Bool("json", false, "print the list as JSON")
+	all := fs.Bool("all", false, "list every reserved token, not only those the model uses")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usagef("sentinels needs exactly one model file.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	uses := c.SentinelUsage()
+	if *all {
+		used := make(map[string]markov.SentinelUse)
+		for _, u := range uses {
+			used[u.Name] = u
+		}
+		uses = uses[:0:0]
+		for _, t := range markov.ReservedTokens() {
+			u, ok := used[t.Name]
+			if !ok {
+				u.ReservedToken = t
+			}
+			uses = append(uses, u)
+		}
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, uses)
+	}
+	for _, u := range uses {
+		note := ""
+		if u.Collision {
+			note = "  collides with corpus words"
+		}
+		fmt.Printf("%-10s %-10q %10s  %s (%s)%s\n", u.Name, u.Literal, formatCount(u.Uses), u.Purpose, u.Option, note)
+	}
+	return nil
+}
+
+// metadataCmd implements "metadata [-json] model".
+func metadataCmd(args []string) error {
+	fs := newFlagSet("metadata")
+	jsonOut := fs.Bool("json", false, "print the metadata as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usagef("metadata needs exactly one model file.")
+	}
+	md, err := markov.ReadMetadata(args[0])
+	if err != nil {
+		return err
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, md)
+	}
+	if !md.Created.IsZero() {
+		fmt.Printf("created     %s\n", md.Created.Format(time.RFC3339))
+	}
+	if md.Tool != "" {
+		fmt.Printf("tool        %s\n", md.Tool)
+	}
+	fmt.Printf("prefix len  %d\n", md.PrefixLen)
+	if md.Sources == nil {
+		return nil
+	}
+	fmt.Printf("tokens      %s\n", formatCount(md.Tokens))
+	fmt.Printf("\n%10s  source\n", "tokens")
+	for _, src := range md.Sources {
+		fmt.Printf("%10s  %s\n", formatCount(src.Tokens), src.Name)
+	}
+	if md.Offsets != nil {
+		fmt.Printf("\n%10s  log\n", "offset")
+	}
+	for _, o := range md.Offsets {
+		fmt.Printf("%10s  %s\n", formatCount(int(o.Offset)), o.Name)
+	}
+	return nil
+}
+
+// statsCmd implements "stats [-json] [-top n] model".
+func statsCmd(args []string) error {
+	fs := newFlagSet("stats")
+	jsonOut := fs.Bool("json", false, "print the summary as JSON")
+	topN := fs.Int("top", 0, "also list the n most frequent prefixes")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usagef("stats needs exactly one model file.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	st := c.Stats()
+	var top []markov.PrefixCount
+	if *topN > 0 {
+		top = c.TopPrefixes(*topN)
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, struct {
+			markov.Stats
+			Version     int                  `json:"format_version"`
+			TopPrefixes []markov.PrefixCount `json:"top_prefixes,omitempty"`
+		}{st, c.Version(), top})
+	}
+	fmt.Printf("format          v%d\n", c.Version())
+	fmt.Printf("prefixes        %s\n", formatCount(st.Prefixes))
+	fmt.Printf("suffix entries  %s\n", formatCount(st.SuffixEntries))
+	fmt.Printf("tokens          %s\n", formatCount(st.Tokens))
+	fmt.Printf("mean suffixes   %.2f\n", st.MeanSuffixes)
+	fmt.Printf("max fan-out     %s (%q)\n", formatCount(st.MaxFanOutSuffixes), st.MaxFanOut.String())
+	if len(top) > 0 {
+		fmt.Printf("\n%10s %10s  prefix\n", "tokens", "suffixes")
+		for _, pc := range top {
+			fmt.Printf("%10s %10s  %s\n", formatCount(pc.Tokens), formatCount(pc.Suffixes), pc.Prefix.String())
+		}
+	}
+	return nil
+}
+
+// scoreResult is a line of score -json.
+type scoreResult struct {
+	Model      string   `json:"model"`
+	LogProb    float64  `json:"log_prob"`
+	Perplexity *float64 `json:"perplexity,omitempty"` // none if no word was scored
+	Unscored   int      `json:"unscored"`
+}
+
+// scoreCmd implements "score [-json] [-smoothing alpha] text model...".
+func scoreCmd(args []string) error {
+	fs := newFlagSet("score")
+	jsonOut := fs.Bool("json", false, "print the scores as JSON")
+	smoothing := fs.Float64("smoothing", 0, "smooth the probabilities by this alpha, see generate -smoothing")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return usagef("score needs a text file and at least one model.")
+	}
+	var results []scoreResult
+	for _, model := range args[1:] {
+		c, err := warnChecksum(markov.ReadFreTable(model))
+		if err != nil {
+			return err
+		}
+		if err := c.SetSmoothing(*smoothing); err != nil {
+			return usagef("%v.", err)
+		}
+		in, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		logProb, perplexity, unscored, err := c.Score(in)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", args[0], err)
+		}
+		res := scoreResult{Model: model, LogProb: logProb, Unscored: unscored}
+		if !math.IsNaN(perplexity) {
+			res.Perplexity = &perplexity
+		}
+		results = append(results, res)
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, results)
+	}
+	fmt.Printf("%12s %14s %10s  model\n", "perplexity", "log-prob", "unscored")
+	for _, res := range results {
+		perplexity := "-"
+		if res.Perplexity != nil {
+			perplexity = fmt.Sprintf("%.2f", *res.Perplexity)
+		}
+		fmt.Printf("%12s %14.2f %10s  %s\n", perplexity, res.LogProb, formatCount(res.Unscored), res.Model)
+	}
+	return nil
+}
+
+// vocabCmd implements "vocab [-json] model [word...]".
+func vocabCmd(args []string) error {
+	fs := newFlagSet("vocab")
+	jsonOut := fs.Bool("json", false, "print the words as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return usagef("vocab needs a model file.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	words := c.VocabularyByCount()
+	if len(args) > 1 {
+		vocab := c.Vocabulary()
+		words = words[:0]
+		for _, w := range args[1:] {
+			if n, ok := vocab[w]; ok {
+				words = append(words, markov.WordCount{Word: w, Count: n})
+			}
+		}
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, words)
+	}
+	for _, w := range words {
+		fmt.Printf("%10s  %s\n", formatCount(w.Count), w.Word)
+	}
+	return nil
+}
+
+// exportCmd implements "export [-format dot|probabilities] [-decimals d] [-min-count n] [-max-nodes n] model".
+func exportCmd(args []string) error {
+	fs := newFlagSet("export")
+	format := fs.String("format", "dot", "output format: dot or probabilities")
+	decimals := fs.Int("decimals", 6, "decimal places of -format probabilities")
+	var opts markov.DotOptions
+	fs.IntVar(&opts.MinCount, "min-count", 0, "leave out transitions seen fewer times than this")
+	fs.IntVar(&opts.MaxNodes, "max-nodes", 0, "keep only the n most used prefixes (0 for all)")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usagef("export needs exactly one model file.")
+	}
+	if *format != "dot" && *format != "probabilities" {
+		return usagef("unknown export format %q; expected dot or probabilities.", *format)
+	}
+	if *decimals < 1 || *decimals > markov.MaxProbabilityDecimals {
+		return usagef("-decimals must be between 1 and %d.", markov.MaxProbabilityDecimals)
+	}
+	m, err := warnChecksum(markov.OpenModel(args[0], markov.OpenOptions{Format: markov.FormatOf(args[0])}))
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if *format == "probabilities" {
+		return m.Chain.WriteProbabilities(os.Stdout, *decimals)
+	}
+	return m.Chain.WriteDOT(os.Stdout, opts)
+}
+
+// synthCmd implements "synth [options] output".
+func synthCmd(args []string) error {
+	fs := newFlagSet("synth")
+	opts := synthcorpus.DefaultOptions()
+	fs.IntVar(&opts.Tokens, "tokens", opts.Tokens, "number of words")
+	fs.IntVar(&opts.Vocab, "vocab", opts.Vocab, "number of distinct words")
+	fs.Float64Var(&opts.Exponent, "zipf", opts.Exponent, "Zipf exponent (> 1)")
+	fs.Int64Var(&opts.Seed, "seed", opts.Seed, "random seed")
+	fs.IntVar(&opts.DocLen, "doc-len", opts.DocLen, "mean document length in words (0 for one document)")
+	fs.Float64Var(&opts.PunctRate, "punct", opts.PunctRate, "probability of trailing punctuation per word")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("synth needs exactly one output file.")
+	}
+	out, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(synthcorpus.Write(out, opts), out.Close()); err != nil {
+		os.Remove(fs.Arg(0))
+		return err
+	}
+	return nil
+}
+
+// remapCmd implements "remap [-lowercase] model newmodel".
+func remapCmd(args []string) error {
+	fs := newFlagSet("remap")
+	lowercase := fs.Bool("lowercase", false, "fold every word to lower case")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usagef("remap needs an input and an output model.")
+	}
+	if !*lowercase {
+		return usagef("remap needs a transformation such as -lowercase.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	out, err := c.RemapTokens("lowercase", strings.ToLower)
+	if err != nil {
+		return err
+	}
+	return out.WriteFreTable(args[1])
+}
+
+// repairCmd implements "repair [-json] model newmodel".
+func repairCmd(args []string) error {
+	fs := newFlagSet("repair")
+	jsonOut := fs.Bool("json", false, "print the summary and repairs as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usagef("repair needs an input and an output model.")
+	}
+	in, err := markov.OpenModelFile(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var repairs []markov.Problem
+	c, sum, err := markov.RepairFreTable(in, func(p markov.Problem) {
+		repairs = append(repairs, p)
+		if !*jsonOut {
+			fmt.Printf("%s: %s\n", args[0], p)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	if err := c.WriteFreTable(args[1]); err != nil {
+		return err
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, RepairReport{sum, repairs})
+	}
+	fmt.Printf("%s: %s prefixes, %s suffix entries kept, %s repaired, %s dropped\n", args[1],
+		formatCount(sum.Prefixes), formatCount(sum.SuffixEntries), formatCount(sum.Repaired), formatCount(sum.Dropped))
+	return nil
+}
+
+// RepairReport is the JSON object printed by repair -json.
+type RepairReport struct {
+	markov.RepairSummary
+	Repairs []markov.Problem `json:"repair_list"`
+}
+
+// migrateCmd implements "migrate [-json] model [newmodel]".
+func migrateCmd(args []string) error {
+	fs := newFlagSet("migrate")
+	jsonOut := fs.Bool("json", false, "print the versions migrated from and to as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 && len(args) != 2 {
+		return usagef("migrate needs a model and optionally a new model.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	out := args[len(args)-1]
+	if err := writeModel(c, out); err != nil {
+		return err
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, MigrateReport{args[0], out, c.Version(), markov.FormatVersion})
+	}
+	return nil
+}
+
+// MigrateReport is the JSON object printed by migrate -json.
+type MigrateReport struct {
+	Model       string `json:"model"`
+	Output      string `json:"output"`
+	FromVersion int    `json:"from_version"` // of the format of model
+	Version     int    `json:"version"`      // written to output
+}
+
+// writeModel writes c to out next to the target and renames it, so that a
+// failure never leaves a half-written model in place of a good one.
+func writeModel(c *markov.Chain, out string) error {
+	tmp := out + ".tmp"
+	if err := c.WriteFreTable(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pruneCmd implements "prune [-json] [-min-count n] [-min-share p] model [newmodel]".
+func pruneCmd(args []string) error {
+	fs := newFlagSet("prune")
+	minCount := fs.Int("min-count", 0, "drop suffix entries seen fewer times than this")
+	minShare := fs.Float64("min-share", 0, "drop suffix entries with a smaller share of their prefix's total")
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 && len(args) != 2 {
+		return usagef("prune needs a model and optionally a new model.")
+	}
+	if *minCount <= 0 && *minShare <= 0 {
+		return usagef("prune needs -min-count or -min-share.")
+	}
+	if *minShare >= 1 {
+		return usagef("-min-share must be below 1.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	var rep markov.PruneReport
+	if *minCount > 0 {
+		rep = c.Prune(*minCount)
+	}
+	if *minShare > 0 {
+		r := c.PruneShare(*minShare)
+		rep.SuffixEntries += r.SuffixEntries
+		rep.Tokens += r.Tokens
+		rep.Prefixes += r.Prefixes
+	}
+	if err := writeModel(c, args[len(args)-1]); err != nil {
+		return err
+	}
+	if *jsonOut {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("pruned %s suffix entries (%s tokens) and %s prefixes\n",
+		formatCount(rep.SuffixEntries), formatCount(rep.Tokens), formatCount(rep.Prefixes))
+	return nil
+}
+
+// mergeCmd implements "merge newmodel [-weight n] model...".
+func mergeCmd(args []string) error {
+	fs := newFlagSet("merge")
+	weight := fs.Int("weight", 1, "multiply the counts of the next model by this")
+	jsonOut := fs.Bool("json", false, "print the models merged and the size of the result as JSON")
+	// Like parseInterspersed, but every model takes the -weight before it.
+	var names []string
+	var weights []int
+	for {
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		if *weight <= 0 {
+			return usagef("-weight must be positive.")
+		}
+		names = append(names, args[0])
+		weights = append(weights, *weight)
+		*weight = 1
+		args = args[1:]
+	}
+	if len(names) < 2 {
+		return usagef("merge needs a new model and at least one model.")
+	}
+	if weights[0] != 1 {
+		return usagef("-weight goes before a model, not the new model.")
+	}
+	rep := MergeReport{Model: names[0]}
+	for i, name := range names[1:] {
+		rep.Inputs = append(rep.Inputs, MergeInput{name, weights[i+1]})
+	}
+	c, err := markov.MergeFreTables(names[1:], weights[1:], func(msg string) {
+		if *jsonOut {
+			rep.Warnings = append(rep.Warnings, msg)
+		} else {
+			fmt.Fprintln(os.Stderr, "warning:", msg)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeModel(c, names[0]); err != nil {
+		return err
+	}
+	if *jsonOut {
+		rep.Stats = c.Stats()
+		return writeJSON(os.Stdout, rep)
+	}
+	return nil
+}
+
+// MergeReport is the JSON object printed by merge -json: the models
+// merged, the size of the merged model and the warnings of the merge.
+type MergeReport struct {
+	Model  string       `json:"model"`
+	Inputs []MergeInput `json:"inputs"`
+	markov.Stats
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// MergeInput is a model of MergeReport with the -weight it was merged with.
+type MergeInput struct {
+	Model  string `json:"model"`
+	Weight int    `json:"weight"`
+}
+
+// presetCmd implements "preset set model name [flag...]" and "preset list model".
+func presetCmd(args []string) error {
+	if len(args) < 2 || args[0] != "set" && args[0] != "list" {
+		return usagef("preset needs set or list and a model.")
+	}
+	model := args[1]
+	if args[0] == "list" {
+		if len(args) != 2 {
+			return usagef("preset list needs only a model.")
+		}
+		c, err := warnChecksum(markov.ReadFreTable(model))
+		if err != nil {
+			return err
+		}
+		for _, name := range c.Presets() {
+			flags, _ := c.Preset(name)
+			fmt.Printf("%s\t%s\n", name, strings.Join(flags, " "))
+		}
+		return nil
+	}
+	if len(args) < 3 {
+		return usagef("preset set needs a model and a preset name.")
+	}
+	name, flags := args[2], args[3:]
+	c, err := warnChecksum(markov.ReadFreTable(model))
+	if err != nil {
+		return err
+	}
+	if len(flags) > 0 {
+		// Catch mistakes now rather than at the first generate -preset.
+		_, g, err := parsePreset(name, flags)
+		if err != nil {
+			return usagef("%v.", err)
+		}
+		opts, err := g.options()
+		if err != nil {
+			return err
+		}
+		// generate sets the alpha of -smoothing on the chain itself.
+		opts.Smooth = false
+		if err := c.Preflight(opts); err != nil {
+			return fmt.Errorf("%s: preset %s: %w", model, name, err)
+		}
+	}
+	if err := c.SetPreset(name, flags); err != nil {
+		return usagef("%v.", err)
+	}
+	return writeModel(c, model)
+}
+
+// diffCmd implements "diff [-metric js] [-json] model1 model2".
+func diffCmd(args []string) error {
+	fs := newFlagSet("diff")
+	metric := fs.String("metric", "js", "comparison metric: js")
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usagef("diff needs two models.")
+	}
+	if *metric != "js" {
+		return usagef("unknown metric %q, want js.", *metric)
+	}
+	a, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	b, err := warnChecksum(markov.ReadFreTable(args[1]))
+	if err != nil {
+		return err
+	}
+	rep := markov.Divergence(a, b)
+	if *jsonOut {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("%s prefixes: %s shared, %s only in %s, %s only in %s\n", formatCount(rep.Prefixes),
+		formatCount(rep.Shared), formatCount(rep.OnlyA), args[0], formatCount(rep.OnlyB), args[1])
+	fmt.Printf("JS divergence: %.4f bits over shared prefixes, %.4f bits overall\n", rep.SharedJS, rep.JS)
+	for _, d := range rep.Top {
+		fmt.Printf("%8.4f %8.4f%%  %s\n", d.JS, 100*d.Weight, d.Prefix)
+	}
+	return nil
+}
+
+// parseInterspersed parses fs from args allowing flags to follow the
+// positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := parseFlags(fs, args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+// inspectCmd implements "inspect [-json] [-smoothing alpha] model word...".
+func inspectCmd(args []string) error {
+	fs := newFlagSet("inspect")
+	smoothing := fs.Float64("smoothing", 0, "smooth the probabilities by this alpha, see generate -smoothing")
+	jsonOut := fs.Bool("json", false, "print the distribution, or the nearest prefixes, as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return usagef("inspect needs a model and a prefix.")
+	}
+	c, err := warnChecksum(markov.ReadFreTable(args[0]))
+	if err != nil {
+		return err
+	}
+	words := args[1:]
+	if len(words) != c.PrefixLen() {
+		return usagef("%s has prefixes of %d words, got %d.", args[0], c.PrefixLen(), len(words))
+	}
+	if err := c.SetSmoothing(*smoothing); err != nil {
+		return usagef("%v.", err)
+	}
+	key := strings.Join(words, " ")
+	rep := InspectReport{Model: args[0], Prefix: words}
+	if len(c.Suffixes(words)) == 0 { // not the prior Distribution falls back to
+		err := fmt.Errorf("%s: unknown prefix %q", args[0], key)
+		if *jsonOut {
+			rep.Nearest = c.NearestPrefixes(words, 5)
+			if werr := writeJSON(os.Stdout, rep); werr != nil {
+				return werr
+			}
+			return err
+		}
+		fmt.Printf("%q is not a prefix of %s\n", key, args[0])
+		if matches := c.NearestPrefixes(words, 5); len(matches) > 0 {
+			fmt.Println("did you mean:")
+			for _, m := range matches {
+				fmt.Printf("  %-30s %.2f\n", m.Prefix, m.Score)
+			}
+		}
+		return err
+	}
+	if *jsonOut {
+		rep.Suffixes = c.Distribution(words)
+		if c.HasPositions() {
+			h := c.PositionHistogram(words)
+			rep.Positions = h[:]
+		}
+		return writeJSON(os.Stdout, rep)
+	}
+	for _, s := range c.Distribution(words) {
+		fmt.Printf("%8s %6.2f%%  %s\n", formatCount(s.Count), 100*s.Probability, s.Word)
+	}
+	if c.HasPositions() {
+		fmt.Print("positions by tenth of the document:")
+		for _, n := range c.PositionHistogram(words) {
+			fmt.Printf(" %d", n)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// InspectReport is the JSON object printed by inspect -json: the words
+// that may follow the prefix, and for a model built with -positions how
+// often it occurs in each tenth of the documents, or for a prefix the
+// model does not know the most similar ones it does.
+type InspectReport struct {
+	Model     string                     `json:"model"`
+	Prefix    []string                   `json:"prefix"`
+	Suffixes  []markov.SuffixProbability `json:"suffixes,omitempty"`
+	Positions []int                      `json:"positions,omitempty"`
+	Nearest   []markov.PrefixMatch       `json:"nearest,omitempty"`
+}
+
+// validateCmd implements "validate [-stream] [-json] model".
+func validateCmd(args []string) error {
+	fs := newFlagSet("validate")
+	stream := fs.Bool("stream", false, "only run the line-by-line checks, without loading the chain")
+	jsonOut := fs.Bool("json", false, "print the summary and problems as JSON")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usagef("validate needs exactly one model file.")
+	}
+	in, err := markov.OpenModelFile(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var problems []markov.Problem
+	sum, err := markov.ValidateStream(in, func(p markov.Problem) {
+		problems = append(problems, p)
+		if !*jsonOut {
+			fmt.Printf("%s: %s\n", args[0], p)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if *stream && sum.Unchecked {
+		fmt.Fprintln(os.Stderr, "warning:", &markov.ChecksumError{Name: args[0], Missing: true})
+	}
+	unreachable := 0
+	if !*stream && sum.Problems == 0 {
+		c, err := warnChecksum(markov.ReadFreTable(args[0]))
+		if err != nil {
+			return err
+		}
+		var msgs []string
+		if unreachable = c.Unreachable(); unreachable > 0 {
+			msgs = append(msgs, fmt.Sprintf("%d prefixes cannot be reached from the start state", unreachable))
+		}
+		for _, u := range c.SentinelUsage() {
+			if u.Collision {
+				msgs = append(msgs, collisionProblem(u))
+			}
+		}
+		for _, msg := range msgs {
+			problems = append(problems, markov.Problem{Msg: msg})
+			sum.Problems++
+			if !*jsonOut {
+				fmt.Printf("%s: %s\n", args[0], msg)
+			}
+		}
+	}
+
+	if *jsonOut {
+		if err := writeJSON(os.Stdout, ValidateReport{sum, problems}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%s: %s lines, %s prefixes, %s suffix entries, %s problems\n", args[0],
+			formatCount(sum.Lines), formatCount(sum.Prefixes), formatCount(sum.SuffixEntries), formatCount(sum.Problems))
+	}
+	if sum.Problems > 0 {
+		return fmt.Errorf("%s: %w", args[0], ErrInvalidModel)
+	}
+	return nil
+}
+
+// collisionProblem describes a collision found by Chain.SentinelUsage.
+func collisionProblem(u markov.SentinelUse) string {
+	return fmt.Sprintf("the corpus word %q collides with the reserved %s token, but the model has no data for %s", u.Literal, u.Name, u.Option)
+}
+
+// ValidateReport is the JSON object printed by validate -json.
+type ValidateReport struct {
+	markov.ValidateSummary
+	Problems []markov.Problem `json:"problem_list"`
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// TestMerge merges two models with a weight on the second, and checks that
+// a model of another prefix length or a misplaced -weight writes nothing.
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	tiny := markov.TinyModel()
+	three, err := markov.NewChain(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := three.AddText(strings.NewReader(goldenCorpus)); err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]*markov.Chain{"a.txt": tiny, "b.txt": tiny, "three.txt": three} {
+		if err := c.WriteFreTable(at(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mergeCmd([]string{at("out.txt"), at("a.txt"), "-weight", "3", at("b.txt")}); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := markov.ReadFreTable(at("out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []markov.Prefix{{"", ""}, {"the", "cat"}, {"sat", "on"}} {
+		got, want := merged.Suffixes(p), tiny.Suffixes(p)
+		if len(got) != len(want) {
+			t.Errorf("suffixes of %q = %v, want those of %v counted 4 times", p, got, want)
+			continue
+		}
+		for i, s := range got {
+			if s.Word() != want[i].Word() || s.Frequency() != 4*want[i].Frequency() {
+				t.Errorf("suffixes of %q = %v, want those of %v counted 4 times", p, got, want)
+				break
+			}
+		}
+	}
+
+	before := dirContents(t, dir)
+	for _, tt := range []struct {
+		args []string
+		want string // in the error
+	}{
+		{[]string{at("new.txt"), at("a.txt"), at("three.txt")}, at("three.txt")},
+		{[]string{"-weight", "2", at("new.txt"), at("a.txt")}, "-weight"},
+		{[]string{at("new.txt"), "-weight", "0", at("a.txt")}, "-weight"},
+		{[]string{at("new.txt")}, "merge needs"},
+	} {
+		if err := mergeCmd(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("merge %s: %v, want an error with %q", strings.Join(tt.args, " "), err, tt.want)
+		}
+	}
+	if !reflect.DeepEqual(dirContents(t, dir), before) {
+		t.Error("a failed merge wrote files")
+	}
+}
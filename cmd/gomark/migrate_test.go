@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// TestMigrate rewrites the model of the first format version in place and
+// into a new file, and checks both are of the current version and read
+// back as the original.
+func TestMigrate(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "markov", "testdata", "versions", "v1.model"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.model")
+	if err := os.WriteFile(old, data, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := markov.ReadFreTable(old)
+
+	for _, args := range [][]string{{old, filepath.Join(dir, "new.model")}, {old}} {
+		warnings := capture(t, &os.Stderr, func() error { return migrateCmd(args) })
+		if !strings.Contains(warnings, "no checksum") {
+			t.Errorf("migrate %q warned %q, want a note about the missing checksum", args, warnings)
+		}
+		c, err := markov.ReadFreTable(args[len(args)-1])
+		if err != nil {
+			t.Fatalf("migrate %q: %v", args, err)
+		}
+		if c.Version() != markov.FormatVersion || c.Hash() != want.Hash() {
+			t.Errorf("migrate %q wrote version %d, hash %s; want version %d, hash %s", args, c.Version(), c.Hash(), markov.FormatVersion, want.Hash())
+		}
+	}
+	if _, err := os.Stat(old + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("migrate left its temporary file behind")
+	}
+}
+
+// TestMigrateFailureCleansUp migrates onto a directory, which the rename
+// at the end cannot replace, and checks that the temporary file is gone.
+func TestMigrateFailureCleansUp(t *testing.T) {
+	dir := t.TempDir()
+	model, out := filepath.Join(dir, "m.model"), filepath.Join(dir, "out")
+	if err := markov.TinyModel().WriteFreTable(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(out, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateCmd([]string{model, out}); err == nil {
+		t.Fatal("migrating onto a directory succeeded")
+	}
+	if _, err := os.Stat(out + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("a failed migrate left its temporary file behind")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// dirContents returns the contents of every file under dir by path.
+func dirContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		files[path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// TestReadDryRun checks that read -dry-run lists the inputs a build would
+// read, and creates or changes no file, whatever it is asked to write.
+func TestReadDryRun(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{
+		"corpus/a.txt":     goldenCorpus,
+		"corpus/sub/b.txt": "The dog sat on the cat.\n",
+		"corpus/c.zip":     "PK\x03\x04",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o777)
+		if err := os.WriteFile(path, []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := func(name string) string { return filepath.Join(dir, name) }
+	capture(t, &os.Stderr, func() error {
+		capture(t, &os.Stdout, func() error { return readCmd([]string{"2", at("m.txt"), at("corpus")}) })
+		return nil
+	})
+	before := dirContents(t, dir)
+
+	for _, args := range [][]string{
+		{"-dry-run", "2", at("new.txt"), at("corpus")},
+		{"-dry-run", "-json", "-format", "gob", "2", at("new.gob"), at("corpus")},
+		{"-dry-run", "-write-index", at("new.idx"), "2", at("new.txt"), at("corpus")},
+		{"-dry-run", "-update", "-stamp", "2", at("m.txt"), at("corpus/sub")},
+	} {
+		var err error
+		var out string
+		capture(t, &os.Stderr, func() error {
+			out = capture(t, &os.Stdout, func() error {
+				err = readCmd(args)
+				return err
+			})
+			return nil
+		})
+		if err != nil {
+			t.Errorf("read %s: %v", strings.Join(args, " "), err)
+			continue
+		}
+		if !strings.Contains(out, at("corpus/sub/b.txt")) || strings.Contains(out, "c.zip") {
+			t.Errorf("read %s listed\n%s\nwant the text files only", strings.Join(args, " "), out)
+		}
+		if after := dirContents(t, dir); !reflect.DeepEqual(after, before) {
+			t.Errorf("read %s changed the files:\n%q\nwant\n%q", strings.Join(args, " "), after, before)
+		}
+	}
+}
+
+// TestReadFailedInputs reads a readable and a missing input, which writes
+// the model of the readable one and lists the other, unless -strict.
+func TestReadFailedInputs(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	if err := os.WriteFile(at("a.txt"), []byte(goldenCorpus), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args  []string
+		out   string
+		wrote bool
+	}{
+		{[]string{"2", at("m.txt"), at("a.txt"), at("missing.txt")}, at("m.txt"), true},
+		{[]string{"-strict", "2", at("strict.txt"), at("a.txt"), at("missing.txt")}, at("strict.txt"), false},
+		{[]string{"2", at("none.txt"), at("missing.txt")}, at("none.txt"), false},
+	} {
+		var err error
+		warnings := capture(t, &os.Stderr, func() error {
+			capture(t, &os.Stdout, func() error {
+				err = readCmd(tt.args)
+				return nil
+			})
+			return nil
+		})
+		if (err == nil) != tt.wrote {
+			t.Errorf("read %s: %v", strings.Join(tt.args, " "), err)
+		}
+		if !strings.Contains(warnings, at("missing.txt")) {
+			t.Errorf("read %s printed\n%s\nwant the missing input listed", strings.Join(tt.args, " "), warnings)
+		}
+		if _, err := os.Stat(tt.out); (err == nil) != tt.wrote {
+			t.Errorf("read %s wrote the model: %v, want %v", strings.Join(tt.args, " "), err == nil, tt.wrote)
+		}
+	}
+}
+
+// TestReadUpdate trains a model further with read -update, and refuses a
+// prefix length other than the model's.
+func TestReadUpdate(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	for name, text := range map[string]string{"a.txt": goldenCorpus, "b.txt": "The dog sat on the cat.\n"} {
+		if err := os.WriteFile(at(name), []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) error {
+		var err error
+		capture(t, &os.Stderr, func() error {
+			capture(t, &os.Stdout, func() error {
+				err = readCmd(args)
+				return nil
+			})
+			return nil
+		})
+		return err
+	}
+	for _, args := range [][]string{
+		{"2", at("both.txt"), at("a.txt"), at("b.txt")},
+		{"2", at("m.txt"), at("a.txt")},
+		{"-update", "2", at("m.txt"), at("b.txt")},
+	} {
+		if err := run(args...); err != nil {
+			t.Fatalf("read %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	both, err := markov.ReadFreTable(at("both.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := markov.ReadFreTable(at("m.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []markov.Prefix{{"sat", "on"}, {"on", "the"}, {"", ""}} {
+		if got, want := updated.Suffixes(p), both.Suffixes(p); len(want) == 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("suffixes of %q after -update = %v, want %v", p, got, want)
+		}
+	}
+
+	before := dirContents(t, dir)
+	if err := run("-update", "3", at("m.txt"), at("b.txt")); err == nil || !strings.Contains(err.Error(), "prefix") {
+		t.Errorf("read -update with another prefix length: %v, want an error about it", err)
+	}
+	if !reflect.DeepEqual(dirContents(t, dir), before) {
+		t.Errorf("a refused -update changed the model")
+	}
+}
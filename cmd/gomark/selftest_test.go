@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSelftest runs selftest, and checks that arguments it does not take
+// are usage errors rather than ignored.
+func TestSelftest(t *testing.T) {
+	var err error
+	out := capture(t, &os.Stdout, func() error {
+		err = selftestCmd(nil)
+		return nil
+	})
+	if err != nil || !strings.Contains(out, "PASS round trip msgpack") || strings.Contains(out, "FAIL") {
+		t.Errorf("selftest = %v:\n%s", err, out)
+	}
+	for _, args := range [][]string{{"-bogus"}, {"extra"}} {
+		if err := selftestCmd(args); exitCode(err) != exitUsage {
+			t.Errorf("selftest %q = %v, want a usage error", args, err)
+		}
+	}
+}
@@ -0,0 +1,776 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// Startup states of serve, as /healthz and /status report them.
+const (
+	stateStarting = "starting" // the model is loading
+	stateReady    = "ready"    // the model loaded and generates text
+	stateFailed   = "failed"   // the model could not be loaded or generates nothing
+	stateClosed   = "closed"   // the server shut down and closed the model
+)
+
+// maxServeWords bounds the n of a /generate request.
+const maxServeWords = 10000
+
+// loadRetryAfter is the Retry-After, in seconds, of /generate requests
+// refused while the model loads.
+const loadRetryAfter = "5"
+
+// maxTrainBytes bounds the text of a /train request.
+const maxTrainBytes = 10 << 20
+
+// serveCmd implements "serve [-addr addr] [-format text|json|gob|msgpack] [-max-download-bytes n] [-max-model-bytes n] [-progress-interval d] [-train [-save-dir dir]] model".
+func serveCmd(args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	codecFlag := fs.String("format", "", "format of the model: text, json, gob or msgpack (default by extension: .json, .gob, .msgpack, otherwise text)")
+	maxBytes := fs.Int64("max-download-bytes", 0, "refuse model files or downloads of more bytes than this (0 means no limit)")
+	maxModel := fs.Int64("max-model-bytes", 0, "refuse models estimated to need more memory than this many bytes (0 means no limit)")
+	interval := fs.Duration("progress-interval", 10*time.Second, "how often to log the progress of loading the model")
+	train := fs.Bool("train", false, "accept POST /train to train the model and POST /save to save it")
+	saveDir := fs.String("save-dir", "", "directory POST /save?path= may write models to")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usagef("serve needs exactly one model file or URL.")
+	}
+	model := args[0]
+	name := model
+	if u, err := url.Parse(model); err == nil && isRemote(model) {
+		name = u.Path
+	}
+	codec, err := modelFormat(*codecFlag, name)
+	if err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return usagef("-progress-interval must be positive.")
+	}
+	if *maxModel < 0 {
+		return usagef("-max-model-bytes must not be negative.")
+	}
+	if *saveDir != "" && !*train {
+		return usagef("-save-dir needs -train.")
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	s := newServer(log.New(os.Stderr, "", log.LstdFlags))
+	s.train, s.saveDir, s.maxModelBytes = *train, *saveDir, *maxModel
+	s.source = model
+	if !isRemote(model) {
+		s.modelPath = model
+	}
+	s.log.Printf("listening on %s, loading %s", ln.Addr(), model)
+	go s.load(func() (io.ReadCloser, int64, error) {
+		return openModelSource(model, *maxBytes)
+	}, codec, *interval)
+
+	// On SIGINT or SIGTERM the requests under way are answered before the
+	// model is closed.
+	srv := &http.Server{Handler: s.handler()}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	shutdown := make(chan error, 1)
+	go func() {
+		<-stop
+		s.log.Printf("shutting down")
+		shutdown <- srv.Shutdown(context.Background())
+	}()
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		err = <-shutdown
+	}
+	return errors.Join(err, s.close())
+}
+
+// isRemote reports whether the model of serve is to be downloaded.
+func isRemote(model string) bool {
+	return strings.HasPrefix(model, "http://") || strings.HasPrefix(model, "https://")
+}
+
+// openModelSource opens the model file name, or downloads it if name is an
+// http or https URL, and returns its size in bytes, -1 if the server did
+// not tell. With max > 0 models of more bytes are refused: before reading
+// anything if their size is known, otherwise once more than max bytes
+// have come in.
+func openModelSource(name string, max int64) (io.ReadCloser, int64, error) {
+	var in io.ReadCloser
+	var size int64
+	if isRemote(name) {
+		resp, err := http.Get(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("%s: %s", name, resp.Status)
+		}
+		in, size = resp.Body, resp.ContentLength
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		in, size = f, fi.Size()
+	}
+	if max <= 0 {
+		return in, size, nil
+	}
+	if size > max {
+		in.Close()
+		return nil, 0, budgetError(name, max)
+	}
+	return &budgetReader{ReadCloser: in, name: name, max: max, left: max}, size, nil
+}
+
+// budgetError reports a model of more than max bytes.
+func budgetError(name string, max int64) error {
+	return fmt.Errorf("%s: model is larger than the budget of %s", name, formatBytes(max))
+}
+
+// budgetReader fails once more bytes than left are read through it, for
+// downloads that do not tell their size.
+type budgetReader struct {
+	io.ReadCloser
+	name string
+	max  int64
+	left int64
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return 0, budgetError(r.name, r.max)
+	}
+	return n, err
+}
+
+// modelOpener opens the model serve loads and returns its size in bytes,
+// or -1 if it is not known in advance.
+type modelOpener func() (io.ReadCloser, int64, error)
+
+// progressReader counts the bytes or the lines read through it, for
+// reporting the progress of a load while it runs.
+type progressReader struct {
+	r     io.Reader
+	bytes *atomic.Int64 // nil if not counted
+	lines *atomic.Int64 // nil if not counted
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.bytes != nil {
+		r.bytes.Add(int64(n))
+	}
+	if r.lines != nil {
+		r.lines.Add(int64(strings.Count(string(p[:n]), "\n")))
+	}
+	return n, err
+}
+
+// server is the state of serve. Its HTTP endpoints are up from the start,
+// while the model loads in the background: /healthz answers at once, and
+// /readyz and /generate only once the model loaded and generated a test
+// text, so that orchestration routes traffic to it when it can answer.
+type server struct {
+	log     *log.Logger
+	started time.Time
+
+	// Progress of the load, updated while it runs.
+	bytesRead  atomic.Int64 // bytes of the model file, compressed or not
+	bytesTotal atomic.Int64 // size of the model file, -1 if unknown
+	entries    atomic.Int64 // lines of text and JSON models read so far
+	estimate   atomic.Int64 // bytes of memory the model needs, 0 until estimated
+
+	maxModelBytes int64 // -max-model-bytes, 0 for no limit
+
+	mu       sync.Mutex
+	state    string
+	err      error         // why the load failed
+	loadTime time.Duration // how long the load took, once done
+	model    *markov.Model // once ready, until closed
+	source   string        // the model file or URL, for the Origin of the model
+	prefixes int
+
+	// Requests to /generate since the model was ready, and the heap
+	// allocations of the process then, see heapAllocs.
+	requests    atomic.Int64
+	readyAllocs uint64
+
+	// Live training, see handleTrain and handleSave.
+	train     bool
+	modelPath string // where /save writes by default, "" for downloaded models
+	saveDir   string // where /save?path= may write, "" for nowhere
+	saveMu    sync.Mutex
+	pending   map[string]*saveCall // saves not started yet, by path
+	writing   sync.Mutex           // held while a save runs
+}
+
+// serverStatus is the answer of /status, /healthz and /readyz.
+type serverStatus struct {
+	State      string  `json:"state"`
+	BytesRead  int64   `json:"bytes_read"`
+	BytesTotal int64   `json:"bytes_total"` // -1 for downloads of unknown size
+	Entries    int64   `json:"entries"`     // lines read, for text and JSON models
+	Elapsed    float64 `json:"elapsed_seconds"`
+	Prefixes   int     `json:"prefixes,omitempty"`
+	Hash       string  `json:"hash,omitempty"` // of the model once ready, see markov.Origin
+	Error      string  `json:"error,omitempty"`
+	// EstimatedBytes is the memory the model needs, see
+	// markov.Chain.EstimateMemory: estimated from the file before a text
+	// model on disk is read, and from the chain once any model is read.
+	EstimatedBytes int64 `json:"estimated_bytes,omitempty"`
+	// Requests counts the /generate requests answered, and
+	// AllocsPerRequest the heap allocations of the process since the model
+	// was ready divided by them, for watching a server under load.
+	Requests         int64   `json:"requests"`
+	AllocsPerRequest float64 `json:"allocs_per_request,omitempty"`
+}
+
+func newServer(logger *log.Logger) *server {
+	s := &server{log: logger, started: time.Now(), state: stateStarting, pending: map[string]*saveCall{}}
+	s.bytesTotal.Store(-1)
+	return s
+}
+
+// load reads the model from open in format and makes the server ready
+// once it generates text, logging the progress every interval meanwhile.
+func (s *server) load(open modelOpener, format string, interval time.Duration) {
+	done := make(chan struct{})
+	go s.logProgress(interval, done)
+	c, err := s.readWithin(open, format)
+	if err == nil {
+		err = checkGenerates(c)
+	}
+	var m *markov.Model
+	if err == nil {
+		m = markov.NewModel(c, markov.Origin{Path: s.source, Format: format, Size: s.bytesRead.Load()})
+	}
+	close(done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == stateClosed {
+		if m != nil {
+			m.Close()
+		}
+		return
+	}
+	s.loadTime = time.Since(s.started)
+	if err != nil {
+		s.state, s.err = stateFailed, err
+		s.log.Printf("loading the model failed after %v: %v", s.loadTime.Round(time.Millisecond), err)
+		return
+	}
+	if s.model != nil {
+		s.model.Close()
+	}
+	s.state, s.model, s.prefixes = stateReady, m, c.Stats().Prefixes
+	s.readyAllocs = heapAllocs()
+	s.log.Printf("ready after %v: %s prefixes", s.loadTime.Round(time.Millisecond), formatCount(s.prefixes))
+}
+
+// close closes the model of s and makes s answer as closed; a load still
+// running closes its model too. No request may be using the model any
+// more, which serveCmd ensures by shutting the HTTP server down first.
+func (s *server) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.model
+	s.state, s.model = stateClosed, nil
+	if m == nil {
+		return nil
+	}
+	return m.Close()
+}
+
+// readWithin reads the model as read does, if it fits in -max-model-bytes.
+// A text model on disk is estimated by a first pass over the file, see
+// markov.EstimateModelFile, and refused before it is read; downloads and
+// binary models, which cannot be estimated without decoding them, are
+// estimated once read and refused before the server uses them.
+func (s *server) readWithin(open modelOpener, format string) (*markov.Chain, error) {
+	if s.modelPath != "" && format == markov.FormatText {
+		est, err := markov.EstimateModelFile(s.modelPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.fits(est); err != nil {
+			return nil, err
+		}
+	}
+	c, err := s.read(open, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fits(c.EstimateMemory()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// fits records est as the memory the model needs, and fails if it is more
+// than -max-model-bytes.
+func (s *server) fits(est int64) error {
+	s.estimate.Store(est)
+	if s.maxModelBytes > 0 && est > s.maxModelBytes {
+		return fmt.Errorf("the model needs about %s of memory, more than the -max-model-bytes budget of %s", formatBytes(est), formatBytes(s.maxModelBytes))
+	}
+	return nil
+}
+
+// read reads the chain of the model from open, counting its bytes and
+// lines as it goes.
+func (s *server) read(open modelOpener, format string) (*markov.Chain, error) {
+	in, size, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	s.bytesTotal.Store(size)
+	zr, err := markov.NewModelReader(&progressReader{r: in, bytes: &s.bytesRead})
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	r := io.Reader(zr)
+	if format == markov.FormatText || format == markov.FormatJSON {
+		r = &progressReader{r: zr, lines: &s.entries}
+	}
+	c, err := markov.ReadModel(r, format)
+	var checksum *markov.ChecksumError
+	if errors.As(err, &checksum) && checksum.Missing {
+		s.log.Printf("warning: %v", err)
+		err = nil
+	}
+	return c, err
+}
+
+// checkGenerates is the readiness check of serve: c must generate a word,
+// without the parts of a model loaded only as generation visits them
+// failing to load.
+func checkGenerates(c *markov.Chain) error {
+	words := c.GenerateWords(10, markov.GenerateOptions{})
+	if err := c.Err(); err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return markov.ErrEmptyModel
+	}
+	return nil
+}
+
+// logProgress logs the progress of the load every interval until done is
+// closed.
+func (s *server) logProgress(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			total := "?"
+			if n := s.bytesTotal.Load(); n >= 0 {
+				total = formatBytes(n)
+			}
+			s.log.Printf("loading the model: %s of %s read, %s entries", formatBytes(s.bytesRead.Load()), total, formatCount(int(s.entries.Load())))
+		}
+	}
+}
+
+// status returns the state of s and the progress of the load.
+func (s *server) status() serverStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := serverStatus{
+		State:      s.state,
+		BytesRead:  s.bytesRead.Load(),
+		BytesTotal: s.bytesTotal.Load(),
+		Entries:    s.entries.Load(),
+		Prefixes:   s.prefixes,
+	}
+	if s.model != nil {
+		st.Hash = s.model.Origin().Hash
+	}
+	st.EstimatedBytes = s.estimate.Load()
+	elapsed := s.loadTime
+	if s.state == stateStarting {
+		elapsed = time.Since(s.started)
+	}
+	st.Elapsed = elapsed.Seconds()
+	if s.err != nil {
+		st.Error = s.err.Error()
+	}
+	if st.Requests = s.requests.Load(); st.Requests > 0 {
+		st.AllocsPerRequest = float64(heapAllocs()-s.readyAllocs) / float64(st.Requests)
+	}
+	return st
+}
+
+// heapAllocs returns the number of heap allocations the process has made,
+// read without stopping the world as runtime.ReadMemStats would.
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:objects"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// ready returns the model if s is ready.
+func (s *server) ready() (*markov.Chain, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != stateReady {
+		return nil, false
+	}
+	return s.model.Chain, true
+}
+
+// handler returns the HTTP endpoints of s.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, s.status())
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, s.status())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		st := s.status()
+		code := http.StatusOK
+		if st.State != stateReady {
+			code = http.StatusServiceUnavailable
+		}
+		writeStatus(w, code, st)
+	})
+	mux.HandleFunc("/generate", s.generate)
+	if s.train {
+		mux.HandleFunc("/train", s.handleTrain)
+		mux.HandleFunc("/save", s.handleSave)
+	}
+	return mux
+}
+
+// writeStatus answers a request with st as JSON.
+func writeStatus(w http.ResponseWriter, code int, st serverStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	writeJSON(w, st)
+}
+
+// readyChain returns the model for a request if s is ready, or answers
+// it with 503 Service Unavailable, with a Retry-After while it loads.
+func (s *server) readyChain(w http.ResponseWriter) (*markov.Chain, bool) {
+	c, ok := s.ready()
+	if ok {
+		return c, true
+	}
+	switch s.status().State {
+	case stateStarting:
+		w.Header().Set("Retry-After", loadRetryAfter)
+		http.Error(w, "the model is loading", http.StatusServiceUnavailable)
+		return nil, false
+	case stateClosed:
+		http.Error(w, "the server is shutting down", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	http.Error(w, "the model failed to load", http.StatusServiceUnavailable)
+	return nil, false
+}
+
+// generate answers GET /generate?n=words&seed=s with a generated text, or
+// with ?annotate=1 with the tokens annotated as by -output-format
+// annotated-json, or with ?prompt=text with a promptResult continuing the
+// text, or with 503 Service Unavailable until the model is ready; while it
+// loads the answer has a Retry-After. ?sentence_start=1 and ?backoff=1 are
+// the generate flags; asking for one the model has no data for is a 400
+// Bad Request naming it, see markov.Chain.Preflight.
+func (s *server) generate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := s.readyChain(w)
+	if !ok {
+		return
+	}
+	query := r.URL.Query() // parsed once, it is most of the allocations of a request
+	n, err := queryInt(query, "n", 30)
+	if err != nil || n < 1 || n > maxServeWords {
+		http.Error(w, "n must be a number of words from 1 to "+strconv.Itoa(maxServeWords), http.StatusBadRequest)
+		return
+	}
+	seed, err := queryInt(query, "seed", 0)
+	if err != nil {
+		http.Error(w, "seed must be a number", http.StatusBadRequest)
+		return
+	}
+	annotate, err := queryInt(query, "annotate", 0)
+	if err != nil {
+		http.Error(w, "annotate must be 0 or 1", http.StatusBadRequest)
+		return
+	}
+	prompt, hasPrompt := query["prompt"]
+	if hasPrompt && annotate != 0 {
+		http.Error(w, "prompt and annotate cannot be combined", http.StatusBadRequest)
+		return
+	}
+	opts := markov.GenerateOptions{}
+	for _, o := range []struct {
+		name string
+		set  *bool
+	}{{"sentence_start", &opts.SentenceStart}, {"backoff", &opts.Backoff}} {
+		v, err := queryInt(query, o.name, 0)
+		if err != nil {
+			http.Error(w, o.name+" must be 0 or 1", http.StatusBadRequest)
+			return
+		}
+		*o.set = v != 0
+	}
+	if err := c.Preflight(opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if seed != 0 {
+		opts.Rand = rand.New(rand.NewSource(int64(seed)))
+	}
+	s.requests.Add(1)
+	buf := responseBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledResponse {
+			responseBuffers.Put(buf)
+		}
+	}()
+	switch {
+	case hasPrompt:
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(buf, continuePrompt(c, strings.Fields(strings.Join(prompt, " ")), n, opts))
+	case annotate != 0:
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(buf, markov.AnnotatedList{Tokens: c.GenerateAnnotated(n, opts)})
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		markov.WriteText(buf, c.GenerateWords(n, opts))
+	}
+	// Rendered in full, the answer goes out with its length rather than
+	// chunked.
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// responseBuffers holds the buffers /generate renders its answers in, so
+// that a busy server does not grow a new one for every request. Every
+// buffer is reset before it is used again.
+var responseBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledResponse is the capacity above which a response buffer is
+// dropped instead of pooled, so that one long answer does not stay
+// allocated for good.
+const maxPooledResponse = 64 << 10
+
+// promptResult is the answer of /generate?prompt=text: the words
+// generated after the prompt, and how the prompt was followed. Context is
+// the end of the prompt the words were drawn from, see
+// markov.Session.Feed.
+type promptResult struct {
+	Text    string   `json:"text"`
+	Context []string `json:"context"`
+	markov.FeedResult
+}
+
+// continuePrompt feeds every word of prompt through a session of c and
+// generates at most n words from where it ends. A prompt ending in a
+// context c does not know continues with nothing.
+func continuePrompt(c *markov.Chain, prompt []string, n int, opts markov.GenerateOptions) promptResult {
+	s := c.NewSession(opts)
+	feed := s.Feed(prompt)
+	res := promptResult{Context: prompt[feed.ContextStart:], FeedResult: feed}
+	if out, err := s.Continue(n); err == nil {
+		res.Text = out.Text
+	}
+	return res
+}
+
+// queryInt returns the query parameter name as a number, or def if it is
+// not set.
+func queryInt(query url.Values, name string, def int) (int, error) {
+	v := query.Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// trainResult is the answer of /train.
+type trainResult struct {
+	Tokens int `json:"tokens"`
+}
+
+// handleTrain answers POST /train by adding the text of the request body
+// to the model as one more document, see markov.Chain.AddText.
+func (s *server) handleTrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := s.readyChain(w)
+	if !ok {
+		return
+	}
+	report, err := c.BuildReader(http.MaxBytesReader(w, r.Body, maxTrainBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, trainResult{report.Tokens})
+}
+
+// saveResult is the answer of /save: what was written where, with the hash
+// of the model file, see markov.Chain.Hash.
+type saveResult struct {
+	Path          string `json:"path"`
+	Hash          string `json:"hash"`
+	Prefixes      int    `json:"prefixes"`
+	SuffixEntries int    `json:"suffix_entries"`
+}
+
+// saveCall is a save of a path that requests wait for together.
+type saveCall struct {
+	done chan struct{}
+	res  saveResult
+	err  error
+}
+
+// handleSave answers POST /save by writing a snapshot of the model to the
+// model file, or to the file path names within the -save-dir directory,
+// while generation and training go on. Saves to the same path coalesce:
+// a request waits for the save that takes the next snapshot, shared by
+// every request arriving before it is taken, rather than queueing a save
+// of its own.
+func (s *server) handleSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, ok := s.readyChain(w)
+	if !ok {
+		return
+	}
+	path, err := s.savePath(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := s.save(c, path)
+	if err != nil {
+		s.log.Printf("saving %s failed: %v", path, err)
+		http.Error(w, "saving failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, res)
+}
+
+// savePath returns the file /save writes for its path parameter name: the
+// model file if name is empty, otherwise name within the -save-dir
+// directory, which it must not leave.
+func (s *server) savePath(name string) (string, error) {
+	if name == "" {
+		if s.modelPath == "" {
+			return "", errors.New("the model was downloaded: give a path to save it to")
+		}
+		return s.modelPath, nil
+	}
+	if s.saveDir == "" {
+		return "", errors.New("saving to a path needs -save-dir")
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("path %q is not within the save directory", name)
+	}
+	return filepath.Join(s.saveDir, name), nil
+}
+
+// save writes a snapshot of c to path, or waits for a save of path that
+// has not taken its snapshot yet and returns what it wrote.
+func (s *server) save(c *markov.Chain, path string) (saveResult, error) {
+	s.saveMu.Lock()
+	call, waiting := s.pending[path]
+	if !waiting {
+		call = &saveCall{done: make(chan struct{})}
+		s.pending[path] = call
+	}
+	s.saveMu.Unlock()
+	if waiting {
+		<-call.done
+		return call.res, call.err
+	}
+
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.saveMu.Lock()
+	delete(s.pending, path) // later requests may have trained since the snapshot
+	s.saveMu.Unlock()
+	call.res, call.err = writeSnapshot(c, path)
+	close(call.done)
+	return call.res, call.err
+}
+
+// writeSnapshot writes a snapshot of c to path, replacing the file only once
+// it is complete, see writeModel.
+func writeSnapshot(c *markov.Chain, path string) (saveResult, error) {
+	snap, err := c.Snapshot()
+	if err != nil {
+		return saveResult{}, err
+	}
+	if err := writeModel(snap, path); err != nil {
+		return saveResult{}, err
+	}
+	st := snap.Stats()
+	return saveResult{Path: path, Hash: snap.Hash(), Prefixes: st.Prefixes, SuffixEntries: st.SuffixEntries}, nil
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// slowReader hands out the first half of data at once and the rest only
+// once release is closed, like a download stalling halfway.
+type slowReader struct {
+	data    []byte
+	off     int
+	release chan struct{}
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.off >= len(r.data) {
+		return 0, io.EOF
+	}
+	if r.off >= len(r.data)/2 {
+		<-r.release
+	}
+	end := len(r.data)
+	if r.off < len(r.data)/2 {
+		end = len(r.data) / 2
+	}
+	n := copy(p, r.data[r.off:end])
+	r.off += n
+	return n, nil
+}
+
+func (r *slowReader) Close() error { return nil }
+
+// getStatus fetches path from ts and decodes the status it answers with.
+func getStatus(t *testing.T, ts *httptest.Server, path string) (int, serverStatus) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var st serverStatus
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return resp.StatusCode, st
+}
+
+// waitFor polls the status of ts until ok accepts it.
+func waitFor(t *testing.T, ts *httptest.Server, what string, ok func(serverStatus) bool) serverStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, st := getStatus(t, ts, "/status")
+		if ok(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s; status %+v", what, st)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServeStartup(t *testing.T) {
+	var model bytes.Buffer
+	if _, err := markov.TinyModel().WriteTo(&model); err != nil {
+		t.Fatal(err)
+	}
+	in := &slowReader{data: model.Bytes(), release: make(chan struct{})}
+	s := newServer(log.New(io.Discard, "", 0))
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+	go s.load(func() (io.ReadCloser, int64, error) {
+		return in, int64(model.Len()), nil
+	}, markov.FormatText, time.Millisecond)
+
+	st := waitFor(t, ts, "the first half of the model", func(st serverStatus) bool { return st.BytesRead > 0 })
+	if st.State != stateStarting || st.BytesTotal != int64(model.Len()) || st.BytesRead >= st.BytesTotal {
+		t.Errorf("status while loading = %+v, want starting with part of %d bytes read", st, model.Len())
+	}
+	if code, st := getStatus(t, ts, "/healthz"); code != http.StatusOK || st.State != stateStarting {
+		t.Errorf("/healthz while loading = %d %q, want 200 starting", code, st.State)
+	}
+	if code, _ := getStatus(t, ts, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while loading = %d, want 503", code)
+	}
+	resp, err := http.Get(ts.URL + "/generate?n=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("/generate while loading = %d with Retry-After %q, want 503 with a Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	close(in.release)
+	st = waitFor(t, ts, "readiness", func(st serverStatus) bool { return st.State != stateStarting })
+	lines := int64(bytes.Count(model.Bytes(), []byte("\n")))
+	if st.State != stateReady || st.BytesRead != int64(model.Len()) || st.Entries != lines || st.Prefixes == 0 {
+		t.Errorf("status once loaded = %+v, want ready with %d bytes and %d entries read", st, model.Len(), lines)
+	}
+	if code, _ := getStatus(t, ts, "/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz once loaded = %d, want 200", code)
+	}
+	resp, err = http.Get(ts.URL + "/generate?n=5&seed=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(strings.Fields(string(text))) == 0 {
+		t.Errorf("/generate once loaded = %d %q, want 200 with text", resp.StatusCode, text)
+	}
+}
+
+func TestServeLoadFails(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+	}{
+		{"truncated", "GOMARK v4 prefix=2\n\"\" \"\" the"},
+		{"empty", "GOMARK v3 prefix=2\n"},
+	}
+	for _, tt := range tests {
+		s := newServer(log.New(io.Discard, "", 0))
+		s.load(func() (io.ReadCloser, int64, error) {
+			return io.NopCloser(strings.NewReader(tt.model)), int64(len(tt.model)), nil
+		}, markov.FormatText, time.Hour)
+		ts := httptest.NewServer(s.handler())
+		if code, st := getStatus(t, ts, "/readyz"); code != http.StatusServiceUnavailable || st.State != stateFailed || st.Error == "" {
+			t.Errorf("%s: /readyz = %d %+v, want 503 failed with an error", tt.name, code, st)
+		}
+		resp, err := http.Get(ts.URL + "/generate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "" {
+			t.Errorf("%s: /generate = %d with Retry-After %q, want 503 without", tt.name, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+		ts.Close()
+	}
+}
+
+// TestServeModelLifecycle loads a model, replaces it with another and
+// closes the server, checking the hash /status reports of the model held,
+// and that a load finishing after the close leaves the server closed.
+func TestServeModelLifecycle(t *testing.T) {
+	opener := func(c *markov.Chain) modelOpener {
+		var model bytes.Buffer
+		if _, err := c.WriteTo(&model); err != nil {
+			t.Fatal(err)
+		}
+		return func() (io.ReadCloser, int64, error) {
+			return io.NopCloser(bytes.NewReader(model.Bytes())), int64(model.Len()), nil
+		}
+	}
+	other, err := markov.NewChain(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.AddText(strings.NewReader(goldenCorpus)); err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(log.New(io.Discard, "", 0))
+	s.source = "tiny.txt"
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	s.load(opener(markov.TinyModel()), markov.FormatText, time.Hour)
+	if _, st := getStatus(t, ts, "/status"); st.State != stateReady || st.Hash != markov.TinyModelHash {
+		t.Errorf("/status after loading TinyModel = %+v, want ready with hash %s", st, markov.TinyModelHash)
+	}
+	if got := s.model.Origin(); got.Path != "tiny.txt" || got.Format != markov.FormatText || got.Size <= 0 {
+		t.Errorf("Origin = %+v, want tiny.txt as text of some size", got)
+	}
+	s.load(opener(other), markov.FormatText, time.Hour)
+	if _, st := getStatus(t, ts, "/status"); st.Hash != other.Hash() {
+		t.Errorf("/status after replacing the model = %+v, want hash %s", st, other.Hash())
+	}
+
+	if err := s.close(); err != nil {
+		t.Fatal(err)
+	}
+	s.load(opener(markov.TinyModel()), markov.FormatText, time.Hour)
+	if _, st := getStatus(t, ts, "/status"); st.State != stateClosed || st.Hash != "" || s.model != nil {
+		t.Errorf("/status after close = %+v, want closed without a model", st)
+	}
+	resp, err := http.Get(ts.URL + "/generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/generate after close = %d, want 503", resp.StatusCode)
+	}
+	if err := s.close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
+
+// TestServeAnnotate checks that /generate?annotate=1 answers with the
+// tokens and annotations generate -output-format annotated-json prints.
+func TestServeAnnotate(t *testing.T) {
+	var model bytes.Buffer
+	if _, err := markov.TinyModel().WriteTo(&model); err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(log.New(io.Discard, "", 0))
+	s.load(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(model.Bytes())), int64(model.Len()), nil
+	}, markov.FormatText, time.Hour)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/generate?n=8&seed=1&annotate=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got markov.AnnotatedList
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := markov.TinyModel().GenerateAnnotated(8, markov.GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" || !reflect.DeepEqual(got.Tokens, want) {
+		t.Errorf("/generate?annotate=1 = %d %s %+v, want 200 JSON %+v", resp.StatusCode, resp.Header.Get("Content-Type"), got.Tokens, want)
+	}
+
+	resp, err = http.Get(ts.URL + "/generate?annotate=yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("/generate?annotate=yes = %d, want 400", resp.StatusCode)
+	}
+}
+
+// TestServePreflight checks that /generate refuses options the model has
+// no data for, naming them, and serves those it has.
+func TestServePreflight(t *testing.T) {
+	var model bytes.Buffer
+	if _, err := markov.TinyModel().WriteTo(&model); err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(log.New(io.Discard, "", 0))
+	s.load(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(model.Bytes())), int64(model.Len()), nil
+	}, markov.FormatText, time.Hour)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		query string
+		code  int
+		want  string // in the answer
+	}{
+		{"backoff=1", http.StatusOK, "the"},
+		{"sentence_start=1", http.StatusBadRequest, "-sentence-start needs"},
+		{"sentence_start=1&backoff=1", http.StatusBadRequest, "read -sentence-starts"},
+		{"backoff=yes", http.StatusBadRequest, "backoff must be 0 or 1"},
+	} {
+		resp, err := http.Get(ts.URL + "/generate?n=5&" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.code || !strings.Contains(string(body), tt.want) {
+			t.Errorf("/generate?%s = %d %q, want %d with %q", tt.query, resp.StatusCode, body, tt.code, tt.want)
+		}
+	}
+}
+
+// TestServePrompt continues long prompts of which only some words are
+// known to the model, and checks the answer tells which end of the prompt
+// the text follows.
+func TestServePrompt(t *testing.T) {
+	var model bytes.Buffer
+	if _, err := markov.TinyModel().WriteTo(&model); err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(log.New(io.Discard, "", 0))
+	s.load(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(model.Bytes())), int64(model.Len()), nil
+	}, markov.FormatText, time.Hour)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		prompt string
+		want   promptResult
+	}{
+		{"the cat", promptResult{"ran.", []string{"the", "cat"}, markov.FeedResult{ContextStart: 0, Known: true, Unknown: 0}}},
+		{"I pasted a long paragraph in which the dog sat on the", promptResult{"cat. the cat ran.", []string{"on", "the"}, markov.FeedResult{ContextStart: 10, Known: true, Unknown: 8}}},
+		{"the cat sat on a zebra and then on the", promptResult{"cat. the cat ran.", []string{"on", "the"}, markov.FeedResult{ContextStart: 8, Known: true, Unknown: 5}}},
+		{"the cat sat on a zebra", promptResult{"", []string{"a", "zebra"}, markov.FeedResult{ContextStart: 4, Known: false, Unknown: 1}}},
+	} {
+		resp, err := http.Get(ts.URL + "/generate?n=5&seed=1&prompt=" + url.QueryEscape(tt.prompt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got promptResult
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("/generate?prompt=%s = %d %+v, want 200 %+v", tt.prompt, resp.StatusCode, got, tt.want)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/generate?prompt=the&annotate=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("/generate?prompt=the&annotate=1 = %d, want 400", resp.StatusCode)
+	}
+}
+
+// readyServer returns a server of TinyModel, ready to answer.
+func readyServer() *server {
+	var model bytes.Buffer
+	markov.TinyModel().WriteTo(&model)
+	s := newServer(log.New(io.Discard, "", 0))
+	s.load(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(model.Bytes())), int64(model.Len()), nil
+	}, markov.FormatText, time.Hour)
+	return s
+}
+
+// TestServeResponsesIsolated sends long and short requests at once and
+// checks that every answer is exactly the text of its own request, with
+// nothing of an answer rendered before in the same pooled buffer.
+func TestServeResponsesIsolated(t *testing.T) {
+	s := readyServer()
+	h := s.handler()
+	want := func(n, seed int, annotate bool) string {
+		var b bytes.Buffer
+		opts := markov.GenerateOptions{Rand: rand.New(rand.NewSource(int64(seed)))}
+		if annotate {
+			writeJSON(&b, markov.AnnotatedList{Tokens: markov.TinyModel().GenerateAnnotated(n, opts)})
+		} else {
+			markov.WriteText(&b, markov.TinyModel().GenerateWords(n, opts))
+		}
+		return b.String()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		n, seed, annotate := 1+i%3*40, 1+i%7, i%5 == 0
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			target := fmt.Sprintf("/generate?n=%d&seed=%d", n, seed)
+			if annotate {
+				target += "&annotate=1"
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			if got := rec.Body.String(); got != want(n, seed, annotate) {
+				t.Errorf("%s answered %q, want %q", target, got, want(n, seed, annotate))
+			}
+		}()
+	}
+	wg.Wait()
+	if st := s.status(); st.Requests != 200 || st.AllocsPerRequest <= 0 {
+		t.Errorf("status reports %d requests and %v allocations per request, want 200 and some", st.Requests, st.AllocsPerRequest)
+	}
+}
+
+// BenchmarkServeGenerate reports the allocations of a /generate request,
+// as /status does under load.
+func BenchmarkServeGenerate(b *testing.B) {
+	h := readyServer().handler()
+	req := httptest.NewRequest(http.MethodGet, "/generate?n=30&seed=1", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestBudgetReader(t *testing.T) {
+	data := strings.Repeat("x", 100)
+	for _, tt := range []struct {
+		max  int64
+		fail bool
+	}{{99, true}, {100, false}, {1000, false}} {
+		r := &budgetReader{ReadCloser: io.NopCloser(smallReads{strings.NewReader(data)}), name: "m", max: tt.max, left: tt.max}
+		got, err := io.ReadAll(r)
+		if tt.fail {
+			if err == nil || !strings.Contains(err.Error(), "budget") {
+				t.Errorf("budget %d: err = %v, want over the budget", tt.max, err)
+			}
+			continue
+		}
+		if err != nil || string(got) != data {
+			t.Errorf("budget %d: read %d bytes, err %v; want all of them", tt.max, len(got), err)
+		}
+	}
+}
+
+// smallReads reads at most 7 bytes at a time from r, so that a limit can
+// fall in the middle of a read.
+type smallReads struct{ r io.Reader }
+
+func (r smallReads) Read(p []byte) (int, error) {
+	if len(p) > 7 {
+		p = p[:7]
+	}
+	return r.r.Read(p)
+}
+
+// postSave asks ts to save the model to path, the model file if empty.
+func postSave(ts *httptest.Server, path string) (saveResult, error) {
+	resp, err := http.Post(ts.URL+"/save?path="+path, "", nil)
+	if err != nil {
+		return saveResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return saveResult{}, fmt.Errorf("/save?path=%s: %s: %s", path, resp.Status, msg)
+	}
+	var res saveResult
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return res, err
+}
+
+func TestServeTrainAndSave(t *testing.T) {
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.txt")
+	if err := markov.TinyModel().WriteFreTable(model); err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(log.New(io.Discard, "", 0))
+	s.train, s.modelPath, s.saveDir = true, model, dir
+	s.load(func() (io.ReadCloser, int64, error) {
+		return openModelSource(model, 0)
+	}, markov.FormatText, time.Hour)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	saved := make(chan saveResult, 100)
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				resp, err := http.Get(ts.URL + "/generate?n=20")
+				if err != nil {
+					errs <- err
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					errs <- fmt.Errorf("/generate: %s", resp.Status)
+				}
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				text := fmt.Sprintf("the cat saw bird%d and bird%d ran.", i, j)
+				resp, err := http.Post(ts.URL+"/train", "text/plain", strings.NewReader(text))
+				if err != nil {
+					errs <- err
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					errs <- fmt.Errorf("/train: %s", resp.Status)
+				}
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				path := ""
+				if j > 0 {
+					path = fmt.Sprintf("save-%d-%d.txt", i, j)
+				}
+				res, err := postSave(ts, path)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if path != "" {
+					saved <- res
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	close(saved)
+	for err := range errs {
+		t.Error(err)
+	}
+	for res := range saved {
+		c, err := markov.ReadFreTable(res.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := c.Hash(); h != res.Hash {
+			t.Errorf("%s hashes to %s, /save answered %s", res.Path, h, res.Hash)
+		}
+		if st := c.Stats(); st.Prefixes != res.Prefixes || st.SuffixEntries != res.SuffixEntries {
+			t.Errorf("%s has %d prefixes and %d suffix entries, /save answered %+v", res.Path, st.Prefixes, st.SuffixEntries, res)
+		}
+	}
+
+	// Once training stops, a save writes the chain being served.
+	res, err := postSave(ts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := s.ready()
+	if res.Path != model || res.Hash != c.Hash() {
+		t.Errorf("/save = %+v, want %s with hash %s", res, model, c.Hash())
+	}
+	if c.Hash() == markov.TinyModelHash {
+		t.Error("training did not change the model")
+	}
+}
+
+func TestServeSavePath(t *testing.T) {
+	s := &server{saveDir: "/models"}
+	for _, name := range []string{"../etc/passwd", "/tmp/x.txt", "a/../../x.txt"} {
+		if path, err := s.savePath(name); err == nil {
+			t.Errorf("savePath(%q) = %s, want an error", name, path)
+		}
+	}
+	if path, err := s.savePath("a/model.txt"); err != nil || path != "/models/a/model.txt" {
+		t.Errorf("savePath(a/model.txt) = %s, %v; want /models/a/model.txt", path, err)
+	}
+	if _, err := s.savePath(""); err == nil {
+		t.Error("savePath of a downloaded model succeeded, want an error")
+	}
+}
+
+// TestServeModelBudget loads models over and within -max-model-bytes: a
+// text model on disk is refused before it is opened, another once read,
+// and /healthz and /status report the estimate either way.
+func TestServeModelBudget(t *testing.T) {
+	c := markov.TinyModel()
+	file := filepath.Join(t.TempDir(), "tiny.txt")
+	if err := c.WriteFreTable(file); err != nil {
+		t.Fatal(err)
+	}
+	fileEstimate, err := markov.EstimateModelFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := c.EstimateMemory()
+	open := func() (io.ReadCloser, int64, error) { return openModelSource(file, 0) }
+	refuse := func() (io.ReadCloser, int64, error) {
+		t.Error("the model was opened although its estimate is over the budget")
+		return nil, 0, errors.New("opened")
+	}
+	for _, tt := range []struct {
+		name      string
+		onDisk    bool
+		max       int64
+		open      modelOpener
+		state     string
+		estimated int64
+	}{
+		{"on disk, over", true, fileEstimate - 1, refuse, stateFailed, fileEstimate},
+		{"stream, over", false, loaded - 1, open, stateFailed, loaded},
+		{"on disk, within", true, fileEstimate + loaded, open, stateReady, loaded},
+		{"no limit", false, 0, open, stateReady, loaded},
+	} {
+		s := newServer(log.New(io.Discard, "", 0))
+		s.maxModelBytes = tt.max
+		if tt.onDisk {
+			s.modelPath = file
+		}
+		s.load(tt.open, markov.FormatText, time.Hour)
+		ts := httptest.NewServer(s.handler())
+		for _, path := range []string{"/healthz", "/status"} {
+			_, st := getStatus(t, ts, path)
+			if st.State != tt.state || st.EstimatedBytes != tt.estimated {
+				t.Errorf("%s: %s = %+v, want %s with an estimate of %d bytes", tt.name, path, st, tt.state, tt.estimated)
+			}
+			if tt.state == stateFailed && !strings.Contains(st.Error, "-max-model-bytes") {
+				t.Errorf("%s: %s gives the error %q, want the budget named", tt.name, path, st.Error)
+			}
+		}
+		ts.Close()
+	}
+
+	if err := serveCmd([]string{"-max-model-bytes", "-1", file}); !errors.As(err, new(*UsageError)) {
+		t.Errorf("serve -max-model-bytes -1: %v, want a usage error", err)
+	}
+}
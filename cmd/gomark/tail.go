@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// tailCmd implements "tail [-prefix n] [-poll d] [-save-interval d] log model".
+func tailCmd(args []string) error {
+	fs := newFlagSet("tail")
+	prefixLen := fs.Int("prefix", 2, "words per prefix of a new model")
+	poll := fs.Duration("poll", time.Second, "how often to look for new lines")
+	saveEvery := fs.Duration("save-interval", time.Minute, "how often to save the model while lines come in")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usagef("tail needs a log file and a model file.")
+	}
+	if *poll <= 0 || *saveEvery <= 0 {
+		return usagef("-poll and -save-interval must be positive.")
+	}
+	t, err := openTailer(args[0], args[1], *prefixLen)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "following %s from byte %d\n", t.log, t.offset)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	lastSave := time.Now()
+	dirty := false
+	for {
+		n, err := t.ingest()
+		if err != nil {
+			return err
+		}
+		dirty = dirty || n > 0
+		if dirty && time.Since(lastSave) >= *saveEvery {
+			if err := t.save(); err != nil {
+				return err
+			}
+			lastSave, dirty = time.Now(), false
+		}
+		select {
+		case <-stop:
+			if !dirty {
+				return nil
+			}
+			return t.save()
+		case <-time.After(*poll):
+		}
+	}
+}
+
+// tailer trains a model on the lines of a log as they are appended to it,
+// every line one more document of the model, see markov.Chain.AddText.
+//
+// Where it is in the log is saved in the model, see markov.Chain.SetOffset,
+// so that the model file always holds the lines before its offset exactly
+// once: a tailer restarted after dying reads the lines after the offset of
+// the model file again, those it had added but not saved. The sidecar file
+// model.offset only tells other programs the offset of the last save; it
+// may fall behind the model when the tailer dies between writing the two,
+// and is never read back.
+type tailer struct {
+	log, model string
+	c          *markov.Chain
+	offset     int64 // of the first byte of the log not added to c
+}
+
+// openTailer returns a tailer of the named log training the model file,
+// which it creates with prefixLen words per prefix if it does not exist.
+func openTailer(log, model string, prefixLen int) (*tailer, error) {
+	c, err := warnChecksum(markov.ReadFreTable(model))
+	if errors.Is(err, fs.ErrNotExist) {
+		c, err = markov.NewChain(prefixLen)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := &tailer{log: log, model: model, c: c}
+	t.offset, _ = c.Offset(log)
+	if side, err := t.readSidecar(); err == nil && side != t.offset {
+		fmt.Fprintf(os.Stderr, "warning: %s says byte %d, the model byte %d; going on from the model\n", t.sidecar(), side, t.offset)
+	}
+	return t, nil
+}
+
+// sidecar names the file the offset of the last save is written to.
+func (t *tailer) sidecar() string { return t.model + ".offset" }
+
+// readSidecar returns the offset in the sidecar file.
+func (t *tailer) readSidecar() (int64, error) {
+	data, err := os.ReadFile(t.sidecar())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// ingest adds the complete lines appended to the log since the last call
+// to the model and returns how many there were. A last line without its
+// newline is left for a later call, as it may still be being written. A
+// log shorter than the offset was rotated and is read from its start.
+func (t *tailer) ingest() (int, error) {
+	f, err := os.Open(t.log)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil // rotated away; the new log is not there yet
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() < t.offset {
+		fmt.Fprintf(os.Stderr, "%s shrank below byte %d: reading it from the start\n", t.log, t.offset)
+		t.offset = 0
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	br := bufio.NewReader(f)
+	n := 0
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := t.c.AddText(strings.NewReader(line)); err != nil {
+			return n, err
+		}
+		t.offset += int64(len(line))
+		t.c.SetOffset(t.log, t.offset)
+		n++
+	}
+	return n, nil
+}
+
+// save writes the model with its offset, then the sidecar.
+func (t *tailer) save() error {
+	if err := writeModel(t.c, t.model); err != nil {
+		return err
+	}
+	return t.writeSidecar()
+}
+
+// writeSidecar writes the offset of the model to the sidecar file.
+func (t *tailer) writeSidecar() error {
+	tmp := t.sidecar() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(t.offset, 10)+"\n"), 0o666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.sidecar())
+}
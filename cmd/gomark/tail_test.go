@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tailLog is a log of lines, some of them repeated.
+var tailLog = func() []string {
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("user%d logged in from host%d.\n", i%7, i%3))
+	}
+	return lines
+}()
+
+// appendLines appends lines to the named log.
+func appendLines(t *testing.T, name string, lines []string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strings.Join(lines, "")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cleanTail returns the hash of the model of a tailer reading the whole
+// log in one go and saving it to model once.
+func cleanTail(t *testing.T, log, model string) string {
+	t.Helper()
+	tl, err := openTailer(log, model, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tl.ingest(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tl.save(); err != nil {
+		t.Fatal(err)
+	}
+	return tl.c.Hash()
+}
+
+// TestTailCrashes kills the tailer, by dropping it, at every point of its
+// cycle and checks that restarting it gives the model a clean single pass
+// over the log gives.
+func TestTailCrashes(t *testing.T) {
+	crashes := []struct {
+		name  string
+		crash func(t *testing.T, tl *tailer)
+	}{
+		{"after ingest, before save", func(t *testing.T, tl *tailer) {}},
+		{"after save, before sidecar", func(t *testing.T, tl *tailer) {
+			if err := writeModel(tl.c, tl.model); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"after sidecar", func(t *testing.T, tl *tailer) {
+			if err := tl.save(); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"with a sidecar ahead of the model", func(t *testing.T, tl *tailer) {
+			tl.offset += 1000
+			if err := tl.writeSidecar(); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range crashes {
+		dir := t.TempDir()
+		log, model := filepath.Join(dir, "app.log"), filepath.Join(dir, "model.txt")
+		// A first run saves part of the log, reads some more and dies.
+		appendLines(t, log, tailLog[:10])
+		tl, err := openTailer(log, model, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tl.ingest(); err != nil {
+			t.Fatal(err)
+		}
+		if err := tl.save(); err != nil {
+			t.Fatal(err)
+		}
+		appendLines(t, log, tailLog[10:20])
+		if _, err := tl.ingest(); err != nil {
+			t.Fatal(err)
+		}
+		tt.crash(t, tl)
+
+		// The restarted tailer goes on from the saved model.
+		appendLines(t, log, tailLog[20:])
+		tl, err = openTailer(log, model, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tl.ingest(); err != nil {
+			t.Fatal(err)
+		}
+		if err := tl.save(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := tl.c.Hash(), cleanTail(t, log, filepath.Join(dir, "clean.txt")); got != want {
+			t.Errorf("crash %s: model hashes to %s, want %s as after a single pass", tt.name, got, want)
+		}
+		if side, err := tl.readSidecar(); err != nil || side != tl.offset {
+			t.Errorf("crash %s: sidecar says %d, %v; want %d", tt.name, side, err, tl.offset)
+		}
+	}
+}
+
+func TestTailPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "app.log")
+	appendLines(t, log, []string{"a whole line.\n", "half a"})
+	tl, err := openTailer(log, filepath.Join(dir, "model.txt"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := tl.ingest(); n != 1 || err != nil || tl.offset != int64(len("a whole line.\n")) {
+		t.Fatalf("ingest = %d, %v at byte %d; want the whole line only", n, err, tl.offset)
+	}
+	appendLines(t, log, []string{" line.\n"})
+	if n, err := tl.ingest(); n != 1 || err != nil {
+		t.Fatalf("ingest = %d, %v; want the finished line", n, err)
+	}
+	if got := tl.c.Suffixes([]string{"half"}); len(got) != 1 || got[0].Word() != "a" {
+		t.Errorf("suffixes of half = %v, want the line read whole", got)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+// TestValidateCollision validates a model written before "<url>" was
+// reserved, from a corpus that contains it.
+func TestValidateCollision(t *testing.T) {
+	model := filepath.Join("..", "..", "markov", "testdata", "reserved", "legacy-url.model")
+	var err error
+	out := capture(t, &os.Stdout, func() error {
+		capture(t, &os.Stderr, func() error {
+			err = validateCmd([]string{model})
+			return nil
+		})
+		return nil
+	})
+	if !errors.Is(err, ErrInvalidModel) {
+		t.Errorf("validate: %v, want ErrInvalidModel", err)
+	}
+	if !strings.Contains(out, `the corpus word "<url>" collides with the reserved url token`) {
+		t.Errorf("validate printed\n%s\nwant the collision of <url>", out)
+	}
+
+	capture(t, &os.Stderr, func() error {
+		out = capture(t, &os.Stdout, func() error { return sentinelsCmd([]string{model}) })
+		return nil
+	})
+	if !strings.Contains(out, "<url>") {
+		t.Errorf("sentinels printed\n%s\nwant <url>", out)
+	}
+}
+
+// TestValidateStreamChecksum validates a model cut short at a line
+// boundary and one changed since it was written, with and without
+// -stream: both must be rejected either way.
+func TestValidateStreamChecksum(t *testing.T) {
+	dir := t.TempDir()
+	model := filepath.Join(dir, "m.txt")
+	if err := markov.TinyModel().WriteFreTable(model); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	for name, text := range map[string]string{
+		"truncated": strings.Join(lines[:8], ""),
+		"corrupted": strings.Replace(string(data), "cat sat on 1 \n", "cat sat on 2 \n", 1),
+	} {
+		path := filepath.Join(dir, name+".txt")
+		if err := os.WriteFile(path, []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{{path}, {"-stream", path}} {
+			var err error
+			capture(t, &os.Stderr, func() error {
+				capture(t, &os.Stdout, func() error {
+					err = validateCmd(args)
+					return nil
+				})
+				return nil
+			})
+			if err == nil {
+				t.Errorf("validate %s accepted the %s model", strings.Join(args, " "), name)
+			}
+		}
+	}
+
+	old := filepath.Join("..", "..", "markov", "testdata", "versions", "v3.model")
+	var verr error
+	warnings := capture(t, &os.Stderr, func() error {
+		capture(t, &os.Stdout, func() error {
+			verr = validateCmd([]string{"-stream", old})
+			return nil
+		})
+		return nil
+	})
+	if verr != nil || !strings.Contains(warnings, "no checksum") {
+		t.Errorf("validate -stream %s: %v, warned %q; want it accepted with a warning", old, verr, warnings)
+	}
+}
@@ -0,0 +1,44 @@
+// Package examples holds small programs built on the markov package; this
+// file only checks that they stay what they are meant to be.
+package examples
+
+import (
+	"go/build"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestExamplesUseOnlyPublicAPI checks that every example is a command
+// that imports nothing of this module but the markov package, so that it
+// shows what a program outside the module can do. Compiling them, which
+// go vet and go test do, checks that the API they use still exists.
+func TestExamplesUseOnlyPublicAPI(t *testing.T) {
+	dirs, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		pkg, err := build.ImportDir(d.Name(), 0)
+		if err != nil {
+			t.Errorf("%s: %v", d.Name(), err)
+			continue
+		}
+		found++
+		if !pkg.IsCommand() {
+			t.Errorf("%s is package %s, want a command", d.Name(), pkg.Name)
+		}
+		for _, imp := range pkg.Imports {
+			if strings.HasPrefix(imp, "github.com/xiaoxulv/go_mark/") && imp != "github.com/xiaoxulv/go_mark/markov" {
+				t.Errorf("%s imports %s", d.Name(), imp)
+			}
+		}
+	}
+	if found < 2 {
+		t.Errorf("found %d examples, want the trainer and the HTTP bot", found)
+	}
+}
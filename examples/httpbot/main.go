@@ -0,0 +1,96 @@
+// Command httpbot is a tiny HTTP bot built on the markov package: it
+// answers every GET /say with a text generated from its model.
+//
+//	httpbot [-addr :8080] [-model model.txt]
+//
+// Without -model it trains a model on an excerpt of The Legend of Sleepy
+// Hollow embedded in the program. /say takes the number of words in the
+// query parameter n, 30 by default, and a seed in seed for a reproducible
+// text; /say?seed=1 always says the same.
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+//go:embed demo.txt
+var demo string
+
+// maxWords bounds the n of a request.
+const maxWords = 1000
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	model := flag.String("model", "", "model file to serve (default: train on the demo text)")
+	flag.Parse()
+
+	c, err := load(*model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/say", sayHandler(c))
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// load reads the named model file, or trains a model on the demo text if
+// name is empty.
+func load(name string) (*markov.Chain, error) {
+	if name != "" {
+		return markov.ReadFreTable(name)
+	}
+	c, err := markov.NewChain(2)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.BuildReader(strings.NewReader(demo)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// sayHandler answers GET /say with a text generated from c. A Chain is
+// safe for concurrent use, so requests need no locking of their own.
+func sayHandler(c *markov.Chain) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		n, err := intParam(r, "n", 30)
+		if err != nil || n < 1 || n > maxWords {
+			http.Error(w, "n must be a number of words from 1 to "+strconv.Itoa(maxWords), http.StatusBadRequest)
+			return
+		}
+		seed, err := intParam(r, "seed", 0)
+		if err != nil {
+			http.Error(w, "seed must be a number", http.StatusBadRequest)
+			return
+		}
+		opts := markov.GenerateOptions{}
+		if seed != 0 {
+			opts.Rand = rand.New(rand.NewSource(int64(seed)))
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		markov.WriteText(w, c.GenerateWords(n, opts))
+	})
+}
+
+// intParam returns the query parameter name of r as a number, or def if
+// it is not set.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
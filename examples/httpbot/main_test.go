@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSay(t *testing.T) {
+	c, err := load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(sayHandler(c))
+	defer srv.Close()
+
+	get := func(query string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/say" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	code, a := get("?n=12&seed=3")
+	if code != http.StatusOK || len(strings.Fields(a)) == 0 || len(strings.Fields(a)) > 12 {
+		t.Errorf("GET ?n=12&seed=3 = %d %q, want at most 12 words", code, a)
+	}
+	if _, b := get("?n=12&seed=3"); b != a {
+		t.Errorf("the same seed said %q, then %q", a, b)
+	}
+	for _, query := range []string{"?n=0", "?n=many", "?n=100000", "?seed=x"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+	resp, err := http.Post(srv.URL+"/say", "text/plain", strings.NewReader("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
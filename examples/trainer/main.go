@@ -0,0 +1,55 @@
+// Command trainer builds a model from text files with the markov package
+// alone, without the gomark command, and writes it as a model file:
+//
+//	trainer [-prefix n] [-o model.txt] [file...]
+//
+// Without files it trains on an excerpt of The Legend of Sleepy Hollow
+// embedded in the program. It prints what the build read and a sample of
+// the text the model generates.
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+//go:embed demo.txt
+var demo string
+
+func main() {
+	prefixLen := flag.Int("prefix", 2, "words per prefix")
+	out := flag.String("o", "model.txt", "model file to write")
+	seed := flag.Int64("seed", 1, "seed of the sample text")
+	flag.Parse()
+
+	c, report, err := train(*prefixLen, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := c.WriteFreTable(*out); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d words, %d prefixes, written to %s\n", report.Tokens, report.Prefixes, *out)
+	fmt.Println(c.GenerateWithRand(rand.New(rand.NewSource(*seed)), 30))
+}
+
+// train builds a chain of prefixLen words from the named files, or from
+// the demo text if there are none.
+func train(prefixLen int, files []string) (*markov.Chain, markov.BuildReport, error) {
+	c, err := markov.NewChain(prefixLen)
+	if err != nil {
+		return nil, markov.BuildReport{}, err
+	}
+	if len(files) == 0 {
+		report, err := c.BuildReaderOpts("demo.txt", strings.NewReader(demo), markov.BuildOptions{})
+		return c, report, err
+	}
+	report, err := c.Build(files) // a *markov.BuildError names the files it could not read
+	return c, report, err
+}
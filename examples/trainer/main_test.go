@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+func TestTrainDemo(t *testing.T) {
+	c, report, err := train(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Tokens != 313 {
+		t.Errorf("demo text has %d words, want 313", report.Tokens)
+	}
+	name := filepath.Join(t.TempDir(), "model.txt")
+	if err := c.WriteFreTable(name); err != nil {
+		t.Fatal(err)
+	}
+	read, err := markov.ReadFreTable(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Hash() != c.Hash() {
+		t.Errorf("the model read back differs from the one written")
+	}
+}
+
+func TestTrainFiles(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "corpus.txt")
+	if err := os.WriteFile(name, []byte("one two three"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, report, err := train(1, []string{name}); err != nil || report.Tokens != 3 {
+		t.Errorf("train = %d tokens, %v; want 3 tokens", report.Tokens, err)
+	}
+	if _, _, err := train(1, []string{name + ".missing"}); err == nil {
+		t.Errorf("train of a missing file succeeded")
+	}
+}
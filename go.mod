@@ -0,0 +1,3 @@
+module github.com/xiaoxulv/go_mark
+
+go 1.21
@@ -0,0 +1,202 @@
+// Package sampletest checks that a sampler draws words with the
+// probabilities it should, by a chi-squared goodness-of-fit test. Tests of
+// the features that change how generation draws, such as temperatures,
+// top-k, rules and smoothing, go through Check rather than counting draws
+// of their own, so that they all fail alike and say why.
+package sampletest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Defaults of Options.
+const (
+	DefaultN     = 10000
+	DefaultAlpha = 0.001
+)
+
+// minExpected is the smallest expected count a word keeps a bin of its own
+// with; rarer words are pooled, as the chi-squared approximation needs.
+const minExpected = 5
+
+// pooled names the bin of the rare words in a Result.
+const pooled = "(rare words)"
+
+// Options says how Check samples and tests. The zero value draws DefaultN
+// words seeded with 1 and fails at significance DefaultAlpha.
+type Options struct {
+	N     int     // words to draw
+	Seed  int64   // of the generator passed to the sampler; 0 means 1
+	Alpha float64 // the chance that a correct sampler fails
+}
+
+// Row is the expected and observed count of a word, or of the rare words
+// pooled together.
+type Row struct {
+	Word     string
+	Expected float64
+	Observed int
+}
+
+// Result is the outcome of a goodness-of-fit test.
+type Result struct {
+	Stat       float64  // the chi-squared statistic
+	DF         int      // its degrees of freedom
+	P          float64  // the chance of a fit this bad or worse from a correct sampler
+	Rows       []Row    // by how far off they are, worst first
+	Unexpected []string // words drawn that should never be, sorted
+}
+
+// Check draws opts.N words with draw, from a generator seeded with
+// opts.Seed, and fails t unless their counts fit want, the probability of
+// every word, by a chi-squared test at significance opts.Alpha. Words want
+// leaves out or gives probability 0 must never be drawn. The failure lists
+// the expected and observed count of every word.
+func Check(t testing.TB, draw func(*rand.Rand) string, want map[string]float64, opts Options) Result {
+	t.Helper()
+	if opts.N == 0 {
+		opts.N = DefaultN
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+	if opts.Alpha == 0 {
+		opts.Alpha = DefaultAlpha
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	observed := make(map[string]int)
+	for i := 0; i < opts.N; i++ {
+		observed[draw(r)]++
+	}
+	res := Fit(observed, want)
+	if len(res.Unexpected) > 0 || res.P < opts.Alpha {
+		t.Errorf("%d draws do not fit the distribution: chi-squared %.2f with %d degrees of freedom, p = %.3g < %g\n%s",
+			opts.N, res.Stat, res.DF, res.P, opts.Alpha, res)
+	}
+	return res
+}
+
+// Fit tests the counts of observed words against want, the probability
+// of every word, which must add up to 1.
+func Fit(observed map[string]int, want map[string]float64) Result {
+	n := 0
+	for _, k := range observed {
+		n += k
+	}
+	var res Result
+	var rows []Row
+	rare := Row{Word: pooled}
+	for w, p := range want {
+		if p <= 0 {
+			continue
+		}
+		row := Row{w, p * float64(n), observed[w]}
+		if row.Expected < minExpected {
+			rare.Expected += row.Expected
+			rare.Observed += row.Observed
+			continue
+		}
+		rows = append(rows, row)
+	}
+	for w := range observed {
+		if want[w] <= 0 {
+			res.Unexpected = append(res.Unexpected, w)
+		}
+	}
+	sort.Strings(res.Unexpected)
+	if rare.Expected > 0 {
+		if rare.Expected < minExpected && len(rows) > 0 {
+			// Still too rare: add them to the least expected word.
+			sort.Slice(rows, func(i, j int) bool { return rows[i].Expected < rows[j].Expected })
+			rows[0] = Row{rows[0].Word + " " + pooled, rows[0].Expected + rare.Expected, rows[0].Observed + rare.Observed}
+		} else {
+			rows = append(rows, rare)
+		}
+	}
+	for _, row := range rows {
+		d := float64(row.Observed) - row.Expected
+		res.Stat += d * d / row.Expected
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		di, dj := deviation(rows[i]), deviation(rows[j])
+		if di != dj {
+			return di > dj
+		}
+		return rows[i].Word < rows[j].Word
+	})
+	res.Rows = rows
+	res.DF = len(rows) - 1
+	res.P = 1
+	if res.DF > 0 {
+		res.P = chiSquaredSurvival(res.Stat, res.DF)
+	}
+	return res
+}
+
+// deviation is how many standard deviations row is off.
+func deviation(row Row) float64 {
+	return math.Abs(float64(row.Observed)-row.Expected) / math.Sqrt(row.Expected)
+}
+
+// String lists the rows of res as a table, and the unexpected words.
+func (res Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-20s %10s %10s %8s\n", "word", "expected", "observed", "sigma")
+	for _, row := range res.Rows {
+		fmt.Fprintf(&b, "%-20q %10.1f %10d %8.2f\n", row.Word, row.Expected, row.Observed, deviation(row))
+	}
+	if len(res.Unexpected) > 0 {
+		fmt.Fprintf(&b, "drawn but never expected: %q\n", res.Unexpected)
+	}
+	return b.String()
+}
+
+// chiSquaredSurvival returns the chance that a chi-squared variable of df
+// degrees of freedom is at least x.
+func chiSquaredSurvival(x float64, df int) float64 {
+	return gammaQ(float64(df)/2, x/2)
+}
+
+// gammaQ is the regularized upper incomplete gamma function Q(a, x),
+// computed by its series for small x and its continued fraction otherwise.
+func gammaQ(a, x float64) float64 {
+	const eps, tiny = 1e-14, 1e-300
+	if x <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	scale := math.Exp(-x + a*math.Log(x) - lg)
+	if x < a+1 {
+		sum, del := 1/a, 1/a
+		for ap := a + 1; math.Abs(del) > math.Abs(sum)*eps; ap++ {
+			del *= x / ap
+			sum += del
+		}
+		return 1 - sum*scale
+	}
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1.0; i < 1000; i++ {
+		an := -i * (i - a)
+		b += 2
+		if d = an*d + b; math.Abs(d) < tiny {
+			d = tiny
+		}
+		if c = b + an/c; math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return scale * h
+}
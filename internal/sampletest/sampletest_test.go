@@ -0,0 +1,88 @@
+package sampletest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestChiSquaredSurvival(t *testing.T) {
+	// Critical values of the chi-squared distribution from statistical tables.
+	tests := []struct {
+		x  float64
+		df int
+		p  float64
+	}{
+		{3.841, 1, 0.05},
+		{6.635, 1, 0.01},
+		{5.991, 2, 0.05},
+		{18.307, 10, 0.05},
+		{9.342, 10, 0.5},
+		{124.342, 100, 0.05},
+		{0, 3, 1},
+	}
+	for _, tt := range tests {
+		if got := chiSquaredSurvival(tt.x, tt.df); math.Abs(got-tt.p) > 1e-3 {
+			t.Errorf("P(X >= %v) for %d degrees of freedom = %.5f, want %v", tt.x, tt.df, got, tt.p)
+		}
+	}
+}
+
+// weighted returns a sampler drawing the words with the given weights.
+func weighted(words []string, weights []float64) func(*rand.Rand) string {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	return func(r *rand.Rand) string {
+		x := r.Float64() * total
+		for i, w := range weights {
+			if x < w {
+				return words[i]
+			}
+			x -= w
+		}
+		return words[len(words)-1]
+	}
+}
+
+func TestCheck(t *testing.T) {
+	words := []string{"a", "b", "c", "rare1", "rare2"}
+	want := map[string]float64{"a": 0.5, "b": 0.3, "c": 0.1996, "rare1": 0.0002, "rare2": 0.0002}
+	res := Check(t, weighted(words, []float64{0.5, 0.3, 0.1996, 0.0002, 0.0002}), want, Options{})
+	if res.DF != 2 {
+		t.Errorf("rare words were not pooled: %d degrees of freedom, want 2\n%s", res.DF, res)
+	}
+
+	// A biased sampler and one drawing a word it should not must fail,
+	// saying what went wrong.
+	for _, tt := range []struct {
+		name    string
+		weights []float64
+		report  string
+	}{
+		{"biased", []float64{0.45, 0.35, 0.2, 0, 0}, `"a"`},
+		{"unexpected", []float64{0.5, 0.3, 0.19, 0, 0.01}, "never expected"},
+	} {
+		want := map[string]float64{"a": 0.5, "b": 0.3, "c": 0.2}
+		ft := &fakeT{}
+		Check(ft, weighted(words, tt.weights), want, Options{Seed: 2})
+		if !strings.Contains(ft.msg, tt.report) {
+			t.Errorf("%s sampler: Check reported %q, want it to mention %s", tt.name, ft.msg, tt.report)
+		}
+	}
+}
+
+// fakeT records the failure of a check.
+type fakeT struct {
+	testing.TB
+	msg string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.msg += fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,94 @@
+// Package synthcorpus writes reproducible corpora of Zipf-distributed
+// words, for the benchmarks of the markov package and the synth command.
+package synthcorpus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// Options describes a synthetic corpus for Write.
+type Options struct {
+	Tokens    int     // number of words to write
+	Vocab     int     // number of distinct words before punctuation
+	Exponent  float64 // Zipf exponent s of the word ranks, must be > 1
+	Seed      int64   // the same seed always yields the same corpus
+	DocLen    int     // mean document length in words; 0 writes one document
+	PunctRate float64 // probability that a word carries trailing punctuation
+}
+
+// DefaultOptions returns the parameters used for benchmark corpora.
+func DefaultOptions() Options {
+	return Options{
+		Tokens:    1000000,
+		Vocab:     50000,
+		Exponent:  1.1,
+		Seed:      1,
+		DocLen:    2000,
+		PunctRate: 0.08,
+	}
+}
+
+// word returns the word of the given Zipf rank: "a", "b", ..., "z",
+// "aa", "ab", ... so that frequent words are short, as in natural text.
+func word(rank uint64) string {
+	var buf [16]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = byte('a' + rank%26)
+		if rank < 26 {
+			break
+		}
+		rank = rank/26 - 1
+	}
+	return string(buf[i:])
+}
+
+// Write writes a reproducible corpus of Zipf-distributed words
+// to w. Documents are separated by blank lines and their lengths are
+// exponentially distributed around opts.DocLen.
+func Write(w io.Writer, opts Options) error {
+	if opts.Tokens < 0 || opts.Vocab <= 0 || opts.Exponent <= 1 {
+		return fmt.Errorf("synthetic corpus needs tokens >= 0, vocab > 0 and exponent > 1")
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	zipf := rand.NewZipf(r, opts.Exponent, 1, uint64(opts.Vocab-1))
+	words := make([]string, opts.Vocab)
+	for i := range words {
+		words[i] = word(uint64(i))
+	}
+
+	bw := bufio.NewWriter(w)
+	docLeft := docLength(r, opts.DocLen)
+	for i := 0; i < opts.Tokens; i++ {
+		bw.WriteString(words[zipf.Uint64()])
+		if r.Float64() < opts.PunctRate {
+			bw.WriteByte(".,;!?"[r.Intn(5)])
+		}
+		docLeft--
+		switch {
+		case i == opts.Tokens-1:
+			bw.WriteByte('\n')
+		case docLeft == 0:
+			bw.WriteString("\n\n")
+			docLeft = docLength(r, opts.DocLen)
+		case i%16 == 15:
+			bw.WriteByte('\n')
+		default:
+			bw.WriteByte(' ')
+		}
+	}
+	return bw.Flush()
+}
+
+// docLength draws the length of the next document; mean <= 0 means the
+// corpus is a single document.
+func docLength(r *rand.Rand, mean int) int {
+	if mean <= 0 {
+		return -1
+	}
+	return 1 + int(r.ExpFloat64()*float64(mean-1))
+}
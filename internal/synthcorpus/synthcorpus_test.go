@@ -0,0 +1,62 @@
+package synthcorpus
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReproducible(t *testing.T) {
+	opts := Options{Tokens: 5000, Vocab: 1000, Exponent: 1.1, Seed: 7, DocLen: 100, PunctRate: 0.1}
+	var a, b, other bytes.Buffer
+	Write(&a, opts)
+	Write(&b, opts)
+	opts.Seed++
+	Write(&other, opts)
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("the same seed wrote different corpora")
+	}
+	if bytes.Equal(a.Bytes(), other.Bytes()) {
+		t.Error("different seeds wrote the same corpus")
+	}
+	if n := len(strings.Fields(a.String())); n != opts.Tokens {
+		t.Errorf("corpus has %d tokens, want %d", n, opts.Tokens)
+	}
+}
+
+// TestZipf fits the exponent of the counts of the 20 most frequent words,
+// by least squares on log count against log rank, and checks it against
+// the one asked for.
+func TestZipf(t *testing.T) {
+	for _, s := range []float64{1.1, 1.5, 2} {
+		opts := Options{Tokens: 200000, Vocab: 10000, Exponent: s, Seed: 1}
+		var buf bytes.Buffer
+		if err := Write(&buf, opts); err != nil {
+			t.Fatal(err)
+		}
+		counts := make(map[string]int)
+		for _, w := range strings.Fields(buf.String()) {
+			counts[w]++
+		}
+		// rand.Zipf draws rank k with probability proportional to (k+1)^-s.
+		var sx, sy, sxx, sxy float64
+		const head = 20
+		for k := 0; k < head; k++ {
+			x, y := math.Log(float64(k+1)), math.Log(float64(counts[word(uint64(k))]))
+			sx, sy, sxx, sxy = sx+x, sy+y, sxx+x*x, sxy+x*y
+		}
+		slope := (head*sxy - sx*sy) / (head*sxx - sx*sx)
+		if got := -slope; math.Abs(got-s) > 0.1 {
+			t.Errorf("exponent %v: the head of the corpus fits exponent %.3f", s, got)
+		}
+	}
+}
+
+func TestWriteRejects(t *testing.T) {
+	for _, opts := range []Options{{Tokens: -1, Vocab: 10, Exponent: 1.1}, {Tokens: 10, Exponent: 1.1}, {Tokens: 10, Vocab: 10, Exponent: 1}} {
+		if err := Write(new(bytes.Buffer), opts); err == nil {
+			t.Errorf("Write(%+v) succeeded", opts)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAddText checks that a chain built in one go and one trained in
+// increments, saved and read back in between, have the same table.
+func TestAddText(t *testing.T) {
+	dir := t.TempDir()
+	docs := []string{benchCorpus(2000), tinyCorpus, "the cat sat on the dog."}
+	var files []string
+	for i, doc := range docs {
+		name := filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(name, []byte(doc), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	for _, n := range []int{1, 2, 3} {
+		want := newChain(n)
+		if _, err := want.Build(files); err != nil {
+			t.Fatal(err)
+		}
+
+		c := newChain(n)
+		if err := c.AddText(strings.NewReader(docs[0])); err != nil {
+			t.Fatal(err)
+		}
+		model := filepath.Join(dir, "model.txt")
+		if err := c.WriteFreTable(model); err != nil {
+			t.Fatal(err)
+		}
+		c, err := ReadFreTable(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.AddFile(files[1]); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.AddText(strings.NewReader(docs[2])); err != nil {
+			t.Fatal(err)
+		}
+		if c.PrefixLen() != n {
+			t.Errorf("prefix length %d after training, want %d", c.PrefixLen(), n)
+		}
+		if !reflect.DeepEqual(c.chain, want.chain) {
+			t.Errorf("prefix length %d: the chain trained in increments differs from one built at once", n)
+		}
+	}
+}
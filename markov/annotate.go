@@ -0,0 +1,48 @@
+package markov
+
+// AnnotatedToken is a generated token with how sure the model was of it.
+type AnnotatedToken struct {
+	Token
+	// Probability is the probability with which the token was drawn.
+	Probability float64 `json:"probability"`
+	// Entropy is the entropy in bits of the distribution it was drawn
+	// from: 0 when there was no choice, higher the more even the choice.
+	Entropy float64 `json:"entropy"`
+	// Temperature is the temperature the distribution was reshaped with,
+	// see GenerateOptions.Temperature; 0 when none was applied.
+	Temperature float64 `json:"temperature,omitempty"`
+}
+
+// AnnotatedList is the JSON object written by -output-format annotated-json.
+type AnnotatedList struct {
+	Tokens []AnnotatedToken `json:"tokens"`
+}
+
+// GenerateAnnotated is like GenerateWords but also returns, for every
+// token, the probability it was drawn with and the entropy of the
+// distribution it was drawn from, after every option of opts had its say.
+func (c *Chain) GenerateAnnotated(n int, opts GenerateOptions) []AnnotatedToken {
+	defer c.beginRead()()
+	r := orGlobal(opts.Rand)
+	var tokens []AnnotatedToken
+	opts.observe = func(probs []float64, chosen int, temperature float64) {
+		tokens = append(tokens, AnnotatedToken{
+			Probability: probs[chosen],
+			Entropy:     entropyBits(probs),
+			Temperature: temperature,
+		})
+	}
+	words := c.generate(nil, c.startPrefix(), n, opts, r)
+	if opts.TrimSentence && !opts.CompleteSentence {
+		words = trimSentence(words, 0)
+	}
+	tokens = tokens[:len(words)] // drop draws a stop took back
+	c.restoreCase(words, r)
+	for i, word := range words {
+		tokens[i].Token = Token{word, tokenClass(word)}
+		if word == ParagraphToken {
+			tokens[i].Text = "\n\n"
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,51 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestGenerateAnnotated follows a seeded run through TinyModel and checks
+// every annotation against the suffixes of the prefix the token was drawn
+// after: each word there is drawn by its count, so the probability of a
+// token is its share of the counts, and the entropy that of the shares.
+func TestGenerateAnnotated(t *testing.T) {
+	c := TinyModel()
+	tokens := c.GenerateAnnotated(100, GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+	if len(tokens) == 0 {
+		t.Fatal("nothing generated")
+	}
+	p := c.startPrefix()
+	sure := 0
+	for i, tok := range tokens {
+		total := 0
+		for _, s := range c.Suffixes(p) {
+			total += s.Frequency()
+		}
+		var prob, entropy float64
+		for _, s := range c.Suffixes(p) {
+			share := float64(s.Frequency()) / float64(total)
+			if s.Word() == tok.Text {
+				prob = share
+			}
+			entropy -= share * math.Log2(share)
+		}
+		if math.Abs(tok.Probability-prob) > 1e-9 || math.Abs(tok.Entropy-entropy) > 1e-9 {
+			t.Errorf("token %d %q after %q: probability %v, entropy %v; want %v, %v",
+				i+1, tok.Text, p.String(), tok.Probability, tok.Entropy, prob, entropy)
+		}
+		if tok.Temperature != 0 {
+			t.Errorf("token %d has temperature %v without one set", i+1, tok.Temperature)
+		}
+		if tok.Probability == 1 {
+			sure++
+		}
+		p.Shift(tok.Text)
+	}
+	// TinyModel has both certain words, such as "the" after "sat on", and
+	// coin tosses, such as "sat" or "ran." after "the cat".
+	if sure == 0 || sure == len(tokens) {
+		t.Errorf("%d of %d tokens were certain, want some but not all", sure, len(tokens))
+	}
+}
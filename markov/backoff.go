@@ -0,0 +1,74 @@
+package markov
+
+import (
+	"math/rand"
+)
+
+// backoffKey is a prefix of the chain as the backoff index keeps it.
+type backoffKey struct {
+	words []string
+	total int // sum of the frequencies of its suffixes
+}
+
+// backoff returns the prefix to go on from when generation reaches the
+// dead end p, for GenerateOptions.Backoff: one drawn by total frequency
+// from the prefixes ending in the longest run of the last words of p that
+// any prefix ends in. It returns false if no prefix ends in even the last
+// word of p.
+func (c *Chain) backoff(p Prefix, r *rand.Rand) (Prefix, bool) {
+	if len(p) < 2 {
+		return nil, false
+	}
+	c.materialize()
+	c.loadMu.Lock()
+	if c.backoffIndex == nil {
+		c.backoffIndex = make(map[string][]backoffKey)
+		for _, key := range sortedKeys(c.chain) {
+			words := splitKey(key)
+			total := 0
+			for _, s := range c.chain[key] {
+				total += s.frequency
+			}
+			last := words[len(words)-1]
+			c.backoffIndex[last] = append(c.backoffIndex[last], backoffKey{words, total})
+		}
+	}
+	keys := c.backoffIndex[p[len(p)-1]]
+	c.loadMu.Unlock()
+
+	for m := len(p) - 1; m > 0; m-- {
+		tail := p[len(p)-m:]
+		var candidates []backoffKey
+		sum := 0
+		for _, k := range keys {
+			if wordsEqual(k.words[len(k.words)-m:], tail) {
+				candidates = append(candidates, k)
+				sum += k.total
+			}
+		}
+		if sum == 0 {
+			continue
+		}
+		x := r.Intn(sum)
+		for _, k := range candidates {
+			if x < k.total {
+				return Prefix(k.words), true
+			}
+			x -= k.total
+		}
+	}
+	return nil, false
+}
+
+// wordsEqual reports whether a and b hold the same words.
+func wordsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
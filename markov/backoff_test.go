@@ -0,0 +1,73 @@
+package markov
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/sampletest"
+)
+
+// TestBackoff checks that Backoff is off by default, that at a dead end it
+// goes on from a prefix ending in the last word, drawn by the total
+// frequency of its suffixes, and that it stops where no prefix does.
+func TestBackoff(t *testing.T) {
+	c := newChain(2)
+	c.add(Prefix{"w", "y"}.key(), "k", 1)
+	c.add(Prefix{"a", "k"}.key(), "p", 2)
+	c.add(Prefix{"a", "k"}.key(), "r", 1)
+	c.add(Prefix{"b", "k"}.key(), "q", 1)
+
+	if got, _ := c.GenerateFromOpts([]string{"w", "y"}, 5, GenerateOptions{}); got != "w y k" {
+		t.Errorf("without Backoff generated %q, want w y k", got)
+	}
+	// (a k) has suffixes 3 times in all, (b k) once.
+	draw := func(r *rand.Rand) string {
+		text, err := c.GenerateFromOpts([]string{"w", "y"}, 2, GenerateOptions{Backoff: true, Rand: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		words := strings.Fields(text)
+		return words[len(words)-1]
+	}
+	sampletest.Check(t, draw, normalize(map[string]float64{"p": 2, "r": 1, "q": 1}), sampletest.Options{})
+
+	// No prefix ends in p, so the text stops there all the same.
+	for seed := int64(1); seed <= 20; seed++ {
+		_, res := c.GenerateWordsChecked(10, GenerateOptions{Backoff: true, Rand: rand.New(rand.NewSource(seed))})
+		if res.Stop != StopDeadEnd || res.Words > 4 {
+			t.Errorf("seed %d: %+v, want at most 4 words, stopping at %s", seed, res, StopDeadEnd)
+		}
+	}
+}
+
+// TestBackoffLonger generates from a sparse model of short documents with
+// and without Backoff from the same seeds: backing off must make the texts
+// much longer on average.
+func TestBackoffLonger(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	c := newChain(2)
+	for doc := 0; doc < 300; doc++ {
+		words := make([]string, 6)
+		for i := range words {
+			words[i] = fmt.Sprintf("w%d", r.Intn(40))
+		}
+		if _, err := c.BuildReaderOpts("", strings.NewReader(strings.Join(words, " ")), BuildOptions{NoEndToken: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const runs, n = 200, 100
+	mean := func(backoff bool) float64 {
+		total := 0
+		for seed := int64(1); seed <= runs; seed++ {
+			opts := GenerateOptions{Backoff: backoff, Rand: rand.New(rand.NewSource(seed))}
+			total += len(c.GenerateWords(n, opts))
+		}
+		return float64(total) / runs
+	}
+	plain, backingOff := mean(false), mean(true)
+	if backingOff < 3*plain {
+		t.Errorf("mean length %.1f words with Backoff, %.1f without; want at least three times as long", backingOff, plain)
+	}
+}
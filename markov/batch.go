@@ -0,0 +1,67 @@
+package markov
+
+import (
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// GenerateN returns samples independent texts of at most wordsPer words
+// each, as many calls of Generate would, generated in parallel on every
+// CPU. See GenerateNWords for reproducible batches.
+func (c *Chain) GenerateN(samples, wordsPer int) []string {
+	batch := c.GenerateNWords(samples, wordsPer, runtime.GOMAXPROCS(0), GenerateOptions{})
+	out := make([]string, len(batch))
+	for i, words := range batch {
+		out[i] = strings.Join(words, " ")
+	}
+	return out
+}
+
+// GenerateNWords returns the words of samples texts generated according
+// to opts, each of at most wordsPer words, on up to workers goroutines at
+// the same time; workers below 2 generate them one after the other in
+// the calling goroutine. Every sample draws from a generator of its own,
+// seeded in order from opts.Rand, so the samples are independent of each
+// other and a seeded batch comes out the same however the goroutines are
+// scheduled. The chain is locked for reading once for the whole batch.
+func (c *Chain) GenerateNWords(samples, wordsPer, workers int, opts GenerateOptions) [][]string {
+	if samples <= 0 {
+		return nil
+	}
+	c.materialize()
+	defer c.beginRead()()
+	r := orGlobal(opts.Rand)
+	rands := make([]*rand.Rand, samples)
+	for i := range rands {
+		rands[i] = rand.New(&splitMix{uint64(r.Int63())})
+	}
+	out := make([][]string, samples)
+	if workers < 2 {
+		for i := range out {
+			out[i] = c.generateWords(wordsPer, opts, rands[i])
+		}
+		return out
+	}
+	if workers > samples {
+		workers = samples
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out[i] = c.generateWords(wordsPer, opts, rands[i])
+			}
+		}()
+	}
+	for i := range out {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,79 @@
+package markov
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGenerateNWords checks that a seeded batch is the same on any number
+// of goroutines, that each sample is what its own generator draws, and
+// that the samples differ from each other.
+func TestGenerateNWords(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader(benchCorpus(5000))); err != nil {
+		t.Fatal(err)
+	}
+	const samples, wordsPer = 50, 20
+	batch := func(workers int) [][]string {
+		return c.GenerateNWords(samples, wordsPer, workers, GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+	}
+	want := batch(1)
+	for _, workers := range []int{0, 4, 64} {
+		if got := batch(workers); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers generated another batch than one", workers)
+		}
+	}
+
+	seeds := rand.New(rand.NewSource(1))
+	distinct := make(map[string]bool)
+	for i, words := range want {
+		own := c.GenerateWords(wordsPer, GenerateOptions{Rand: rand.New(&splitMix{uint64(seeds.Int63())})})
+		if !reflect.DeepEqual(words, own) {
+			t.Errorf("sample %d is %q, want %q", i, words, own)
+		}
+		if len(words) == 0 || len(words) > wordsPer {
+			t.Errorf("sample %d has %d words", i, len(words))
+		}
+		distinct[strings.Join(words, " ")] = true
+	}
+	if len(distinct) < samples*9/10 {
+		t.Errorf("only %d of %d samples differ", len(distinct), samples)
+	}
+	if other := c.GenerateNWords(samples, wordsPer, 4, GenerateOptions{Rand: rand.New(rand.NewSource(2))}); reflect.DeepEqual(other, want) {
+		t.Error("another seed generated the same batch")
+	}
+
+	if got := c.GenerateNWords(0, wordsPer, 4, GenerateOptions{}); got != nil {
+		t.Errorf("a batch of no samples is %q", got)
+	}
+	if got := c.GenerateN(samples, wordsPer); len(got) != samples {
+		t.Errorf("GenerateN(%d, %d) returned %d texts", samples, wordsPer, len(got))
+	}
+}
+
+// BenchmarkGenerateN compares a batch of 50 samples with 50 calls of
+// GenerateWords.
+func BenchmarkGenerateN(b *testing.B) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader(benchCorpus(50000))); err != nil {
+		b.Fatal(err)
+	}
+	const samples, wordsPer = 50, 50
+	b.Run("calls", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < samples; j++ {
+				c.GenerateWords(wordsPer, GenerateOptions{})
+			}
+		}
+	})
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprint("workers ", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c.GenerateNWords(samples, wordsPer, workers, GenerateOptions{})
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package markov
+
+import "unicode/utf8"
+
+// budget keeps the size of the text generated so far against MaxBytes and
+// MaxRunes.
+type budget struct {
+	maxBytes, maxRunes int
+	bytes, runes       int
+	j                  joiner // only its sep is used
+}
+
+// newBudget returns the budget opts set, or nil if they set none.
+func newBudget(opts GenerateOptions) *budget {
+	if opts.MaxBytes <= 0 && opts.MaxRunes <= 0 {
+		return nil
+	}
+	return &budget{maxBytes: opts.MaxBytes, maxRunes: opts.MaxRunes, j: joiner{pretty: opts.Pretty}}
+}
+
+// fits reports whether word, with the white space before it, still fits
+// in the budget, and if so spends it.
+func (b *budget) fits(word string) bool {
+	if b == nil {
+		return true
+	}
+	s := b.j.sep(word)
+	if word != ParagraphToken {
+		s += word
+	}
+	bytes, runes := b.bytes+len(s), b.runes+utf8.RuneCountInString(s)
+	if b.maxBytes > 0 && bytes > b.maxBytes || b.maxRunes > 0 && runes > b.maxRunes {
+		return false
+	}
+	b.bytes, b.runes = bytes, runes
+	b.j.prev = word
+	return true
+}
+
+// fitting returns the longest start of words that fits in the budget of
+// opts.
+func fitting(words []string, opts GenerateOptions) []string {
+	b := newBudget(opts)
+	for i, w := range words {
+		if !b.fits(w) {
+			return words[:i]
+		}
+	}
+	return words
+}
@@ -0,0 +1,46 @@
+package markov
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestBudget generates a text of accented words, each following from the
+// last, under byte and character budgets: the budget counts UTF-8 bytes
+// and the spaces between the words, and it or the word count, whichever
+// comes first, ends the text.
+func TestBudget(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader("ça va très bien à côté du café.")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		n                  int
+		maxBytes, maxRunes int
+		want               string
+		stop               StopReason
+	}{
+		// "ça va très bien" is 17 bytes and 15 characters.
+		{100, 17, 0, "ça va très bien", StopBudget},
+		{100, 16, 0, "ça va très", StopBudget},
+		{100, 0, 15, "ça va très bien", StopBudget},
+		{100, 0, 14, "ça va très", StopBudget},
+		{100, 16, 15, "ça va très", StopBudget},
+		{100, 17, 14, "ça va très", StopBudget},
+		{100, 2, 0, "", StopBudget},
+		{2, 17, 15, "ça va", StopLimit},
+		{100, 1000, 1000, "ça va très bien à côté du café.", StopEnd},
+	} {
+		opts := GenerateOptions{MaxBytes: tt.maxBytes, MaxRunes: tt.maxRunes}
+		words, res := c.GenerateWordsChecked(tt.n, opts)
+		got := strings.Join(words, " ")
+		if got != tt.want || res.Stop != tt.stop {
+			t.Errorf("%d words in %d bytes, %d runes: %q, stopping at %s, want %q, stopping at %s",
+				tt.n, tt.maxBytes, tt.maxRunes, got, res.Stop, tt.want, tt.stop)
+		}
+		if res.Words != len(words) || res.Bytes != len(got) || res.Runes != utf8.RuneCountInString(got) {
+			t.Errorf("%q reported as %+v", got, res)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package markov
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestBuildError builds from readable, missing, binary, unreadable and
+// directory inputs, and checks the chain covers exactly the readable
+// files and the error names each of the others.
+func TestBuildError(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	for name, text := range map[string]string{
+		"a.txt":      tinyCorpus,
+		"b.txt":      "the dog ran on the mat.",
+		"bin.dat":    "\x00\x01\x02\x03",
+		"secret.txt": "the cat is secret.",
+	} {
+		if err := os.WriteFile(at(name), []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Mkdir(at("sub.txt"), 0o777)
+	// Root reads files whatever their mode.
+	unreadable := os.Chmod(at("secret.txt"), 0) == nil && os.Geteuid() != 0
+
+	var skipped []string
+	inputs, err := ResolveInputs([]string{at("a.txt"), at("missing.txt"), at("bin.dat"), at("b.txt")}, func(msg string) { skipped = append(skipped, msg) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || !strings.Contains(skipped[0], "bin.dat") {
+		t.Errorf("ResolveInputs skipped %q, want bin.dat", skipped)
+	}
+	names := []string{at("sub.txt")}
+	for _, in := range inputs {
+		names = append(names, in.Name)
+	}
+	if unreadable {
+		names = append(names, at("secret.txt"))
+	}
+
+	c := newChain(2)
+	report, err := c.Build(names)
+	var berr *BuildError
+	if !errors.As(err, &berr) {
+		t.Fatalf("Build = %v, want a *BuildError", err)
+	}
+	failed := []string{at("sub.txt"), at("missing.txt")}
+	if unreadable {
+		failed = append(failed, at("secret.txt"))
+	}
+	var got []string
+	for _, f := range berr.Files {
+		got = append(got, f.Name)
+		if !strings.Contains(f.Error(), f.Name) {
+			t.Errorf("the error %q does not name %s", f, f.Name)
+		}
+	}
+	if !reflect.DeepEqual(got, failed) {
+		t.Errorf("Build failed on %q, want %q", got, failed)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the BuildError does not unwrap to fs.ErrNotExist")
+	}
+	if unreadable && !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("the BuildError does not unwrap to fs.ErrPermission")
+	}
+	for _, f := range report.Files {
+		if want := f.Name != at("a.txt") && f.Name != at("b.txt"); (f.Error != "") != want {
+			t.Errorf("the report has %+v, want an error: %v", f, want)
+		}
+	}
+
+	want := newChain(2)
+	if _, err := want.Build([]string{at("a.txt"), at("b.txt")}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.chain, want.chain) {
+		t.Errorf("the chain differs from one built from the readable files alone")
+	}
+}
@@ -0,0 +1,97 @@
+package markov
+
+import (
+	"math/rand"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// caseStats records how often a case-folded word was capitalized in the
+// corpus, separately for sentence-initial and other positions.
+type caseStats struct {
+	initial, initialCaps int
+	mid, midCaps         int
+}
+
+// unusual reports whether the word deviates from the default rule of being
+// capitalized exactly at sentence starts; only those words are saved.
+func (s caseStats) unusual() bool {
+	return s.midCaps > 0 || s.initialCaps < s.initial
+}
+
+// isCapitalized reports whether tok starts with an upper-case letter.
+func isCapitalized(tok string) bool {
+	r, _ := utf8.DecodeRuneInString(tok)
+	return unicode.IsUpper(r)
+}
+
+// capitalize upper-cases the first letter of tok.
+func capitalize(tok string) string {
+	r, size := utf8.DecodeRuneInString(tok)
+	if !unicode.IsLower(r) {
+		return tok
+	}
+	return string(unicode.ToUpper(r)) + tok[size:]
+}
+
+// foldCase returns the lower-case form of tok and counts its original
+// capitalization at the given position.
+func (c *Chain) foldCase(tok string, initial bool, vocab interner) string {
+	lower := strings.ToLower(tok)
+	if c.caseStats == nil {
+		c.caseStats = make(map[string]caseStats)
+	}
+	s := c.caseStats[lower]
+	caps := 0
+	if isCapitalized(tok) {
+		caps = 1
+	}
+	if initial {
+		s.initial++
+		s.initialCaps += caps
+	} else {
+		s.mid++
+		s.midCaps += caps
+	}
+	c.caseStats[lower] = s
+	return vocab.intern([]byte(lower))
+}
+
+// restoreCase re-capitalizes generated words of a case-folded model: each
+// word is capitalized with the probability observed for its position in the
+// corpus, and sentence starts of words without statistics are capitalized.
+func (c *Chain) restoreCase(words []string, r *rand.Rand) {
+	c.restoreCaseFrom(words, true, r)
+}
+
+// restoreCaseFrom is restoreCase for words continuing a text; initial tells
+// whether the first word starts a sentence.
+func (c *Chain) restoreCaseFrom(words []string, initial bool, r *rand.Rand) {
+	if c.caseStats == nil {
+		return
+	}
+	for i, w := range words {
+		if w == ParagraphToken {
+			continue
+		}
+		s, ok := c.caseStats[w]
+		switch {
+		case !ok:
+			if initial {
+				words[i] = capitalize(w)
+			}
+		case initial && s.initial == 0:
+			words[i] = capitalize(w)
+		case initial:
+			if r.Intn(s.initial) < s.initialCaps {
+				words[i] = capitalize(w)
+			}
+		case s.mid > 0:
+			if r.Intn(s.mid) < s.midCaps {
+				words[i] = capitalize(w)
+			}
+		}
+		initial = endsSentence(w)
+	}
+}
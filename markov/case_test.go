@@ -0,0 +1,50 @@
+package markov
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestLowercaseRestoresCase builds a folded model of a corpus naming
+// London mid-sentence: generated text must name it capitalized, start its
+// sentences capitalized, and keep other words in lower case.
+func TestLowercaseRestoresCase(t *testing.T) {
+	corpus := "We went to London by train. The trains to London are slow. " +
+		"She likes London in spring. They left London at dawn. "
+	for name, roundTrip := range formats {
+		c := newChain(1)
+		if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader(corpus), BuildOptions{Lowercase: true}); err != nil {
+			t.Fatal(err)
+		}
+		if c.Suffixes(Prefix{"London"}) != nil || c.Suffixes(Prefix{"london"}) == nil {
+			t.Fatal("the chain is not folded to lower case")
+		}
+		c, err := roundTrip(c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		sawLondon := false
+		for seed := int64(1); seed <= 50; seed++ {
+			words := c.GenerateWords(30, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+			initial := true
+			for _, w := range words {
+				switch {
+				case strings.EqualFold(w, "london"):
+					sawLondon = true
+					if w != "London" {
+						t.Errorf("%s: seed %d: %q in %q", name, seed, w, words)
+					}
+				case initial && !isCapitalized(w):
+					t.Errorf("%s: seed %d: sentence starts with %q in %q", name, seed, w, words)
+				case !initial && isCapitalized(w):
+					t.Errorf("%s: seed %d: %q capitalized mid-sentence in %q", name, seed, w, words)
+				}
+				initial = endsSentence(w)
+			}
+		}
+		if !sawLondon {
+			t.Errorf("%s: London never generated", name)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checksumVersion is the first format version whose model files end with
+// a checksum record.
+const checksumVersion = 4
+
+// checksumRecord returns the line WriteTo ends model files with: the CRC-32
+// (IEEE) of every byte before it.
+func checksumRecord(sum uint32) string {
+	return fmt.Sprintf("\tchecksum crc32 %08x", sum)
+}
+
+// isChecksumRecord reports whether line is a checksum record.
+func isChecksumRecord(line string) bool {
+	return strings.HasPrefix(line, "\tchecksum ")
+}
+
+// checkChecksum returns what is wrong with the checksum record line given
+// sum, the CRC-32 of the lines before it, or "".
+func checkChecksum(line string, sum uint32) string {
+	fields := recordFields(line)
+	var want uint32
+	if len(fields) != 3 || fields[1] != "crc32" || len(fields[2]) != 8 {
+		return fmt.Sprintf("malformed checksum record %q", strings.TrimSpace(line))
+	}
+	if _, err := fmt.Sscanf(fields[2], "%08x", &want); err != nil {
+		return fmt.Sprintf("malformed checksum record %q", strings.TrimSpace(line))
+	}
+	if want != sum {
+		return fmt.Sprintf("checksum %08x does not match the content, %08x", want, sum)
+	}
+	return ""
+}
+
+// missingChecksum returns what it means that a model file of the given
+// version has no checksum record: "" for versions before checksums, when
+// the file is read as it is.
+func missingChecksum(version int) string {
+	if version < checksumVersion {
+		return ""
+	}
+	return "no checksum record at the end"
+}
@@ -0,0 +1,68 @@
+package markov
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestChecksumTruncated cuts a model file short at many places, and damages
+// one byte of it, and checks that every way of reading it fails with a
+// *ChecksumError rather than returning part of the chain. Mapped files are
+// only checked for being cut short, see OpenFreTableMmap.
+func TestChecksumTruncated(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if _, err := synthChain(t, 5000).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	model := buf.Bytes()
+	checksumLine := bytes.LastIndexByte(model[:len(model)-1], '\n') + 1
+	firstLine := bytes.IndexByte(model, '\n') + 1
+	damaged := append([]byte(nil), model...)
+	damaged[len(model)/2] ^= 1
+
+	cases := map[string][]byte{
+		"damaged":            damaged,
+		"without checksum":   model[:checksumLine],
+		"mid checksum":       model[:checksumLine+5],
+		"after the header":   model[:firstLine],
+		"mid file":           model[:len(model)/2],
+		"mid file at a line": model[:bytes.IndexByte(model[len(model)/2:], '\n')+len(model)/2+1],
+	}
+	for name, data := range cases {
+		file := filepath.Join(dir, "m.txt")
+		if err := os.WriteFile(file, data, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		readers := map[string]func() (*Chain, error){
+			"ReadFreTable":     func() (*Chain, error) { return ReadFreTable(file) },
+			"OpenFreTableMmap": func() (*Chain, error) { return OpenFreTableMmap(file) },
+			"ReadFrom": func() (*Chain, error) {
+				c := new(Chain)
+				_, err := c.ReadFrom(bytes.NewReader(data))
+				return c, err
+			},
+		}
+		for reader, read := range readers {
+			if name == "damaged" && reader == "OpenFreTableMmap" {
+				continue
+			}
+			c, err := read()
+			var checksum *ChecksumError
+			if !errors.As(err, &checksum) || checksum.Missing {
+				t.Errorf("%s, %s: %v, want a *ChecksumError", name, reader, err)
+				continue
+			}
+			if reader != "ReadFrom" && c != nil {
+				c.Close()
+				t.Errorf("%s, %s: returned a chain along with %v", name, reader, err)
+			}
+			if want := map[bool]string{true: "", false: file}[reader == "ReadFrom"]; checksum.Name != want {
+				t.Errorf("%s, %s: the error names %q, want %q", name, reader, checksum.Name, want)
+			}
+		}
+	}
+}
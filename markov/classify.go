@@ -0,0 +1,128 @@
+package markov
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenClassifier decides whether a token belongs to a class of tokens
+// (URLs, e-mail addresses, names, ...) that should be stored in the chain as
+// a placeholder instead of verbatim.
+type TokenClassifier interface {
+	Classify(tok string) (class string, ok bool)
+}
+
+// Built-in classifiers, selectable by name with read -classify.
+var (
+	URLClassifier   TokenClassifier = regexpClassifier{"url", regexp.MustCompile(`^(https?://|www\.)\S+$`)}
+	EmailClassifier TokenClassifier = regexpClassifier{"email", regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[A-Za-z]{2,}$`)}
+	NameClassifier  TokenClassifier = nameClassifier{}
+)
+
+// Classifiers maps the names accepted by the -classify flag of the gomark
+// command to classifiers.
+var Classifiers = map[string]TokenClassifier{
+	"url":   URLClassifier,
+	"email": EmailClassifier,
+	"name":  NameClassifier,
+}
+
+// defaultReservoirSize is the number of originals kept per class when
+// BuildOptions.ReservoirSize is zero.
+const defaultReservoirSize = 64
+
+// placeholder returns the token stored in the chain for class.
+func placeholder(class string) string {
+	return "<" + class + ">"
+}
+
+// trimPunct strips the punctuation a token commonly carries from the
+// surrounding sentence, so "(see http://x)." still looks like a URL.
+func trimPunct(tok string) string {
+	return strings.Trim(tok, `.,;:!?()"'`)
+}
+
+// regexpClassifier puts tokens matching re into class.
+type regexpClassifier struct {
+	class string
+	re    *regexp.Regexp
+}
+
+func (r regexpClassifier) Classify(tok string) (string, bool) {
+	if r.re.MatchString(trimPunct(tok)) {
+		return r.class, true
+	}
+	return "", false
+}
+
+// nameClassifier treats capitalized, otherwise lower-case words as names.
+// It is deliberately crude: sentence-initial words are caught as well.
+type nameClassifier struct{}
+
+func (nameClassifier) Classify(tok string) (string, bool) {
+	w := trimPunct(tok)
+	first, size := utf8.DecodeRuneInString(w)
+	if size >= len(w) || !unicode.IsUpper(first) || w == "I" {
+		return "", false
+	}
+	for _, r := range w[size:] {
+		if !unicode.IsLower(r) {
+			return "", false
+		}
+	}
+	return "name", true
+}
+
+// reservoir keeps a uniform sample of at most size originals seen for a class.
+type reservoir struct {
+	size  int
+	seen  int
+	items []string
+}
+
+// add offers tok to the reservoir (Algorithm R).
+func (r *reservoir) add(tok string, rng *rand.Rand) {
+	r.seen++
+	if len(r.items) < r.size {
+		r.items = append(r.items, tok)
+		return
+	}
+	if j := rng.Intn(r.seen); j < r.size {
+		r.items[j] = tok
+	}
+}
+
+// classify returns the token to store for tok, recording the original in
+// the reservoir of its class when one of cs matches.
+func (c *Chain) classify(tok string, cs []TokenClassifier, res map[string]*reservoir, size int, rng *rand.Rand) string {
+	for _, cl := range cs {
+		class, ok := cl.Classify(tok)
+		if !ok {
+			continue
+		}
+		r := res[class]
+		if r == nil {
+			r = &reservoir{size: size}
+			res[class] = r
+		}
+		r.add(tok, rng)
+		return placeholder(class)
+	}
+	return tok
+}
+
+// fill replaces a placeholder produced by a classifier with one of the
+// originals recorded for its class, sampled anew for every emission.
+func (c *Chain) fill(word string, r *rand.Rand) string {
+	if len(c.reservoirs) == 0 || !strings.HasPrefix(word, "<") || !strings.HasSuffix(word, ">") {
+		return word
+	}
+	items := c.reservoirs[word[1:len(word)-1]]
+	if len(items) == 0 {
+		return word
+	}
+	return items[r.Intn(len(items))]
+}
@@ -0,0 +1,80 @@
+package markov
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestClassifyTokens(t *testing.T) {
+	urls := []string{"https://example.com/a", "http://go.dev", "www.example.org"}
+	emails := []string{"ann@example.com", "bob@mail.example.net"}
+	var b strings.Builder
+	for i := 0; i < 30; i++ {
+		b.WriteString("see " + urls[i%len(urls)] + " or write to " + emails[i%len(emails)] + ". ")
+	}
+	c := newChain(1)
+	opts := BuildOptions{Classifiers: []TokenClassifier{URLClassifier, EmailClassifier}, Rand: rand.New(rand.NewSource(1))}
+	if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader(b.String()), opts); err != nil {
+		t.Fatal(err)
+	}
+	raw := append(append([]string{}, urls...), emails...)
+	isRaw := func(w string) bool {
+		for _, r := range raw {
+			if strings.Contains(w, r) {
+				return true
+			}
+		}
+		return false
+	}
+	for key, sufs := range c.chain {
+		for _, w := range splitKey(key) {
+			if isRaw(w) {
+				t.Errorf("prefix %q keeps a classified token", splitKey(key).String())
+			}
+		}
+		for _, s := range sufs {
+			if isRaw(s.word) {
+				t.Errorf("suffix %q is a classified token", s.word)
+			}
+		}
+	}
+	if c.Suffixes(Prefix{"see"}) == nil || c.Suffixes(Prefix{"<url>"}) == nil {
+		t.Fatal("the chain has no <url> placeholder")
+	}
+
+	// Generation puts originals back, from the right class.
+	seen := map[string]bool{}
+	for seed := int64(1); seed <= 20; seed++ {
+		words := c.GenerateWords(40, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		for i, w := range words {
+			if strings.HasPrefix(w, "<") {
+				t.Fatalf("placeholder %q left in %q", w, words)
+			}
+			switch {
+			case i > 0 && words[i-1] == "see":
+				if !isRaw(w) || strings.Contains(w, "@") {
+					t.Errorf("%q after see, want a URL of the corpus", w)
+				}
+			case i > 0 && words[i-1] == "to":
+				if !isRaw(strings.TrimSuffix(w, ".")) || !strings.Contains(w, "@") {
+					t.Errorf("%q after to, want an e-mail address of the corpus", w)
+				}
+			}
+			seen[w] = true
+		}
+	}
+	for _, u := range urls {
+		if !seen[u] {
+			t.Errorf("%s never generated", u)
+		}
+	}
+}
+
+func TestNameClassifier(t *testing.T) {
+	for tok, want := range map[string]bool{"London": true, "London,": true, "I": false, "NASA": false, "london": false, "McDonald": false} {
+		if _, ok := NameClassifier.Classify(tok); ok != want {
+			t.Errorf("NameClassifier.Classify(%q) = %v, want %v", tok, ok, want)
+		}
+	}
+}
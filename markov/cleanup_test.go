@@ -0,0 +1,44 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// badModel is a model file of a version without checksums whose second
+// table line is damaged.
+const badModel = "GOMARK v3 prefix=2\n\"\" \"\" the 1 \nthe cat sat\n"
+
+// TestWriteFailureRemovesFile fails to write a model, after the file was
+// created, and checks that nothing is left in the directory.
+func TestWriteFailureRemovesFile(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.model")
+	if err := os.WriteFile(bad, []byte(badModel), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	// The damaged line is only parsed when the write needs the whole table.
+	c, err := OpenFreTableMmap(bad)
+	if c == nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for name, write := range map[string]func(string) error{
+		"out.model":    c.WriteFreTable,
+		"out.model.gz": c.WriteFreTable,
+		"out.json":     c.WriteJSONFile,
+		"out.gob":      c.WriteGobFile,
+		"out.msgpack":  c.WriteMsgpackFile,
+		"out.prob": func(name string) error {
+			return c.WriteProbabilitiesFile(name, 3)
+		},
+	} {
+		dir := t.TempDir()
+		if err := write(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: saving a damaged model succeeded", name)
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("%s: a failed save left %v behind", name, files)
+		}
+	}
+}
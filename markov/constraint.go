@@ -0,0 +1,92 @@
+package markov
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultAttempts is the number of texts GenerateSatisfying tries when
+// Constraint.Attempts is 0.
+const DefaultAttempts = 100
+
+// Constraint is what GenerateSatisfying requires of a text.
+type Constraint struct {
+	// Require lists words that must all occur in the text, in any case.
+	Require []string
+	// Match, if set, must match the text as WriteText writes it.
+	Match *regexp.Regexp
+	// Attempts bounds the texts generated before giving up; 0 means
+	// DefaultAttempts.
+	Attempts int
+}
+
+// satisfied reports whether words satisfy con.
+func (con Constraint) satisfied(words []string) bool {
+	for _, req := range con.Require {
+		found := false
+		for _, w := range words {
+			if strings.EqualFold(w, req) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return con.Match == nil || con.Match.MatchString(joinWords(words))
+}
+
+// GenerateSatisfying generates texts of at most n words as GenerateWords
+// does, one after the other from opts.Rand, until one satisfies con, and
+// returns it. It fails with ErrUnsatisfiable when a required word is not
+// in the vocabulary of c or none of con.Attempts texts satisfies con, and
+// with ErrEmptyModel if c has no prefixes.
+func (c *Chain) GenerateSatisfying(n int, opts GenerateOptions, con Constraint) ([]string, error) {
+	if c.IsEmpty() {
+		return nil, ErrEmptyModel
+	}
+	vocab := c.Vocabulary()
+	for _, w := range con.Require {
+		if _, ok := vocab[w]; !ok {
+			if _, ok := vocab[strings.ToLower(w)]; !ok {
+				return nil, fmt.Errorf("%q is not a word of the model: %w", w, ErrUnsatisfiable)
+			}
+		}
+	}
+	attempts := con.Attempts
+	if attempts <= 0 {
+		attempts = DefaultAttempts
+	}
+	for i := 0; i < attempts; i++ {
+		if words := c.GenerateWords(n, opts); con.satisfied(words) {
+			return words, nil
+		}
+	}
+	return nil, fmt.Errorf("none of %d texts satisfies the constraints: %w", attempts, ErrUnsatisfiable)
+}
+
+// Bridge returns the shortest sequence of words leading through observed
+// transitions from the context from ends in to the context to ends in, as
+// GenerateParallel joins its chunks; it ends with the words of to. Both
+// are read as GenerateFrom reads its seed. It fails with ErrUnknownPrefix
+// if c has no suffixes for from, and with ErrUnsatisfiable if no sequence
+// of at most 8 words leads to to.
+func (c *Chain) Bridge(from, to []string) ([]string, error) {
+	if err := c.materialize(); err != nil {
+		return nil, err
+	}
+	defer c.beginRead()()
+	p, suf := c.context(from)
+	if len(suf) == 0 {
+		return nil, fmt.Errorf("%q: %w", strings.Join(from, " "), ErrUnknownPrefix)
+	}
+	q, _ := c.context(to)
+	path := c.bridge(p, q)
+	if path == nil {
+		return nil, fmt.Errorf("no bridge of at most %d words from %q to %q: %w",
+			maxBridgeWords, strings.Join(from, " "), strings.Join(to, " "), ErrUnsatisfiable)
+	}
+	return path, nil
+}
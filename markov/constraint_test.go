@@ -0,0 +1,52 @@
+package markov
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSatisfying(t *testing.T) {
+	c := TinyModel()
+	opts := GenerateOptions{Rand: rand.New(rand.NewSource(1))}
+	words, err := c.GenerateSatisfying(20, opts, Constraint{Require: []string{"dog"}, Match: regexp.MustCompile(`ran\.$`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.Join(words, " ")
+	if !strings.Contains(text, "dog") || !strings.HasSuffix(text, "ran.") {
+		t.Errorf("%q does not satisfy the constraint", text)
+	}
+
+	for _, con := range []Constraint{
+		{Require: []string{"zebra"}},
+		{Match: regexp.MustCompile(`zebra`), Attempts: 5},
+	} {
+		if _, err := c.GenerateSatisfying(20, opts, con); !errors.Is(err, ErrUnsatisfiable) {
+			t.Errorf("%+v: got %v, want ErrUnsatisfiable", con, err)
+		}
+	}
+	if _, err := newChain(2).GenerateSatisfying(20, opts, Constraint{}); !errors.Is(err, ErrEmptyModel) {
+		t.Errorf("empty chain: got %v, want ErrEmptyModel", err)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	c := TinyModel()
+	got, err := c.Bridge([]string{"the", "dog"}, []string{"the", "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sat on the cat. the cat"; strings.Join(got, " ") != want {
+		t.Errorf("bridge %q, want %q", got, want)
+	}
+	// Nothing leads back to the start of the text.
+	if _, err := c.Bridge([]string{"the", "dog"}, nil); !errors.Is(err, ErrUnsatisfiable) {
+		t.Errorf("got %v, want ErrUnsatisfiable", err)
+	}
+	if _, err := c.Bridge([]string{"no", "such"}, []string{"the", "cat"}); !errors.Is(err, ErrUnknownPrefix) {
+		t.Errorf("got %v, want ErrUnknownPrefix", err)
+	}
+}
@@ -0,0 +1,180 @@
+package markov
+
+import (
+	"math"
+	"strings"
+)
+
+// divergenceTop is the number of most-changed prefixes a DivergenceReport
+// lists.
+const divergenceTop = 10
+
+// PrefixDivergence is the divergence between the suffix distributions of
+// one prefix in two chains.
+type PrefixDivergence struct {
+	Prefix string  `json:"prefix"`
+	JS     float64 `json:"js"`     // Jensen-Shannon divergence in bits, 0..1
+	Weight float64 `json:"weight"` // mean share of the prefix in the two chains
+}
+
+// DivergenceReport compares two chains; see Divergence.
+type DivergenceReport struct {
+	Prefixes int `json:"prefixes"` // prefixes in either chain
+	Shared   int `json:"shared"`
+	OnlyA    int `json:"only_a"`
+	OnlyB    int `json:"only_b"`
+	// SharedJS is the weighted mean divergence over the shared prefixes,
+	// renormalized to their weight. JS also counts the prefixes found in
+	// one chain only, as maximally divergent (1 bit).
+	SharedJS float64            `json:"shared_js"`
+	JS       float64            `json:"js"`
+	Top      []PrefixDivergence `json:"top"`
+}
+
+// Divergence compares the chains a and b prefix by prefix: for every
+// prefix of either chain it computes the Jensen-Shannon divergence between
+// its suffix distributions and weights it by how often the prefix occurs,
+// as the mean of its shares of all transitions in a and in b. The report
+// lists the most changed prefixes, heaviest first among equal divergences,
+// and is the same for the same chains.
+func Divergence(a, b *Chain) DivergenceReport {
+	a.materialize()
+	b.materialize()
+	defer a.beginRead()()
+	defer b.beginRead()()
+
+	da, totalA := a.distributions()
+	db, totalB := b.distributions()
+	keys := make(map[string]bool)
+	for k := range da {
+		keys[k] = true
+	}
+	for k := range db {
+		keys[k] = true
+	}
+
+	var rep DivergenceReport
+	top := newTopN(divergenceTop, func(a, b PrefixDivergence) bool {
+		if a.JS != b.JS {
+			return a.JS > b.JS
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Prefix < b.Prefix
+	})
+	var sharedWeight float64
+	for _, key := range sortedKeys(keys) {
+		sa, sb := da[key], db[key]
+		d := PrefixDivergence{Prefix: splitKey(key).String(), JS: 1}
+		d.Weight = (share(sa, totalA) + share(sb, totalB)) / 2
+		switch {
+		case sa == nil:
+			rep.OnlyB++
+		case sb == nil:
+			rep.OnlyA++
+		default:
+			rep.Shared++
+			d.JS = jsDivergence(sa, sb)
+			rep.SharedJS += d.Weight * d.JS
+			sharedWeight += d.Weight
+		}
+		rep.JS += d.Weight * d.JS
+		rep.Prefixes++
+		top.push(d)
+	}
+	if sharedWeight > 0 {
+		rep.SharedJS /= sharedWeight
+	}
+	rep.Top = top.sorted()
+	return rep
+}
+
+// distributions returns the suffix counts of every prefix of c and the
+// total count.
+func (c *Chain) distributions() (map[string]map[string]int, int) {
+	dist := make(map[string]map[string]int, len(c.chain))
+	total := 0
+	for key, suf := range c.chain {
+		m := dist[key]
+		if m == nil {
+			m = make(map[string]int, len(suf))
+			dist[key] = m
+		}
+		for _, s := range suf {
+			m[s.word] += s.frequency
+			total += s.frequency
+		}
+	}
+	return dist, total
+}
+
+// sentinelPrefix returns the prefix of key with its empty slots spelled as
+// the "" sentinel of model files, for showing it; a word `""` of the corpus
+// looks the same.
+func sentinelPrefix(key string) Prefix {
+	words := splitKey(key)
+	for i, w := range words {
+		if w == "" {
+			words[i] = `""`
+		}
+	}
+	return words
+}
+
+// emptySlotKey returns the key of a prefix given as model files spell it,
+// its words separated by single spaces and `""` for the empty slots.
+func emptySlotKey(prefix string) string {
+	words := Prefix(strings.Split(prefix, " "))
+	for i, w := range words {
+		if w == `""` {
+			words[i] = ""
+		}
+	}
+	return words.key()
+}
+
+// share returns the fraction of total that the counts of m make up.
+func share(m map[string]int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	n := 0
+	for _, f := range m {
+		n += f
+	}
+	return float64(n) / float64(total)
+}
+
+// jsDivergence returns the Jensen-Shannon divergence in bits between the
+// distributions given by the counts p and q.
+func jsDivergence(p, q map[string]int) float64 {
+	var np, nq float64
+	for _, f := range p {
+		np += float64(f)
+	}
+	for _, f := range q {
+		nq += float64(f)
+	}
+	// JS = H(M) - (H(P)+H(Q))/2 with M the mixture, summed term by term
+	// over the union of the words, in sorted order for reproducibility.
+	words := make(map[string]bool)
+	for w := range p {
+		words[w] = true
+	}
+	for w := range q {
+		words[w] = true
+	}
+	var js float64
+	for _, w := range sortedKeys(words) {
+		pw, qw := float64(p[w])/np, float64(q[w])/nq
+		m := (pw + qw) / 2
+		if pw > 0 {
+			js += pw / 2 * math.Log2(pw/m)
+		}
+		if qw > 0 {
+			js += qw / 2 * math.Log2(qw/m)
+		}
+	}
+	return math.Max(0, math.Min(1, js))
+}
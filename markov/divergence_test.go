@@ -0,0 +1,91 @@
+package markov
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestJSDivergence(t *testing.T) {
+	// By JS = H(M) - (H(P)+H(Q))/2, with H(3/4, 1/4) = 0.811278 bits.
+	tests := []struct {
+		p, q map[string]int
+		want float64
+	}{
+		{map[string]int{"a": 1}, map[string]int{"a": 5}, 0},
+		{map[string]int{"a": 1}, map[string]int{"b": 1}, 1},
+		{map[string]int{"a": 1, "b": 1}, map[string]int{"a": 1}, 0.811278 - 0.5},
+		{map[string]int{"a": 2, "b": 2}, map[string]int{"a": 7}, 0.811278 - 0.5},
+		{map[string]int{"a": 3, "b": 1}, map[string]int{"a": 1, "b": 3}, 1 - 0.811278},
+		{map[string]int{"a": 1, "b": 1}, map[string]int{"b": 1, "c": 1}, 0.5},
+	}
+	for _, tt := range tests {
+		if got := jsDivergence(tt.p, tt.q); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("JS(%v, %v) = %.6f, want %.6f", tt.p, tt.q, got, tt.want)
+		}
+		if got, back := jsDivergence(tt.p, tt.q), jsDivergence(tt.q, tt.p); got != back {
+			t.Errorf("JS(%v, %v) = %v but the other way round %v", tt.p, tt.q, got, back)
+		}
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	a, b := newChain(1), newChain(1)
+	a.add(Prefix{"x"}.key(), "a", 1)
+	a.add(Prefix{"x"}.key(), "b", 1)
+	a.add(Prefix{"y"}.key(), "a", 2)
+	b.add(Prefix{"x"}.key(), "a", 2)
+	b.add(Prefix{"z"}.key(), "c", 2)
+	// x is half of both chains; y and z are a quarter of one chain each,
+	// and count as maximally divergent.
+	js := 0.811278 - 0.5
+	want := DivergenceReport{
+		Prefixes: 3, Shared: 1, OnlyA: 1, OnlyB: 1,
+		SharedJS: js,
+		JS:       0.5*js + 0.25 + 0.25,
+		Top:      []PrefixDivergence{{"y", 1, 0.25}, {"z", 1, 0.25}, {"x", js, 0.5}},
+	}
+	got := Divergence(a, b)
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-6 }
+	if got.Prefixes != want.Prefixes || got.Shared != want.Shared || got.OnlyA != want.OnlyA || got.OnlyB != want.OnlyB ||
+		!near(got.SharedJS, want.SharedJS) || !near(got.JS, want.JS) || len(got.Top) != len(want.Top) {
+		t.Fatalf("Divergence = %+v, want %+v", got, want)
+	}
+	for i, d := range got.Top {
+		if w := want.Top[i]; d.Prefix != w.Prefix || !near(d.JS, w.JS) || !near(d.Weight, w.Weight) {
+			t.Errorf("top %d = %+v, want %+v", i+1, d, w)
+		}
+	}
+	if self := Divergence(a, a); self.JS != 0 || self.Shared != 2 || self.Top[0].JS != 0 {
+		t.Errorf("Divergence of a chain with itself = %+v, want no divergence", self)
+	}
+}
+
+// TestDivergenceDeterministic compares chains with more prefixes than the
+// report lists, many of them tied, and checks that the report comes out
+// the same every time, also for copies of the chains read back from their
+// model files, whose maps are filled in another order.
+func TestDivergenceDeterministic(t *testing.T) {
+	a, b := synthChain(t, 3000), synthChain(t, 2000)
+	reload := func(c *Chain) *Chain {
+		var buf bytes.Buffer
+		if _, err := c.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		read := new(Chain)
+		if _, err := read.ReadFrom(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return read
+	}
+	want := Divergence(a, b)
+	if len(want.Top) != divergenceTop {
+		t.Fatalf("report lists %d prefixes, want %d", len(want.Top), divergenceTop)
+	}
+	for i := 0; i < 5; i++ {
+		if got := Divergence(reload(a), reload(b)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: Divergence = %+v, want %+v", i+1, got, want)
+		}
+	}
+}
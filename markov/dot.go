@@ -0,0 +1,123 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DotOptions configures WriteDOT. The zero value draws the whole chain.
+type DotOptions struct {
+	// MinCount leaves out the transitions seen fewer times than this.
+	MinCount int
+	// MaxNodes, if positive, keeps only this many prefixes, those with the
+	// highest total frequency of the transitions drawn into and out of
+	// them, and the transitions between them.
+	MaxNodes int
+}
+
+// dotEdge is a transition WriteDOT draws.
+type dotEdge struct {
+	from, to string // prefix keys, or dotEnd
+	word     string
+	count    int
+}
+
+// dotEnd is the node the transitions to EndToken lead to.
+const dotEnd = "\x00end"
+
+// WriteDOT writes the transition graph of c to w in the DOT language of
+// GraphViz, for looking at the chains of small corpora with dot -Tsvg.
+// Nodes are prefixes and every suffix is an edge from its prefix to the
+// prefix shifted by it, labeled with the word; the width and weight of an
+// edge grow with its frequency. Transitions to EndToken all lead to one
+// end node. opts prunes the graph of big models, which is unreadable
+// otherwise. Nodes and edges are written in sorted order, so the same
+// chain always produces the same graph.
+func (c *Chain) WriteDOT(w io.Writer, opts DotOptions) error {
+	defer c.beginRead()()
+	if err := c.materialize(); err != nil {
+		return err
+	}
+	var edges []dotEdge
+	touch := make(map[string]int)
+	maxCount := 0
+	for _, key := range sortedKeys(c.chain) {
+		from := key
+		for _, s := range c.chain[key] {
+			if s.frequency < opts.MinCount {
+				continue
+			}
+			to := dotEnd
+			if s.word != EndToken {
+				p := splitKey(from)
+				p.Shift(s.word)
+				to = p.key()
+			}
+			edges = append(edges, dotEdge{from, to, s.word, s.frequency})
+			touch[from] += s.frequency
+			touch[to] += s.frequency
+			if s.frequency > maxCount {
+				maxCount = s.frequency
+			}
+		}
+	}
+	nodes := sortedKeys(touch)
+	if opts.MaxNodes > 0 && len(nodes) > opts.MaxNodes {
+		sort.SliceStable(nodes, func(i, j int) bool { return touch[nodes[i]] > touch[nodes[j]] })
+		nodes = nodes[:opts.MaxNodes]
+		sort.Strings(nodes)
+	}
+	ids := make(map[string]string, len(nodes))
+	for i, n := range nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph markov {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for _, n := range nodes {
+		if n == dotEnd {
+			fmt.Fprintf(bw, "\t%s [label=%s, shape=doublecircle];\n", ids[n], dotQuote("end"))
+			continue
+		}
+		words := splitKey(n)
+		for i, word := range words {
+			words[i] = dotWord(word)
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s];\n", ids[n], dotQuote(strings.Join(words, " ")))
+	}
+	for _, e := range edges {
+		from, ok1 := ids[e.from]
+		to, ok2 := ids[e.to]
+		if !ok1 || !ok2 {
+			continue
+		}
+		width := 1 + 4*float64(e.count)/float64(maxCount)
+		fmt.Fprintf(bw, "\t%s -> %s [label=%s, weight=%d, penwidth=%.2f];\n", from, to, dotQuote(dotWord(e.word)), e.count, width)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotWord returns how WriteDOT shows a word: empty slots and ParagraphToken
+// and EndToken, which are control characters, by their ReservedToken names.
+func dotWord(word string) string {
+	if word == "" {
+		return "[" + reservedLiterals()[`""`] + "]"
+	}
+	if word == ParagraphToken || word == EndToken {
+		return "[" + reservedLiterals()[word] + "]"
+	}
+	return word
+}
+
+// dotQuote returns s as a DOT string literal.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
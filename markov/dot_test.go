@@ -0,0 +1,36 @@
+package markov
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestWriteDOTGolden pins the graph of the example of the package comment,
+// whole and pruned.
+func TestWriteDOTGolden(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader("I am not a number! I am a free man!")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		golden string
+		opts   DotOptions
+	}{
+		{"number.dot.golden", DotOptions{}},
+		{"number.pruned.dot.golden", DotOptions{MinCount: 1, MaxNodes: 4}},
+	} {
+		var buf bytes.Buffer
+		if err := c.WriteDOT(&buf, tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, tt.golden, buf.Bytes())
+	}
+	var none bytes.Buffer
+	if err := c.WriteDOT(&none, DotOptions{MinCount: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(none.String(), "->") {
+		t.Errorf("with MinCount 2 the graph of transitions seen once has edges:\n%s", none.String())
+	}
+}
@@ -0,0 +1,68 @@
+package markov
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEndToken builds documents with and without their ends and checks
+// where EndToken is counted, that drawing it ends the text, and that it
+// stays apart from words spelled like it in every format.
+func TestEndToken(t *testing.T) {
+	build := func(opts BuildOptions, docs ...string) *Chain {
+		c := newChain(2)
+		for _, doc := range docs {
+			if _, err := c.BuildReaderOpts("", strings.NewReader(doc), opts); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return c
+	}
+	// The corpus spells the end token as model files and DOT graphs do.
+	docs := []string{"one two three.", `four five %0B \v end`}
+
+	c := build(BuildOptions{}, docs...)
+	for _, tt := range []struct {
+		prefix Prefix
+		want   []Suffix
+	}{
+		{Prefix{"two", "three."}, []Suffix{{EndToken, 1}}},
+		{Prefix{"five", "%0B"}, []Suffix{{`\v`, 1}}},
+		{Prefix{"%0B", `\v`}, []Suffix{{"end", 1}}},
+		{Prefix{`\v`, "end"}, []Suffix{{EndToken, 1}}},
+	} {
+		if got := c.Suffixes(tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suffixes of %q = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+	for seed := int64(1); seed <= 10; seed++ {
+		words, res := c.GenerateWordsChecked(100, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		if got := strings.Join(words, " "); (got != docs[0] && got != docs[1]) || res.Stop != StopEnd {
+			t.Errorf("seed %d: generated %q, stopping at %s, want a whole document, stopping at %s", seed, got, res.Stop, StopEnd)
+		}
+	}
+	checkRoundTrip(t, c, "")
+
+	// Without ends the documents stop at a dead end, and with
+	// EndParagraphs every paragraph ends.
+	noEnd := build(BuildOptions{NoEndToken: true}, docs...)
+	for key, suf := range noEnd.chain {
+		for _, s := range suf {
+			if s.word == EndToken {
+				t.Errorf("NoEndToken counted an end after %q", splitKey(key))
+			}
+		}
+	}
+	if _, res := noEnd.GenerateWordsChecked(100, GenerateOptions{}); res.Stop != StopDeadEnd {
+		t.Errorf("without ends generation stopped at %s, want %s", res.Stop, StopDeadEnd)
+	}
+	paragraphs := build(BuildOptions{EndParagraphs: true}, "one two.\n\nthree four.")
+	if got, want := paragraphs.Suffixes(Prefix{"one", "two."}), []Suffix{{EndToken, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with EndParagraphs the first paragraph ends in %v, want %v", got, want)
+	}
+	if got, want := paragraphs.Suffixes(Prefix{"", ""}), []Suffix{{"one", 1}, {"three", 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with EndParagraphs the documents start with %v, want %v", got, want)
+	}
+}
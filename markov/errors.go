@@ -0,0 +1,83 @@
+package markov
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrEmptyModel is returned when a model has no transitions to sample from.
+var ErrEmptyModel = errors.New("model is empty")
+
+// ErrDeadEnd is returned when the chain has no continuation for the
+// current context.
+var ErrDeadEnd = errors.New("no continuation for the current context")
+
+// ErrUnsatisfiable is returned by generation modes whose constraints
+// (required words, bridges, patterns) no sampled text could satisfy.
+var ErrUnsatisfiable = errors.New("generation constraint cannot be satisfied")
+
+// ErrUnsupportedVersion is returned for model files written in a newer
+// version of the format than FormatVersion.
+var ErrUnsupportedVersion = errors.New("unsupported model format version")
+
+// ErrUnknownPrefix is returned by GenerateFrom when the chain does not know
+// the context of the seed, so that callers can fall back to Generate.
+var ErrUnknownPrefix = errors.New("seed is not a prefix of the model")
+
+// FileError is the failure to read one input of a build.
+type FileError struct {
+	Name string
+	Err  error // names the file already
+}
+
+func (e *FileError) Error() string { return e.Err.Error() }
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// BuildError is returned by Build when some of its inputs could not be
+// read. The build went on without them: the chain and the BuildReport
+// cover every other input, and the report marks the failed ones.
+type BuildError struct {
+	Files []*FileError
+}
+
+func (e *BuildError) Error() string {
+	msgs := make([]string, len(e.Files))
+	for i, f := range e.Files {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("cannot read %d of the inputs: %s", len(e.Files), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the FileErrors, for errors.Is and errors.As.
+func (e *BuildError) Unwrap() []error {
+	errs := make([]error, len(e.Files))
+	for i, f := range e.Files {
+		errs[i] = f
+	}
+	return errs
+}
+
+// ChecksumError reports a model file whose checksum record, which ends
+// every file from format version 4 on, is missing or does not match its
+// content. Missing is set for files of older versions, which have none:
+// ReadFreTable, OpenFreTableMmap and Chain.ReadFrom read those all the
+// same and return the chain along with the error, for callers to warn
+// about. Otherwise the file is truncated or corrupt and nothing is read.
+type ChecksumError struct {
+	Name    string // the model file; empty for Chain.ReadFrom
+	Missing bool
+	Problem string // what is wrong, unless Missing
+}
+
+func (e *ChecksumError) Error() string {
+	msg := e.Problem + ": the model file is truncated or corrupt"
+	if e.Missing {
+		msg = "the model file has no checksum, as files before format version 4; it cannot be checked for damage"
+	}
+	if e.Name == "" {
+		return msg
+	}
+	return e.Name + ": " + msg
+}
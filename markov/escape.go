@@ -0,0 +1,172 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escapedVersion is the first format version whose model files escape
+// their words, see escapeWord.
+const escapedVersion = 3
+
+// escapeWord returns word as model files from version 3 on spell it: a
+// percent sign and every white space rune, which would otherwise split the
+// word or its line, are written as %XX, one per byte of their UTF-8
+// encoding. ParagraphToken and EndToken, which are white space, are words
+// of their own and written as they are, as are all other words but `""`,
+// which would read as an empty slot and is written %22%22.
+func escapeWord(word string) string {
+	if word == `""` {
+		return "%22%22"
+	}
+	if word == ParagraphToken || word == EndToken || !strings.ContainsFunc(word, escapedRune) {
+		return word
+	}
+	var b strings.Builder
+	for i := 0; i < len(word); {
+		r, size := utf8.DecodeRuneInString(word[i:])
+		if !escapedRune(r) {
+			b.WriteString(word[i : i+size]) // as it is, even if not UTF-8
+		} else {
+			for _, c := range []byte(word[i : i+size]) {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// escapedRune reports whether escapeWord escapes r.
+func escapedRune(r rune) bool {
+	return r == '%' || unicode.IsSpace(r)
+}
+
+// unescapeWord is the inverse of escapeWord. It fails on a percent sign
+// not followed by two hex digits, and on %00, as no word can hold a NUL
+// byte, see keySep.
+func unescapeWord(word string) (string, error) {
+	if !strings.Contains(word, "%") {
+		return word, nil
+	}
+	b := make([]byte, 0, len(word))
+	for i := 0; i < len(word); i++ {
+		if word[i] != '%' {
+			b = append(b, word[i])
+			continue
+		}
+		hi, lo := -1, -1
+		if i+2 < len(word) {
+			hi, lo = unhex(word[i+1]), unhex(word[i+2])
+		}
+		if hi < 0 || lo < 0 {
+			return "", fmt.Errorf("bad escape in %q", word)
+		}
+		b = append(b, byte(hi<<4|lo))
+		i += 2
+	}
+	if bytes.Contains(b, []byte(keySep)) {
+		return "", fmt.Errorf("bad word %q: contains a NUL byte", word)
+	}
+	return string(b), nil
+}
+
+// unhex returns the value of the hex digit c, or -1.
+func unhex(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c - 'a' + 10)
+	case 'A' <= c && c <= 'F':
+		return int(c - 'A' + 10)
+	}
+	return -1
+}
+
+// fileWord returns a prefix word or record field as model files spell it:
+// the empty slot as `""`, other words escaped.
+func fileWord(word string) string {
+	if word == "" {
+		return `""`
+	}
+	return escapeWord(word)
+}
+
+// readWord is the inverse of fileWord for model files of the given
+// version. Files of every version spell the empty slot `""`; before
+// version 3 they did not escape words, so that a word `""` of their corpus
+// reads as an empty slot too.
+func readWord(word string, version int) (string, error) {
+	if word == `""` {
+		return "", nil
+	}
+	if version < escapedVersion {
+		return word, nil
+	}
+	return unescapeWord(word)
+}
+
+// readKey returns the chain key of the prefix of a table line of a model
+// file, its words read with readWord. Words with a bad escape are kept as
+// they are, for parseTableLine to report.
+func readKey(key string, version int) string {
+	if !strings.Contains(key, `"`) && (version < escapedVersion || !strings.Contains(key, "%")) {
+		return strings.ReplaceAll(key, " ", keySep)
+	}
+	words := Prefix(strings.Split(key, " "))
+	for i, w := range words {
+		if r, err := readWord(w, version); err == nil {
+			words[i] = r
+		}
+	}
+	return words.key()
+}
+
+// unescapeFields applies unescapeWord to fields in place.
+func unescapeFields(fields []string) error {
+	for i, f := range fields {
+		w, err := unescapeWord(f)
+		if err != nil {
+			return err
+		}
+		fields[i] = w
+	}
+	return nil
+}
+
+// readFields splits an extension record line of a model file of the
+// given version and prefix length into its fields, with readWord from
+// version 3 on.
+func readFields(line string, version, prefixLen int) ([]string, error) {
+	fields := recordFields(line)
+	if version < escapedVersion {
+		legacyRecord(fields, prefixLen)
+		return fields, nil
+	}
+	for i, f := range fields {
+		var err error
+		if fields[i], err = readWord(f, version); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// legacyRecord turns the `""` empty slots of the prefix of a position or
+// start record in fields into "". Model files before version 3, and JSON
+// and gob models without a version, spelled them so, and any other word
+// of them as it is.
+func legacyRecord(fields []string, prefixLen int) {
+	if len(fields) <= prefixLen || fields[0] != "position" && fields[0] != "start" {
+		return
+	}
+	for i, f := range fields[1 : 1+prefixLen] {
+		if f == `""` {
+			fields[1+i] = ""
+		}
+	}
+}
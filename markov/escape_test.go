@@ -0,0 +1,164 @@
+package markov
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// trickyWords are words the text model format must escape.
+var trickyWords = []string{
+	"a b", "new\nline", "tab\tbed", `""`, `"quoted"`, "100%", "%41", "non breaking",
+	" ", " ", "end.",
+}
+
+// addWords adds words to c as the build loop would, from the start state.
+func addWords(c *Chain, words []string) {
+	p := make(Prefix, c.prefixLen)
+	for _, w := range words {
+		c.add(p.key(), w, 1)
+		p.Shift(w)
+	}
+}
+
+func TestEscapeWordRoundTrip(t *testing.T) {
+	for _, w := range append(trickyWords, "plain", ParagraphToken, EndToken) {
+		esc := escapeWord(w)
+		if w != ParagraphToken && w != EndToken && strings.ContainsFunc(esc, unicode.IsSpace) {
+			t.Errorf("escapeWord(%q) = %q holds white space", w, esc)
+		}
+		if got, err := readWord(esc, FormatVersion); err != nil || got != w {
+			t.Errorf("readWord(escapeWord(%q)) = %q, %v", w, got, err)
+		}
+	}
+	if _, err := unescapeWord("a%00b"); err == nil {
+		t.Errorf("unescapeWord accepted a NUL byte")
+	}
+}
+
+func FuzzEscapeWord(f *testing.F) {
+	for _, w := range trickyWords {
+		f.Add(w)
+	}
+	f.Fuzz(func(t *testing.T, w string) {
+		if w == "" || strings.Contains(w, keySep) {
+			t.Skip()
+		}
+		if got, err := readWord(fileWord(w), FormatVersion); err != nil || got != w {
+			t.Errorf("readWord(fileWord(%q)) = %q, %v", w, got, err)
+		}
+	})
+}
+
+// TestModelRoundTripEscaped checks that words holding white space, quotes
+// and percent signs survive the text and gob formats, and that no prefix
+// is split at a space inside one of its words.
+func TestModelRoundTripEscaped(t *testing.T) {
+	for _, n := range []int{1, 2, 3} {
+		c := newChain(n)
+		addWords(c, trickyWords)
+		addWords(c, []string{"a", "b", "a b", EndToken})
+
+		var text bytes.Buffer
+		if _, err := c.WriteTo(&text); err != nil {
+			t.Fatal(err)
+		}
+		fromText := new(Chain)
+		if _, err := fromText.ReadFrom(&text); err != nil {
+			t.Fatalf("prefix length %d: %v", n, err)
+		}
+		var gob bytes.Buffer
+		if err := c.WriteGob(&gob); err != nil {
+			t.Fatal(err)
+		}
+		fromGob, err := ReadGob(&gob)
+		if err != nil {
+			t.Fatalf("prefix length %d: %v", n, err)
+		}
+
+		for name, read := range map[string]*Chain{"text": fromText, "gob": fromGob} {
+			if !reflect.DeepEqual(read.chain, c.chain) {
+				t.Errorf("prefix length %d: %s model read back as %v, want %v", n, name, read.chain, c.chain)
+			}
+			for key := range read.chain {
+				if len(splitKey(key)) != n {
+					t.Errorf("prefix length %d: %s model has prefix %q", n, name, splitKey(key))
+				}
+			}
+		}
+	}
+}
+
+func TestBuildNULAsSpace(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader("a\x00b a"), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for w := range c.Vocabulary() {
+		if strings.Contains(w, keySep) {
+			t.Errorf("built word %q holds a NUL byte", w)
+		}
+	}
+	if got := c.Suffixes(Prefix{"a", "b"}); len(got) != 1 || got[0].word != "a" {
+		t.Errorf("suffixes of a b = %v, want a", got)
+	}
+}
+
+// TestLiteralEmptyQuotes trains on a text holding the word `""`, which
+// model files also use to spell the empty slots of prefixes, and checks
+// that the start of the text, the model file and generation keep them
+// apart.
+func TestLiteralEmptyQuotes(t *testing.T) {
+	const text = `"" is empty. print "" now.`
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader(text), BuildOptions{NoEndToken: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		prefix Prefix
+		want   []Suffix
+	}{
+		{Prefix{"", ""}, []Suffix{{`""`, 1}}},
+		{Prefix{"", `""`}, []Suffix{{"is", 1}}},
+		{Prefix{`""`, "is"}, []Suffix{{"empty.", 1}}},
+		{Prefix{"print", `""`}, []Suffix{{"now.", 1}}},
+		{Prefix{`""`, `""`}, nil},
+	} {
+		if got := c.Suffixes(tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suffixes of %q = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\"\" \"\" %22%22 1") {
+		t.Errorf("the start of the model file does not tell the word from the empty slots:\n%s", buf.String())
+	}
+	checkRoundTrip(t, c, "")
+	for name, roundTrip := range formats {
+		read, err := roundTrip(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(read.GenerateWords(20, GenerateOptions{}), " "); got != text {
+			t.Errorf("%s: generated %q, want %q", name, got, text)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "m.txt")
+	if err := c.WriteFreTable(file); err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := OpenFreTableMmap(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mapped.Close()
+	if got := strings.Join(mapped.GenerateWords(20, GenerateOptions{}), " "); got != text {
+		t.Errorf("mapped: generated %q, want %q", got, text)
+	}
+}
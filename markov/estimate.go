@@ -0,0 +1,116 @@
+package markov
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+)
+
+// How EstimateBuild samples a corpus: the first and last sampleBytes of
+// at most sampleFiles files spread over the input.
+const (
+	sampleBytes = 100 << 10
+	sampleFiles = 4
+)
+
+// BuildEstimate is the guess of read -dry-run at the model a build would
+// produce.
+type BuildEstimate struct {
+	SampledBytes  int64 `json:"sampled_bytes"`
+	SampledTokens int   `json:"sampled_tokens"`
+	Tokens        int   `json:"tokens"`
+	Prefixes      int   `json:"prefixes"`
+	SuffixEntries int   `json:"suffix_entries"`
+	MemoryBytes   int64 `json:"memory_bytes"`
+}
+
+// EstimateBuild estimates the size of the chain of the given prefix length
+// built from files, without reading them whole. It builds a chain from a
+// sample of the corpus and extrapolates the growth of its prefixes and
+// suffix entries between the first half and the whole of the sample, as a
+// power of the number of words (Heaps' law), to the size of the corpus.
+func EstimateBuild(files []InputFile, prefixLen int) (BuildEstimate, error) {
+	var est BuildEstimate
+	var total int64
+	for _, f := range files {
+		total += f.Bytes
+	}
+	var tokens [][]byte
+	step := 1
+	if len(files) > sampleFiles {
+		step = len(files) / sampleFiles
+	}
+	for i := 0; i < len(files) && i/step < sampleFiles; i += step {
+		sample, err := sampleFile(files[i])
+		if err != nil {
+			return est, err
+		}
+		est.SampledBytes += int64(len(sample))
+		tokens = append(tokens, bytes.Fields(sample)...)
+	}
+	est.SampledTokens = len(tokens)
+	if len(tokens) == 0 {
+		return est, nil
+	}
+
+	c := newChain(prefixLen)
+	p := make(Prefix, prefixLen)
+	var halfPrefixes, halfEntries, entries int
+	var text int64
+	for i, tok := range tokens {
+		if i == len(tokens)/2 {
+			halfPrefixes, halfEntries = len(c.chain), entries
+		}
+		key, word := p.key(), string(tok)
+		if _, ok := c.chain[key]; !ok {
+			text += int64(len(key))
+		}
+		before := len(c.chain[key])
+		c.add(key, word, 1)
+		if len(c.chain[key]) > before {
+			entries++
+			text += int64(len(word))
+		}
+		p.Shift(word)
+	}
+
+	scale := float64(total) / float64(est.SampledBytes)
+	grow := func(half, full int) float64 {
+		beta := 1.0
+		if half > 0 && full > half {
+			beta = math.Log(float64(full)/float64(half)) / math.Ln2
+		}
+		beta = math.Max(0.3, math.Min(1, beta))
+		return float64(full) * math.Pow(scale, beta)
+	}
+	est.Tokens = int(float64(len(tokens)) * scale)
+	est.Prefixes = int(grow(halfPrefixes, len(c.chain)))
+	est.SuffixEntries = int(grow(halfEntries, entries))
+	textPerEntry := float64(text) / float64(entries)
+	est.MemoryBytes = estimateBytes(est.Prefixes, est.SuffixEntries, int64(textPerEntry*float64(est.SuffixEntries)))
+	return est, nil
+}
+
+// sampleFile returns the first and last sampleBytes of f, or all of it if
+// it is not much longer.
+func sampleFile(f InputFile) ([]byte, error) {
+	in, err := os.Open(f.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	if f.Bytes <= 2*sampleBytes {
+		return io.ReadAll(in)
+	}
+	buf := make([]byte, 2*sampleBytes)
+	if _, err := io.ReadFull(in, buf[:sampleBytes]); err != nil {
+		return nil, err
+	}
+	if _, err := in.ReadAt(buf[sampleBytes:], f.Bytes-sampleBytes); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// Cut the words torn apart at the seam.
+	buf[sampleBytes-1], buf[sampleBytes] = ' ', ' '
+	return buf, nil
+}
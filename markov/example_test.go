@@ -0,0 +1,66 @@
+package markov_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+
+	"github.com/xiaoxulv/go_mark/markov"
+)
+
+func ExampleChain_Generate() {
+	c, err := markov.NewChain(2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Every prefix of this text is followed by one word only, so the text
+	// comes out the same whatever is drawn.
+	if _, err := c.BuildReader(strings.NewReader("the quick brown fox jumps over the lazy dog.")); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(c.Generate(100))
+	// Output: the quick brown fox jumps over the lazy dog.
+}
+
+func ExampleChain_GenerateWithRand() {
+	c := markov.TinyModel()
+	a := c.GenerateWithRand(rand.New(rand.NewSource(7)), 20)
+	b := c.GenerateWithRand(rand.New(rand.NewSource(7)), 20)
+	fmt.Println(a == b)
+	// Output: true
+}
+
+func ExampleChain_Merge() {
+	a, b := markov.TinyModel(), markov.TinyModel()
+	if err := a.AddText(strings.NewReader("the cat slept.")); err != nil {
+		log.Fatal(err)
+	}
+	if err := b.Merge(a); err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range b.Distribution([]string{"the", "cat"}) {
+		fmt.Printf("%s %d %.2f\n", s.Word, s.Count, s.Probability)
+	}
+	// Output:
+	// ran. 2 0.40
+	// sat 2 0.40
+	// slept. 1 0.20
+}
+
+func ExampleReadJSON() {
+	var buf bytes.Buffer
+	if err := markov.TinyModel().WriteJSON(&buf); err != nil {
+		log.Fatal(err)
+	}
+	c, err := markov.ReadJSON(&buf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(c.PrefixLen(), c.Hash() == markov.TinyModelHash)
+	fmt.Println(c.Suffixes(markov.Prefix{"sat", "on"}))
+	// Output:
+	// 2 true
+	// [{the 2}]
+}
@@ -0,0 +1,64 @@
+//go:build linux
+
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openFiles returns the number of file descriptors the process has open.
+func openFiles(t *testing.T) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip(err)
+	}
+	return len(fds)
+}
+
+// TestFailuresCloseFiles fails builds and loads over and over, after they
+// have opened their files, and checks that no descriptor is left open.
+func TestFailuresCloseFiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	bad := filepath.Join(dir, "bad.model")
+	if err := os.WriteFile(good, []byte(tinyCorpus), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte(badModel), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	fail := map[string]func() error{
+		// A directory opens, and fails when read.
+		"build": func() error {
+			_, err := newChain(2).BuildOpts([]string{good, dir, good}, BuildOptions{})
+			return err
+		},
+		"read": func() error {
+			_, err := ReadFreTable(bad)
+			return err
+		},
+		"mmap": func() error {
+			c, err := OpenFreTableMmap(bad)
+			if c == nil {
+				return err
+			}
+			defer c.Close()
+			return c.materialize()
+		},
+	}
+	for name, f := range fail {
+		if f() == nil {
+			t.Fatalf("%s did not fail", name)
+		}
+		before := openFiles(t)
+		for i := 0; i < 50; i++ {
+			f()
+		}
+		if after := openFiles(t); after > before {
+			t.Errorf("50 failed %s calls left %d files open", name, after-before)
+		}
+	}
+}
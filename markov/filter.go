@@ -0,0 +1,120 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// FilterCommand returns the external token filter command to run under
+// ctx. A fresh command is requested for every input file.
+//
+// The filter protocol: the command reads tokens from stdin, one per line,
+// and must write exactly one line to stdout for every line it reads, in
+// the same order. An output line is the replacement token; an empty line
+// drops the token. Output lines must not contain white space or NUL bytes.
+// The command must exit with status 0 after its stdin is closed; anything
+// it writes to stderr is included in error messages.
+type FilterCommand func(ctx context.Context) *exec.Cmd
+
+// ShellFilter returns a FilterCommand running the program and arguments
+// given in command, split at white space (there is no quoting).
+func ShellFilter(command string) FilterCommand {
+	args := strings.Fields(command)
+	return func(ctx context.Context) *exec.Cmd {
+		return exec.CommandContext(ctx, args[0], args[1:]...)
+	}
+}
+
+// DefaultFilterTimeout bounds a filter run when BuildOptions.FilterTimeout is zero.
+const DefaultFilterTimeout = time.Minute
+
+// runFilter pipes tokens through the command made by newCmd and returns the
+// tokens it writes back, with dropped tokens removed.
+func runFilter(newCmd FilterCommand, tokens []string, timeout time.Duration, vocab interner) ([]string, error) {
+	if timeout <= 0 {
+		timeout = DefaultFilterTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := newCmd(ctx)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		bw := bufio.NewWriter(stdin)
+		for _, t := range tokens {
+			bw.WriteString(t)
+			bw.WriteByte('\n')
+		}
+		err := bw.Flush()
+		if cerr := stdin.Close(); err == nil {
+			err = cerr
+		}
+		written <- err
+	}()
+
+	out := make([]string, 0, len(tokens))
+	lines := 0
+	var bad error
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 4096), maxTokenSize)
+	for scanner.Scan() {
+		lines++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if bytes.ContainsAny(line, " \t\r\v\f"+keySep) && bad == nil {
+			bad = fmt.Errorf("filter: output line %d %q contains white space or a NUL byte", lines, line)
+		}
+		out = append(out, vocab.intern(line))
+	}
+	scanErr := scanner.Err()
+	writeErr := <-written
+	waitErr := cmd.Wait()
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("filter: timed out after %v", timeout)
+	case waitErr != nil:
+		return nil, fmt.Errorf("filter: %v%s", waitErr, stderrNote(&stderr))
+	case writeErr != nil:
+		return nil, fmt.Errorf("filter: exited before reading all %d tokens: %v", len(tokens), writeErr)
+	case scanErr != nil:
+		return nil, fmt.Errorf("filter: reading output: %v", scanErr)
+	case lines != len(tokens):
+		return nil, fmt.Errorf("filter: wrote %d lines for %d tokens; it must write one line per token", lines, len(tokens))
+	case bad != nil:
+		return nil, bad
+	}
+	return out, nil
+}
+
+// stderrNote formats the first line of a filter's stderr for an error message.
+func stderrNote(stderr *bytes.Buffer) string {
+	msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+	if msg == "" {
+		return ""
+	}
+	return ": " + msg
+}
@@ -0,0 +1,109 @@
+package markov
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helperFilter returns a FilterCommand running this test binary as the
+// filter of the given mode, see TestFilterHelperProcess.
+func helperFilter(mode string) FilterCommand {
+	return func(ctx context.Context) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestFilterHelperProcess$")
+		cmd.Env = append(os.Environ(), "GOMARK_FILTER_MODE="+mode)
+		return cmd
+	}
+}
+
+// TestFilterHelperProcess is not a test but the filter helperFilter runs,
+// an in-process stand-in for tr and for filters that misbehave.
+func TestFilterHelperProcess(t *testing.T) {
+	mode := os.Getenv("GOMARK_FILTER_MODE")
+	if mode == "" {
+		return
+	}
+	in := bufio.NewScanner(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+	switch mode {
+	case "upper":
+		for in.Scan() {
+			fmt.Fprintln(out, strings.ToUpper(in.Text()))
+		}
+	case "drop-the":
+		for in.Scan() {
+			if in.Text() != "the" {
+				out.WriteString(in.Text())
+			}
+			out.WriteByte('\n')
+		}
+	case "early-exit":
+		in.Scan()
+		fmt.Fprintln(out, in.Text())
+	case "no-read":
+	case "space":
+		for in.Scan() {
+			fmt.Fprintln(out, "two words")
+		}
+	case "fail":
+		fmt.Fprintln(os.Stderr, "dictionary not found")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	out.Flush()
+	os.Exit(0)
+}
+
+func TestRunFilter(t *testing.T) {
+	tokens := strings.Fields("the cat sat on the mat")
+	many := strings.Fields(strings.Repeat("lots of words to fill the pipe ", 20000))
+	for _, tt := range []struct {
+		mode   string
+		tokens []string
+		want   string // the tokens out, or a part of the error
+	}{
+		{"upper", tokens, "THE CAT SAT ON THE MAT"},
+		{"drop-the", tokens, "cat sat on mat"},
+		{"early-exit", tokens, "wrote 1 lines for 6 tokens"},
+		{"no-read", many, "exited before reading all"},
+		{"space", tokens, "contains white space"},
+		{"fail", tokens, "exit status 3: dictionary not found"},
+		{"hang", tokens, "timed out"},
+	} {
+		timeout := 10 * time.Second
+		if tt.mode == "hang" {
+			timeout = 200 * time.Millisecond
+		}
+		got, err := runFilter(helperFilter(tt.mode), tt.tokens, timeout, make(interner))
+		if err != nil {
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: %v, want %q", tt.mode, err, tt.want)
+			}
+			continue
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%s: %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestBuildFilter(t *testing.T) {
+	c := newChain(1)
+	opts := BuildOptions{Filter: helperFilter("upper")}
+	if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader("the cat sat"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Suffixes(Prefix{"CAT"}); len(got) != 1 || got[0].Word() != "SAT" {
+		t.Errorf("suffixes of CAT = %v, want SAT", got)
+	}
+	opts.Filter = helperFilter("fail")
+	if _, err := newChain(1).BuildReaderOpts("corpus.txt", strings.NewReader("the cat sat"), opts); err == nil || !strings.Contains(err.Error(), "dictionary not found") {
+		t.Errorf("Build with a failing filter: %v", err)
+	}
+}
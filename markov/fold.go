@@ -0,0 +1,116 @@
+package markov
+
+import "strings"
+
+// FoldReport tells what FoldCaseVariants merged.
+type FoldReport struct {
+	Words         int `json:"words"`          // surface forms replaced by another form
+	Prefixes      int `json:"prefixes"`       // prefixes merged into another prefix
+	SuffixEntries int `json:"suffix_entries"` // suffix entries merged into another entry
+}
+
+// FoldCaseVariants merges the words of c that differ only by case,
+// such as "The" and "the", into their most frequent surface form (the
+// smallest in byte order among equally frequent ones), summing the counts
+// of prefixes and suffixes that become equal. Unlike RemapTokens with
+// strings.ToLower, it keeps the form the text mostly used, so no case
+// statistics are needed to restore it. Chains that are case-folded
+// already are left alone.
+func (c *Chain) FoldCaseVariants() FoldReport {
+	var rep FoldReport
+	if c.caseStats != nil {
+		return rep
+	}
+	c.materialize()
+	defer c.beginWrite()()
+
+	counts := make(map[string]int)
+	for _, suf := range c.chain {
+		for _, s := range suf {
+			counts[s.word] += s.frequency
+		}
+	}
+	for key := range c.chain {
+		for _, w := range splitKey(key) {
+			if _, ok := counts[w]; !ok {
+				counts[w] = 0
+			}
+		}
+	}
+	for _, s := range c.prior {
+		if _, ok := counts[s.word]; !ok {
+			counts[s.word] = 0
+		}
+	}
+	best := make(map[string]string)
+	for _, w := range sortedKeys(counts) {
+		folded := strings.ToLower(w)
+		if b, ok := best[folded]; !ok || counts[w] > counts[b] {
+			best[folded] = w
+		}
+	}
+	rep.Words = len(counts) - len(best)
+	if rep.Words == 0 {
+		return rep
+	}
+	mapWord := func(w string) string {
+		if w == "" || w == `""` || w == ParagraphToken || w == EndToken {
+			return w
+		}
+		return best[strings.ToLower(w)]
+	}
+
+	old := c.chain
+	entries := 0
+	c.chain = make(map[string][]Suffix)
+	for _, key := range sortedKeys(old) {
+		words := splitKey(key)
+		for i, w := range words {
+			words[i] = mapWord(w)
+		}
+		newKey := words.key()
+		for _, s := range old[key] {
+			c.add(newKey, mapWord(s.word), s.frequency)
+			entries++
+		}
+	}
+	rep.Prefixes = len(old) - len(c.chain)
+	if c.positions != nil {
+		positions := c.positions
+		c.positions = nil
+		for _, key := range sortedKeys(positions) {
+			words := splitKey(key)
+			for i, w := range words {
+				words[i] = mapWord(w)
+			}
+			c.mergePositions(words.key(), positions[key])
+		}
+	}
+	if c.starts != nil {
+		starts := c.starts
+		c.starts = nil
+		for _, key := range sortedKeys(starts) {
+			words := splitKey(key)
+			for i, w := range words {
+				words[i] = mapWord(w)
+			}
+			c.addStart(words.key(), starts[key])
+		}
+	}
+	for _, suf := range c.chain {
+		entries -= len(suf)
+	}
+	rep.SuffixEntries = entries
+
+	if len(c.prior) > 0 {
+		prior := make(map[string]int)
+		for _, s := range c.prior {
+			prior[mapWord(s.word)] += s.frequency
+		}
+		c.prior = c.prior[:0]
+		for _, w := range sortedKeys(prior) {
+			c.prior = append(c.prior, Suffix{w, prior[w]})
+		}
+	}
+	return rep
+}
@@ -0,0 +1,100 @@
+package markov
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// foldedCounts returns the counts of c by case-folded prefix and word, and
+// their total.
+func foldedCounts(c *Chain) (map[string]int, int) {
+	counts := make(map[string]int)
+	total := 0
+	for key, suf := range c.chain {
+		for _, s := range suf {
+			counts[strings.ToLower(key)+"\x00"+strings.ToLower(s.word)] += s.frequency
+			total += s.frequency
+		}
+	}
+	return counts, total
+}
+
+// TestFoldCaseVariantsConservesCounts folds a chain built from a corpus
+// whose words come in random capitalizations, and checks that every count
+// went somewhere: the chain after the fold has the same total, and the
+// same count for every transition up to case.
+func TestFoldCaseVariantsConservesCounts(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	words := strings.Fields(benchCorpus(5000))
+	for i, w := range words {
+		switch r.Intn(10) {
+		case 0:
+			words[i] = strings.ToUpper(w)
+		case 1, 2:
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("corpus", strings.NewReader(strings.Join(words, " ")), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	prior := map[string]int{"worda": 3, "WORDA": 2, "wordb": 1}
+	c.SetUnigramPrior(prior)
+	before, total := foldedCounts(c)
+	prefixes := len(c.chain)
+
+	rep := c.FoldCaseVariants()
+	if rep.Words == 0 || rep.Prefixes == 0 || rep.SuffixEntries == 0 {
+		t.Errorf("FoldCaseVariants merged nothing: %+v", rep)
+	}
+	if got := prefixes - len(c.chain); got != rep.Prefixes {
+		t.Errorf("the chain lost %d prefixes, the report says %d", got, rep.Prefixes)
+	}
+	after, afterTotal := foldedCounts(c)
+	if afterTotal != total {
+		t.Errorf("the fold changed the total count from %d to %d", total, afterTotal)
+	}
+	for k, n := range before {
+		if after[k] != n {
+			t.Errorf("transition %q counted %d times before the fold, %d after", k, n, after[k])
+		}
+	}
+	// The mostly lower-case forms win, and only they are left.
+	for key, suf := range c.chain {
+		for _, s := range suf {
+			if w := strings.ToLower(s.word); s.word != w && s.word != EndToken {
+				t.Fatalf("suffix %q of %q was not folded into %q", s.word, splitKey(key), w)
+			}
+		}
+	}
+	// The prior words are not in the chain, so they tie at no count, and
+	// the smallest form in byte order wins.
+	if got := c.prior; len(got) != 2 || got[0] != (Suffix{"WORDA", 5}) || got[1] != (Suffix{"wordb", 1}) {
+		t.Errorf("folded prior = %v, want [{WORDA 5} {wordb 1}]", got)
+	}
+
+	if again := c.FoldCaseVariants(); again != (FoldReport{}) {
+		t.Errorf("folding twice merged %+v", again)
+	}
+}
+
+func TestFoldCaseVariantsRepresentative(t *testing.T) {
+	c := newChain(1)
+	corpus := "The cat. The dog. the cat. Cat! CAT ran."
+	if _, err := c.BuildReaderOpts("corpus", strings.NewReader(corpus), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	rep := c.FoldCaseVariants()
+	// "The" is twice as frequent as "the"; "cat." and "Cat!" are different
+	// words; "CAT" stands alone.
+	if want := (FoldReport{Words: 1, Prefixes: 1, SuffixEntries: 1}); rep != want {
+		t.Errorf("FoldCaseVariants = %+v, want %+v", rep, want)
+	}
+	if got := c.Suffixes(Prefix{"The"}); len(got) != 2 || got[0] != (Suffix{"cat.", 2}) || got[1] != (Suffix{"dog.", 1}) {
+		t.Errorf("suffixes of The = %v, want [{cat. 2} {dog. 1}]", got)
+	}
+	if c.Suffixes(Prefix{"the"}) != nil {
+		t.Errorf("the rarer form of the is still a prefix")
+	}
+}
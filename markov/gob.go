@@ -0,0 +1,228 @@
+package markov
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// gobModel is the structure WriteGob encodes. Every distinct word is
+// stored once, in Words, and referred to by index: prefix i is the words
+// Keys[i*PrefixLen:(i+1)*PrefixLen], and its Counts[i] suffixes follow those
+// of prefix i-1 in Suffixes and Freqs. A few big slices decode much faster
+// than a string per word and a slice per prefix. Version is the
+// FormatVersion of the writer; models without one spell empty slots `""`.
+//
+// Most suffixes of big models were seen once, so the frequencies are
+// written as Runs instead of Freqs: a run of n suffixes of frequency 1 in
+// a row is -n, and any other frequency is itself, see freqRuns. Models
+// written before Runs existed have Freqs, and are still read.
+type gobModel struct {
+	Version   int
+	PrefixLen int
+	Records   [][]string
+	Words     []string
+	Keys      []int32
+	Counts    []int32
+	Suffixes  []int32
+	Freqs     []int
+	Runs      []int
+}
+
+// WriteGob writes c to w in binary with encoding/gob, for models that take
+// too long to parse from text: ReadGob loads them faster than ReadFreTable
+// and the file is smaller. Prefixes are written in sorted order, so that
+// the same chain always produces the same output.
+func (c *Chain) WriteGob(w io.Writer) error {
+	defer c.beginRead()()
+	if err := c.materialize(); err != nil {
+		return err
+	}
+	m := gobModel{Version: FormatVersion, PrefixLen: c.prefixLen, Records: c.records()}
+	index := make(map[string]int32)
+	id := func(word string) int32 {
+		i, ok := index[word]
+		if !ok {
+			i = int32(len(m.Words))
+			index[word] = i
+			m.Words = append(m.Words, word)
+		}
+		return i
+	}
+	for _, key := range sortedKeys(c.chain) {
+		for _, word := range splitKey(key) {
+			m.Keys = append(m.Keys, id(word))
+		}
+		for _, s := range c.chain[key] {
+			m.Suffixes = append(m.Suffixes, id(s.word))
+			m.Freqs = append(m.Freqs, s.frequency)
+		}
+		m.Counts = append(m.Counts, int32(len(c.chain[key])))
+	}
+	m.Runs, m.Freqs = freqRuns(m.Freqs), nil
+	return gob.NewEncoder(w).Encode(&m)
+}
+
+// freqRuns returns freqs with every run of 1s replaced by minus its
+// length, as gobModel.Runs holds them: 1 1 1 5 1 becomes -3 5 -1.
+func freqRuns(freqs []int) []int {
+	runs := []int{}
+	for _, f := range freqs {
+		if f == 1 && len(runs) > 0 && runs[len(runs)-1] < 0 {
+			runs[len(runs)-1]--
+		} else if f == 1 {
+			runs = append(runs, -1)
+		} else {
+			runs = append(runs, f)
+		}
+	}
+	return runs
+}
+
+// expandRuns reverses freqRuns for total frequencies.
+func expandRuns(runs []int, total int) ([]int, error) {
+	freqs := make([]int, 0, total)
+	for _, r := range runs {
+		if r > 0 {
+			freqs = append(freqs, r)
+		} else if r < 0 && -r <= total-len(freqs) {
+			for ; r < 0; r++ {
+				freqs = append(freqs, 1)
+			}
+		} else {
+			return nil, fmt.Errorf("frequency run %d of %d suffixes", r, total)
+		}
+	}
+	return freqs, nil
+}
+
+// GobRunSavings returns how many bytes smaller WriteGob writes c than it
+// would with a frequency for every suffix, as it did before it wrote runs
+// of suffixes seen once, see WriteGob. read -format gob reports it.
+func (c *Chain) GobRunSavings() (int64, error) {
+	defer c.beginRead()()
+	if err := c.materialize(); err != nil {
+		return 0, err
+	}
+	var freqs []int
+	for _, key := range sortedKeys(c.chain) {
+		for _, s := range c.chain[key] {
+			freqs = append(freqs, s.frequency)
+		}
+	}
+	each, runs := &countingWriter{w: io.Discard}, &countingWriter{w: io.Discard}
+	if err := gob.NewEncoder(each).Encode(freqs); err != nil {
+		return 0, err
+	}
+	if err := gob.NewEncoder(runs).Encode(freqRuns(freqs)); err != nil {
+		return 0, err
+	}
+	return each.n - runs.n, nil
+}
+
+// WriteGobFile is WriteGob to the named file, which is removed again if
+// anything fails.
+func (c *Chain) WriteGobFile(name string) error {
+	return writeFile(name, c.WriteGob)
+}
+
+// ReadGob reads a chain written by WriteGob, which writes every prefix
+// once.
+func ReadGob(r io.Reader) (*Chain, error) {
+	var m gobModel
+	if err := gob.NewDecoder(bufio.NewReader(r)).Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.PrefixLen <= 0 {
+		return nil, fmt.Errorf("expected a positive prefix length, got %d", m.PrefixLen)
+	}
+	total := 0
+	for _, n := range m.Counts {
+		total += int(n)
+	}
+	if m.Runs != nil {
+		freqs, err := expandRuns(m.Runs, total)
+		if err != nil {
+			return nil, err
+		}
+		m.Freqs = freqs
+	}
+	if len(m.Keys) != len(m.Counts)*m.PrefixLen || len(m.Suffixes) != total || len(m.Freqs) != total {
+		return nil, fmt.Errorf("%d prefixes do not match %d prefix words and %d suffixes with %d frequencies",
+			len(m.Counts), len(m.Keys), len(m.Suffixes), len(m.Freqs))
+	}
+	c := newChain(m.PrefixLen)
+	for i, fields := range m.Records {
+		if m.Version == 0 {
+			legacyRecord(fields, m.PrefixLen)
+		}
+		if err := c.readRecord(fields); err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+	}
+	for _, w := range m.Words {
+		if err := checkWord(w); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range m.Keys {
+		if k < 0 || int(k) >= len(m.Words) {
+			return nil, fmt.Errorf("prefix word %d of %d words", k, len(m.Words))
+		}
+	}
+	for _, k := range m.Suffixes {
+		if k < 0 || int(k) >= len(m.Words) {
+			return nil, fmt.Errorf("suffix word %d of %d words", k, len(m.Words))
+		}
+	}
+	// The keys are cut from one string holding them all, which saves an
+	// allocation per prefix.
+	var buf []byte
+	ends := make([]int, len(m.Counts))
+	for i := range m.Counts {
+		for j, k := range m.Keys[i*m.PrefixLen : (i+1)*m.PrefixLen] {
+			if j > 0 {
+				buf = append(buf, keySep...)
+			}
+			if m.Version == 0 && m.Words[k] == `""` {
+				continue
+			}
+			buf = append(buf, m.Words[k]...)
+		}
+		ends[i] = len(buf)
+	}
+	keys := string(buf)
+	c.chain = make(map[string][]Suffix, len(m.Counts))
+	all := make([]Suffix, total)
+	next, start := 0, 0
+	for i, n := range m.Counts {
+		key := keys[start:ends[i]]
+		start = ends[i]
+		suf := all[next : next+int(n) : next+int(n)]
+		for j := range suf {
+			w, f := m.Words[m.Suffixes[next+j]], m.Freqs[next+j]
+			if f <= 0 {
+				return nil, fmt.Errorf("prefix %q: expected positive frequency for %q, got %d", splitKey(key).String(), w, f)
+			}
+			suf[j] = Suffix{w, f}
+		}
+		next += int(n)
+		c.chain[key] = suf
+	}
+	return c, nil
+}
+
+// ReadGobFile is ReadGob on the named file. Errors name the file.
+func ReadGobFile(name string) (*Chain, error) {
+	in, err := OpenModelFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	c, err := ReadGob(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,154 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/synthcorpus"
+)
+
+func TestFreqRuns(t *testing.T) {
+	tests := []struct {
+		freqs, runs []int
+	}{
+		{nil, []int{}},
+		{[]int{1, 1, 1, 5, 1}, []int{-3, 5, -1}},
+		{[]int{2, 3}, []int{2, 3}},
+		{[]int{1, 4, 4, 1, 1}, []int{-1, 4, 4, -2}},
+	}
+	for _, tt := range tests {
+		runs := freqRuns(tt.freqs)
+		if !reflect.DeepEqual(runs, tt.runs) {
+			t.Errorf("freqRuns(%v) = %v, want %v", tt.freqs, runs, tt.runs)
+		}
+		if freqs, err := expandRuns(runs, len(tt.freqs)); err != nil || len(freqs) != len(tt.freqs) || (len(freqs) > 0 && !reflect.DeepEqual(freqs, tt.freqs)) {
+			t.Errorf("expandRuns(%v) = %v, %v; want %v", runs, freqs, err, tt.freqs)
+		}
+	}
+	for _, runs := range [][]int{{0}, {-4}, {-1 << 40}} {
+		if freqs, err := expandRuns(runs, 3); err == nil {
+			t.Errorf("expandRuns(%v, 3) = %v, want an error", runs, freqs)
+		}
+	}
+}
+
+// TestGobRunsSmaller writes a model of a Zipfian corpus, where most
+// suffixes are seen once, with runs and as models were written before
+// them, and checks that both read back the same and runs are smaller.
+func TestGobRunsSmaller(t *testing.T) {
+	var corpus bytes.Buffer
+	opts := synthcorpus.DefaultOptions()
+	opts.Tokens, opts.Vocab = 20000, 2000
+	if err := synthcorpus.Write(&corpus, opts); err != nil {
+		t.Fatal(err)
+	}
+	c := newChain(2)
+	if _, err := c.BuildReader(&corpus); err != nil {
+		t.Fatal(err)
+	}
+	var runs bytes.Buffer
+	if err := c.WriteGob(&runs); err != nil {
+		t.Fatal(err)
+	}
+	var m gobModel
+	if err := gob.NewDecoder(bytes.NewReader(runs.Bytes())).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, n := range m.Counts {
+		total += int(n)
+	}
+	ones := 0
+	for _, r := range m.Runs {
+		if r < 0 {
+			ones -= r
+		}
+	}
+	if ones < total/2 {
+		t.Fatalf("only %d of %d suffixes seen once: not the fixture this test needs", ones, total)
+	}
+	freqs, err := expandRuns(m.Runs, total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Freqs, m.Runs = freqs, nil
+	var each bytes.Buffer
+	if err := gob.NewEncoder(&each).Encode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, buf := range map[string][]byte{"runs": runs.Bytes(), "a frequency each": each.Bytes()} {
+		read, err := ReadGob(bytes.NewReader(buf))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if read.Hash() != c.Hash() {
+			t.Errorf("the model with %s reads back differently", name)
+		}
+	}
+	saved, err := c.GobRunSavings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := int64(each.Len() - runs.Len()); got != saved || saved < int64(each.Len())/10 {
+		t.Errorf("runs saved %d of %d bytes, GobRunSavings says %d; want the same, and at least a tenth", got, each.Len(), saved)
+	}
+}
+
+// TestGobMatchesText checks that a chain read back from gob and from text
+// is the same chain, and writes the same model file, byte for byte.
+func TestGobMatchesText(t *testing.T) {
+	c := synthChain(t, 20000)
+	var want bytes.Buffer
+	if _, err := c.WriteTo(&want); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"text", "gob"} {
+		read, err := formats[name](c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(read.chain, c.chain) {
+			t.Errorf("%s: the chain reads back differently", name)
+		}
+		var got bytes.Buffer
+		if _, err := read.WriteTo(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("%s: the chain read back writes a different model file", name)
+		}
+	}
+}
+
+// BenchmarkLoad reads the model of a synthetic corpus of 500,000 tokens in
+// the text format and in gob, which should take a fraction of the time.
+func BenchmarkLoad(b *testing.B) {
+	c := synthChain(b, 500000)
+	var text, binary bytes.Buffer
+	if _, err := c.WriteTo(&text); err != nil {
+		b.Fatal(err)
+	}
+	if err := c.WriteGob(&binary); err != nil {
+		b.Fatal(err)
+	}
+	for _, bm := range []struct {
+		format string
+		model  []byte
+	}{
+		{FormatText, text.Bytes()},
+		{FormatGob, binary.Bytes()},
+	} {
+		b.Run(bm.format, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(bm.model)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadModel(bytes.NewReader(bm.model), bm.format); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
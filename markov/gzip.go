@@ -0,0 +1,69 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressed reports whether a model file is to be written compressed
+// with gzip: whether its name ends in .gz.
+func compressed(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".gz")
+}
+
+// modelReader is a model file opened by OpenModelFile.
+type modelReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor, if any, and the file.
+func (f *modelReader) Close() error {
+	var err error
+	for _, c := range f.closers {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// OpenModelFile opens the named model file for reading, decompressing it
+// if it is compressed with gzip, whatever its name. Every reader of model
+// files goes through it, so that compressed and plain files of any format
+// load alike.
+func OpenModelFile(name string) (io.ReadCloser, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewModelReader(in)
+	if err != nil {
+		in.Close()
+		return nil, err
+	}
+	r.(*modelReader).closers = append(r.(*modelReader).closers, in)
+	return r, nil
+}
+
+// NewModelReader returns a reader of the model read from r, decompressing
+// it if it is compressed with gzip, for models that do not come from a
+// file, such as a download. Closing it closes the decompressor, not r.
+func NewModelReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return &modelReader{Reader: br}, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &modelReader{zr, []io.Closer{zr}}, nil
+}
@@ -0,0 +1,77 @@
+package markov
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGzipModels writes models of every format compressed and plain, and
+// checks that they read back the same whatever the name of the file.
+func TestGzipModels(t *testing.T) {
+	dir := t.TempDir()
+	c := synthChain(t, 20000)
+	for _, ext := range []string{".txt", ".json", ".gob", ".msgpack"} {
+		plain, packed := filepath.Join(dir, "m"+ext), filepath.Join(dir, "m"+ext+".gz")
+		write := map[string]func(string) error{
+			".txt": c.WriteFreTable, ".json": c.WriteJSONFile, ".gob": c.WriteGobFile, ".msgpack": c.WriteMsgpackFile,
+		}[ext]
+		for _, name := range []string{plain, packed} {
+			if err := write(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		plainData, err := os.ReadFile(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packedData, err := os.ReadFile(packed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.HasPrefix(plainData, gzipMagic) || !bytes.HasPrefix(packedData, gzipMagic) {
+			t.Fatalf("%s: only the file named .gz should be compressed", ext)
+		}
+		if len(packedData) >= len(plainData) {
+			t.Errorf("%s: compressed to %d bytes from %d", ext, len(packedData), len(plainData))
+		}
+		// The compressed data is recognized without the name saying so.
+		renamed := filepath.Join(dir, "renamed"+ext)
+		if err := os.WriteFile(renamed, packedData, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{plain, packed, renamed} {
+			m, err := OpenModel(name, OpenOptions{})
+			if err != nil {
+				t.Errorf("%s: %v", filepath.Base(name), err)
+				continue
+			}
+			if !reflect.DeepEqual(m.chain, c.chain) {
+				t.Errorf("%s reads back a different chain", filepath.Base(name))
+			}
+			m.Close()
+		}
+	}
+
+	// Models that do not come from a file are sniffed the same way.
+	var packed bytes.Buffer
+	zw := gzip.NewWriter(&packed)
+	zw.Write([]byte("model data"))
+	zw.Close()
+	for name, r := range map[string]io.Reader{"plain": strings.NewReader("model data"), "gzip": &packed} {
+		mr, err := NewModelReader(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(mr)
+		if err != nil || string(got) != "model data" {
+			t.Errorf("%s: NewModelReader read %q, %v", name, got, err)
+		}
+		mr.Close()
+	}
+}
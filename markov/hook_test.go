@@ -0,0 +1,73 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOnTransitionWeights counts every transition by its position in the
+// document times the number of the document, leaves out the transitions
+// to "skip", and scribbles over the prefix it is given, which must not
+// change what is counted.
+func TestOnTransitionWeights(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, text := range []string{"x y x y", "x skip y"} {
+		name := filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(name, []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	var calls []string
+	opts := BuildOptions{OnTransition: func(prefix []string, word string, doc, pos int) (int, bool) {
+		calls = append(calls, strings.Join(prefix, "|")+">"+word)
+		prefix[0] = "corrupted"
+		return (pos + 1) * (doc + 1), word != "skip"
+	}}
+	c := newChain(1)
+	if _, err := c.BuildOpts(files, opts); err != nil {
+		t.Fatal(err)
+	}
+	wantCalls := []string{">x", "x>y", "y>x", "x>y", "y>" + EndToken, ">x", "x>skip", "skip>y", "y>" + EndToken}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("hook called with %q, want %q", calls, wantCalls)
+	}
+	for _, tt := range []struct {
+		prefix string
+		want   []Suffix
+	}{
+		// Document 0 counts position+1, document 1 twice that.
+		{"", []Suffix{{"x", 1 + 2}}},
+		{"x", []Suffix{{"y", 2 + 4}}},
+		{"y", []Suffix{{"x", 3}, {EndToken, 5 + 8}}},
+		{"skip", []Suffix{{"y", 6}}},
+	} {
+		if got := c.Suffixes(Prefix{tt.prefix}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suffixes of %q = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+	if c.Suffixes(Prefix{"corrupted"}) != nil {
+		t.Errorf("the hook changed the prefix Build counts with")
+	}
+}
+
+// BenchmarkBuildOnTransition is BenchmarkBuild with a hook that counts
+// every transition once, as Build does without one; compare the two for
+// the cost of the hook, and BenchmarkBuild with earlier versions for the
+// cost of a nil hook, which should be none.
+func BenchmarkBuildOnTransition(b *testing.B) {
+	corpus := benchCorpus(50000)
+	opts := BuildOptions{OnTransition: func([]string, string, int, int) (int, bool) { return 1, true }}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(corpus)))
+	for i := 0; i < b.N; i++ {
+		c := newChain(2)
+		if _, err := c.BuildReaderOpts("", strings.NewReader(corpus), opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
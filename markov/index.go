@@ -0,0 +1,178 @@
+package markov
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// DefaultIndexFPRate is the false-positive rate indexes are sized for
+// unless another rate is given.
+const DefaultIndexFPRate = 0.01
+
+// indexMagic starts every index file.
+const indexMagic = "GOMARKIDX1\n"
+
+// Index answers whether an n-gram occurred in the corpus a chain was built
+// from, without keeping the corpus. It is a Bloom filter over hashed
+// n-grams: ContainsNGram never returns false for an n-gram that was added,
+// and returns true for one that was not with probability about FPRate.
+type Index struct {
+	MinN, MaxN int     // n-gram orders covered
+	FPRate     float64 // false-positive rate the filter was sized for
+	k          uint32  // hash functions per n-gram
+	bits       []uint64
+}
+
+// IndexBuilder collects n-grams for an Index. It keeps one 8-byte hash per
+// n-gram and sizes the filter in Finish, once the number of distinct
+// n-grams is known.
+type IndexBuilder struct {
+	MinN, MaxN int
+	hashes     []uint64
+}
+
+// NewIndexBuilder returns a builder for n-grams of minN to maxN words.
+func NewIndexBuilder(minN, maxN int) *IndexBuilder {
+	return &IndexBuilder{MinN: minN, MaxN: maxN}
+}
+
+// hashNGram hashes words, separated by a byte that never occurs in a word.
+func hashNGram(words []string) uint64 {
+	h := fnv.New64a()
+	for _, w := range words {
+		h.Write([]byte(w))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// AddTokens adds every n-gram of the covered orders in the token sequence.
+func (b *IndexBuilder) AddTokens(tokens []string) {
+	for n := b.MinN; n <= b.MaxN; n++ {
+		for i := 0; i+n <= len(tokens); i++ {
+			b.hashes = append(b.hashes, hashNGram(tokens[i:i+n]))
+		}
+	}
+}
+
+// Finish returns an Index holding the n-grams added so far, sized for the
+// given false-positive rate (DefaultIndexFPRate if fpRate is not in (0, 1)).
+func (b *IndexBuilder) Finish(fpRate float64) *Index {
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = DefaultIndexFPRate
+	}
+	sort.Slice(b.hashes, func(i, j int) bool { return b.hashes[i] < b.hashes[j] })
+	distinct := 0
+	for i, h := range b.hashes {
+		if i == 0 || h != b.hashes[i-1] {
+			distinct++
+		}
+	}
+	// Optimal Bloom filter parameters for distinct items at fpRate.
+	n := math.Max(float64(distinct), 1)
+	m := math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := uint32(math.Max(1, math.Round(m/n*math.Ln2)))
+	idx := &Index{MinN: b.MinN, MaxN: b.MaxN, FPRate: fpRate, k: k, bits: make([]uint64, (int(m)+63)/64)}
+	for _, h := range b.hashes {
+		idx.set(h)
+	}
+	return idx
+}
+
+// positions calls f with the k bit positions of hash h (double hashing).
+func (idx *Index) positions(h uint64, f func(uint64) bool) bool {
+	m := uint64(len(idx.bits) * 64)
+	h2 := (h>>33 | h<<31) * 0x9e3779b97f4a7c15
+	h2 |= 1
+	for i := uint32(0); i < idx.k; i++ {
+		if !f((h + uint64(i)*h2) % m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *Index) set(h uint64) {
+	idx.positions(h, func(bit uint64) bool {
+		idx.bits[bit/64] |= 1 << (bit % 64)
+		return true
+	})
+}
+
+// ContainsNGram reports whether words probably occurred in the corpus.
+// N-grams of orders the index does not cover are reported as absent.
+func (idx *Index) ContainsNGram(words []string) bool {
+	if len(words) < idx.MinN || len(words) > idx.MaxN || len(idx.bits) == 0 {
+		return false
+	}
+	return idx.positions(hashNGram(words), func(bit uint64) bool {
+		return idx.bits[bit/64]&(1<<(bit%64)) != 0
+	})
+}
+
+// WriteTo writes the index in its binary file format.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(indexMagic)
+	hdr := []uint64{uint64(idx.MinN), uint64(idx.MaxN), math.Float64bits(idx.FPRate), uint64(idx.k), uint64(len(idx.bits))}
+	binary.Write(bw, binary.LittleEndian, hdr)
+	binary.Write(bw, binary.LittleEndian, idx.bits)
+	n := int64(len(indexMagic) + 8*len(hdr) + 8*len(idx.bits))
+	return n, bw.Flush()
+}
+
+// ReadIndex reads an index written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != indexMagic {
+		return nil, errors.New("not an n-gram index file")
+	}
+	var hdr [5]uint64
+	if err := binary.Read(br, binary.LittleEndian, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading index header: %v", err)
+	}
+	if hdr[4] > 1<<40 {
+		return nil, fmt.Errorf("index claims %d words of bits", hdr[4])
+	}
+	idx := &Index{MinN: int(hdr[0]), MaxN: int(hdr[1]), FPRate: math.Float64frombits(hdr[2]), k: uint32(hdr[3])}
+	idx.bits = make([]uint64, hdr[4])
+	if err := binary.Read(br, binary.LittleEndian, idx.bits); err != nil {
+		return nil, fmt.Errorf("reading index bits: %v", err)
+	}
+	return idx, nil
+}
+
+// OpenIndex reads the index file name.
+func OpenIndex(name string) (*Index, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	idx, err := ReadIndex(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return idx, nil
+}
+
+// WriteIndexFile writes idx to the file name.
+func WriteIndexFile(name string, idx *Index) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = idx.WriteTo(out)
+	if err = errors.Join(err, out.Close()); err != nil {
+		os.Remove(name)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestIndexFalsePositives builds an index of the n-grams of a synthetic
+// corpus and checks that it holds every one of them, that n-grams that
+// never occurred are reported present at about the rate it was sized for,
+// and that it reads back from its file format unchanged.
+func TestIndexFalsePositives(t *testing.T) {
+	tokens := strings.Fields(string(synthCorpus(t, 50000)))
+	b := NewIndexBuilder(3, 4)
+	b.AddTokens(tokens)
+	idx := b.Finish(DefaultIndexFPRate)
+
+	var file bytes.Buffer
+	if _, err := idx.WriteTo(&file); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadIndex(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []*Index{idx, read} {
+		for i := 0; i+4 <= len(tokens); i++ {
+			if !x.ContainsNGram(tokens[i:i+3]) || !x.ContainsNGram(tokens[i:i+4]) {
+				t.Fatalf("n-gram %q of the corpus is missing", tokens[i:i+4])
+			}
+		}
+	}
+	if idx.ContainsNGram(tokens[:2]) || idx.ContainsNGram(tokens[:5]) {
+		t.Error("the index reports n-grams of orders it does not cover")
+	}
+
+	// Words of the form absentN never occur in the corpus.
+	const trials = 100000
+	fp := 0
+	for i := 0; i < trials; i++ {
+		ngram := []string{tokens[i%len(tokens)], fmt.Sprintf("absent%d", i), tokens[(i*7)%len(tokens)]}
+		if read.ContainsNGram(ngram) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / trials; rate > 1.5*DefaultIndexFPRate {
+		t.Errorf("false-positive rate %.4f, sized for %.4f", rate, DefaultIndexFPRate)
+	}
+}
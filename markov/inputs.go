@@ -0,0 +1,141 @@
+package markov
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InputFile is an input file of read after resolution.
+type InputFile struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// binarySniffLen is how much of a file looksBinary reads.
+const binarySniffLen = 8000
+
+// archiveExts are the extensions of files read reports as archives
+// instead of reading them as text.
+var archiveExts = []string{".zip", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".7z"}
+
+// ResolveInputs turns the input arguments of read into the files to read.
+// Arguments with glob metacharacters are expanded, and directories are
+// walked recursively in lexical order. Archives and files that look
+// binary are skipped, with a message passed to warn. Files that cannot be
+// looked at are listed anyway, so that Build reports them together with
+// every other unreadable input. Every file is listed once, in the order of
+// the arguments. URLs are not supported.
+func ResolveInputs(args []string, warn func(string)) ([]InputFile, error) {
+	var files []InputFile
+	seen := make(map[string]bool)
+	add := func(path string, info fs.FileInfo) error {
+		switch {
+		case seen[path] || !info.Mode().IsRegular():
+			return nil
+		case hasArchiveExt(path):
+			warn(fmt.Sprintf("%s: skipping archive", path))
+			return nil
+		}
+		if binary, err := looksBinary(path); err == nil && binary {
+			warn(fmt.Sprintf("%s: skipping binary file", path))
+			return nil
+		}
+		seen[path] = true
+		files = append(files, InputFile{path, info.Size()})
+		return nil
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			return nil, fmt.Errorf("%s: URLs are not supported as input, download the file first", arg)
+		}
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if paths, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("%s: %w", arg, err)
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("%s: no file matches", arg)
+			}
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				if !seen[path] {
+					seen[path] = true
+					files = append(files, InputFile{Name: path})
+				}
+				continue
+			}
+			if !info.IsDir() {
+				if err := add(path, info); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				return add(p, info)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// InputNames returns the names of files.
+func InputNames(files []InputFile) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func hasArchiveExt(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, a := range archiveExts {
+		if ext == a {
+			return true
+		}
+	}
+	return false
+}
+
+// looksBinary reports whether the start of the file at path contains a
+// NUL byte or mostly control characters, which text never does.
+func looksBinary(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	control := 0
+	for _, b := range buf[:n] {
+		switch {
+		case b == 0:
+			return true, nil
+		case b < ' ' && b != '\n' && b != '\r' && b != '\t' && b != '\f':
+			control++
+		}
+	}
+	return n > 0 && control > n/10, nil
+}
@@ -0,0 +1,67 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveInputs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "plain text",
+		"b.md":          "more text",
+		"dir/c.txt":     "nested",
+		"dir/sub/d.txt": "deeper",
+		"dir/e.zip":     "PK",
+		"dir/f.bin":     "\x00\x01\x02binary",
+		"notes.TAR":     "archive",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o777)
+		if err := os.WriteFile(path, []byte(data), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := func(name string) string { return filepath.Join(dir, name) }
+	file := func(name string) InputFile { return InputFile{at(name), int64(len(files[name]))} }
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     []InputFile
+		warnings []string // the base names of the files skipped
+	}{
+		{"file", []string{at("a.txt")}, []InputFile{file("a.txt")}, nil},
+		{"glob", []string{at("*.txt"), at("*.md")}, []InputFile{file("a.txt"), file("b.md")}, nil},
+		{"directory", []string{at("dir")}, []InputFile{file("dir/c.txt"), file("dir/sub/d.txt")}, []string{"e.zip", "f.bin"}},
+		{"archive", []string{at("notes.TAR")}, nil, []string{"notes.TAR"}},
+		{"missing", []string{at("missing.txt")}, []InputFile{{Name: at("missing.txt")}}, nil},
+		{"listed once", []string{at("a.txt"), at("*.txt"), at("a.txt")}, []InputFile{file("a.txt")}, nil},
+	}
+	for _, tt := range tests {
+		var warnings []string
+		got, err := ResolveInputs(tt.args, func(msg string) {
+			warnings = append(warnings, filepath.Base(strings.SplitN(msg, ":", 2)[0]))
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ResolveInputs = %v, want %v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%s: skipped %q, want %q", tt.name, warnings, tt.warnings)
+		}
+	}
+
+	for _, args := range [][]string{{"https://example.com/book.txt"}, {at("*.none")}} {
+		if _, err := ResolveInputs(args, func(string) {}); err == nil {
+			t.Errorf("ResolveInputs(%q) succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSONModel is the structure of a model written by WriteJSON, for tools
+// that cannot parse the space-separated model file:
+//
+//	{
+//	  "version": 3,
+//	  "prefix_len": 2,
+//	  "records": [["prior", "the", "3"], ...],
+//	  "prefixes": [
+//	    {"prefix": ["", ""], "suffixes": [{"word": "The", "frequency": 1}]},
+//	    ...
+//	  ]
+//	}
+//
+// The start of a document is the prefix of empty strings; the prefixes of
+// its first words begin with empty strings too. Records are the extension
+// records of the model file, see WriteFreTable, field by field, with empty
+// slots as empty strings too. Version is the FormatVersion of the writer;
+// models without one spell the empty slots of records `""`.
+// Words are JSON strings, which hold any text: white space, quotes and
+// all. Only bytes that are not UTF-8, as in words of a corpus in another
+// encoding, are written as the replacement character U+FFFD.
+type JSONModel struct {
+	Version   int          `json:"version,omitempty"`
+	PrefixLen int          `json:"prefix_len"`
+	Records   [][]string   `json:"records,omitempty"`
+	Prefixes  []JSONPrefix `json:"prefixes"`
+}
+
+// JSONPrefix is a prefix of a JSONModel with the words that followed it.
+type JSONPrefix struct {
+	Prefix   []string     `json:"prefix"`
+	Suffixes []JSONSuffix `json:"suffixes"`
+}
+
+// JSONSuffix is a word that followed a prefix and how often it did.
+type JSONSuffix struct {
+	Word      string `json:"word"`
+	Frequency int    `json:"frequency"`
+}
+
+// WriteJSON writes c to w as a JSONModel, with one prefix per line and
+// prefixes in sorted order, so that the same chain always produces the
+// same output.
+func (c *Chain) WriteJSON(w io.Writer) error {
+	defer c.beginRead()()
+	if err := c.materialize(); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// encode writes v after sep, without the newline Encode ends it with.
+	encode := func(sep string, v any) error {
+		buf.Reset()
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		_, err := fmt.Fprint(w, sep, strings.TrimSuffix(buf.String(), "\n"))
+		return err
+	}
+	records := c.records()
+	if records == nil {
+		records = [][]string{}
+	}
+	fmt.Fprintf(w, "{\"version\": %d,\n\"prefix_len\": %d,\n", FormatVersion, c.prefixLen)
+	if err := encode(`"records": `, records); err != nil {
+		return err
+	}
+	fmt.Fprint(w, ",\n\"prefixes\": [")
+	for i, key := range sortedKeys(c.chain) {
+		p := JSONPrefix{Prefix: splitKey(key)}
+		for _, s := range c.chain[key] {
+			p.Suffixes = append(p.Suffixes, JSONSuffix{s.word, s.frequency})
+		}
+		sep := ",\n"
+		if i == 0 {
+			sep = "\n"
+		}
+		if err := encode(sep, p); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "\n]}")
+	return err
+}
+
+// WriteJSONFile is WriteJSON to the named file, which is removed again if
+// anything fails.
+func (c *Chain) WriteJSONFile(name string) error {
+	return writeFile(name, c.WriteJSON)
+}
+
+// ReadJSON reads a chain written by WriteJSON. Words are taken as they are
+// after JSON unescaping, white space, quotes, backslashes and all; only
+// words holding a NUL byte, which no chain can hold, see keySep, are
+// rejected, as are empty suffix words and frequencies below 1. Suffixes
+// listed twice for a prefix are summed.
+func ReadJSON(r io.Reader) (*Chain, error) {
+	var m JSONModel
+	if err := json.NewDecoder(bufio.NewReader(r)).Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.PrefixLen <= 0 {
+		return nil, fmt.Errorf("expected a positive prefix length, got %d", m.PrefixLen)
+	}
+	c := newChain(m.PrefixLen)
+	for i, p := range m.Prefixes {
+		if len(p.Prefix) != m.PrefixLen {
+			return nil, fmt.Errorf("prefix %d: expected %d words, got %d", i+1, m.PrefixLen, len(p.Prefix))
+		}
+		for _, w := range p.Prefix {
+			if err := checkWord(w); err != nil {
+				return nil, fmt.Errorf("prefix %d: %v", i+1, err)
+			}
+		}
+		key := Prefix(p.Prefix).key()
+		for _, s := range p.Suffixes {
+			if s.Word == "" {
+				return nil, fmt.Errorf("prefix %d: empty suffix word", i+1)
+			}
+			if err := checkWord(s.Word); err != nil {
+				return nil, fmt.Errorf("prefix %d: %v", i+1, err)
+			}
+			if s.Frequency <= 0 {
+				return nil, fmt.Errorf("prefix %d: expected positive frequency for %q, got %d", i+1, s.Word, s.Frequency)
+			}
+			c.add(key, s.Word, s.Frequency)
+		}
+	}
+	// The records come last, as adding to the table drops the k of a
+	// choices record.
+	for i, fields := range m.Records {
+		if m.Version == 0 {
+			legacyRecord(fields, m.PrefixLen)
+		}
+		if err := c.readRecord(fields); err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+	}
+	return c, nil
+}
+
+// ReadJSONFile is ReadJSON on the named file. Errors name the file.
+func ReadJSONFile(name string) (*Chain, error) {
+	in, err := OpenModelFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	c, err := ReadJSON(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
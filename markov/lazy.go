@@ -0,0 +1,297 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// lazyTable backs a Chain opened with OpenFreTableMmap. Table lines are
+// indexed by prefix only when a lookup needs to read past them, and their
+// suffixes are parsed on first access. Everything stored in the Chain is
+// copied out of the mapping, so it stays valid after the mapping is gone.
+type lazyTable struct {
+	data      []byte
+	next      int              // offset of the first line not yet indexed
+	lineNo    int              // line number of the line at next
+	index     map[string][]int // line offsets of indexed, unparsed prefixes
+	prefixLen int
+	decimals  int // of a model of probabilities, see WriteProbabilities
+	name      string
+	release   func() error
+	err       error // the first line that could not be parsed, see Err
+}
+
+// OpenFreTableMmap opens a model file like ReadFreTable, but maps it into
+// memory and only parses the prefixes generation actually visits, so the
+// first words of a very large model come out almost immediately.
+//
+// Methods that need the whole table (Build, WriteFreTable, ...) load the
+// rest of the file transparently. Close releases the mapping. Files
+// compressed with gzip cannot be mapped and are read with ReadFreTable.
+// Of the checksum record only its presence at the end of the file is
+// checked, which catches files cut short: verifying it would read the
+// whole file. Files without one are treated as by ReadFreTable.
+func OpenFreTableMmap(modelFile string) (*Chain, error) {
+	f, err := os.Open(modelFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, release, err := mapFile(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", modelFile, err)
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		release()
+		return ReadFreTable(modelFile)
+	}
+
+	header, rest := data, []byte(nil)
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		header, rest = data[:i], data[i+1:]
+	}
+	if len(data) == 0 {
+		release()
+		return nil, fmt.Errorf("%s: %w", modelFile, ErrEmptyModel)
+	}
+	prefixLen, version, err := parseHeader(string(header))
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("%s:1: %w", modelFile, err)
+	}
+	decimals, err := headerDecimals(string(header))
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("%s:1: %w", modelFile, err)
+	}
+	c := newChain(prefixLen)
+	c.version = version
+	c.lazy = &lazyTable{
+		data:      data,
+		next:      len(data) - len(rest),
+		lineNo:    2,
+		index:     make(map[string][]int),
+		prefixLen: prefixLen,
+		decimals:  decimals,
+		name:      modelFile,
+		release:   release,
+	}
+	c.lazyOpen.Store(true)
+	// Extension records are small and needed up front; they precede the
+	// table in files written by WriteFreTable.
+	for c.lazy.next < len(data) && data[c.lazy.next] == '\t' {
+		line := c.lazy.line(c.lazy.next)
+		fields, err := readFields(string(line), version, prefixLen)
+		if err == nil {
+			err = c.readRecord(fields)
+		}
+		if err != nil {
+			lineNo := c.lazy.lineNo
+			c.Close()
+			return nil, fmt.Errorf("%s:%d: %v", modelFile, lineNo, err)
+		}
+		c.lazy.advance(len(line))
+	}
+	tail := bytes.TrimRight(data, "\n")
+	if !isChecksumRecord(string(tail[bytes.LastIndexByte(tail, '\n')+1:])) {
+		if problem := missingChecksum(version); problem != "" {
+			c.Close()
+			return nil, &ChecksumError{Name: modelFile, Problem: problem}
+		}
+		return c, &ChecksumError{Name: modelFile, Missing: true}
+	}
+	return c, nil
+}
+
+// line returns the line starting at off, without its newline.
+func (t *lazyTable) line(off int) []byte {
+	line := t.data[off:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return line
+}
+
+// advance moves past a line of n bytes and its newline.
+func (t *lazyTable) advance(n int) {
+	t.next += n + 1
+	t.lineNo++
+}
+
+// key returns the prefix key of a table line.
+func (t *lazyTable) key(line []byte) string {
+	end, fields := 0, 0
+	for end < len(line) && fields < t.prefixLen {
+		if line[end] == ' ' {
+			fields++
+			if fields == t.prefixLen {
+				break
+			}
+		}
+		end++
+	}
+	return string(line[:end])
+}
+
+// indexNext indexes the next unindexed line and returns its key, or false
+// at the end of the file.
+func (t *lazyTable) indexNext(c *Chain) (string, bool) {
+	for t.next < len(t.data) {
+		off := t.next
+		line := t.line(off)
+		t.advance(len(line))
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '\t' {
+			// Only hand-edited files have records after the table; a
+			// malformed one is skipped, as lookups cannot fail.
+			if fields, err := readFields(string(line), c.version, c.prefixLen); err == nil {
+				c.readRecord(fields)
+			}
+			continue
+		}
+		key := t.key(line)
+		key = readKey(key, c.version)
+		t.index[key] = append(t.index[key], off)
+		return key, true
+	}
+	return "", false
+}
+
+// load parses the indexed lines of key into the chain. Errors name the
+// line, as those of ReadFreTable do; counting the lines up to it is only
+// paid for on failure.
+func (t *lazyTable) load(c *Chain, key string) error {
+	for _, off := range t.index[key] {
+		_, suffixes, err := parseTableLine(string(t.line(off)), t.prefixLen, c.version, t.decimals)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", t.name, bytes.Count(t.data[:off], []byte{'\n'})+1, err)
+		}
+		c.chain[key] = append(c.chain[key], suffixes...)
+	}
+	delete(t.index, key)
+	return nil
+}
+
+// lookup returns the suffixes of key. On a lazily opened chain it indexes
+// the file up to the line of key and parses that line.
+func (c *Chain) lookup(key string) []Suffix {
+	if !c.lazyOpen.Load() {
+		return c.chain[key]
+	}
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if suf, ok := c.chain[key]; ok || c.lazy == nil {
+		return suf
+	}
+	t := c.lazy
+	if _, ok := t.index[key]; !ok {
+		for {
+			k, more := t.indexNext(c)
+			if !more {
+				return nil
+			}
+			if k == key {
+				break
+			}
+		}
+	}
+	// A prefix may be split over several lines in hand-edited files.
+	for {
+		k, more := t.indexNext(c)
+		if !more || k != key {
+			break
+		}
+	}
+	if err := t.load(c, key); err != nil {
+		if t.err == nil {
+			t.err = err
+		}
+		return nil
+	}
+	return c.chain[key]
+}
+
+// materialize loads whatever part of a lazily opened model is not in
+// memory yet and releases the mapping, turning c into an ordinary chain.
+// A failure is also kept for Err, for the callers that have no error to
+// return it in.
+func (c *Chain) materialize() error {
+	if !c.lazyOpen.Load() {
+		return nil
+	}
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	t := c.lazy
+	if t == nil {
+		return nil
+	}
+	for {
+		if _, more := t.indexNext(c); !more {
+			break
+		}
+	}
+	for key := range t.index {
+		if err := t.load(c, key); err != nil {
+			if t.err == nil {
+				t.err = err
+			}
+			return err
+		}
+	}
+	c.lazy = nil
+	c.lazyOpen.Store(false)
+	return t.release()
+}
+
+// Close releases the memory mapping of a chain opened with
+// OpenFreTableMmap; only the prefixes already parsed stay usable. It is a
+// no-op for other chains.
+func (c *Chain) Close() error {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	t := c.lazy
+	if t == nil {
+		return nil
+	}
+	c.lazy = nil
+	c.lazyOpen.Store(false)
+	return t.release()
+}
+
+// Err returns the first error a chain opened with OpenFreTableMmap hit
+// while parsing the lines generation, or a call such as Stats that reads
+// the whole chain, visited. Such lines are treated as prefixes without
+// suffixes, so generation stops there; callers should check Err after
+// generating. It is nil for other chains.
+func (c *Chain) Err() error {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.lazy == nil {
+		return nil
+	}
+	return c.lazy.err
+}
+
+// IsEmpty reports whether c has no prefixes at all.
+func (c *Chain) IsEmpty() bool {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if len(c.chain) > 0 {
+		return false
+	}
+	if c.lazy == nil {
+		return true
+	}
+	if len(c.lazy.index) > 0 {
+		return false
+	}
+	_, more := c.lazy.indexNext(c)
+	return !more
+}
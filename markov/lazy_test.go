@@ -0,0 +1,95 @@
+package markov
+
+import (
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeSynthModel writes the chain of synthCorpus(tokens) to a model file.
+func writeSynthModel(tb testing.TB, tokens int) string {
+	tb.Helper()
+	file := filepath.Join(tb.TempDir(), "model.txt")
+	if err := synthChain(tb, tokens).WriteFreTable(file); err != nil {
+		tb.Fatal(err)
+	}
+	return file
+}
+
+func TestMmapMatchesEager(t *testing.T) {
+	file := writeSynthModel(t, 20000)
+	eager, err := ReadFreTable(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(1); seed <= 10; seed++ {
+		mapped, err := OpenFreTableMmap(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := eager.GenerateWords(200, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		if got := mapped.GenerateWords(200, GenerateOptions{Rand: rand.New(rand.NewSource(seed))}); !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %d: mapped chain generates %q, eager %q", seed, got, want)
+		}
+		if mapped.Hash() != eager.Hash() {
+			t.Errorf("seed %d: mapped chain loads into a different chain", seed)
+		}
+		if err := mapped.Err(); err != nil {
+			t.Error(err)
+		}
+		mapped.Close()
+	}
+}
+
+// BenchmarkFirstWord measures the time from opening a model file to its
+// first generated word, eagerly and mapped.
+func BenchmarkFirstWord(b *testing.B) {
+	file := writeSynthModel(b, 200000)
+	for _, load := range []struct {
+		name string
+		open func(string) (*Chain, error)
+	}{{"eager", ReadFreTable}, {"mmap", OpenFreTableMmap}} {
+		b.Run(load.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c, err := load.open(file)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(c.GenerateWords(1, GenerateOptions{Rand: rand.New(rand.NewSource(int64(i)))})) != 1 {
+					b.Fatal("no word generated")
+				}
+				c.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkSteadyGenerate measures generation once a model is open, by
+// the word, eagerly loaded and mapped; the mapped chain has parsed the
+// prefixes it visits by the time the timer starts.
+func BenchmarkSteadyGenerate(b *testing.B) {
+	file := writeSynthModel(b, 200000)
+	for _, load := range []struct {
+		name string
+		open func(string) (*Chain, error)
+	}{{"eager", ReadFreTable}, {"mmap", OpenFreTableMmap}} {
+		b.Run(load.name, func(b *testing.B) {
+			c, err := load.open(file)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer c.Close()
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 1000; i++ {
+				c.GenerateWords(100, GenerateOptions{Rand: r})
+			}
+			b.ResetTimer()
+			words := 0
+			for i := 0; i < b.N; i++ {
+				words += len(c.GenerateWords(100, GenerateOptions{Rand: r}))
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(max(words, 1)), "ns/word")
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package markov
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestLibraryDoesNotExit parses the sources of the package and fails on
+// anything that would end or write to the process of a program importing
+// it: calls of os.Exit and the printing functions of fmt and log, the
+// standard streams, and the apologies the command used to print. Errors
+// are returned to the caller instead.
+func TestLibraryDoesNotExit(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forbidden := map[string]bool{"os.Exit": true, "os.Stdout": true, "os.Stderr": true}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				pkg, ok := n.X.(*ast.Ident)
+				if !ok {
+					break
+				}
+				sel := pkg.Name + "." + n.Sel.Name
+				if forbidden[sel] || pkg.Name == "log" || pkg.Name == "fmt" && strings.HasPrefix(n.Sel.Name, "Print") {
+					t.Errorf("%s: the library uses %s", fset.Position(n.Pos()), sel)
+				}
+			case *ast.BasicLit:
+				if s, err := strconv.Unquote(n.Value); err == nil && strings.Contains(s, "Sorry") {
+					t.Errorf("%s: the library apologizes: %s", fset.Position(n.Pos()), n.Value)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package markov
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadMalformed reads damaged model files, and checks that each fails
+// with an error naming the file, the line and what is wrong there.
+func TestReadMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  string
+	}{
+		{"frequency", "GOMARK v3 prefix=2\n\"\" \"\" the 1 \nthe cat sat foo \n", `model.txt:3: expected integer frequency, got "foo"`},
+		{"no frequency", "GOMARK v3 prefix=2\n\"\" \"\" the \n", `model.txt:2: suffix "the" has no frequency`},
+		{"zero", "GOMARK v3 prefix=2\n\"\" \"\" the 0 \n", `model.txt:2: expected positive frequency for "the", got 0`},
+		{"short prefix", "GOMARK v3 prefix=2\nthe \n", `model.txt:2: expected a prefix of 2 words, got "the "`},
+		{"legacy frequency", "2\n\"\" \"\" the 1.5 \n", `model.txt:2: expected integer frequency, got "1.5"`},
+		{"prefix length", "0\n", `model.txt:1: expected a positive prefix length, got "0"`},
+		{"header", "hello world\n", `model.txt:1: expected a GOMARK header or a prefix length, got "hello world": not a model file?`},
+		{"no prefix", "GOMARK v3\n", `model.txt:1: header "GOMARK v3" has no prefix=`},
+	}
+	for _, tt := range tests {
+		name := filepath.Join(t.TempDir(), "model.txt")
+		if err := os.WriteFile(name, []byte(tt.model), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadFreTable(name)
+		if want := filepath.Join(filepath.Dir(name), tt.want); err == nil || err.Error() != want {
+			t.Errorf("%s: ReadFreTable = %v, want %s", tt.name, err, want)
+		}
+	}
+
+	name := filepath.Join(t.TempDir(), "model.txt")
+	os.WriteFile(name, nil, 0o666)
+	if _, err := ReadFreTable(name); !errors.Is(err, ErrEmptyModel) {
+		t.Errorf("empty file: ReadFreTable = %v, want ErrEmptyModel", err)
+	}
+}
+
+// TestMmapMalformed checks that a damaged line a mapped chain only parses
+// during generation is reported by Err, and generation does not panic.
+func TestMmapMalformed(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "model.txt")
+	model := "GOMARK v3 prefix=2\n\"\" \"\" the 1 \n\"\" the cat 1 \nthe cat sat foo \n"
+	if err := os.WriteFile(name, []byte(model), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenFreTableMmap(name)
+	if c == nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.GenerateWords(10, GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+	if err := c.Err(); err == nil || err.Error() != name+`:4: expected integer frequency, got "foo"` {
+		t.Errorf("Err = %v, want line 4 named", err)
+	}
+}
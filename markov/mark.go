@@ -0,0 +1,1699 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package markov generates random text with a Markov chain algorithm.
+
+Based on the program presented in the "Design and Implementation" chapter
+of The Practice of Programming (Kernighan and Pike, Addison-Wesley 1999).
+See also Computer Recreations, Scientific American 260, 122 - 125 (1989).
+
+A Markov chain algorithm generates text by creating a statistical model of
+potential textual suffixes for a given prefix. Consider this text:
+
+	I am not a number! I am a free man!
+
+Our Markov chain algorithm would arrange this text into this set of prefixes
+and suffixes, or "chain": (This table assumes a prefix length of two words.)
+
+	Prefix       Suffix
+
+	"" ""        I
+	"" I         am
+	I am         a
+	I am         not
+	a free       man!
+	am a         free
+	am not       a
+	a number!    I
+	number! I    am
+	not a        number!
+
+To generate text using this table we select an initial prefix ("I am", for
+example), choose one of the suffixes associated with that prefix at random
+with probability determined by the input statistics ("a"),
+and then create a new prefix by removing the first word from the prefix
+and appending the suffix (making the new prefix is "am a"). Repeat this process
+until we can't find any suffixes for the current prefix or we exceed the word
+limit. (The word limit is necessary as the chain table may contain cycles.)
+
+A Chain is created with NewChain and filled by Build from text files;
+Generate samples text from it. WriteFreTable saves a chain as a model file
+and ReadFreTable loads one again. Suffixes lists the words that may follow
+a prefix with their frequencies. The package never prints or exits; all
+failures are returned as errors. The gomark command in cmd/gomark is a
+command-line interface to it.
+*/
+package markov
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Prefix is a Markov chain prefix of one or more words.
+type Prefix []string
+
+/*
+ * Suffix is a struct that maintains every prefix's suffix word and its frequency
+ */
+type Suffix struct{
+	word string
+	frequency int
+}
+
+// Word returns the word that follows the prefix.
+func (s Suffix) Word() string { return s.word }
+
+// Frequency returns how often the word followed the prefix in the corpus.
+func (s Suffix) Frequency() int { return s.frequency }
+
+// String returns the words of p joined with spaces, for showing it.
+func (p Prefix) String() string {
+	return strings.Join(p, " ")
+}
+
+// keySep separates the words of a prefix in the keys of the chain. No word
+// of a chain can contain it: Build reads NUL bytes as white space and the
+// model readers reject words containing one, so that words with spaces,
+// which JSON models and RemapTokens may produce, cannot run into each
+// other. A prefix of outside words, such as a seed, that does contain it
+// has more separators than any key and is simply unknown.
+const keySep = "\x00"
+
+// key returns p as a key of the chain.
+func (p Prefix) key() string {
+	return strings.Join(p, keySep)
+}
+
+// appendKey appends p as a key of the chain to b, for building keys in a
+// reused buffer.
+func (p Prefix) appendKey(b []byte) []byte {
+	for i, w := range p {
+		if i > 0 {
+			b = append(b, keySep...)
+		}
+		b = append(b, w...)
+	}
+	return b
+}
+
+// splitKey returns the prefix a key of the chain stands for.
+func splitKey(key string) Prefix {
+	return Prefix(strings.Split(key, keySep))
+}
+
+// checkWord fails for a word no chain can hold, see keySep.
+func checkWord(w string) error {
+	if strings.Contains(w, keySep) {
+		return fmt.Errorf("word %q contains a NUL byte", w)
+	}
+	return nil
+}
+
+// Shift removes the first word from the Prefix and appends the given word.
+func (p Prefix) Shift(word string) {
+	copy(p, p[1:])
+	p[len(p)-1] = word
+}
+
+/* Chain contains a map ("chain") of prefixes to a list of suffixes.
+ * A prefix is a string of prefixLen words joined with keySep.
+ * A suffix is a slice of struct Suffix. A prefix can have multiple suffixes.
+ */
+type Chain struct {
+	chain map[string][]Suffix
+	prefixLen int
+
+	// reservoirs holds, per token class, originals replaced by the class
+	// placeholder at build time. A class is active iff it has a reservoir.
+	reservoirs map[string][]string
+
+	// prior is the unigram distribution sampled from when a prefix is
+	// unknown, sorted by word. See SetUnigramPrior.
+	prior []Suffix
+
+	// lazy is set for chains opened with OpenFreTableMmap while parts of
+	// the table are still only in the mapped file.
+	lazy *lazyTable
+
+	// caseStats is set for chains built with BuildOptions.Lowercase; it
+	// holds the capitalization of folded words that generation restores.
+	caseStats map[string]caseStats
+
+	// transforms names the token transformations applied by RemapTokens.
+	transforms []string
+
+	// presets holds the generation flags stored by SetPreset, by name.
+	presets map[string][]string
+
+	// paragraphLengths counts the paragraphs of each length in words seen
+	// by Build with BuildOptions.Paragraphs.
+	paragraphLengths map[int]int
+
+	// positions holds, for chains built with BuildOptions.Positions, where
+	// in their documents the prefixes occurred.
+	positions map[string]positionHist
+
+	// version is the format version of the model file the chain was read
+	// from, see Version.
+	version int
+
+	// starts counts, for chains built with BuildOptions.SentenceStarts,
+	// the sentences of the corpus beginning with each prefix.
+	starts map[string]int
+
+	// meta is where the chain came from, see Metadata.
+	meta metadata
+
+	// prefixIndex lists the prefixes containing each word; it is built by
+	// NearestPrefixes and dropped whenever the chain changes.
+	prefixIndex map[string][]string
+
+	// backoffIndex lists the prefixes by their last word; it is built on
+	// the first backoff of GenerateOptions.Backoff and dropped whenever
+	// the chain changes.
+	backoffIndex map[string][]backoffKey
+
+	// interior lists the prefixes without empty slots in increasing order,
+	// for the chunk starts of GenerateParallel; it is built on first use
+	// and dropped whenever the chain changes.
+	interior []string
+
+	// smoothing is the alpha set by SetSmoothing, and vocab the vocabulary
+	// it is spread over, computed on first use and dropped whenever the
+	// chain changes.
+	smoothing float64
+	vocab     map[string]int
+
+	// ranked is set by PrecomputeChoices with k rankK, or ranked by rankK
+	// when first needed in a chain read from a model file; both are dropped
+	// whenever the chain changes.
+	ranked *rankedChoices
+	rankK  int
+
+	mu sync.RWMutex // see beginRead and beginWrite
+
+	// loadMu serializes filling in lazy and the indexes, which readers
+	// do on demand; lazyOpen is set while lazy is, so that lookups on
+	// ordinary chains need not take it.
+	loadMu   sync.Mutex
+	lazyOpen atomic.Bool
+}
+
+// MaxPrefixLen is the longest prefix NewChain accepts. Long prefixes on
+// ordinary corpora only reproduce the training text verbatim.
+var MaxPrefixLen = 10
+
+// NewChain returns a new Chain with prefixes of prefixLen words. It fails
+// unless 0 < prefixLen <= MaxPrefixLen.
+func NewChain(prefixLen int) (*Chain, error) {
+	if prefixLen <= 0 || prefixLen > MaxPrefixLen {
+		return nil, fmt.Errorf("prefix length %d out of range 1..%d", prefixLen, MaxPrefixLen)
+	}
+	return newChain(prefixLen), nil
+}
+
+// PrefixLen returns the number of words in the prefixes of c.
+func (c *Chain) PrefixLen() int {
+	return c.prefixLen
+}
+
+// Version returns the format version of the model file c was read from
+// with ReadFreTable or OpenFreTableMmap: 1 for files that start with the
+// bare prefix length, as files did before the format had versions, and
+// FormatVersion for files written now. It is 0 for other chains.
+func (c *Chain) Version() int {
+	return c.version
+}
+
+// Suffixes returns a copy of the words that followed prefix in the corpus
+// with their frequencies, in the order of the model. It returns nil for
+// prefixes the chain does not know; the start of a document is the prefix
+// of prefixLen empty strings, and a prefix of the first words of a document
+// starts with empty strings too.
+func (c *Chain) Suffixes(prefix Prefix) []Suffix {
+	defer c.beginRead()()
+	return append([]Suffix(nil), c.lookup(prefix.key())...)
+}
+
+// newChain is NewChain without the range check, for chains whose prefix
+// length comes from an existing model.
+func newChain(prefixLen int) *Chain {
+	return &Chain{chain: make(map[string][]Suffix), prefixLen: prefixLen}
+}
+
+// BuildOptions configures BuildOpts. The zero value builds exactly like Build.
+type BuildOptions struct {
+	// Classifiers replace matching tokens by a class placeholder such as
+	// <url>; the first classifier that matches wins.
+	Classifiers []TokenClassifier
+	// ReservoirSize caps the originals kept per class (default 64).
+	ReservoirSize int
+	// Lowercase folds every word to lower case, keeping statistics on how
+	// it was capitalized so that generated text can be re-capitalized.
+	Lowercase bool
+	// Filter, if set, pipes the words of every input file through an
+	// external command before anything else is done with them; see
+	// FilterCommand for the protocol. FilterTimeout bounds each run
+	// (default one minute).
+	Filter        FilterCommand
+	FilterTimeout time.Duration
+	// Rand is the source of every random decision made while building,
+	// such as reservoir sampling; nil means the math/rand global source.
+	Rand *rand.Rand
+	// Index, if set, receives the n-grams of every input file.
+	Index *IndexBuilder
+	// SkipLines and SkipTokens drop the first lines, then the first
+	// words of every input file before anything is counted.
+	SkipLines  int
+	SkipTokens int
+	// StripHeaderUntil drops everything up to and including the first
+	// line matching it, after SkipLines and before SkipTokens, such as the
+	// "*** START OF ..." line ending the license header of Project
+	// Gutenberg texts. Files without a matching line are kept whole.
+	StripHeaderUntil *regexp.Regexp
+	// OnTransition, if set, is called for every transition before it is
+	// counted, with a copy of the prefix (empty slots are ""), the word
+	// following it, the index of the input file and the position of the
+	// word in it. The transition is counted weight times, or skipped if
+	// keep is false or weight is below 1.
+	OnTransition func(prefix []string, word string, docIndex, position int) (weight int, keep bool)
+	// Positions records for every prefix a histogram of where in the
+	// documents it occurs; see PositionHistogram.
+	Positions bool
+	// Paragraphs turns every blank line between two words into a
+	// ParagraphToken and records the distribution of paragraph lengths.
+	Paragraphs bool
+	// NoEndToken leaves out the EndToken Build counts after the last word
+	// of every input, so that generation only stops at the word limit or
+	// a dead end, as it did before models recorded their ends. OnTransition
+	// is called for the EndToken transitions too.
+	NoEndToken bool
+	// EndParagraphs also ends a document at every blank line: each block
+	// of text ends with EndToken and the next starts from the start state
+	// again. It is ignored with NoEndToken.
+	EndParagraphs bool
+	// SentenceStarts records how often every prefix began a sentence: came
+	// first in a document or right after a word ending in '.', '!' or '?'
+	// or a ParagraphToken. GenerateOptions.SentenceStart starts from them.
+	SentenceStarts bool
+	// Stamp records the time of the build and the version of the tool in
+	// the metadata of the chain. The model file, and its Hash, then differ
+	// from build to build; leave it off for reproducible builds.
+	Stamp bool
+}
+// maxTokenSize bounds the scanner buffer used by Build. The buffer starts
+// small and only grows when a single token does not fit.
+const maxTokenSize = 1 << 20
+
+// interner hands out one shared string per distinct token.
+type interner map[string]string
+
+// intern returns the string for b, allocating only the first time a token is
+// seen. The lookup with string(b) as key does not allocate.
+func (in interner) intern(b []byte) string {
+	if s, ok := in[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	in[s] = s
+	return s
+}
+
+// BuildReport summarizes what Build read and produced. Its JSON encoding
+// (see read -json) is a stable schema: fields may be added but existing
+// names must not change.
+type BuildReport struct {
+	Files         []FileReport `json:"files"`
+	Tokens        int          `json:"tokens"`
+	Prefixes      int          `json:"prefixes"`
+	SuffixEntries int          `json:"suffix_entries"`
+	ModelBytes    int64        `json:"model_bytes,omitempty"`
+	RunSavings    int64        `json:"run_savings,omitempty"` // bytes gob models save, see Chain.GobRunSavings
+	Warnings      []string     `json:"warnings,omitempty"`
+}
+
+// memorizedRatio is the share of distinct prefixes per token above which a
+// build is reported as having mostly memorized its corpus.
+const memorizedRatio = 0.85
+
+// PrefixRatio returns the number of distinct prefixes per token read. Close
+// to 1 means nearly every context occurs once and generation will copy the
+// corpus verbatim.
+func (r BuildReport) PrefixRatio() float64 {
+	if r.Tokens == 0 {
+		return 0
+	}
+	return float64(r.Prefixes) / float64(r.Tokens)
+}
+
+// FileReport describes a single input file of a Build.
+type FileReport struct {
+	Name   string `json:"name"`
+	Bytes  int64  `json:"bytes"`
+	Tokens int    `json:"tokens"`
+	// Stripped counts the words dropped by the header options of
+	// BuildOptions; they are not included in Tokens.
+	Stripped int `json:"stripped_tokens"`
+	// Error is set when the file could not be read; nothing of it is in
+	// the chain then.
+	Error string `json:"error,omitempty"`
+}
+
+/*
+ * Build reads text from the provided slice of inputfile
+ * parses it into prefixes and suffixes that are stored in Chain.
+ * It returns a report of the files read and the resulting chain size.
+ * Every file is a document of its own, see BuildReader.
+ * Files that cannot be read are left out and reported by a *BuildError,
+ * see there; other errors stop the build.
+ */
+func (c *Chain) Build(inputFile []string) (BuildReport, error) {
+	return c.BuildOpts(inputFile, BuildOptions{})
+}
+
+// BuildOpts is like Build but applies opts while reading the input.
+func (c *Chain) BuildOpts(inputFile []string, opts BuildOptions) (BuildReport, error) {
+	return c.build(len(inputFile), func(i int, opts BuildOptions, vocab interner, report *BuildReport) ([]string, FileReport, error) {
+		return readInput(inputFile[i], opts, vocab, report)
+	}, opts)
+}
+
+// BuildReader adds the text read from r to the chain as one document, with
+// the same word splitting as Build: it starts from the empty prefix and
+// its last words do not run on into the next document. Calling it again,
+// or mixing it with Build, adds to the counts, so a chain can be fed one
+// string or response body at a time. The report lists r as a file
+// without a name.
+func (c *Chain) BuildReader(r io.Reader) (BuildReport, error) {
+	return c.BuildReaderOpts("", r, BuildOptions{})
+}
+
+// BuildReaderOpts is like BuildReader but applies opts while reading, and
+// names the document in the report and in errors.
+func (c *Chain) BuildReaderOpts(name string, r io.Reader, opts BuildOptions) (BuildReport, error) {
+	return c.build(1, func(_ int, opts BuildOptions, vocab interner, report *BuildReport) ([]string, FileReport, error) {
+		return readTokens(name, r, opts, vocab, report)
+	}, opts)
+}
+
+// AddText adds the text read from r to c as one more document, see
+// BuildReader. It is meant for training a model further as new text
+// arrives: c may have been read with ReadFreTable, and the counts add up,
+// so that a chain trained in several calls is the same as one built from
+// all the text at once. Inputs that cannot be read come back as a
+// *BuildError.
+func (c *Chain) AddText(r io.Reader) error {
+	_, err := c.BuildReader(r)
+	return err
+}
+
+// AddFile is AddText on the named file.
+func (c *Chain) AddFile(name string) error {
+	_, err := c.Build([]string{name})
+	return err
+}
+
+// inputReader returns the tokens of the i-th document of a build and a
+// FileReport for it without the token count.
+type inputReader func(i int, opts BuildOptions, vocab interner, report *BuildReport) ([]string, FileReport, error)
+
+// build is the body of BuildOpts and BuildReaderOpts for n documents.
+func (c *Chain) build(n int, readDoc inputReader, opts BuildOptions) (BuildReport, error) {
+	defer c.beginWrite()()
+	var report BuildReport
+	if err := c.materialize(); err != nil {
+		return report, err
+	}
+	vocab := make(interner)
+	res := make(map[string]*reservoir)
+	collisions := newCollisionCounter()
+	var failed []*FileError
+	rng := orGlobal(opts.Rand)
+	if opts.ReservoirSize <= 0 {
+		opts.ReservoirSize = defaultReservoirSize
+	}
+	if opts.Stamp {
+		c.meta.stamp()
+	}
+	var s [][]string = make([][]string, n)//nest slices to store content of input
+	for i := range s{
+		s[i] = make([]string, 0)
+	}
+
+	//for each input file
+	for i := 0; i < n; i++{
+		tokens, file, err := readDoc(i, opts, vocab, &report)
+		if err != nil {
+			failed = append(failed, &FileError{file.Name, err})
+			file.Error = err.Error()
+			report.Files = append(report.Files, file)
+			continue
+		}
+
+		initial := true//the first word of a file starts a sentence
+		paragraph := 0//words in the current paragraph
+		ends := 0//end tokens added, which are not tokens of the file
+		endDoc := func() {
+			if n := len(s[i]); n > 0 && s[i][n-1] != EndToken && !opts.NoEndToken {
+				s[i] = append(s[i], EndToken)
+				ends++
+			}
+		}
+		for _, raw := range tokens{
+			if raw == ParagraphToken && opts.EndParagraphs && !opts.NoEndToken {
+				if opts.Paragraphs {
+					c.countParagraph(paragraph)
+				}
+				paragraph = 0
+				initial = true
+				endDoc()
+				continue
+			}
+			if raw == ParagraphToken {
+				c.countParagraph(paragraph)
+				paragraph = 0
+				s[i] = append(s[i], raw)
+				continue
+			}
+			collisions.add(raw)
+			paragraph++
+			word := raw
+			if opts.Classifiers != nil {
+				word = c.classify(word, opts.Classifiers, res, opts.ReservoirSize, rng)
+			}
+			if opts.Lowercase && word == raw {
+				word = c.foldCase(raw, initial, vocab)
+			}
+			initial = endsSentence(raw)
+			s[i] = append(s[i], word)//each file gets a slice of words
+		}
+		if opts.Paragraphs {
+			c.countParagraph(paragraph)
+		}
+		endDoc()
+
+		file.Tokens = len(s[i]) - ends
+		report.Files = append(report.Files, file)
+		report.Tokens += file.Tokens
+		c.meta.addSource(file.Name, file.Tokens)
+		if opts.Index != nil {
+			opts.Index.AddTokens(s[i])
+		}
+	}
+	// Keys are built in buf and interned, so that a prefix seen before
+	// costs no allocation.
+	keys := make(interner)
+	var buf []byte
+	for i, _ := range s{
+		p := make(Prefix, c.prefixLen)
+		block := 0//index of the first word after the last EndToken
+		for j, get := range s[i]{//get word from slice
+			weight := 1
+			if opts.OnTransition != nil {
+				w, keep := opts.OnTransition(append([]string(nil), p...), get, i, j)
+				if weight = w; !keep {
+					weight = 0
+				}
+			}
+			if weight > 0 {
+				buf = p.appendKey(buf[:0])
+				key := keys.intern(buf)
+				c.add(key, get, weight)
+				if opts.Positions {
+					c.addPosition(key, j*PositionBuckets/len(s[i]), weight)
+				}
+			}
+			p.Shift(s[i][j])
+			if opts.SentenceStarts && startsSentence(s[i][:j+1], block, j+1-c.prefixLen) {
+				buf = p.appendKey(buf[:0])
+				c.addStart(keys.intern(buf), 1)
+			}
+			if get == EndToken {
+				p = make(Prefix, c.prefixLen)//the next block starts afresh
+				block = j + 1
+			}
+		}
+	}
+	for class, r := range res {
+		if c.reservoirs == nil {
+			c.reservoirs = make(map[string][]string)
+		}
+		c.reservoirs[class] = append(c.reservoirs[class], r.items...)
+	}
+	report.Warnings = append(report.Warnings, collisions.warnings()...)
+	report.Prefixes = len(c.chain)
+	for _, suf := range c.chain {
+		report.SuffixEntries += len(suf)
+	}
+	if ratio := report.PrefixRatio(); ratio > memorizedRatio {
+		hint := fmt.Sprintf("try a prefix length below %d", c.prefixLen)
+		if c.prefixLen == 1 {
+			hint = "the corpus is too small"
+		}
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"%.0f%% of the words start a distinct prefix: the model has mostly memorized its corpus; %s",
+			100*ratio, hint))
+	}
+	if failed != nil {
+		return report, &BuildError{failed}
+	}
+	return report, nil
+}
+// readInput returns the tokens of an input file after the header options
+// and the filter of opts are applied, and a FileReport for it without the
+// token count. Warnings are added to report. Errors name the file.
+func readInput(name string, opts BuildOptions, vocab interner, report *BuildReport) ([]string, FileReport, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, FileReport{Name: name}, err
+	}
+	defer in.Close()
+	return readTokens(name, in, opts, vocab, report)
+}
+
+// readTokens is readInput for a document read from r.
+func readTokens(name string, r io.Reader, opts BuildOptions, vocab interner, report *BuildReport) ([]string, FileReport, error) {
+	file := FileReport{Name: name}
+	named := func(err error) error {
+		if name == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	counted := &countingReader{r: r}
+
+	var src io.Reader = nulAsSpace{counted}
+	if opts.SkipLines > 0 || opts.StripHeaderUntil != nil {
+		var found bool
+		var err error
+		src, file.Stripped, found, err = stripHeader(counted, opts.SkipLines, opts.StripHeaderUntil)
+		if err != nil {
+			return nil, file, named(err)
+		}
+		if !found {
+			report.Warnings = append(report.Warnings, named(fmt.Errorf("no line matches %q, nothing stripped", opts.StripHeaderUntil)).Error())
+		}
+	}
+
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 4096), maxTokenSize)//grow only for unusually long tokens
+	scanner.Split(bufio.ScanWords)//split by white space get words 
+	if opts.Paragraphs || opts.EndParagraphs {
+		scanner.Split(scanWordsAndParagraphs())
+	}
+
+	var tokens []string
+	for skip := opts.SkipTokens; scanner.Scan(); {
+		if skip > 0 {
+			skip--
+			file.Stripped++
+			continue
+		}
+		tokens = append(tokens, vocab.intern(scanner.Bytes()))
+	}
+	file.Bytes = counted.n
+	if err := scanner.Err(); err != nil {
+		return nil, file, named(err)
+	}
+	if opts.Filter != nil {
+		var err error
+		if tokens, err = runFilter(opts.Filter, tokens, opts.FilterTimeout, vocab); err != nil {
+			return nil, file, named(err)
+		}
+	}
+	return tokens, file, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// nulAsSpace reads r with its NUL bytes, which no word may hold, see
+// keySep, turned into spaces.
+type nulAsSpace struct{ r io.Reader }
+
+func (n nulAsSpace) Read(p []byte) (int, error) {
+	k, err := n.r.Read(p)
+	for i, b := range p[:k] {
+		if b == 0 {
+			p[i] = ' '
+		}
+	}
+	return k, err
+}
+
+// dropIndexes drops what is computed from the table on demand, for calls
+// that change it.
+func (c *Chain) dropIndexes() {
+	c.prefixIndex = nil
+	c.backoffIndex = nil
+	c.interior = nil
+	c.vocab = nil
+	c.ranked, c.rankK = nil, 0
+}
+
+// add counts n more occurrences of word after the prefix key.
+func (c *Chain) add(key, word string, n int) {
+	c.dropIndexes()
+	/*
+	* maps of structs: can’t change the value of a field in a 
+ 	* struct that is in a map. solution: use a copy!!
+	* be careful when it comes to slices of struct as value field in map 
+	*/
+	suf := c.chain[key]//a slice of suffix of key's
+	for i, value := range suf{
+		if value.word == word{//suffix exists in table, frequency += n
+			value.frequency += n
+			suf[i] = value
+			return
+		}
+	}
+	//suffix not exists in table, frequency = n
+	c.chain[key] = append(c.chain[key], Suffix{word, n})
+}
+
+/*
+ * WirteFreTable writes chain in to output file.
+ * The format should be prefix Suffix{word frequency}.
+ * First line is the header "GOMARK v4 prefix=n", giving the format version
+ * and the prefixLen n.
+ * Prefixes and records are written in sorted order, so the same chain always
+ * produces the same file.
+ * Lines starting with a tab are extension records, which no table line can
+ * start with because words never contain white space:
+ *	\treservoir class original...
+ *	\tprior word frequency
+ *	\tcase word initial initialCaps mid midCaps
+ *	\ttransform name
+ *	\tpreset name flag...
+ *	\tchoices k (suffixes ranked per prefix, see PrecomputeChoices)
+ *	\tparagraph length count
+ *	\tposition prefix... count... (one count per tenth of the documents)
+ *	\tstart prefix... count (sentences beginning with prefix)
+ * The file ends with a checksum record after the table, see ReadFreTable.
+ * Words are escaped: percent signs and white space within them are written
+ * as %XX, so that "50%" is "50%25" in the file, and the word `""` as
+ * %22%22, as `""` spells the empty slots of prefixes; see escapeWord.
+ * Files named *.gz are compressed with gzip; ReadFreTable reads them back.
+ * If anything fails the file is removed again and the error returned.
+ * WriteTo writes the same to any io.Writer.
+ */
+func (c *Chain) WriteFreTable(name string) error {
+	return writeFile(name, func(w io.Writer) error {
+		_, err := c.WriteTo(w)
+		return err
+	})
+}
+
+// WriteTo writes the model file of c to w, as WriteFreTable writes it to a
+// file, and returns the number of bytes written; it implements io.WriterTo,
+// for models sent over the network or kept in memory. w is written through
+// a buffer. The last line is the checksum record, see ReadFreTable.
+func (c *Chain) WriteTo(w io.Writer) (int64, error) {
+	return c.writeTo(w, 0)
+}
+
+// writeTo is WriteTo, writing probabilities of the given decimal places
+// instead of counts unless decimals is 0, see WriteProbabilities.
+func (c *Chain) writeTo(w io.Writer, decimals int) (int64, error) {
+	cw := &countingWriter{w: w}
+	sum := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(cw, sum))
+	if err := c.writeFreTable(bw, decimals); err != nil {
+		return cw.n, err
+	}
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	_, err := fmt.Fprintln(cw, checksumRecord(sum.Sum32()))
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// writeFile creates the file name and writes it with write, compressed
+// with gzip if name ends in .gz, removing it again if anything fails.
+func writeFile(name string, write func(io.Writer) error) (err error) {
+	outFile, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = errors.Join(err, outFile.Close()); err != nil {
+			os.Remove(name)
+		}
+	}()
+	var w io.Writer = outFile
+	if compressed(name) {
+		zw := gzip.NewWriter(outFile)
+		defer func() { err = errors.Join(err, zw.Close()) }()
+		w = zw
+	}
+	bw := bufio.NewWriter(w)
+	if err := write(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeFreTable writes the model file of c to w, with probabilities of the
+// given decimal places instead of counts unless decimals is 0.
+func (c *Chain) writeFreTable(w io.Writer, decimals int) error {
+	defer c.beginRead()()
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
+	header := c.header()//first line gives the version and prefixLen
+	if decimals > 0 {
+		header += fmt.Sprintf(" probabilities=%d", decimals)
+	}
+	fmt.Fprintln(w, header)
+	for _, fields := range c.records() {
+		escaped := make([]string, len(fields))
+		for i, f := range fields {
+			escaped[i] = fileWord(f)
+		}
+		fmt.Fprintln(w, "\t"+strings.Join(escaped, " "))
+	}
+
+	for _, key := range sortedKeys(c.chain){//for each prefix, in a stable order
+		suffix := c.chain[key]
+		for _, word := range splitKey(key) {//empty slots are written ""
+			fmt.Fprint(w, fileWord(word), " ")
+		}
+		if decimals > 0 {
+			units, err := probabilityUnits(suffix, decimals)
+			if err != nil {
+				return fmt.Errorf("prefix %q: %v", splitKey(key).String(), err)
+			}
+			for j, val := range suffix{
+				fmt.Fprint(w, escapeWord(val.word), " ", formatUnits(units[j], decimals), " ")
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+		for _, val := range suffix{//for each suffix
+			fmt.Fprint(w, escapeWord(val.word), " ", val.frequency, " ")
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+/*
+ * ReadFreTable reads the given model file and initilize a chain.
+ * The first line of model file gives the version and prefixLen; files of
+ * version 1 give the bare prefixLen, and newer versions than
+ * FormatVersion fail with ErrUnsupportedVersion.
+ * The rest, Each line of model file in format prefix Suffix{word frequency}
+ * Malformed lines are reported as file:line: problem; an empty file
+ * fails with ErrEmptyModel. Files compressed with gzip are decompressed,
+ * whatever their names, see OpenModelFile. ReadFrom reads the same from
+ * any io.Reader.
+ * From version 4 on the last line is a checksum record,
+ *	\tchecksum crc32 hex
+ * giving the CRC-32 of the lines before it; files cut short or damaged fail
+ * with a *ChecksumError. Files of older versions are returned along with a
+ * *ChecksumError whose Missing is set.
+ */
+func ReadFreTable(modelFile string) (*Chain, error) {
+	in, err := OpenModelFile(modelFile)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return readFreTable(in, modelFile)
+}
+
+// ReadFrom replaces the model of c by the model file read from r up to
+// EOF, as ReadFreTable reads it from a file, and returns the number of
+// bytes read; it implements io.ReaderFrom, so that the zero Chain can be
+// read into. Everything the model file holds is replaced; settings it does
+// not hold, such as the smoothing, are kept. Errors give the line as
+// "line n:". r is read as it is: wrap compressed data in gzip.NewReader.
+// On error c is left unchanged, but for the *ChecksumError of models
+// without a checksum, see ReadFreTable.
+func (c *Chain) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	read, err := readFreTable(cr, "")
+	if read == nil {
+		return cr.n, err
+	}
+	c.replace(read, false)
+	return cr.n, err
+}
+
+// replace replaces the model of c by that of read, which is not used
+// afterwards, keeping the settings of c, see ReadFrom, unless reset is set:
+// then c is read as if it were new.
+func (c *Chain) replace(read *Chain, reset bool) {
+	defer c.beginWrite()()
+	c.dropIndexes()
+	c.chain, c.prefixLen, c.version = read.chain, read.prefixLen, read.version
+	c.reservoirs, c.prior, c.caseStats = read.reservoirs, read.prior, read.caseStats
+	c.transforms, c.presets = read.transforms, read.presets
+	c.paragraphLengths, c.positions, c.starts = read.paragraphLengths, read.positions, read.starts
+	c.meta = read.meta
+	c.rankK = read.rankK
+	c.lazy = nil
+	c.lazyOpen.Store(false)
+	if reset {
+		c.smoothing = read.smoothing
+	}
+}
+
+// readFreTable reads a model file from r. Errors start with name:line, or
+// with "line n" if name is empty. Like ReadFreTable it returns the chain
+// with the error for files without a checksum.
+func readFreTable(r io.Reader, name string) (*Chain, error) {
+	return readModel(r, name, nil)
+}
+
+// readModel is readFreTable, but if table is not nil the lines of the table
+// are passed to it, with the prefix length of the file, instead of being
+// stored in the chain returned, which then only holds the records.
+func readModel(r io.Reader, name string, table func(prefixLen int, key string, suffixes []Suffix) error) (*Chain, error) {
+	at := func(lineNo int) string {
+		if name == "" {
+			return fmt.Sprintf("line %d", lineNo)
+		}
+		return fmt.Sprintf("%s:%d", name, lineNo)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+
+	if !scanner.Scan() {
+		err := scanner.Err()
+		if err == nil {
+			err = ErrEmptyModel
+		}
+		if name == "" {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	prefixLen, version, err := parseHeader(scanner.Text())//get prefixLen
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", at(1), err)
+	}
+	decimals, err := headerDecimals(scanner.Text())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", at(1), err)
+	}
+	c := newChain(prefixLen)//a new chain
+	c.version = version
+	sum := crc32.NewIEEE()//of the lines before the checksum record
+	sum.Write(scanner.Bytes())
+	sum.Write(newline)
+	checked := false
+	// A line that does not parse in a file with a checksum is most likely
+	// where the file was cut short: the rest is only summed, and the
+	// checksum decides which error is returned.
+	var lineErr error
+
+	lineNo := 2
+	for ; scanner.Scan(); lineNo++{
+		line := scanner.Text()//get a whole line each time we scan
+		if checked {
+			if line == "" {
+				continue
+			}
+			return nil, &ChecksumError{Name: name, Problem: fmt.Sprintf("line %d follows the checksum record", lineNo)}
+		}
+		if isChecksumRecord(line) {
+			if problem := checkChecksum(line, sum.Sum32()); problem != "" {
+				return nil, &ChecksumError{Name: name, Problem: problem}
+			}
+			if lineErr != nil {
+				return nil, lineErr
+			}
+			checked = true
+			continue
+		}
+		sum.Write(scanner.Bytes())
+		sum.Write(newline)
+		if lineErr != nil {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			fields, err := readFields(line, version, prefixLen)
+			if err == nil {
+				err = c.readRecord(fields)
+			}
+			if err != nil {
+				lineErr = fmt.Errorf("%s: %v", at(lineNo), err)
+			}
+			continue
+		}
+		key, suffixes, err := parseTableLine(line, prefixLen, version, decimals)
+		if err != nil {
+			lineErr = fmt.Errorf("%s: %v", at(lineNo), err)
+		}
+		if len(suffixes) == 0 {
+			continue
+		}
+		if table == nil {
+			c.chain[key] = append(c.chain[key], suffixes...)
+		} else if err := table(prefixLen, key, suffixes); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", at(lineNo), err)
+	}
+	if lineErr != nil && version < checksumVersion {
+		return nil, lineErr
+	}
+	if !checked {
+		if problem := missingChecksum(version); problem != "" {
+			return nil, &ChecksumError{Name: name, Problem: problem}
+		}
+		return c, &ChecksumError{Name: name, Missing: true}
+	}
+	return c, nil
+}
+
+// newline ends every line of a model file.
+var newline = []byte{'\n'}
+
+// FormatVersion is the version of the model file format WriteFreTable
+// writes. Version 1 files, from before the format had versions, start with
+// the bare prefix length instead of a header; version 2 files do not
+// escape their words, and version 3 files do not end with a checksum.
+const FormatVersion = 4
+
+// headerMagic starts the first line of model files from version 2 on.
+const headerMagic = "GOMARK"
+
+// header returns the first line of the model file of c.
+func (c *Chain) header() string {
+	return fmt.Sprintf("%s v%d prefix=%d", headerMagic, FormatVersion, c.prefixLen)
+}
+
+// parseHeader returns the prefix length and format version given by the
+// first line of a model file: "GOMARK v4 prefix=n" or, in version 1, the
+// bare n. Other key=value fields of the header are ignored, so that later
+// versions can add some; versions newer than FormatVersion fail with
+// ErrUnsupportedVersion.
+func parseHeader(line string) (prefixLen, version int, err error) {
+	line = strings.TrimSpace(line)
+	if n, err := strconv.Atoi(line); err == nil {
+		if n <= 0 {
+			return 0, 0, fmt.Errorf("expected a positive prefix length, got %q", line)
+		}
+		return n, 1, nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != headerMagic || !strings.HasPrefix(fields[1], "v") {
+		return 0, 0, fmt.Errorf("expected a %s header or a prefix length, got %q: not a model file?", headerMagic, line)
+	}
+	if version, err = strconv.Atoi(fields[1][1:]); err != nil || version < 2 {
+		return 0, 0, fmt.Errorf("bad format version %q", fields[1])
+	}
+	if version > FormatVersion {
+		return 0, version, fmt.Errorf("%w: the file is v%d, this gomark reads up to v%d", ErrUnsupportedVersion, version, FormatVersion)
+	}
+	for _, f := range fields[2:] {
+		if v, ok := strings.CutPrefix(f, "prefix="); ok {
+			if prefixLen, err = strconv.Atoi(v); err != nil || prefixLen <= 0 {
+				return 0, 0, fmt.Errorf("expected a positive prefix length, got %q", f)
+			}
+		}
+	}
+	if prefixLen == 0 {
+		return 0, 0, fmt.Errorf("header %q has no prefix=", line)
+	}
+	return prefixLen, version, nil
+}
+
+// parseTableLine splits a table line of a model file of the given version
+// into its prefix key and suffixes. decimals is that of the header, see
+// parseFrequency.
+func parseTableLine(line string, prefixLen, version, decimals int) (string, []Suffix, error) {
+	if strings.Contains(line, keySep) {
+		return "", nil, fmt.Errorf("line contains a NUL byte")
+	}
+	words := strings.Split(strings.TrimSuffix(line, " "), " ")//split the line by white space
+	if prefixLen <= 0 || len(words) < prefixLen {
+		return "", nil, fmt.Errorf("expected a prefix of %d words, got %q", prefixLen, line)
+	}
+	if (len(words)-prefixLen)%2 != 0 {
+		return "", nil, fmt.Errorf("suffix %q has no frequency", words[len(words)-1])
+	}
+	for i, w := range words[:prefixLen] {
+		var err error
+		if words[i], err = readWord(w, version); err != nil {
+			return "", nil, err
+		}
+	}
+	if version >= escapedVersion {
+		if err := unescapeFields(words[prefixLen:]); err != nil {
+			return "", nil, err
+		}
+	}
+	key := Prefix(words[:prefixLen]).key()//get key of the map, which is prefix
+	var suffixes []Suffix
+	for i := prefixLen; i < len(words)-1; i += 2{//get all suffix of current prefix
+		var newSuf Suffix
+		newSuf.word = words[i]
+		f, err := parseFrequency(words[i+1], decimals)
+		if err != nil {
+			return "", nil, err
+		}
+		if f <= 0 {
+			return "", nil, fmt.Errorf("expected positive frequency for %q, got %d", words[i], f)
+		}
+		newSuf.frequency = f
+		suffixes = append(suffixes, newSuf)
+	}
+	return key, suffixes, nil
+}
+
+// records returns the fields of the extension records of the model file
+// of c, without the tab that starts them, in the order they are written.
+func (c *Chain) records() [][]string {
+	out := c.meta.records()
+	for _, class := range sortedKeys(c.reservoirs) {
+		out = append(out, append([]string{"reservoir", class}, c.reservoirs[class]...))
+	}
+	for _, s := range c.prior {
+		out = append(out, []string{"prior", s.word, strconv.Itoa(s.frequency)})
+	}
+	for _, t := range c.transforms {
+		out = append(out, []string{"transform", t})
+	}
+	for _, name := range sortedKeys(c.presets) {
+		out = append(out, append([]string{"preset", name}, c.presets[name]...))
+	}
+	if c.rankK > 0 {
+		out = append(out, []string{"choices", strconv.Itoa(c.rankK)})
+	}
+	lengths := make([]int, 0, len(c.paragraphLengths))
+	for n := range c.paragraphLengths {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+	for _, n := range lengths {
+		out = append(out, []string{"paragraph", strconv.Itoa(n), strconv.Itoa(c.paragraphLengths[n])})
+	}
+	for _, key := range sortedKeys(c.positions) {
+		out = append(out, positionRecord(key, c.positions[key]))
+	}
+	for _, key := range sortedKeys(c.starts) {
+		out = append(out, startRecord(key, c.starts[key]))
+	}
+	if c.caseStats != nil {
+		out = append(out, []string{"case"})//marks the model as case-folded
+	}
+	for _, word := range sortedKeys(c.caseStats) {
+		if s := c.caseStats[word]; s.unusual() {
+			out = append(out, []string{"case", word, strconv.Itoa(s.initial), strconv.Itoa(s.initialCaps), strconv.Itoa(s.mid), strconv.Itoa(s.midCaps)})
+		}
+	}
+	return out
+}
+
+// recordFields splits an extension record line into its fields. Unlike
+// strings.Fields it keeps ParagraphToken and EndToken, which are white
+// space but may be words of the prefix of a record.
+func recordFields(line string) []string {
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+}
+
+// readRecord stores the extension record fields read from a model file,
+// failing on malformed records as validate reports them. Unknown records
+// are ignored so that older binaries can read newer files.
+func (c *Chain) readRecord(fields []string) error {
+	if msg := checkRecord(fields); msg != "" {
+		return errors.New(msg)
+	}
+	switch fields[0] {
+	case "reservoir":
+		if len(fields) < 2 {
+			return nil
+		}
+		if c.reservoirs == nil {
+			c.reservoirs = make(map[string][]string)
+		}
+		c.reservoirs[fields[1]] = append(c.reservoirs[fields[1]], fields[2:]...)
+	case "prior":
+		if len(fields) != 3 {
+			return nil
+		}
+		if f, err := strconv.Atoi(fields[2]); err == nil && f > 0 {
+			c.prior = append(c.prior, Suffix{fields[1], f})
+		}
+	case "case":
+		if c.caseStats == nil {
+			c.caseStats = make(map[string]caseStats)
+		}
+		if len(fields) != 6 {
+			return nil
+		}
+		var n [4]int
+		for i := range n {
+			n[i], _ = strconv.Atoi(fields[2+i])
+		}
+		c.caseStats[fields[1]] = caseStats{n[0], n[1], n[2], n[3]}
+	case "transform":
+		if len(fields) == 2 {
+			c.transforms = append(c.transforms, fields[1])
+		}
+	case "preset":
+		if c.presets == nil {
+			c.presets = make(map[string][]string)
+		}
+		c.presets[fields[1]] = fields[2:]
+	case "meta", "source", "offset":
+		c.meta.readRecord(fields)
+	case "choices":
+		if k, err := strconv.Atoi(fields[1]); err == nil && k > 0 {
+			c.rankK = k
+		}
+	case "position":
+		c.readPositionRecord(fields[1:])
+	case "start":
+		c.readStartRecord(fields[1:])
+	case "paragraph":
+		if len(fields) != 3 {
+			return nil
+		}
+		n, err1 := strconv.Atoi(fields[1])
+		count, err2 := strconv.Atoi(fields[2])
+		if err1 == nil && err2 == nil && n > 0 && count > 0 {
+			if c.paragraphLengths == nil {
+				c.paragraphLengths = make(map[int]int)
+			}
+			c.paragraphLengths[n] += count
+		}
+	}
+	return nil
+}
+
+
+// GenerateOptions configures GenerateOpts. The zero value generates
+// exactly like Generate.
+type GenerateOptions struct {
+	// AvoidDeadEnds excludes suffixes whose shifted prefix has no suffixes
+	// of its own, unless every candidate is such a dead end. EndToken is
+	// one, so the text only ends where nothing else can follow.
+	AvoidDeadEnds bool
+	// Rand is the source of every random decision made while generating;
+	// nil means the math/rand global source.
+	Rand *rand.Rand
+	// ParagraphLengths makes paragraph lengths follow the distribution
+	// recorded by Build with BuildOptions.Paragraphs, by softly raising
+	// or lowering the probability of a ParagraphToken. Chains without
+	// that distribution are not affected.
+	ParagraphLengths bool
+
+	// RandomStart is the probability of starting at a prefix picked at
+	// random from the chain instead of the start state, whose successors
+	// are dominated by whatever boilerplate opens the documents.
+	RandomStart float64
+	// SentenceStart starts at a prefix drawn, by how often it did, from
+	// those that began a sentence in the corpus, for chains built with
+	// BuildOptions.SentenceStarts, so that the text opens like a sentence
+	// rather than like the documents. Other chains start at the start
+	// state. A RandomStart drawn still wins.
+	SentenceStart bool
+
+	// Rules, if set, forbid, boost and replace words, see Rules.
+	Rules *Rules
+
+	// CompleteSentence keeps generating past the word limit until a word
+	// ends the sentence with '.', '!' or '?', or a paragraph ends, adding
+	// at most SentenceWordCap words. TrimSentence instead cuts the text
+	// back to the end of its last complete sentence, unless it has none;
+	// it is ignored by Session and GenerateParallel, and when
+	// CompleteSentence is set.
+	CompleteSentence bool
+	TrimSentence     bool
+
+	// Temperature reshapes every draw by raising the weights to the power
+	// 1/Temperature: below 1 favours frequent suffixes, above 1 flattens
+	// the choice. The weights are reshaped in float64 logarithms, so large
+	// frequencies cannot overflow. 0 means 1, drawing by frequency exactly
+	// as without a temperature; the limit of 0 is Greedy.
+	Temperature float64
+	// Repeat, if set, breaks the loops generation falls into, see
+	// RepeatGuard.
+	Repeat *RepeatGuard
+
+	// TopK, if positive, draws only from the TopK suffixes with the
+	// highest weight, the first in byte order among equals, once
+	// AvoidDeadEnds, ParagraphLengths and Rules had their say. TopK 1 is
+	// Greedy.
+	TopK int
+	// TopP, if below 1, then draws only from the smallest set of the most
+	// likely suffixes left whose probabilities add up to at least TopP,
+	// nucleus sampling. 0 and 1 draw from all of them.
+	TopP float64
+
+	// MaxBytes and MaxRunes, if positive, stop generation before a word
+	// that would make the text longer than that many bytes of UTF-8 or
+	// that many characters, counting the white space between the words as
+	// WriteText writes it, or WritePretty with Pretty. Whichever
+	// of them and the word count is reached first ends the text, with
+	// StopBudget when it is a budget. Words are counted before their
+	// capitalization is restored, which only changes the length of a few
+	// rare letters, and the seed of GenerateFrom is not counted.
+	MaxBytes, MaxRunes int
+	// Pretty joins the words as WritePretty does rather than as WriteText
+	// does, for GenerateToOpts, which writes them itself, and for MaxBytes
+	// and MaxRunes. Other calls return the words for the caller to join.
+	Pretty bool
+	// Backoff, if set, goes on past dead ends rather than stopping there:
+	// when the prefix has no suffixes, generation forgets its oldest word
+	// and goes on from a prefix ending in the words left, drawn by the
+	// total frequency of its suffixes, forgetting more words until some
+	// prefix matches; with two-word prefixes only the last word is left.
+	// It lengthens the output of models of small corpora a lot. The index
+	// of prefixes by last word this takes is built when first needed.
+	Backoff bool
+	// Smooth, if set, draws from the distribution smoothed by the alpha of
+	// the chain, see SetSmoothing: now and then a word of the vocabulary
+	// never seen after the prefix comes out, and prefixes the chain does
+	// not know are followed by any word of the vocabulary rather than
+	// ending the text. The smoothing applies to the distribution the other
+	// options leave; Greedy picks as without it. It costs time in
+	// proportion to the vocabulary once per call.
+	Smooth bool
+	// Greedy always picks the suffix with the highest weight, the first
+	// in byte order among equals, instead of drawing one, for a stable
+	// canonical text such as a snapshot test wants. It overrides the
+	// temperature options and stops at dead ends, budgets and limits like
+	// sampling does. The text is then the same for every seed unless
+	// another option draws: RandomStart, ParagraphLengths, class
+	// placeholders and the capitalization of case-folded models still use
+	// Rand. Greedy text often falls into a loop, going round the most
+	// frequent cycle of the model for ever; combine it with a Repeat
+	// guard, whose RepeatResample keeps it deterministic.
+	Greedy bool
+	// TargetEntropy, if positive, replaces Temperature by one chosen anew
+	// at every step, within MinTemperature and MaxTemperature, so that the
+	// entropy in bits of the distribution drawn from comes close to
+	// TargetEntropy: peaked prefixes are warmed and flat ones cooled.
+	TargetEntropy float64
+	// MinTemperature and MaxTemperature bound the adaptive temperature;
+	// 0 means DefaultMinTemperature and DefaultMaxTemperature.
+	MinTemperature, MaxTemperature float64
+
+	// emit, if set, is handed every word as it is generated instead of
+	// collecting them, and stops generation by returning false; see
+	// GenerateTo.
+	emit func(word string) bool
+
+	// observe, if set, is told the final probabilities of every draw, the
+	// index of the choice made and the temperature applied (0 for none);
+	// see GenerateAnnotated.
+	observe func(probs []float64, chosen int, temperature float64)
+
+	// stop, if set, is told why generation stopped short of its limit.
+	stop *StopReason
+}
+
+//Generate returns a string of at most n words generated from Chain.
+func (c *Chain) Generate(n int) string {
+	return strings.Join(c.GenerateTokens(n), " ")
+}
+
+// GenerateTokens returns at most n words generated from the chain as
+// separate tokens, for callers that post-process the words; it is
+// GenerateWords with the default options, which every other Generate
+// method joins or annotates. Empty slots and the `""` start token are never
+// among the tokens; a paragraph break is ParagraphToken.
+func (c *Chain) GenerateTokens(n int) []string {
+	return c.GenerateWords(n, GenerateOptions{})
+}
+
+// GenerateWithRand is like Generate but makes every random decision with
+// r, so that the same seed always yields the same text from the same
+// model. It is GenerateOpts with GenerateOptions.Rand set to r.
+func (c *Chain) GenerateWithRand(r *rand.Rand, n int) string {
+	return c.GenerateOpts(n, GenerateOptions{Rand: r})
+}
+
+// GenerateOpts is like Generate but samples according to opts.
+func (c *Chain) GenerateOpts(n int, opts GenerateOptions) string {
+	return strings.Join(c.GenerateWords(n, opts), " ")
+}
+
+// GenerateWords returns at most n words generated from the chain
+// according to opts, as separate tokens.
+func (c *Chain) GenerateWords(n int, opts GenerateOptions) []string {
+	defer c.beginRead()()
+	return c.generateWords(n, opts, orGlobal(opts.Rand))
+}
+
+// generateWords is GenerateWords drawing from r, for callers holding the
+// lock.
+func (c *Chain) generateWords(n int, opts GenerateOptions, r *rand.Rand) []string {
+	p, words := c.startState(n, opts, r)
+	first := len(words)
+	words = c.generate(words, p, n-len(words), opts, r)
+	if opts.TrimSentence && !opts.CompleteSentence {
+		words = trimSentence(words, first)
+	}
+	c.restoreCase(words, r)
+	return words
+}
+
+// startState returns the prefix generation of n words according to opts
+// starts from and the at most n words of it that belong in the output.
+func (c *Chain) startState(n int, opts GenerateOptions, r *rand.Rand) (Prefix, []string) {
+	p, words := c.startPrefix(), []string(nil)
+	// Prune may have removed the start state; start anywhere then.
+	start := p.key()
+	deadStart := len(c.lookup(start)) == 0 && len(c.prior) == 0
+	random := !deadStart && opts.RandomStart > 0 && r.Float64() < opts.RandomStart
+	if opts.SentenceStart && !random {
+		if q, ok := c.sentenceStart(r); ok {
+			return q, c.startAt(q, n, opts, r)
+		}
+	}
+	if deadStart || random {
+		c.materialize()
+		if keys := c.interiorKeys(); len(keys) > 0 {
+			p = splitKey(keys[r.Intn(len(keys))])
+			words = c.startAt(p, n, opts, r)
+		}
+	}
+	return p, words
+}
+
+// startAt returns the at most n words of the start prefix p that belong in
+// the output, within the budget of opts.
+func (c *Chain) startAt(p Prefix, n int, opts GenerateOptions, r *rand.Rand) []string {
+	if n <= 0 {
+		return nil
+	}
+	words := c.appendFilled(nil, c.startWords(p), r)
+	if len(words) > n {
+		words = words[:n]
+	}
+	return fitting(words, opts)
+}
+
+// StopReason tells why generation stopped.
+type StopReason string
+
+const (
+	StopLimit   StopReason = "limit"    // as many words as asked for were generated
+	StopDeadEnd StopReason = "dead_end" // the chain had no continuation
+	StopRepeat  StopReason = "repeat"   // Repeat stopped a loop
+	StopBudget  StopReason = "budget"   // the next word fell outside MaxBytes or MaxRunes
+	StopEnd     StopReason = "end"      // the chain drew EndToken, the end of a document
+)
+
+// GenerateResult describes the outcome of GenerateChecked.
+type GenerateResult struct {
+	Words int        `json:"words"` // number of words generated
+	Stop  StopReason `json:"stop"`
+	Bytes int        `json:"bytes"` // length of the text as WriteText writes it, in bytes
+	Runes int        `json:"runes"` // and in characters
+}
+
+// GenerateChecked is like GenerateOpts but also reports how many words it
+// generated and why it stopped, for callers that want to retry with
+// another seed when a dead end cuts the text short. It fails with
+// ErrEmptyModel instead of returning an empty string if c has no prefixes.
+func (c *Chain) GenerateChecked(n int, opts GenerateOptions) (string, GenerateResult, error) {
+	if c.IsEmpty() {
+		return "", GenerateResult{Stop: StopDeadEnd}, ErrEmptyModel
+	}
+	words, res := c.GenerateWordsChecked(n, opts)
+	return strings.Join(words, " "), res, nil
+}
+
+// GenerateWordsChecked is like GenerateWords but also reports how many
+// words it generated, why it stopped and how much of the budget of
+// GenerateOptions.MaxBytes and MaxRunes the words use.
+func (c *Chain) GenerateWordsChecked(n int, opts GenerateOptions) ([]string, GenerateResult) {
+	why := StopLimit
+	opts.stop = &why
+	words := c.GenerateWords(n, opts)
+	text := joinWords(words)
+	return words, GenerateResult{len(words), why, len(text), utf8.RuneCountInString(text)}
+}
+
+// GenerateFrom returns the words of seed followed by at most n words
+// generated from the context they end in: the last prefix-length words of
+// seed, or for shorter seeds the start state shifted by them. It fails with
+// ErrUnknownPrefix if the chain has no suffixes for that context.
+func (c *Chain) GenerateFrom(seed []string, n int) (string, error) {
+	return c.GenerateFromOpts(seed, n, GenerateOptions{})
+}
+
+// GenerateFromOpts is like GenerateFrom but samples according to opts;
+// RandomStart is ignored.
+func (c *Chain) GenerateFromOpts(seed []string, n int, opts GenerateOptions) (string, error) {
+	defer c.beginRead()()
+	r := orGlobal(opts.Rand)
+	p, suf := c.context(seed)
+	if len(suf) == 0 {
+		return "", fmt.Errorf("%q: %w", strings.Join(seed, " "), ErrUnknownPrefix)
+	}
+	words := c.generate(nil, p, n, opts, r)
+	c.restoreCaseFrom(words, len(seed) == 0 || endsSentence(seed[len(seed)-1]), r)
+	return strings.Join(append(append([]string(nil), seed...), words...), " "), nil
+}
+
+// context returns the prefix words end in, the last prefix-length words of
+// words or for fewer words the start state shifted by them, and its
+// suffixes. Words are lower-cased in case-folded chains.
+func (c *Chain) context(words []string) (Prefix, []Suffix) {
+	p := c.startPrefix()
+	for _, w := range words {
+		if c.caseStats != nil {
+			w = strings.ToLower(w)
+		}
+		p.Shift(w)
+	}
+	return p, c.lookup(p.key())
+}
+
+// startPrefix returns the prefix generation starts from: all empty slots,
+// which are empty strings, as no word of a corpus can be. Model files spell
+// them `""`.
+func (c *Chain) startPrefix() Prefix {
+	return make(Prefix, c.prefixLen)
+}
+
+// generate samples at most n words following p and appends them to words.
+// p is shifted along and ends up as the prefix after the last word.
+func (c *Chain) generate(words []string, p Prefix, n int, opts GenerateOptions, r *rand.Rand) []string {
+	var para *paragraphPlanner
+	if opts.ParagraphLengths {
+		para = c.newParagraphPlanner(r)
+	}
+	// The weights and their running sums are reused from step to step;
+	// long outputs would otherwise allocate two slices per word.
+	var weights, sum []int
+	guard := newRepeatTracker(opts.Repeat)
+	var restartKeys []string
+	restarts := 0
+	b := newBudget(opts)
+	smooth := c.newSmoother(opts)
+	for _, w := range words {
+		b.fits(w) // the start words, which startState made fit
+	}
+	stopped := func(why StopReason) {
+		if opts.stop != nil {
+			*opts.stop = why
+		}
+	}
+	limit := n
+	if opts.CompleteSentence && n > 0 {
+		limit += SentenceWordCap
+	}
+	for i := 0; i < limit; i++ {
+		if i >= n && sentenceEnd(words[len(words)-1]) {
+			break
+		}
+		temp := p.key()
+		choices := c.lookup(temp)//get slices of suffix
+		if len(choices) == 0 {//unknown prefix: fall back to the unigram prior if any
+			choices = c.prior
+		}
+		if len(choices) == 0 && opts.Backoff {
+			if q, ok := c.backoff(p, r); ok {
+				copy(p, q)
+				choices = c.lookup(p.key())
+			}
+		}
+		if len(choices) == 0 && smooth != nil {
+			choices = smooth.suffixes
+		}
+		if len(choices) == 0 {//nothing could be generated as no key in map
+			stopped(StopDeadEnd)
+			break
+		}
+		weights = c.weights(p, choices, opts, weights)
+		if para != nil {
+			para.bias(weights, choices)
+		}
+		if guard != nil && guard.Action == RepeatResample && !guard.exclude(c, p, choices, weights) {
+			stopped(StopRepeat)
+			break
+		}
+		factors := opts.Rules.apply(p, choices, weights)
+		truncate(weights, factors, choices, opts.TopK, opts.TopP)
+		var count int = 0
+		var probs []float64 // the distribution drawn from, for observe
+		var t float64       // the temperature applied, for observe
+		if opts.Greedy {
+			count = argmax(floatWeights(weights, factors), choices)
+			if opts.observe != nil {
+				probs = make([]float64, len(choices))
+				probs[count] = 1
+			}
+		}else if factors != nil || opts.tempered() {
+			fw := floatWeights(weights, factors)
+			t = opts.temperatureFor(fw)
+			probs = temper(fw, t)
+			count = drawTempered(probs, r)
+			if !opts.tempered() {
+				t = 0
+			}
+		}else{
+			if cap(sum) < len(choices) {
+				sum = make([]int, len(choices))
+			}
+			sum = sum[:len(choices)]
+			//for prorportion calculation
+			for j, w := range weights{
+				if j == 0{
+					sum[j] = w
+				}else{
+					sum[j] = sum[j-1] + w
+				}
+			}
+			//random num to choose, by proportion/possibility
+			random := r.Intn(sum[len(choices)-1])
+			for i := 0; i < len(choices); i++{
+				if random >= sum[i]{
+					count++
+				}
+			}
+			if opts.observe != nil {
+				probs = temper(floatWeights(weights, nil), 1)
+			}
+		}
+		next := choices[count].word
+		if smooth != nil && !opts.Greedy {
+			next, probs, count = smooth.draw(next, weights, factors, choices, probs, r, opts.observe != nil)
+		}
+		if opts.observe != nil {
+			opts.observe(probs, count, t)
+		}
+		if next == EndToken {
+			stopped(StopEnd)
+			break
+		}
+		if guard != nil && guard.Action != RepeatResample && guard.loops(c.shiftedKey(p, next)) {
+			if guard.Action != RepeatRestart || restarts >= n {
+				stopped(StopRepeat)
+				break
+			}
+			q, ok := c.restartPrefix(&restartKeys, r)
+			if !ok {
+				stopped(StopRepeat)
+				break
+			}
+			copy(p, q)
+			restarts++
+			i--
+			continue
+		}
+		word := opts.Rules.rewrite(c.fill(next, r))
+		if !b.fits(word) {
+			stopped(StopBudget)
+			break
+		}
+		if opts.emit != nil {
+			if !opts.emit(word) {
+				break
+			}
+			words = append(words[:0], word) // only the last is needed
+		}else{
+			words = append(words, word)
+		}
+		if para != nil {
+			para.advance(next, r)
+		}
+		p.Shift(next)
+		if guard != nil {
+			guard.push(p.key())
+		}
+	}
+	return words
+}
+
+// weights returns the sampling weight of each of the choices following p,
+// reusing the storage of buf if it is large enough.
+func (c *Chain) weights(p Prefix, choices []Suffix, opts GenerateOptions, buf []int) []int {
+	w := buf[:0]
+	for _, s := range choices {
+		w = append(w, s.frequency)
+	}
+	if opts.AvoidDeadEnds {
+		live := make([]int, len(choices))
+		alive := false
+		for i, s := range choices {
+			if len(c.lookup(c.shiftedKey(p, s.word))) > 0 {
+				live[i] = w[i]
+				alive = true
+			}
+		}
+		if alive {
+			w = live
+		}
+	}
+	return w
+}
+
+// shiftedKey returns the map key of p shifted by word, without modifying p.
+func (c *Chain) shiftedKey(p Prefix, word string) string {
+	if len(p) <= 1 {
+		return word
+	}
+	return p[1:].key() + keySep + word
+}
@@ -0,0 +1,432 @@
+package markov
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// benchCorpus returns n words drawn from a vocabulary of 50, with a
+// sentence end every 12 words, so that most prefixes recur.
+func benchCorpus(n int) string {
+	r := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString("word")
+		b.WriteByte(byte('a' + r.Intn(5)))
+		b.WriteByte(byte('a' + r.Intn(10)))
+		if i%12 == 11 {
+			b.WriteString(" .")
+		}
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func TestAppendKey(t *testing.T) {
+	for _, p := range []Prefix{{""}, {"", ""}, {"a", "b c", ""}} {
+		if got := string(p.appendKey([]byte("junk")[:0])); got != p.key() {
+			t.Errorf("appendKey(%q) = %q, want %q", p, got, p.key())
+		}
+	}
+}
+
+// TestBuildReader builds the same documents from files, from strings, from
+// file handles and from a gzip stream, and checks the chains are the same.
+func TestBuildReader(t *testing.T) {
+	docs := []string{tinyCorpus, benchCorpus(500)}
+	var files []string
+	for i, doc := range docs {
+		name := filepath.Join(t.TempDir(), fmt.Sprint(i, ".txt"))
+		if err := os.WriteFile(name, []byte(doc), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	want := newChain(2)
+	if _, err := want.Build(files); err != nil {
+		t.Fatal(err)
+	}
+
+	readers := map[string]func(i int) io.Reader{
+		"string": func(i int) io.Reader { return strings.NewReader(docs[i]) },
+		"file": func(i int) io.Reader {
+			f, err := os.Open(files[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f
+		},
+		"gzip": func(i int) io.Reader {
+			var b bytes.Buffer
+			zw := gzip.NewWriter(&b)
+			zw.Write([]byte(docs[i]))
+			zw.Close()
+			zr, err := gzip.NewReader(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return zr
+		},
+	}
+	for name, open := range readers {
+		c := newChain(2)
+		for i := range docs {
+			if _, err := c.BuildReader(open(i)); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if !reflect.DeepEqual(c.chain, want.chain) {
+			t.Errorf("%s: building from readers one at a time differs from Build", name)
+		}
+	}
+
+	// Building the same text twice doubles every count.
+	twice := newChain(2)
+	for i := 0; i < 2; i++ {
+		twice.BuildReader(strings.NewReader(tinyCorpus))
+	}
+	for key, suf := range TinyModel().chain {
+		for i, s := range suf {
+			if got := twice.chain[key][i]; got != (Suffix{s.word, 2 * s.frequency}) {
+				t.Errorf("after two builds, suffix %v of %q, want %v counted twice", got, splitKey(key), s)
+			}
+		}
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+// TestWriteToReadFrom round-trips a chain through a buffer, as through a
+// file, and checks the byte counts and that a failed read leaves the
+// chain as it was.
+func TestWriteToReadFrom(t *testing.T) {
+	var _ io.WriterTo = (*Chain)(nil)
+	var _ io.ReaderFrom = (*Chain)(nil)
+	c := synthChain(t, 5000)
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, %v; the buffer holds %d bytes", n, err, buf.Len())
+	}
+	file := filepath.Join(t.TempDir(), "m.txt")
+	if err := c.WriteFreTable(file); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("WriteFreTable wrote other bytes than WriteTo: %v", err)
+	}
+	model := buf.String()
+
+	read := TinyModel()
+	if err := read.SetSmoothing(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := read.ReadFrom(strings.NewReader(model)); err != nil || n != int64(len(model)) {
+		t.Fatalf("ReadFrom = %d, %v; want %d", n, err, len(model))
+	}
+	if !reflect.DeepEqual(read.chain, c.chain) || read.Hash() != c.Hash() {
+		t.Error("ReadFrom read back a different chain")
+	}
+	if read.Smoothing() != 0.5 {
+		t.Errorf("ReadFrom changed the smoothing to %v", read.Smoothing())
+	}
+
+	cut := TinyModel()
+	if _, err := cut.ReadFrom(strings.NewReader(model[:len(model)/2])); err == nil {
+		t.Error("ReadFrom of half a model succeeded")
+	}
+	if cut.Hash() != TinyModelHash {
+		t.Error("a failed ReadFrom changed the chain")
+	}
+	if _, err := c.WriteTo(errWriter{}); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("WriteTo a failing writer: %v, want its error", err)
+	}
+}
+
+// BenchmarkBuild reports the allocations of building a chain; once the
+// corpus has been seen, a token should cost next to none.
+func BenchmarkBuild(b *testing.B) {
+	corpus := benchCorpus(50000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(corpus)))
+	for i := 0; i < b.N; i++ {
+		c := newChain(2)
+		if _, err := c.BuildReaderOpts("", strings.NewReader(corpus), BuildOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestConcurrentGenerate runs 50 Generate calls at once on one chain, in
+// memory and mapped from a file, while AddText trains it further; run it
+// with -race.
+func TestConcurrentGenerate(t *testing.T) {
+	built := newChain(2)
+	if _, err := built.BuildReader(strings.NewReader(benchCorpus(5000))); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "model.txt")
+	if err := built.WriteFreTable(file); err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := OpenFreTableMmap(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mapped.Close()
+
+	for name, c := range map[string]*Chain{"memory": built, "mmap": mapped} {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					if i%10 == 0 {
+						if err := c.AddText(strings.NewReader("wordaa wordbb wordcc .")); err != nil {
+							t.Error(err)
+						}
+						return
+					}
+					r := rand.New(rand.NewSource(int64(i)))
+					if words := c.GenerateWords(100, GenerateOptions{Rand: r}); len(words) == 0 {
+						t.Error("Generate returned no words")
+					}
+					if c.Generate(20) == "" {
+						t.Error("Generate returned no text")
+					}
+				}(i)
+			}
+			wg.Wait()
+			if err := c.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestConcurrentMutation mutates a chain from many goroutines while others
+// read it, and checks that no update is lost.
+func TestConcurrentMutation(t *testing.T) {
+	c := newChain(1)
+	other := newChain(1)
+	addWords(other, []string{"x", "y"})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.AddText(strings.NewReader("a b a b")); err != nil {
+				t.Error(err)
+			}
+			if err := c.Merge(other); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.Stats()
+			c.Suffixes(Prefix{"a"})
+			c.Generate(5)
+		}()
+	}
+	wg.Wait()
+	if got := c.Suffixes(Prefix{"a"}); len(got) != 1 || got[0].Frequency() != 40 {
+		t.Errorf("suffixes of a = %v, want b seen 40 times", got)
+	}
+	if got := c.Suffixes(Prefix{"x"}); len(got) != 1 || got[0].Frequency() != 20 {
+		t.Errorf("suffixes of x = %v, want y seen 20 times", got)
+	}
+}
+
+// TestAvoidDeadEndsLonger generates from a sparse model of short
+// documents with and without AvoidDeadEnds from the same seeds: steering
+// clear of the ends must make the texts measurably longer on average.
+func TestAvoidDeadEndsLonger(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	c := newChain(2)
+	for doc := 0; doc < 300; doc++ {
+		words := make([]string, 6)
+		for i := range words {
+			words[i] = fmt.Sprintf("w%d", r.Intn(40))
+		}
+		if err := c.AddText(strings.NewReader(strings.Join(words, " "))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const runs, n = 500, 100
+	mean := func(avoid bool) float64 {
+		total := 0
+		for seed := int64(1); seed <= runs; seed++ {
+			opts := GenerateOptions{AvoidDeadEnds: avoid, Rand: rand.New(rand.NewSource(seed))}
+			total += len(c.GenerateWords(n, opts))
+		}
+		return float64(total) / runs
+	}
+	plain, avoiding := mean(false), mean(true)
+	if avoiding < 1.3*plain {
+		t.Errorf("mean length %.1f words with AvoidDeadEnds, %.1f without; want at least 30%% longer", avoiding, plain)
+	}
+}
+
+func TestGenerateFrom(t *testing.T) {
+	c := TinyModel()
+	for _, tt := range []struct {
+		name string
+		seed []string
+		want string // with one word generated
+	}{
+		{"exact", []string{"sat", "on"}, "sat on the"},
+		{"longer", []string{"the", "dog", "sat", "on"}, "the dog sat on the"},
+		{"shorter", []string{"the"}, "the cat"},
+		{"empty", nil, "the"},
+	} {
+		got, err := c.GenerateFrom(tt.seed, 1)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: GenerateFrom(%q, 1) = %q, %v, want %q", tt.name, tt.seed, got, err, tt.want)
+		}
+	}
+	if got, err := c.GenerateFrom([]string{"sat", "on"}, 0); err != nil || got != "sat on" {
+		t.Errorf("GenerateFrom(sat on, 0) = %q, %v, want the seed alone", got, err)
+	}
+	for _, seed := range [][]string{{"no", "such"}, {"the", "no"}, {"cat", "sat", "down"}} {
+		got, err := c.GenerateFrom(seed, 5)
+		if !errors.Is(err, ErrUnknownPrefix) || got != "" {
+			t.Errorf("GenerateFrom(%q, 5) = %q, %v, want ErrUnknownPrefix", seed, got, err)
+		}
+	}
+}
+
+// TestGenerateTokens checks that no empty slot or end token comes out as a
+// word, also when generation starts inside the chain, and that the
+// Generate methods join the same words.
+func TestGenerateTokens(t *testing.T) {
+	pruned := TinyModel()
+	if !pruned.DeletePrefix(" ") {
+		t.Fatal("TinyModel has no start state to delete")
+	}
+	for name, c := range map[string]*Chain{"tiny": TinyModel(), "no start": pruned} {
+		for i := 0; i < 200; i++ {
+			for _, w := range c.GenerateTokens(30) {
+				if w == "" || w == `""` || w == EndToken {
+					t.Fatalf("%s: GenerateTokens returned %q", name, w)
+				}
+			}
+		}
+		for seed := int64(1); seed <= 10; seed++ {
+			words := c.GenerateWords(30, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+			text := c.GenerateWithRand(rand.New(rand.NewSource(seed)), 30)
+			if text != strings.Join(words, " ") {
+				t.Errorf("%s, seed %d: generated %q, want the words %q joined", name, seed, text, words)
+			}
+		}
+	}
+}
+
+// TestGenerateNoWords asks every start mode for no words and for a
+// negative number of them, which must generate nothing rather than panic.
+func TestGenerateNoWords(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader(tinyCorpus), BuildOptions{SentenceStarts: true}); err != nil {
+		t.Fatal(err)
+	}
+	for name, opts := range map[string]GenerateOptions{
+		"start state":    {},
+		"random start":   {RandomStart: 1},
+		"sentence start": {SentenceStart: true},
+	} {
+		for _, n := range []int{-1, 0} {
+			if words := c.GenerateWords(n, opts); len(words) != 0 {
+				t.Errorf("%s: GenerateWords(%d) = %q, want no words", name, n, words)
+			}
+			var b bytes.Buffer
+			if _, err := c.GenerateToOpts(&b, n, opts); err != nil || b.Len() != 0 {
+				t.Errorf("%s: GenerateToOpts(%d) wrote %q, %v, want nothing", name, n, b.String(), err)
+			}
+		}
+	}
+}
+
+func TestGenerateChecked(t *testing.T) {
+	if text, res, err := newChain(2).GenerateChecked(10, GenerateOptions{}); !errors.Is(err, ErrEmptyModel) || text != "" || res.Stop != StopDeadEnd {
+		t.Errorf("GenerateChecked of an empty chain = %q, %+v, %v, want ErrEmptyModel", text, res, err)
+	}
+	dead := newChain(2)
+	if _, err := dead.BuildReaderOpts("", strings.NewReader("just three words"), BuildOptions{NoEndToken: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		c    *Chain
+		n    int
+		want GenerateResult
+	}{
+		{TinyModel(), 2, GenerateResult{2, StopLimit, 7, 7}},
+		{TinyModel(), 0, GenerateResult{0, StopLimit, 0, 0}},
+		{dead, 10, GenerateResult{3, StopDeadEnd, 16, 16}},
+		{dead, 3, GenerateResult{3, StopLimit, 16, 16}},
+	} {
+		text, res, err := tt.c.GenerateChecked(tt.n, GenerateOptions{})
+		if err != nil || res != tt.want || len(strings.Fields(text)) != tt.want.Words {
+			t.Errorf("GenerateChecked(%d) = %q, %+v, %v, want %+v", tt.n, text, res, err, tt.want)
+		}
+	}
+}
+
+func TestNewChainPrefixCap(t *testing.T) {
+	for _, n := range []int{-1, 0, MaxPrefixLen + 1} {
+		if _, err := NewChain(n); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("NewChain(%d) = %v, want an out of range error", n, err)
+		}
+	}
+	if _, err := NewChain(MaxPrefixLen); err != nil {
+		t.Errorf("NewChain(MaxPrefixLen): %v", err)
+	}
+	defer func(max int) { MaxPrefixLen = max }(MaxPrefixLen)
+	MaxPrefixLen = 20
+	if _, err := NewChain(15); err != nil {
+		t.Errorf("NewChain(15) with MaxPrefixLen 20: %v", err)
+	}
+}
+
+func TestMemorizedWarning(t *testing.T) {
+	distinct := make([]string, 100)
+	for i := range distinct {
+		distinct[i] = fmt.Sprintf("w%d", i)
+	}
+	for _, tt := range []struct {
+		prefixLen int
+		corpus    string
+		want      string // in the warning, "" for none
+	}{
+		{2, strings.Repeat("the cat sat on the mat. ", 20), ""},
+		{2, strings.Join(distinct, " "), "try a prefix length below 2"},
+		{1, strings.Join(distinct, " "), "the corpus is too small"},
+	} {
+		c := newChain(tt.prefixLen)
+		report, err := c.BuildReader(strings.NewReader(tt.corpus))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Join(report.Warnings, "\n")
+		switch {
+		case tt.want == "" && strings.Contains(got, "memorized"):
+			t.Errorf("prefix length %d, ratio %.2f: warned %q", tt.prefixLen, report.PrefixRatio(), got)
+		case tt.want != "" && !strings.Contains(got, tt.want):
+			t.Errorf("prefix length %d, ratio %.2f: warnings %q, want %q", tt.prefixLen, report.PrefixRatio(), got, tt.want)
+		}
+	}
+}
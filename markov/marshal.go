@@ -0,0 +1,67 @@
+package markov
+
+import (
+	"bytes"
+	"errors"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler, so that a chain can be
+// kept by anything that stores values through it, such as a cache: it
+// encodes c as WriteGob does.
+func (c *Chain) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.WriteGob(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding what
+// MarshalBinary encodes. Everything c holds is replaced, settings such as
+// the smoothing too, which a model does not hold, so that c is the chain
+// ReadGob would return; on error c is left unchanged.
+func (c *Chain) UnmarshalBinary(data []byte) error {
+	read, err := ReadGob(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	c.replace(read, true)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, so that a chain in a
+// struct encoded with encoding/json is written as a string: it encodes c as
+// the model file WriteFreTable writes.
+func (c *Chain) MarshalText() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, decoding a model file
+// as ReadFrom does and replacing everything c holds, settings included, as
+// UnmarshalBinary does. Models from before format version 4, without a
+// checksum, are read without complaint.
+func (c *Chain) UnmarshalText(text []byte) error {
+	read, err := readFreTable(bytes.NewReader(text), "")
+	var checksum *ChecksumError
+	if read == nil || err != nil && !(errors.As(err, &checksum) && checksum.Missing) {
+		return err
+	}
+	c.replace(read, true)
+	return nil
+}
+
+// Snapshot returns a copy of c as it is at one moment, which training c
+// further does not change, for saving a chain that goes on learning while
+// it is written: c is only locked while it is encoded, as MarshalBinary
+// encodes it. Settings such as the smoothing are not copied.
+func (c *Chain) Snapshot() (*Chain, error) {
+	var buf bytes.Buffer
+	if err := c.WriteGob(&buf); err != nil {
+		return nil, err
+	}
+	return ReadGob(&buf)
+}
@@ -0,0 +1,115 @@
+package markov
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalRoundTripResets(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader(tinyCorpus), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSmoothing(1); err != nil {
+		t.Fatal(err)
+	}
+	binary, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsmoothed, err := ReadGob(bytes.NewReader(binary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := c.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, unmarshal := range map[string]func(*Chain) error{
+		"binary": func(d *Chain) error { return d.UnmarshalBinary(binary) },
+		"text":   func(d *Chain) error { return d.UnmarshalText(text) },
+	} {
+		d := newChain(3)
+		if err := d.SetSmoothing(0.5); err != nil {
+			t.Fatal(err)
+		}
+		if err := unmarshal(d); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(d.chain, c.chain) || d.prefixLen != 2 {
+			t.Errorf("%s: read back %v, want %v", name, d.chain, c.chain)
+		}
+		if d.smoothing != 0 {
+			t.Errorf("%s: smoothing %v kept, want it reset", name, d.smoothing)
+		}
+		prefix, word := []string{"the", "cat"}, "sat"
+		if got, want := d.Probability(prefix, word), unsmoothed.Probability(prefix, word); got != want || want == 0 {
+			t.Errorf("%s: P(%s | %v) = %v, want %v as without smoothing", name, word, prefix, got, want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	c := TinyModel()
+	s, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Hash() != TinyModelHash {
+		t.Errorf("snapshot hashes to %s, want %s", s.Hash(), TinyModelHash)
+	}
+	if err := c.AddText(strings.NewReader("the cat slept.")); err != nil {
+		t.Fatal(err)
+	}
+	if s.Hash() != TinyModelHash || c.Hash() == TinyModelHash {
+		t.Errorf("training the chain changed its snapshot, or did not change the chain")
+	}
+}
+
+// TestMarshalWrapped round-trips a struct holding chains through
+// encoding/json, which uses MarshalText, and encoding/gob, which uses
+// MarshalBinary, into a struct whose chain held another model before.
+func TestMarshalWrapped(t *testing.T) {
+	type wrapper struct {
+		Name  string
+		Model *Chain
+		Empty *Chain
+	}
+	in := wrapper{Name: "tiny", Model: TinyModel()}
+	codecs := map[string]struct {
+		marshal   func(any) ([]byte, error)
+		unmarshal func([]byte, any) error
+	}{
+		"json": {json.Marshal, json.Unmarshal},
+		"gob": {
+			func(v any) ([]byte, error) {
+				var buf bytes.Buffer
+				err := gob.NewEncoder(&buf).Encode(v)
+				return buf.Bytes(), err
+			},
+			func(data []byte, v any) error { return gob.NewDecoder(bytes.NewReader(data)).Decode(v) },
+		},
+	}
+	for name, codec := range codecs {
+		data, err := codec.marshal(in)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for into, model := range map[string]*Chain{"nil": nil, "another model": synthChain(t, 2000)} {
+			out := wrapper{Model: model}
+			if err := codec.unmarshal(data, &out); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if out.Name != "tiny" || out.Model == nil || out.Model.Hash() != TinyModelHash {
+				t.Errorf("%s into %s: read back %+v, want TinyModel", name, into, out)
+			}
+			if out.Empty != nil {
+				t.Errorf("%s: a nil chain read back as %v", name, out.Empty)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+)
+
+// Approximate per-item costs of the in-memory Chain on a 64-bit platform,
+// calibrated against heap growth when loading huck.model.
+const (
+	prefixOverhead = 16 + 24 + 80 // key string header, slice header, map and key allocation
+	suffixOverhead = 16 + 8       // Suffix{word, frequency}
+	sliceSlack     = 1.25         // average unused capacity left by append
+)
+
+// estimateBytes returns the approximate memory of a chain with the given
+// number of prefixes and suffix entries whose strings total textBytes.
+// Chain.EstimateMemory and EstimateModelFile both go through here.
+func estimateBytes(prefixes, entries int, textBytes int64) int64 {
+	return int64(prefixes)*prefixOverhead + int64(float64(entries)*suffixOverhead*sliceSlack) + textBytes
+}
+
+// EstimateMemory returns the approximate number of bytes c occupies.
+func (c *Chain) EstimateMemory() int64 {
+	c.materialize()
+	var entries int
+	var text int64
+	for key, suf := range c.chain {
+		text += int64(len(key))
+		entries += len(suf)
+		for _, s := range suf {
+			text += int64(len(s.word))
+		}
+	}
+	return estimateBytes(len(c.chain), entries, text)
+}
+
+// EstimateModelFile estimates how much memory ReadFreTable would need to
+// load modelFile, by streaming over the file once without building a chain.
+func EstimateModelFile(modelFile string) (int64, error) {
+	in, err := OpenModelFile(modelFile)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+
+	prefixLen := 0
+	if scanner.Scan() {
+		prefixLen, _, _ = parseHeader(scanner.Text())
+	}
+	var prefixes, entries int
+	var text int64
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) > 0 && line[0] == '\t' {
+			text += int64(len(line))
+			continue
+		}
+		fields := len(bytes.Fields(line))
+		prefixes++
+		if fields > prefixLen {
+			entries += (fields - prefixLen) / 2
+		}
+		// ReadFreTable keeps every word as a slice of its line.
+		text += int64(len(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return estimateBytes(prefixes, entries, text), nil
+}
+
+// CheckModelBudget refuses models whose estimated in-memory size exceeds
+// maxBytes before any of them is loaded. maxBytes <= 0 disables the check.
+func CheckModelBudget(modelFile string, maxBytes int64) error {
+	if maxBytes <= 0 {
+		return nil
+	}
+	size, err := EstimateModelFile(modelFile)
+	if err != nil {
+		return err
+	}
+	if size > maxBytes {
+		return fmt.Errorf("%s: model needs about %s in memory, over the budget of %s", modelFile, formatBytes(size), formatBytes(maxBytes))
+	}
+	return nil
+}
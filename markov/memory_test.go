@@ -0,0 +1,75 @@
+package markov
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/synthcorpus"
+)
+
+// heapAlloc returns the bytes allocated on the heap after a collection.
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// TestEstimateModelFileCalibrated loads fixture models of several shapes
+// and checks that the estimates stay within a factor of 1.5 of the heap
+// the loads actually took, which the per-item costs of memory.go promise.
+func TestEstimateModelFileCalibrated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds models of 200,000 tokens")
+	}
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name      string
+		prefixLen int
+		opts      synthcorpus.Options
+	}{
+		{"small-vocab", 1, synthcorpus.Options{Tokens: 200000, Vocab: 500, Exponent: 1.1, Seed: 1, DocLen: 500}},
+		{"large-vocab", 2, synthcorpus.Options{Tokens: 200000, Vocab: 50000, Exponent: 1.1, Seed: 2, DocLen: 2000, PunctRate: 0.08}},
+	} {
+		var corpus bytes.Buffer
+		if err := synthcorpus.Write(&corpus, tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		c := newChain(tt.prefixLen)
+		if _, err := c.BuildReader(&corpus); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, tt.name+".txt")
+		if err := c.WriteFreTable(file); err != nil {
+			t.Fatal(err)
+		}
+		inMemory := c.EstimateMemory()
+		c = nil
+
+		estimate, err := EstimateModelFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := heapAlloc()
+		loaded, err := ReadFreTable(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := int64(heapAlloc() - before)
+		runtime.KeepAlive(loaded)
+		t.Logf("%s: estimated %d, %d in memory, took %d", tt.name, estimate, inMemory, actual)
+		for what, est := range map[string]int64{"EstimateModelFile": estimate, "EstimateMemory": inMemory} {
+			if ratio := float64(est) / float64(actual); ratio < 1/1.5 || ratio > 1.5 {
+				t.Errorf("%s: %s = %s, but loading took %s", tt.name, what, formatBytes(est), formatBytes(actual))
+			}
+		}
+		if err := CheckModelBudget(file, estimate-1); err == nil {
+			t.Errorf("%s: CheckModelBudget accepts a budget below the estimate", tt.name)
+		}
+		if err := CheckModelBudget(file, estimate); err != nil {
+			t.Errorf("%s: CheckModelBudget refuses the estimate: %v", tt.name, err)
+		}
+	}
+}
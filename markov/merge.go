@@ -0,0 +1,174 @@
+package markov
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Merge adds the counts of other to c, as if c had also been built from
+// the corpus of other: the frequencies of a suffix under the same prefix
+// are summed into one entry, and prefixes only other knows are added. The
+// statistics kept besides the table (class originals, the unigram prior,
+// capitalization, paragraph lengths, positions and sentence starts) are
+// combined the same way; presets of c win over those of other with the
+// same name. The sources of the metadata of other are added to those of c.
+// Chains with different prefix lengths cannot be merged. other
+// is not modified; it must not be merging c into itself at the same time,
+// which would deadlock.
+func (c *Chain) Merge(other *Chain) error {
+	if c == other {
+		return fmt.Errorf("cannot merge a chain with itself")
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+	if err := other.materialize(); err != nil {
+		return err
+	}
+	defer c.beginWrite()()
+	defer other.beginRead()()
+	if other.prefixLen != c.prefixLen {
+		return fmt.Errorf("cannot merge a chain with prefixes of %d words into one with %d", other.prefixLen, c.prefixLen)
+	}
+
+	for _, key := range sortedKeys(other.chain) {
+		for _, s := range other.chain[key] {
+			c.add(key, s.word, s.frequency)
+		}
+	}
+	for _, key := range sortedKeys(other.positions) {
+		c.mergePositions(key, other.positions[key])
+	}
+	for _, key := range sortedKeys(other.starts) {
+		c.addStart(key, other.starts[key])
+	}
+
+	for _, class := range sortedKeys(other.reservoirs) {
+		if c.reservoirs == nil {
+			c.reservoirs = make(map[string][]string)
+		}
+		c.reservoirs[class] = append(c.reservoirs[class], other.reservoirs[class]...)
+	}
+	if len(other.prior) > 0 {
+		prior := make(map[string]int)
+		for _, s := range c.prior {
+			prior[s.word] += s.frequency
+		}
+		for _, s := range other.prior {
+			prior[s.word] += s.frequency
+		}
+		c.prior = c.prior[:0]
+		for _, w := range sortedKeys(prior) {
+			c.prior = append(c.prior, Suffix{w, prior[w]})
+		}
+	}
+	for _, w := range sortedKeys(other.caseStats) {
+		if c.caseStats == nil {
+			c.caseStats = make(map[string]caseStats)
+		}
+		a, b := c.caseStats[w], other.caseStats[w]
+		c.caseStats[w] = caseStats{a.initial + b.initial, a.initialCaps + b.initialCaps, a.mid + b.mid, a.midCaps + b.midCaps}
+	}
+	lengths := make([]int, 0, len(other.paragraphLengths))
+	for n := range other.paragraphLengths {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+	for _, n := range lengths {
+		if c.paragraphLengths == nil {
+			c.paragraphLengths = make(map[int]int)
+		}
+		c.paragraphLengths[n] += other.paragraphLengths[n]
+	}
+	for _, t := range other.transforms {
+		if !contains(c.transforms, t) {
+			c.transforms = append(c.transforms, t)
+		}
+	}
+	c.meta.merge(other.meta)
+	for _, name := range sortedKeys(other.presets) {
+		if _, ok := c.presets[name]; !ok {
+			if c.presets == nil {
+				c.presets = make(map[string][]string)
+			}
+			c.presets[name] = other.presets[name]
+		}
+	}
+	return nil
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeFreTables merges the named model files into one chain, as Merge
+// would merge the chains ReadFreTable reads from them, but without ever
+// holding more than the merged chain in memory: the tables are added to it
+// line by line. The frequencies of the table lines of names[i] are
+// multiplied by weights[i], so that a small corpus can count for more than
+// a large one; weights may be nil, meaning 1 for every file. The records
+// besides the table are merged unweighted. All files must have the same
+// prefix length; the error names the first one that does not. Files
+// without a checksum are merged with a message passed to warn; on any
+// other error nothing is returned.
+func MergeFreTables(names []string, weights []int, warn func(string)) (*Chain, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no model files to merge")
+	}
+	if weights != nil && len(weights) != len(names) {
+		return nil, fmt.Errorf("%d weights for %d model files", len(weights), len(names))
+	}
+	var c *Chain
+	for i, name := range names {
+		weight := 1
+		if weights != nil {
+			weight = weights[i]
+		}
+		if weight <= 0 {
+			return nil, fmt.Errorf("%s: weight %d is not positive", name, weight)
+		}
+		fits := func(prefixLen int) error {
+			if c == nil {
+				c = newChain(prefixLen)
+			}
+			if prefixLen != c.prefixLen {
+				return fmt.Errorf("%s has prefixes of %d words, not %d as %s", name, prefixLen, c.prefixLen, names[0])
+			}
+			return nil
+		}
+		in, err := OpenModelFile(name)
+		if err != nil {
+			return nil, err
+		}
+		records, err := readModel(in, name, func(prefixLen int, key string, suffixes []Suffix) error {
+			if err := fits(prefixLen); err != nil {
+				return err
+			}
+			for _, s := range suffixes {
+				c.add(key, s.word, s.frequency*weight)
+			}
+			return nil
+		})
+		in.Close()
+		var checksum *ChecksumError
+		if errors.As(err, &checksum) && checksum.Missing {
+			warn(err.Error())
+		} else if err != nil {
+			return nil, err
+		}
+		if err := fits(records.prefixLen); err != nil {
+			return nil, err
+		}
+		if err := c.Merge(records); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,158 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMerge checks that merging the chains of two corpora gives the chain
+// built from both, with no suffix entered twice under a prefix.
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte(tinyCorpus), 0o666)
+	os.WriteFile(b, []byte("the dog sat on the mat. the cat sat on the dog. "+benchCorpus(300)), 0o666)
+	opts := BuildOptions{Positions: true, SentenceStarts: true}
+	build := func(files ...string) *Chain {
+		c := newChain(2)
+		if _, err := c.BuildOpts(files, opts); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	want := build(a, b)
+
+	// Merged into a chain read back from its model file, with its empty
+	// prefix slots spelled as loaded.
+	model := filepath.Join(dir, "a.model")
+	if err := build(a).WriteFreTable(model); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadFreTable(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]*Chain{"built": build(a), "loaded": loaded} {
+		other := build(b)
+		before := other.Hash()
+		if err := c.Merge(other); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(c.chain, want.chain) {
+			t.Errorf("%s: Merge(a, b) differs from a build of a and b", name)
+		}
+		if !reflect.DeepEqual(c.positions, want.positions) || !reflect.DeepEqual(c.starts, want.starts) {
+			t.Errorf("%s: Merge(a, b) combined positions or sentence starts differently from a build", name)
+		}
+		for key, suf := range c.chain {
+			seen := make(map[string]bool)
+			for _, s := range suf {
+				if seen[s.word] {
+					t.Errorf("%s: %q follows %q twice", name, s.word, splitKey(key))
+				}
+				seen[s.word] = true
+			}
+		}
+		if other.Hash() != before {
+			t.Errorf("%s: Merge changed the chain merged in", name)
+		}
+	}
+
+	c := build(a)
+	if err := c.Merge(newChain(3)); err == nil {
+		t.Errorf("merging chains of prefix lengths 2 and 3 succeeded")
+	}
+	if err := c.Merge(c); err == nil {
+		t.Errorf("merging a chain with itself succeeded")
+	}
+	if !reflect.DeepEqual(c.chain, build(a).chain) {
+		t.Errorf("a failed merge changed the chain")
+	}
+}
+
+// TestMergeFreTables merges model files with and without weights, and
+// checks the errors name the file at fault.
+func TestMergeFreTables(t *testing.T) {
+	dir := t.TempDir()
+	at := func(name string) string { return filepath.Join(dir, name) }
+	texts := map[string]string{"a": tinyCorpus, "b": "the dog sat on the mat. the cat sat on the dog."}
+	build := func(prefixLen int, names ...string) *Chain {
+		c := newChain(prefixLen)
+		for _, name := range names {
+			if _, err := c.BuildReader(strings.NewReader(texts[name])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return c
+	}
+	for name := range texts {
+		if err := build(2, name).WriteFreTable(at(name + ".txt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := build(3, "b").WriteFreTable(at("b3.txt")); err != nil {
+		t.Fatal(err)
+	}
+	noWarning := func(msg string) { t.Errorf("unexpected warning %q", msg) }
+
+	c, err := MergeFreTables([]string{at("a.txt"), at("b.txt")}, nil, noWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := build(2, "a", "b"); !reflect.DeepEqual(c.chain, want.chain) {
+		t.Errorf("merging the files differs from a build of both texts")
+	}
+
+	c, err = MergeFreTables([]string{at("a.txt"), at("b.txt")}, []int{3, 1}, noWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := build(2, "a"), build(2, "b")
+	for key, suf := range c.chain {
+		for _, s := range suf {
+			want := 0
+			for _, weighted := range []struct {
+				c      *Chain
+				weight int
+			}{{a, 3}, {b, 1}} {
+				for _, w := range weighted.c.chain[key] {
+					if w.word == s.word {
+						want += weighted.weight * w.frequency
+					}
+				}
+			}
+			if s.frequency != want {
+				t.Errorf("%q after %q counted %d times, want %d", s.word, splitKey(key), s.frequency, want)
+			}
+		}
+	}
+
+	// A model without a checksum is merged with a warning naming it.
+	old := filepath.Join("testdata", "versions", "v1.model")
+	var warnings []string
+	if _, err := MergeFreTables([]string{at("a.txt"), old}, nil, func(msg string) { warnings = append(warnings, msg) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], old) {
+		t.Errorf("merging %s warned %q, want one warning naming it", old, warnings)
+	}
+
+	for _, tt := range []struct {
+		names   []string
+		weights []int
+		want    string // in the error
+	}{
+		{[]string{at("a.txt"), at("b3.txt")}, nil, at("b3.txt") + " has prefixes of 3 words, not 2 as " + at("a.txt")},
+		{[]string{at("a.txt"), at("b.txt")}, []int{1}, "1 weights for 2 model files"},
+		{[]string{at("a.txt"), at("b.txt")}, []int{1, 0}, at("b.txt") + ": weight 0"},
+		{[]string{at("a.txt"), at("missing.txt")}, nil, "missing.txt"},
+		{nil, nil, "no model files"},
+	} {
+		if _, err := MergeFreTables(tt.names, tt.weights, noWarning); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("MergeFreTables(%q, %v): %v, want an error with %q", tt.names, tt.weights, err, tt.want)
+		}
+	}
+}
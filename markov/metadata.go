@@ -0,0 +1,275 @@
+package markov
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata says where a model came from. Build records it in the chain and
+// WriteFreTable writes it to the model file, in records before the table:
+//
+//	\tmeta created 2026-10-15T09:30:00Z
+//	\tmeta tool github.com/xiaoxulv/go_mark@v1.2.0
+//	\tmeta tokens n
+//	\tsource name n (one per input file, with its tokens)
+//	\toffset name n (one per log followed, with the bytes of it read)
+//
+// The creation time and tool are only recorded with BuildOptions.Stamp, as
+// they would make every build of the same corpus a different file. Models
+// written before metadata existed, and chains not made by Build, have none:
+// every field but PrefixLen is zero. Offsets are recorded by SetOffset.
+type Metadata struct {
+	Created   time.Time `json:"created"`        // of the first stamped Build; left out of JSON if zero
+	Tool      string    `json:"tool,omitempty"` // module and version of the program that built it
+	PrefixLen int       `json:"prefix_len"`
+	Sources   []Source  `json:"sources,omitempty"`
+	Tokens    int       `json:"tokens"` // of all sources
+	Offsets   []Offset  `json:"offsets,omitempty"`
+}
+
+// MarshalJSON leaves a zero Created out, as omitempty cannot.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	type plain Metadata // without this method
+	out := struct {
+		Created *time.Time `json:"created,omitempty"`
+		plain
+	}{plain: plain(m)}
+	if !m.Created.IsZero() {
+		out.Created = &m.Created
+	}
+	return json.Marshal(out)
+}
+
+// Source is an input a model was built from, with the number of words read
+// from it. Documents given to BuildReader without a name are named "-".
+type Source struct {
+	Name   string `json:"name"`
+	Tokens int    `json:"tokens"`
+}
+
+// Offset is how far into a log a model has read it, see SetOffset.
+type Offset struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"` // in bytes
+}
+
+// metadata is the part of Metadata a Chain keeps.
+type metadata struct {
+	created time.Time
+	tool    string
+	sources []Source
+	tokens  int
+	offsets []Offset
+}
+
+// Metadata returns the metadata of c. The sources are a copy.
+func (c *Chain) Metadata() Metadata {
+	defer c.beginRead()()
+	return Metadata{
+		Created:   c.meta.created,
+		Tool:      c.meta.tool,
+		PrefixLen: c.prefixLen,
+		Sources:   append([]Source(nil), c.meta.sources...),
+		Tokens:    c.meta.tokens,
+		Offsets:   append([]Offset(nil), c.meta.offsets...),
+	}
+}
+
+// SetOffset records that c holds the text of the named log up to byte
+// offset, for a program following the log to resume from after a restart.
+// The offset is written to the model file with the counts, so that the
+// two always match: a program that saves the offset anywhere else, and
+// dies between saving it and the model, either skips text or counts it
+// twice. It must not let c be written between adding text and recording
+// where it ends.
+func (c *Chain) SetOffset(name string, offset int64) {
+	defer c.beginWrite()()
+	c.meta.setOffset(name, offset)
+}
+
+// Offset returns the offset SetOffset recorded for the named log, and
+// whether there is one.
+func (c *Chain) Offset(name string) (int64, bool) {
+	defer c.beginRead()()
+	for _, o := range c.meta.offsets {
+		if o.Name == name {
+			return o.Offset, true
+		}
+	}
+	return 0, false
+}
+
+// setOffset records the offset of the named log, replacing any earlier one.
+func (m *metadata) setOffset(name string, offset int64) {
+	for i := range m.offsets {
+		if m.offsets[i].Name == name {
+			m.offsets[i].Offset = offset
+			return
+		}
+	}
+	m.offsets = append(m.offsets, Offset{name, offset})
+}
+
+// stamp records the time and the tool of a build, unless m has them from an
+// earlier one.
+func (m *metadata) stamp() {
+	if m.created.IsZero() {
+		m.created = time.Now().UTC().Truncate(time.Second)
+		m.tool = toolVersion()
+	}
+}
+
+// addSource records that a build read tokens words from the named input.
+// Inputs read again add up, under the name they were first read with.
+func (m *metadata) addSource(name string, tokens int) {
+	if name == "" {
+		name = "-"
+	}
+	m.tokens += tokens
+	for i := range m.sources {
+		if m.sources[i].Name == name {
+			m.sources[i].Tokens += tokens
+			return
+		}
+	}
+	m.sources = append(m.sources, Source{name, tokens})
+}
+
+// merge adds the sources of other to m, as Chain.Merge does; the creation
+// time and tool of m win, unless m has none.
+func (m *metadata) merge(other metadata) {
+	if m.created.IsZero() {
+		m.created, m.tool = other.created, other.tool
+	}
+	for _, s := range other.sources {
+		m.addSource(s.Name, s.Tokens)
+	}
+}
+
+// records returns the metadata records of m, see Metadata.
+func (m *metadata) records() [][]string {
+	var out [][]string
+	if !m.created.IsZero() {
+		out = append(out, []string{"meta", "created", m.created.Format(time.RFC3339)})
+	}
+	if m.tool != "" {
+		out = append(out, []string{"meta", "tool", m.tool})
+	}
+	if m.sources != nil {
+		out = append(out, []string{"meta", "tokens", strconv.Itoa(m.tokens)})
+	}
+	for _, s := range m.sources {
+		out = append(out, []string{"source", s.Name, strconv.Itoa(s.Tokens)})
+	}
+	for _, o := range m.offsets {
+		out = append(out, []string{"offset", o.Name, strconv.FormatInt(o.Offset, 10)})
+	}
+	return out
+}
+
+// readRecord stores a meta, source or offset record, checked by
+// checkRecord.
+func (m *metadata) readRecord(fields []string) {
+	switch fields[0] {
+	case "source":
+		n, _ := strconv.Atoi(fields[2])
+		m.sources = append(m.sources, Source{fields[1], n})
+		return
+	case "offset":
+		n, _ := strconv.ParseInt(fields[2], 10, 64)
+		m.setOffset(fields[1], n)
+		return
+	}
+	switch fields[1] {
+	case "created":
+		m.created, _ = time.Parse(time.RFC3339, fields[2])
+	case "tool":
+		m.tool = fields[2]
+	case "tokens":
+		m.tokens, _ = strconv.Atoi(fields[2])
+	}
+}
+
+// checkMetaRecord returns what is wrong with a meta record, or "". Unknown
+// keys are accepted, for later versions to add some.
+func checkMetaRecord(fields []string) string {
+	if len(fields) != 3 {
+		return "meta record must be: meta key value"
+	}
+	switch fields[1] {
+	case "created":
+		if _, err := time.Parse(time.RFC3339, fields[2]); err != nil {
+			return "meta created must be an RFC 3339 time"
+		}
+	case "tokens":
+		if n, err := strconv.Atoi(fields[2]); err != nil || n < 0 {
+			return "meta tokens must be a count"
+		}
+	}
+	return ""
+}
+
+// toolVersion names this package's module and its version, as far as the
+// binary knows it.
+func toolVersion() string {
+	const module = "github.com/xiaoxulv/go_mark"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return module
+	}
+	for _, m := range append([]*debug.Module{&info.Main}, info.Deps...) {
+		if m.Path == module && m.Version != "" {
+			return module + "@" + m.Version
+		}
+	}
+	return module
+}
+
+// ReadMetadata returns the metadata of the named model file, reading only
+// its header and the records before the table, so that it is quick even
+// for huge models. Files without metadata give a Metadata with only the
+// prefix length.
+func ReadMetadata(modelFile string) (Metadata, error) {
+	in, err := OpenModelFile(modelFile)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return Metadata{}, fmt.Errorf("%s: %w", modelFile, err)
+		}
+		return Metadata{}, fmt.Errorf("%s: %w", modelFile, ErrEmptyModel)
+	}
+	prefixLen, version, err := parseHeader(scanner.Text())
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%s:1: %w", modelFile, err)
+	}
+	c := newChain(prefixLen)
+	for lineNo := 2; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "\t") || isChecksumRecord(line) {
+			break
+		}
+		fields, err := readFields(line, version, prefixLen)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("%s:%d: %v", modelFile, lineNo, err)
+		}
+		if len(fields) > 0 && (fields[0] == "meta" || fields[0] == "source" || fields[0] == "offset") {
+			if err := c.readRecord(fields); err != nil {
+				return Metadata{}, fmt.Errorf("%s:%d: %v", modelFile, lineNo, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Metadata{}, fmt.Errorf("%s: %w", modelFile, err)
+	}
+	return c.Metadata(), nil
+}
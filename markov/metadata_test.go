@@ -0,0 +1,87 @@
+package markov
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMetadataReproducible(t *testing.T) {
+	build := func(opts BuildOptions) *Chain {
+		c := newChain(2)
+		if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader(tinyCorpus), opts); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	a, b := build(BuildOptions{}), build(BuildOptions{})
+	if a.Hash() != b.Hash() {
+		t.Errorf("two builds of the same corpus hash differently")
+	}
+	md := a.Metadata()
+	if !md.Created.IsZero() || md.Tool != "" {
+		t.Errorf("unstamped build recorded created %v, tool %q", md.Created, md.Tool)
+	}
+	if len(md.Sources) != 1 || md.Sources[0] != (Source{"corpus.txt", 15}) || md.Tokens != 15 {
+		t.Errorf("metadata %+v, want one source corpus.txt of 15 tokens", md)
+	}
+
+	stamped := build(BuildOptions{Stamp: true})
+	if md := stamped.Metadata(); md.Created.IsZero() || md.Tool == "" {
+		t.Errorf("stamped build recorded created %v, tool %q", md.Created, md.Tool)
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader(tinyCorpus), BuildOptions{Stamp: true}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read := new(Chain)
+	if _, err := read.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, want := read.Metadata(), c.Metadata()
+	if !got.Created.Equal(want.Created) || got.Tool != want.Tool || got.Tokens != want.Tokens || len(got.Sources) != 1 || got.Sources[0] != want.Sources[0] {
+		t.Errorf("read back %+v, want %+v", got, want)
+	}
+}
+
+func TestMetadataLegacyModel(t *testing.T) {
+	read := new(Chain)
+	_, err := read.ReadFrom(strings.NewReader("2\n\"\" \"\" a 1 \n"))
+	var checksum *ChecksumError
+	if !errors.As(err, &checksum) || !checksum.Missing {
+		t.Fatalf("got error %v, want a missing checksum", err)
+	}
+	if md := read.Metadata(); md.PrefixLen != 2 || md.Sources != nil || md.Tokens != 0 || !md.Created.IsZero() {
+		t.Errorf("legacy model has metadata %+v", md)
+	}
+}
+
+func TestOffsetRoundTrip(t *testing.T) {
+	c := TinyModel()
+	if _, ok := c.Offset("app.log"); ok {
+		t.Error("a new chain has an offset")
+	}
+	c.SetOffset("app.log", 10)
+	c.SetOffset("app.log", 1<<40)
+	c.SetOffset("web.log", 7)
+	for name, roundTrip := range formats {
+		read, err := roundTrip(c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n, ok := read.Offset("app.log"); !ok || n != 1<<40 {
+			t.Errorf("%s: offset of app.log read back as %d, %v; want %d", name, n, ok, int64(1<<40))
+		}
+		if got := read.Metadata().Offsets; len(got) != 2 || got[1] != (Offset{"web.log", 7}) {
+			t.Errorf("%s: offsets read back as %v", name, got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package markov
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OpenOptions control how OpenModel loads a model file.
+type OpenOptions struct {
+	// Mmap maps the file into memory and parses it lazily, see
+	// OpenFreTableMmap.
+	Mmap bool
+	// Format is the format of the file: FormatText, FormatJSON for models
+	// written by WriteJSON, FormatGob for those written by WriteGob or
+	// FormatMsgpack for those written by WriteMsgpack. The
+	// empty string picks it by the extension of the file name, see
+	// FormatOf. Only text models can be mapped.
+	Format string
+}
+
+// The model file formats of OpenOptions.Format.
+const (
+	FormatText    = "text"
+	FormatJSON    = "json"
+	FormatGob     = "gob"
+	FormatMsgpack = "msgpack"
+)
+
+// FormatOf returns the model file format the name of a file suggests:
+// FormatJSON for names ending in .json, FormatGob for .gob, FormatMsgpack
+// for .msgpack and FormatText for everything else, looking past a .gz
+// ending.
+func FormatOf(name string) string {
+	if compressed(name) {
+		name = name[:len(name)-len(".gz")]
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".gob":
+		return FormatGob
+	case ".msgpack":
+		return FormatMsgpack
+	}
+	return FormatText
+}
+
+// Origin describes where a Model was loaded from.
+type Origin struct {
+	Path   string `json:"path"`
+	Format string `json:"format"` // that of OpenOptions, or "text-mmap" when mapped
+	Size   int64  `json:"size"`   // of the file when it was opened
+	// Hash is the Chain.Hash of the model as loaded. For a mapped model,
+	// which hashing would load in full, it is the SHA-256 of the file
+	// instead, the same for files written in the current format.
+	Hash string `json:"hash"`
+}
+
+// Model is a chain loaded from a file together with the resources it holds
+// while in use. Callers should always Close it when done, however it was
+// opened: Close is a no-op for models read into memory, and calling it
+// again after the first time does nothing.
+type Model struct {
+	*Chain
+	origin Origin
+}
+
+// OpenModel loads the model file name according to opts. It is
+// ReadFreTable, OpenFreTableMmap, ReadJSONFile, ReadGobFile or
+// ReadMsgpackFile, returning a Model that says what it was loaded from. For
+// text models without a checksum it returns the Model along with the
+// *ChecksumError, as ReadFreTable does.
+func OpenModel(name string, opts OpenOptions) (*Model, error) {
+	format := opts.Format
+	if format == "" {
+		format = FormatOf(name)
+	}
+	var load func(string) (*Chain, error)
+	switch format {
+	case FormatText:
+		load = ReadFreTable
+	case FormatJSON:
+		load = ReadJSONFile
+	case FormatGob:
+		load = ReadGobFile
+	case FormatMsgpack:
+		load = ReadMsgpackFile
+	default:
+		return nil, fmt.Errorf("unknown model format %q", format)
+	}
+	if opts.Mmap {
+		if format != FormatText {
+			return nil, fmt.Errorf("%s models cannot be mapped", format)
+		}
+		load, format = OpenFreTableMmap, "text-mmap"
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := load(name)
+	if c == nil {
+		return nil, err
+	}
+	origin := Origin{Path: name, Format: format, Size: info.Size()}
+	if opts.Mmap {
+		var herr error
+		if origin.Hash, herr = fileHash(name); herr != nil {
+			c.Close()
+			return nil, herr
+		}
+	}
+	return NewModel(c, origin), err
+}
+
+// NewModel returns c as a Model loaded from origin, for chains read by
+// other means than OpenModel, such as ReadModel from a download. An empty
+// origin.Hash is filled in with the hash of c.
+func NewModel(c *Chain, origin Origin) *Model {
+	if origin.Hash == "" {
+		origin.Hash = c.Hash()
+	}
+	return &Model{c, origin}
+}
+
+// fileHash returns the SHA-256 of the file name in hex, as Chain.Hash
+// spells hashes.
+func fileHash(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadModel reads a model in format, one of the formats of OpenOptions,
+// from r, as it is: wrap compressed data in NewModelReader. For text
+// models without a checksum it returns the chain along with the
+// *ChecksumError, as ReadFreTable does.
+func ReadModel(r io.Reader, format string) (*Chain, error) {
+	switch format {
+	case FormatText:
+		return readFreTable(r, "")
+	case FormatJSON:
+		return ReadJSON(r)
+	case FormatGob:
+		return ReadGob(r)
+	case FormatMsgpack:
+		return ReadMsgpack(r)
+	}
+	return nil, fmt.Errorf("unknown model format %q", format)
+}
+
+// Origin returns where m was loaded from.
+func (m *Model) Origin() Origin {
+	return m.origin
+}
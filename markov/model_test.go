@@ -0,0 +1,88 @@
+package markov
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestOpenModel opens TinyModel written in every format, checks what the
+// Model says it was loaded from and closes it twice.
+func TestOpenModel(t *testing.T) {
+	dir := t.TempDir()
+	c := TinyModel()
+	for _, tt := range []struct {
+		name   string
+		write  func(string) error
+		opts   OpenOptions
+		format string
+	}{
+		{"tiny.txt", c.WriteFreTable, OpenOptions{}, FormatText},
+		{"tiny.txt.gz", c.WriteFreTable, OpenOptions{}, FormatText},
+		{"mapped.txt", c.WriteFreTable, OpenOptions{Mmap: true}, "text-mmap"},
+		{"tiny.json", c.WriteJSONFile, OpenOptions{}, FormatJSON},
+		{"tiny.gob", c.WriteGobFile, OpenOptions{}, FormatGob},
+		{"tiny.msgpack", c.WriteMsgpackFile, OpenOptions{}, FormatMsgpack},
+		{"named.model", c.WriteGobFile, OpenOptions{Format: FormatGob}, FormatGob},
+	} {
+		path := filepath.Join(dir, tt.name)
+		if err := tt.write(path); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := OpenModel(path, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := (Origin{path, tt.format, info.Size(), TinyModelHash}); m.Origin() != want {
+			t.Errorf("%s: Origin = %+v, want %+v", tt.name, m.Origin(), want)
+		}
+		if got := m.Suffixes(Prefix{"the", "cat"}); !reflect.DeepEqual(got, c.Suffixes(Prefix{"the", "cat"})) {
+			t.Errorf("%s: suffixes of the cat = %v", tt.name, got)
+		}
+		if m.Hash() != TinyModelHash {
+			t.Errorf("%s: the model loaded differs from TinyModel", tt.name)
+		}
+		for i := 0; i < 2; i++ {
+			if err := m.Close(); err != nil {
+				t.Errorf("%s: Close %d: %v", tt.name, i+1, err)
+			}
+		}
+	}
+
+	// A model too old for a checksum loads with the error saying so, also
+	// once its file is hashed; NewModel hashes a chain from elsewhere.
+	for _, mmap := range []bool{false, true} {
+		m, err := OpenModel(filepath.Join("testdata", "versions", "v3.model"), OpenOptions{Mmap: mmap})
+		var checksum *ChecksumError
+		if m == nil || !errors.As(err, &checksum) || !checksum.Missing || m.Origin().Hash == "" {
+			t.Errorf("OpenModel(v3.model, mmap %v) = %v, %v; want the model, hashed, and a missing checksum", mmap, m, err)
+		}
+		if m != nil {
+			m.Close()
+		}
+	}
+	if got := NewModel(TinyModel(), Origin{Path: "https://example.com/tiny.txt"}).Origin().Hash; got != TinyModelHash {
+		t.Errorf("NewModel hashed TinyModel to %s, want %s", got, TinyModelHash)
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts OpenOptions
+	}{
+		{"tiny.json", OpenOptions{Mmap: true}},
+		{"tiny.txt", OpenOptions{Format: "yaml"}},
+		{"missing.txt", OpenOptions{}},
+	} {
+		if m, err := OpenModel(filepath.Join(dir, tt.name), tt.opts); err == nil {
+			m.Close()
+			t.Errorf("OpenModel(%s, %+v) succeeded, want an error", tt.name, tt.opts)
+		}
+	}
+}
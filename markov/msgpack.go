@@ -0,0 +1,434 @@
+package markov
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// WriteMsgpack writes c to w in MessagePack, a compact binary relative of
+// JSON that libraries for most languages read, for front ends that load
+// the model themselves. The model is a map of four entries:
+//
+//	version     the FormatVersion of the writer
+//	prefix_len  the prefix length
+//	records     the extension records, as arrays of strings, see JSONModel
+//	prefixes    a map from every prefix to its suffixes
+//
+// The prefixes are the words joined by NUL bytes, which no word holds,
+// with empty strings for empty slots, so that the start of a document is
+// prefix_len-1 NUL bytes. Files written before words could hold white
+// space joined them by single spaces, and are still read.
+// The suffixes of a prefix are a flat array of words each followed by its
+// frequency, in the order of the chain. Prefixes are written in sorted
+// order, so that the same chain always produces the same output. The
+// encoder is part of this package, without any dependency.
+func (c *Chain) WriteMsgpack(w io.Writer) error {
+	defer c.beginRead()()
+	if err := c.materialize(); err != nil {
+		return err
+	}
+	e := &msgpackWriter{w: bufio.NewWriter(w)}
+	e.mapHeader(4)
+	e.str("version")
+	e.int(FormatVersion)
+	e.str("prefix_len")
+	e.int(c.prefixLen)
+	e.str("records")
+	records := c.records()
+	e.arrayHeader(len(records))
+	for _, fields := range records {
+		e.arrayHeader(len(fields))
+		for _, f := range fields {
+			e.str(f)
+		}
+	}
+	e.str("prefixes")
+	e.mapHeader(len(c.chain))
+	for _, key := range sortedKeys(c.chain) {
+		e.str(key) // chain keys are joined by keySep already
+		e.arrayHeader(2 * len(c.chain[key]))
+		for _, s := range c.chain[key] {
+			e.str(s.word)
+			e.int(s.frequency)
+		}
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// WriteMsgpackFile is WriteMsgpack to the named file, which is removed
+// again if anything fails.
+func (c *Chain) WriteMsgpackFile(name string) error {
+	return writeFile(name, c.WriteMsgpack)
+}
+
+// ReadMsgpack reads a chain written by WriteMsgpack. Entries of the model
+// map other than those WriteMsgpack writes are skipped. Prefixes must have
+// prefix_len words and suffix words must not be empty; as for ReadJSON,
+// words may hold anything, white space included, but a NUL byte, and
+// frequencies must be positive. Suffixes listed twice for a prefix are
+// summed.
+func ReadMsgpack(r io.Reader) (*Chain, error) {
+	d := &msgpackReader{r: bufio.NewReader(r)}
+	n, err := d.mapHeader()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		version, prefixLen int
+		records            [][]string
+		table              map[string][]Suffix
+	)
+	for i := 0; i < n; i++ {
+		name, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "version":
+			version, err = d.int()
+		case "prefix_len":
+			prefixLen, err = d.int()
+		case "records":
+			records, err = d.records()
+		case "prefixes":
+			if prefixLen <= 0 {
+				return nil, fmt.Errorf("prefixes before a positive prefix length")
+			}
+			table, err = d.table(prefixLen)
+		default:
+			err = d.skip()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if prefixLen <= 0 {
+		return nil, fmt.Errorf("expected a positive prefix length, got %d", prefixLen)
+	}
+	c := newChain(prefixLen)
+	for key, suf := range table {
+		for _, s := range suf {
+			c.add(key, s.word, s.frequency)
+		}
+	}
+	// The records come last, as adding to the table drops the k of a
+	// choices record.
+	for i, fields := range records {
+		if version == 0 {
+			legacyRecord(fields, prefixLen)
+		}
+		if err := c.readRecord(fields); err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+	}
+	return c, nil
+}
+
+// ReadMsgpackFile is ReadMsgpack on the named file. Errors name the file.
+func ReadMsgpackFile(name string) (*Chain, error) {
+	in, err := OpenModelFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	c, err := ReadMsgpack(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+// msgpackWriter encodes the few MessagePack types WriteMsgpack needs. The
+// first error is kept in err and everything after it is dropped.
+type msgpackWriter struct {
+	w   *bufio.Writer
+	buf [9]byte
+	err error
+}
+
+// header writes a type byte followed by n in size bytes, big-endian.
+func (e *msgpackWriter) header(typ byte, n uint64, size int) {
+	if e.err != nil {
+		return
+	}
+	e.buf[0] = typ
+	for i := 0; i < size; i++ {
+		e.buf[size-i] = byte(n >> (8 * i))
+	}
+	_, e.err = e.w.Write(e.buf[:1+size])
+}
+
+// sized writes the header of a string, array or map of n elements: fix is
+// the type byte of the short form, holding up to max elements, and b8, b16
+// and b32 those of the longer ones; b8 is 0 for types without it.
+func (e *msgpackWriter) sized(n int, fix byte, max int, b8, b16, b32 byte) {
+	switch {
+	case n <= max:
+		e.header(fix|byte(n), 0, 0)
+	case b8 != 0 && n <= math.MaxUint8:
+		e.header(b8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.header(b16, uint64(n), 2)
+	default:
+		e.header(b32, uint64(n), 4)
+	}
+}
+
+func (e *msgpackWriter) mapHeader(n int)   { e.sized(n, 0x80, 15, 0, 0xde, 0xdf) }
+func (e *msgpackWriter) arrayHeader(n int) { e.sized(n, 0x90, 15, 0, 0xdc, 0xdd) }
+
+func (e *msgpackWriter) str(s string) {
+	e.sized(len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// int writes n in the shortest form; counts are never negative.
+func (e *msgpackWriter) int(n int) {
+	switch {
+	case n < 0:
+		e.header(0xd3, uint64(n), 8)
+	case n <= 0x7f:
+		e.header(byte(n), 0, 0)
+	case n <= math.MaxUint8:
+		e.header(0xcc, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.header(0xcd, uint64(n), 2)
+	case n <= math.MaxUint32:
+		e.header(0xce, uint64(n), 4)
+	default:
+		e.header(0xcf, uint64(n), 8)
+	}
+}
+
+// msgpackReader decodes MessagePack for ReadMsgpack.
+type msgpackReader struct {
+	r   *bufio.Reader
+	buf [8]byte
+}
+
+// errMsgpackType is returned for a value of another type than expected.
+var errMsgpackType = errors.New("unexpected MessagePack type")
+
+// uint reads an unsigned big-endian number of size bytes.
+func (d *msgpackReader) uint(size int) (uint64, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:size]); err != nil {
+		return 0, noEOF(err)
+	}
+	var n uint64
+	for _, b := range d.buf[:size] {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+// noEOF turns the end of the input within a value into an error.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// length reads the header of a string, array or map, whose type bytes are
+// as for msgpackWriter.sized, and returns the number of its elements.
+func (d *msgpackReader) length(fix byte, max int, b8, b16, b32 byte) (int, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	var n uint64
+	switch {
+	case typ&^byte(max) == fix:
+		return int(typ & byte(max)), nil
+	case b8 != 0 && typ == b8:
+		n, err = d.uint(1)
+	case typ == b16:
+		n, err = d.uint(2)
+	case typ == b32:
+		n, err = d.uint(4)
+	default:
+		return 0, fmt.Errorf("%w 0x%02x", errMsgpackType, typ)
+	}
+	return int(n), err
+}
+
+func (d *msgpackReader) mapHeader() (int, error)   { return d.length(0x80, 15, 0, 0xde, 0xdf) }
+func (d *msgpackReader) arrayHeader() (int, error) { return d.length(0x90, 15, 0, 0xdc, 0xdd) }
+
+func (d *msgpackReader) str() (string, error) {
+	n, err := d.length(0xa0, 31, 0xd9, 0xda, 0xdb)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if _, err := io.CopyN(&b, d.r, int64(n)); err != nil {
+		return "", noEOF(err)
+	}
+	return b.String(), nil
+}
+
+// int reads an integer of any of the MessagePack integer types.
+func (d *msgpackReader) int() (int, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return 0, noEOF(err)
+	}
+	var n uint64
+	switch {
+	case typ <= 0x7f:
+		return int(typ), nil
+	case typ >= 0xe0:
+		return int(int8(typ)), nil
+	case typ >= 0xcc && typ <= 0xcf:
+		n, err = d.uint(1 << (typ - 0xcc))
+		if n > math.MaxInt {
+			return 0, fmt.Errorf("integer %d out of range", n)
+		}
+		return int(n), err
+	case typ >= 0xd0 && typ <= 0xd3:
+		size := 1 << (typ - 0xd0)
+		n, err = d.uint(size)
+		shift := 64 - 8*size
+		return int(int64(n<<shift) >> shift), err
+	}
+	return 0, fmt.Errorf("%w 0x%02x, expected an integer", errMsgpackType, typ)
+}
+
+// records reads the records of a model.
+func (d *msgpackReader) records() ([][]string, error) {
+	n, err := d.arrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	// Lengths are not trusted to allocate for: a damaged file could claim
+	// billions of elements.
+	var records [][]string
+	for i := 0; i < n; i++ {
+		m, err := d.arrayHeader()
+		if err != nil {
+			return nil, err
+		}
+		var fields []string
+		for j := 0; j < m; j++ {
+			f, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		}
+		records = append(records, fields)
+	}
+	return records, nil
+}
+
+// table reads the prefixes of a model. They are added to the chain only
+// after the records, which may come after them.
+func (d *msgpackReader) table(prefixLen int) (map[string][]Suffix, error) {
+	n, err := d.mapHeader()
+	if err != nil {
+		return nil, err
+	}
+	table := make(map[string][]Suffix, min(n, 1<<16))
+	for i := 0; i < n; i++ {
+		key, err := d.str()
+		if err != nil {
+			return nil, err
+		}
+		if prefixLen > 1 && !strings.Contains(key, keySep) {
+			key = strings.ReplaceAll(key, " ", keySep) // an older file
+		}
+		name := splitKey(key).String()
+		if strings.Count(key, keySep) != prefixLen-1 {
+			return nil, fmt.Errorf("prefix %q: expected %d words", name, prefixLen)
+		}
+		m, err := d.arrayHeader()
+		if err != nil {
+			return nil, fmt.Errorf("prefix %q: %w", name, err)
+		}
+		if m%2 != 0 {
+			return nil, fmt.Errorf("prefix %q: %d elements, expected words and frequencies", name, m)
+		}
+		for j := 0; j < m; j += 2 {
+			word, err := d.str()
+			if err != nil {
+				return nil, fmt.Errorf("prefix %q: %w", name, err)
+			}
+			f, err := d.int()
+			if err != nil {
+				return nil, fmt.Errorf("prefix %q: %w", name, err)
+			}
+			if word == "" {
+				return nil, fmt.Errorf("prefix %q: empty suffix word", name)
+			}
+			if err := checkWord(word); err != nil {
+				return nil, fmt.Errorf("prefix %q: %v", name, err)
+			}
+			if f <= 0 {
+				return nil, fmt.Errorf("prefix %q: expected positive frequency for %q, got %d", name, word, f)
+			}
+			table[key] = append(table[key], Suffix{word, f})
+		}
+	}
+	return table, nil
+}
+
+// skip reads past a value of any type.
+func (d *msgpackReader) skip() error {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	var n, elems uint64 // bytes and values to skip
+	switch {
+	case typ <= 0x7f, typ >= 0xe0, typ == 0xc0, typ == 0xc2, typ == 0xc3:
+	case typ >= 0x80 && typ <= 0x8f:
+		elems = 2 * uint64(typ&0x0f)
+	case typ >= 0x90 && typ <= 0x9f:
+		elems = uint64(typ & 0x0f)
+	case typ >= 0xa0 && typ <= 0xbf:
+		n = uint64(typ & 0x1f)
+	case typ == 0xc4, typ == 0xd9:
+		n, err = d.uint(1)
+	case typ == 0xc5, typ == 0xda:
+		n, err = d.uint(2)
+	case typ == 0xc6, typ == 0xdb:
+		n, err = d.uint(4)
+	case typ == 0xc7, typ == 0xc8, typ == 0xc9:
+		n, err = d.uint(1 << (typ - 0xc7))
+		n++ // the extension type
+	case typ == 0xca, typ == 0xcb:
+		n = 4 << (typ - 0xca)
+	case typ >= 0xcc && typ <= 0xd3:
+		n = 1 << ((typ - 0xcc) % 4)
+	case typ >= 0xd4 && typ <= 0xd8:
+		n = 1 + 1<<(typ-0xd4)
+	case typ == 0xdc, typ == 0xdd:
+		elems, err = d.uint(2 << (typ - 0xdc))
+	case typ == 0xde, typ == 0xdf:
+		elems, err = d.uint(2 << (typ - 0xde))
+		elems *= 2
+	default:
+		return fmt.Errorf("%w 0x%02x", errMsgpackType, typ)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := d.r.Discard(int(n)); err != nil {
+		return noEOF(err)
+	}
+	for ; elems > 0; elems-- {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
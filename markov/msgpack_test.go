@@ -0,0 +1,131 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// TestMsgpackEncoding checks the integers and the headers of strings,
+// arrays and maps against their encodings in the MessagePack
+// specification, at the bounds of every form, and reads them back.
+func TestMsgpackEncoding(t *testing.T) {
+	type encoding struct {
+		write func(e *msgpackWriter)
+		read  func(d *msgpackReader) (int, error)
+		n     int
+		want  string // hex
+	}
+	var tests []encoding
+	ints := map[int]string{
+		0: "00", 127: "7f", 128: "cc80", 255: "ccff", 256: "cd0100", 65535: "cdffff",
+		65536: "ce00010000", 1<<32 - 1: "ceffffffff", 1 << 32: "cf0000000100000000",
+	}
+	for n, want := range ints {
+		n := n
+		tests = append(tests, encoding{func(e *msgpackWriter) { e.int(n) }, (*msgpackReader).int, n, want})
+	}
+	headers := []struct {
+		write func(e *msgpackWriter, n int)
+		read  func(d *msgpackReader) (int, error)
+		sizes map[int]string
+	}{
+		{(*msgpackWriter).arrayHeader, (*msgpackReader).arrayHeader, map[int]string{0: "90", 15: "9f", 16: "dc0010", 65535: "dcffff", 65536: "dd00010000"}},
+		{(*msgpackWriter).mapHeader, (*msgpackReader).mapHeader, map[int]string{0: "80", 15: "8f", 16: "de0010", 65535: "deffff", 65536: "df00010000"}},
+	}
+	for _, h := range headers {
+		for n, want := range h.sizes {
+			write, n := h.write, n
+			tests = append(tests, encoding{func(e *msgpackWriter) { write(e, n) }, h.read, n, want})
+		}
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		e := &msgpackWriter{w: bufio.NewWriter(&buf)}
+		tt.write(e)
+		if err := e.w.Flush(); err != nil || e.err != nil {
+			t.Fatal(err, e.err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()); got != tt.want {
+			t.Errorf("%d encodes as %s, want %s", tt.n, got, tt.want)
+		}
+		d := &msgpackReader{r: bufio.NewReader(&buf)}
+		if got, err := tt.read(d); err != nil || got != tt.n {
+			t.Errorf("%s decodes as %d, %v, want %d", tt.want, got, err, tt.n)
+		}
+	}
+
+	for n, want := range map[int]string{0: "a0", 31: "bf", 32: "d920", 255: "d9ff", 256: "da0100", 65536: "db00010000"} {
+		s := strings.Repeat("x", n)
+		var buf bytes.Buffer
+		e := &msgpackWriter{w: bufio.NewWriter(&buf)}
+		e.str(s)
+		e.w.Flush()
+		if got := hex.EncodeToString(buf.Bytes()[:len(buf.Bytes())-n]); got != want {
+			t.Errorf("a string of %d bytes starts with %s, want %s", n, got, want)
+		}
+		d := &msgpackReader{r: bufio.NewReader(&buf)}
+		if got, err := d.str(); err != nil || got != s {
+			t.Errorf("a string of %d bytes decodes to %d bytes, %v", n, len(got), err)
+		}
+	}
+
+	// Signed integers, which WriteMsgpack never writes, are read too.
+	for enc, want := range map[string]int{"ff": -1, "e0": -32, "d0ff": -1, "d1fffe": -2, "d3ffffffffffffffff": -1} {
+		data, _ := hex.DecodeString(enc)
+		d := &msgpackReader{r: bufio.NewReader(bytes.NewReader(data))}
+		if got, err := d.int(); err != nil || got != want {
+			t.Errorf("%s decodes as %d, %v, want %d", enc, got, err, want)
+		}
+	}
+}
+
+// TestMsgpackRoundTripSizes round-trips a chain of words, counts and
+// suffix lists big enough to take the longer forms of every type.
+func TestMsgpackRoundTripSizes(t *testing.T) {
+	c := newChain(1)
+	for i, w := range []string{strings.Repeat("w", 40), strings.Repeat("ü", 200), strings.Repeat("x", 70000)} {
+		c.add(Prefix{"x"}.key(), w, []int{128, 70000, 1 << 33}[i])
+	}
+	for i := 0; i < 20; i++ {
+		c.add(Prefix{"many"}.key(), strings.Repeat("m", i+1), i+1)
+	}
+	checkRoundTrip(t, c, "")
+
+	var buf bytes.Buffer
+	if err := c.WriteMsgpack(&buf); err != nil {
+		t.Fatal(err)
+	}
+	model := buf.Bytes()
+	for n := 0; n < len(model); n += 1 + n/4 {
+		if _, err := ReadMsgpack(bytes.NewReader(model[:n])); err == nil {
+			t.Errorf("ReadMsgpack of the first %d of %d bytes succeeded", n, len(model))
+		}
+	}
+}
+
+// BenchmarkModelSize writes the model of a synthetic corpus of 200,000
+// tokens in MessagePack and in JSON, reporting the size of each as bytes.
+func BenchmarkModelSize(b *testing.B) {
+	c := synthChain(b, 200000)
+	for _, bm := range []struct {
+		format string
+		write  func(*bytes.Buffer) error
+	}{
+		{FormatMsgpack, func(buf *bytes.Buffer) error { return c.WriteMsgpack(buf) }},
+		{FormatJSON, func(buf *bytes.Buffer) error { return c.WriteJSON(buf) }},
+	} {
+		b.Run(bm.format, func(b *testing.B) {
+			var buf bytes.Buffer
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := bm.write(&buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buf.Len()), "bytes")
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package markov
+
+import (
+	"unicode/utf8"
+)
+
+// PrefixMatch is a known prefix suggested by NearestPrefixes.
+type PrefixMatch struct {
+	Prefix string  `json:"prefix"`
+	Score  float64 `json:"score"` // 1 for the query itself, towards 0 for unrelated prefixes
+}
+
+// NearestPrefixes returns the at most k known prefixes most similar to
+// words, best first, spelled as model files spell them. Every word of a
+// prefix is compared with the word of the query at the same position:
+// equal words score 1, others 1 minus their edit distance relative to the
+// longer word, and the score of the prefix is the mean over its words.
+// Prefixes scoring 0 are left out. Only prefixes sharing at least one
+// word with the query are considered, found through an index of the
+// prefixes by word built on first use; when there are none, every prefix
+// is. Ties are broken by prefix order.
+func (c *Chain) NearestPrefixes(words []string, k int) []PrefixMatch {
+	c.materialize()
+	defer c.beginRead()()
+	if k <= 0 || len(words) != c.prefixLen {
+		return nil
+	}
+	c.loadMu.Lock()
+	if c.prefixIndex == nil {
+		c.prefixIndex = make(map[string][]string)
+		for _, key := range sortedKeys(c.chain) {
+			for _, w := range uniqueWords(key) {
+				c.prefixIndex[w] = append(c.prefixIndex[w], key)
+			}
+		}
+	}
+	c.loadMu.Unlock()
+
+	candidates := make(map[string]bool)
+	for _, w := range words {
+		for _, key := range c.prefixIndex[w] {
+			candidates[key] = true
+		}
+	}
+	if len(candidates) == 0 {
+		for key := range c.chain {
+			candidates[key] = true
+		}
+	}
+
+	top := newTopN(k, func(a, b PrefixMatch) bool {
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Prefix < b.Prefix
+	})
+	for key := range candidates {
+		var score float64
+		for i, w := range splitKey(key) {
+			score += wordSimilarity(words[i], w)
+		}
+		if score > 0 {
+			top.push(PrefixMatch{sentinelPrefix(key).String(), score / float64(len(words))})
+		}
+	}
+	return top.sorted()
+}
+
+// uniqueWords returns the distinct words of a prefix key.
+func uniqueWords(key string) []string {
+	var out []string
+	for _, w := range splitKey(key) {
+		dup := false
+		for _, o := range out {
+			dup = dup || o == w
+		}
+		if !dup {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// wordSimilarity is 1 minus the edit distance of a and b relative to the
+// length of the longer one, in runes.
+func wordSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	n := utf8.RuneCountInString(a)
+	if m := utf8.RuneCountInString(b); m > n {
+		n = m
+	}
+	return 1 - float64(editDistance(a, b))/float64(n)
+}
+
+// editDistance returns the edit distance between a and b in runes,
+// counting insertions, deletions, substitutions and transpositions of
+// adjacent runes, the usual typing mistakes, as one edit each.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// d[i][j] is the distance between ra[:i] and rb[:j].
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			best := d[i-1][j-1] + cost
+			if d[i-1][j]+1 < best {
+				best = d[i-1][j] + 1
+			}
+			if d[i][j-1]+1 < best {
+				best = d[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < best {
+				best = d[i-2][j-2] + 1
+			}
+			d[i][j] = best
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
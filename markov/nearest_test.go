@@ -0,0 +1,66 @@
+package markov
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNearestPrefixes(t *testing.T) {
+	c := newChain(2)
+	corpus := "the quick brown fox jumps over the lazy dog. the quick red fox runs over the hill."
+	if _, err := c.BuildReaderOpts("fox.txt", strings.NewReader(corpus), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query []string
+		want  []PrefixMatch
+	}{
+		// A transposition is one edit of three letters: (2/3 + 1) / 2.
+		{[]string{"teh", "quick"}, []PrefixMatch{{"the quick", 5.0 / 6}}},
+		// Ties in score go by prefix.
+		{[]string{"the", "lasy"}, []PrefixMatch{{"the lazy", 0.875}, {"the hill.", 0.5}, {"the quick", 0.5}}},
+		{[]string{"quick", "brwon"}, []PrefixMatch{{"quick brown", 0.9}, {"quick red", 0.6}}},
+		{[]string{"fox", "jumsp"}, []PrefixMatch{{"fox jumps", 0.9}, {"fox runs", 0.7}, {"brown fox", 0.1}}},
+		// Start states are spelled with the sentinel.
+		{[]string{"", "teh"}, []PrefixMatch{{`"" the`, 5.0 / 6}, {`"" ""`, 0.5}}},
+		// Without a shared word, every prefix is a candidate.
+		{[]string{"xyz", "qqq"}, []PrefixMatch{{"lazy dog.", 0.125}, {"the quick", 0.1}}},
+		{[]string{"only one word"}, nil},
+	}
+	for _, tt := range tests {
+		got := c.NearestPrefixes(tt.query, 3)
+		for i := range got {
+			// Round off the float error of the means.
+			got[i].Score = float64(int(got[i].Score*1e6+0.5)) / 1e6
+		}
+		for i := range tt.want {
+			tt.want[i].Score = float64(int(tt.want[i].Score*1e6+0.5)) / 1e6
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NearestPrefixes(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"cat", "cat", 0},
+		{"cat", "act", 1},
+		{"cat", "cart", 1},
+		{"kitten", "sitting", 3},
+		{"naïve", "naive", 1},
+		{"", "abc", 3},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package markov
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxProbabilityDecimals is the most decimal places WriteProbabilities
+// writes probabilities with.
+const MaxProbabilityDecimals = 9
+
+// WriteProbabilities writes c to w as WriteTo does, but with every suffix
+// carrying the probability that it follows its prefix instead of its
+// count, with the given number of decimal places, for consumers that
+// should not have to sum up every prefix themselves. The header says so,
+// "GOMARK v4 prefix=n probabilities=d". The probabilities of a prefix are
+// rounded so that they sum to exactly 1: every one is rounded down to d
+// decimals and the units of the last place left over go to those rounded
+// down the most. A word too rare to show at d decimals is given the
+// smallest probability that can be written, taken from the most likely
+// word, so that no word is lost; a prefix with more than 10^d suffixes
+// cannot be written at all. The statistics besides the table keep their
+// counts. ReadFreTable reads such a model back with the probabilities in
+// units of the last decimal place as frequencies, which generate from the
+// same distribution.
+func (c *Chain) WriteProbabilities(w io.Writer, decimals int) error {
+	if decimals < 1 || decimals > MaxProbabilityDecimals {
+		return fmt.Errorf("probabilities need 1 to %d decimal places, got %d", MaxProbabilityDecimals, decimals)
+	}
+	_, err := c.writeTo(w, decimals)
+	return err
+}
+
+// WriteProbabilitiesFile is WriteProbabilities to the named file, which is
+// removed again if anything fails.
+func (c *Chain) WriteProbabilitiesFile(name string, decimals int) error {
+	return writeFile(name, func(w io.Writer) error {
+		return c.WriteProbabilities(w, decimals)
+	})
+}
+
+// probabilityUnits returns the frequencies of suf as probabilities in units
+// of 10^-decimals, summing to exactly 10^decimals, see WriteProbabilities.
+func probabilityUnits(suf []Suffix, decimals int) ([]int, error) {
+	one := pow10(decimals)
+	if len(suf) > one {
+		return nil, fmt.Errorf("%d suffixes cannot all have a probability of %d decimal places", len(suf), decimals)
+	}
+	total := 0
+	for _, s := range suf {
+		total += s.frequency
+	}
+	units := make([]int, len(suf))
+	rest := make([]float64, len(suf))
+	left := one
+	for i, s := range suf {
+		share := float64(s.frequency) * float64(one) / float64(total)
+		units[i] = int(math.Floor(share))
+		rest[i] = share - float64(units[i])
+		left -= units[i]
+	}
+	order := make([]int, len(suf))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return rest[order[a]] > rest[order[b]] })
+	for i := 0; left > 0; i = (i + 1) % len(order) {
+		units[order[i]]++
+		left--
+	}
+	for i := range units {
+		if units[i] > 0 {
+			continue
+		}
+		most := 0
+		for j := range units {
+			if units[j] > units[most] {
+				most = j
+			}
+		}
+		units[most]--
+		units[i]++
+	}
+	return units, nil
+}
+
+// formatUnits writes n units of 10^-decimals as a decimal number.
+func formatUnits(n, decimals int) string {
+	one := pow10(decimals)
+	return fmt.Sprintf("%d.%0*d", n/one, decimals, n%one)
+}
+
+// pow10 returns 10^n.
+func pow10(n int) int {
+	p := 1
+	for ; n > 0; n-- {
+		p *= 10
+	}
+	return p
+}
+
+// headerDecimals returns the decimal places of the probabilities=d field
+// of a model file header, or 0 for models of counts.
+func headerDecimals(header string) (int, error) {
+	for _, f := range strings.Fields(header) {
+		if v, ok := strings.CutPrefix(f, "probabilities="); ok {
+			d, err := strconv.Atoi(v)
+			if err != nil || d < 1 || d > MaxProbabilityDecimals {
+				return 0, fmt.Errorf("expected 1 to %d decimal places, got %q", MaxProbabilityDecimals, f)
+			}
+			return d, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseFrequency parses the frequency of a suffix in a table line: an
+// integer, or for models of probabilities with d decimals a number with
+// exactly d decimals, returned in units of the last place.
+func parseFrequency(s string, decimals int) (int, error) {
+	if decimals == 0 {
+		f, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("expected integer frequency, got %q", s)
+		}
+		return f, nil
+	}
+	whole, frac, ok := strings.Cut(s, ".")
+	w, err1 := strconv.Atoi(whole)
+	f, err2 := strconv.Atoi(frac)
+	if !ok || len(frac) != decimals || err1 != nil || err2 != nil || !digits(whole) || !digits(frac) {
+		return 0, fmt.Errorf("expected a probability with %d decimals, got %q", decimals, s)
+	}
+	return w*pow10(decimals) + f, nil
+}
+
+// digits reports whether s is made of ASCII digits only, as the numbers
+// strconv.Atoi accepts with a sign are not.
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,126 @@
+package markov
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/sampletest"
+)
+
+func TestProbabilityUnits(t *testing.T) {
+	many := make([]Suffix, 101)
+	for i := range many {
+		many[i] = Suffix{strconv.Itoa(i), 1}
+	}
+	for _, tt := range []struct {
+		freqs    []int
+		decimals int
+		want     []int
+	}{
+		{[]int{7}, 2, []int{100}},
+		{[]int{1, 1}, 1, []int{5, 5}},
+		{[]int{1, 1, 1}, 2, []int{34, 33, 33}},
+		{[]int{1, 2, 3}, 3, []int{167, 333, 500}},
+		// The rare word keeps the smallest probability that can be written.
+		{[]int{1, 1000}, 2, []int{1, 99}},
+		{[]int{1, 1, 1000}, 1, []int{1, 1, 8}},
+	} {
+		suf := make([]Suffix, len(tt.freqs))
+		for i, f := range tt.freqs {
+			suf[i] = Suffix{strconv.Itoa(i), f}
+		}
+		if got, err := probabilityUnits(suf, tt.decimals); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("probabilityUnits(%v, %d) = %v, %v, want %v", tt.freqs, tt.decimals, got, err, tt.want)
+		}
+	}
+	if _, err := probabilityUnits(many, 2); err == nil {
+		t.Error("101 suffixes got probabilities of 2 decimal places")
+	}
+}
+
+func TestParseFrequency(t *testing.T) {
+	for _, tt := range []struct {
+		s        string
+		decimals int
+		want     int
+		ok       bool
+	}{
+		{"12", 0, 12, true},
+		{"0.5", 0, 0, false},
+		{"0.500", 3, 500, true},
+		{"1.000", 3, 1000, true},
+		{"0.50", 3, 0, false},
+		{"0.5000", 3, 0, false},
+		{"1", 3, 0, false},
+		{".500", 3, 0, false},
+		{"-0.500", 3, 0, false},
+		{"+0.500", 3, 0, false},
+		{"0.-50", 3, 0, false},
+		{"0.5e0", 3, 0, false},
+	} {
+		got, err := parseFrequency(tt.s, tt.decimals)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseFrequency(%q, %d) = %d, %v, want %d, ok %v", tt.s, tt.decimals, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestWriteProbabilities writes a model of probabilities, checks that those
+// of every prefix sum to 1, and that the model read back generates from the
+// distribution of the counts.
+func TestWriteProbabilities(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader(benchCorpus(5000))); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteProbabilities(&buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	header, table, _ := strings.Cut(buf.String(), "\n")
+	if !strings.HasSuffix(header, " probabilities=3") {
+		t.Errorf("header %q does not say the model holds probabilities", header)
+	}
+	lines := 0
+	for _, line := range strings.Split(table, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		lines++
+		fields := strings.Split(strings.TrimSuffix(line, " "), " ")[c.prefixLen:]
+		sum := 0.0
+		for i := 1; i < len(fields); i += 2 {
+			p, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil || p <= 0 {
+				t.Fatalf("line %q has probability %q", line, fields[i])
+			}
+			sum += p
+		}
+		if sum < 1-1e-9 || sum > 1+1e-9 {
+			t.Errorf("the probabilities of line %q sum to %v", line, sum)
+		}
+	}
+	if lines != len(c.chain) {
+		t.Errorf("wrote %d table lines for %d prefixes", lines, len(c.chain))
+	}
+
+	samples := samplingChain()
+	buf.Reset()
+	if err := samples.WriteProbabilities(&buf, 4); err != nil {
+		t.Fatal(err)
+	}
+	read := new(Chain)
+	if _, err := read.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	sampletest.Check(t, drawAfter(t, read, []string{"x"}, GenerateOptions{}), normalize(map[string]float64{"a": 1, "b": 2, "c": 3}), sampletest.Options{})
+
+	for _, decimals := range []int{0, MaxProbabilityDecimals + 1} {
+		if err := c.WriteProbabilities(&buf, decimals); err == nil {
+			t.Errorf("WriteProbabilities with %d decimals succeeded", decimals)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package markov
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// orderCorpus has many ties: words and prefixes seen equally often.
+const orderCorpus = "a b c d. b a d c. c d a b. d c b a. a c b d. b d a c."
+
+// TestDeterministicOrder runs every operation whose result could depend
+// on map iteration order many times, each on a chain built anew, and
+// checks that the results are identical.
+func TestDeterministicOrder(t *testing.T) {
+	build := func() *Chain {
+		c := newChain(1)
+		if _, err := c.BuildReaderOpts("corpus.txt", strings.NewReader(orderCorpus), BuildOptions{SentenceStarts: true, Positions: true}); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	other := newChain(1)
+	addWords(other, strings.Fields("a b a c a d b c"))
+	ops := map[string]func(c *Chain) string{
+		"text": func(c *Chain) string {
+			var b bytes.Buffer
+			c.WriteTo(&b)
+			return b.String()
+		},
+		"json": func(c *Chain) string {
+			var b bytes.Buffer
+			c.WriteJSON(&b)
+			return b.String()
+		},
+		"gob": func(c *Chain) string {
+			var b bytes.Buffer
+			c.WriteGob(&b)
+			return b.String()
+		},
+		"msgpack": func(c *Chain) string {
+			var b bytes.Buffer
+			c.WriteMsgpack(&b)
+			return b.String()
+		},
+		"dot": func(c *Chain) string {
+			var b bytes.Buffer
+			c.WriteDOT(&b, DotOptions{MaxNodes: 5})
+			return b.String()
+		},
+		"stats":        func(c *Chain) string { return fmt.Sprint(c.Stats()) },
+		"top-prefixes": func(c *Chain) string { return fmt.Sprint(c.TopPrefixes(3)) },
+		"vocabulary":   func(c *Chain) string { return fmt.Sprint(c.VocabularyByCount()) },
+		"distribution": func(c *Chain) string { return fmt.Sprint(c.Distribution([]string{"a"})) },
+		"nearest":      func(c *Chain) string { return fmt.Sprint(c.NearestPrefixes([]string{"e"}, 3)) },
+		"divergence":   func(c *Chain) string { return fmt.Sprint(Divergence(c, other)) },
+		"greedy":       func(c *Chain) string { return fmt.Sprint(c.GenerateWords(20, GenerateOptions{Greedy: true})) },
+		"hash":         func(c *Chain) string { return c.Hash() },
+	}
+	for name, op := range ops {
+		want := op(build())
+		for i := 0; i < 20; i++ {
+			if got := op(build()); got != want {
+				t.Errorf("%s differs between runs:\n%s\n%s", name, got, want)
+				break
+			}
+		}
+	}
+}
+
+// TestResolveInputsOrder checks that the files of a directory are listed
+// in lexical order, whatever order they were created in.
+func TestResolveInputsOrder(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"c.txt", "a.txt", "sub/b.txt", "b.txt"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o777)
+		if err := os.WriteFile(path, []byte("text"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ResolveInputs([]string{dir}, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range InputNames(files) {
+		rel, _ := filepath.Rel(dir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := "a.txt b.txt c.txt sub/b.txt"; strings.Join(got, " ") != want {
+		t.Errorf("ResolveInputs lists %q, want %s", got, want)
+	}
+}
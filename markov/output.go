@@ -0,0 +1,187 @@
+package markov
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// OutputFormats are the encoders selectable with generate -output-format.
+var OutputFormats = map[string]func(io.Writer, []string) error{
+	"text":        WriteText,
+	"ssml":        WriteSSML,
+	"tokens-json": WriteTokensJSON,
+}
+
+// Token classes reported in TokenList.
+const (
+	ClassWord        = "word"
+	ClassPunctuation = "punctuation"
+	ClassParagraph   = "paragraph"
+)
+
+// Token is one generated token with its class.
+type Token struct {
+	Text  string `json:"text"`
+	Class string `json:"class"`
+}
+
+// TokenList is the JSON object written by -output-format tokens-json.
+type TokenList struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// tokenClass classifies a generated token.
+func tokenClass(tok string) string {
+	if tok == ParagraphToken {
+		return ClassParagraph
+	}
+	for _, r := range tok {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return ClassWord
+		}
+	}
+	return ClassPunctuation
+}
+
+// joiner writes tokens one at a time, deciding the white space before
+// each from the token before it, so that text can be written as it is
+// generated. joinWords and detokenize go through it as well; the rules of
+// streamed and buffered output are the same by construction.
+type joiner struct {
+	w      io.Writer
+	pretty bool   // attach punctuation tokens as detokenize does
+	prev   string // the token written last, "" at the start
+}
+
+// sep returns the white space to write before tok.
+func (j *joiner) sep(tok string) string {
+	switch {
+	case tok == ParagraphToken:
+		return "\n\n"
+	case j.prev == "" || j.prev == ParagraphToken:
+		return ""
+	case j.pretty && (attachesLeft(tok) || attachesRight(j.prev)):
+		return ""
+	}
+	return " "
+}
+
+// write writes tok with the white space it needs.
+func (j *joiner) write(tok string) error {
+	s := j.sep(tok)
+	if tok != ParagraphToken {
+		s += tok
+	}
+	j.prev = tok
+	_, err := io.WriteString(j.w, s)
+	return err
+}
+
+// join joins words with a joiner into a string.
+func join(words []string, pretty bool) string {
+	var b strings.Builder
+	j := joiner{w: &b, pretty: pretty}
+	for _, w := range words {
+		j.write(w)
+	}
+	return b.String()
+}
+
+// joinWords joins words with spaces, rendering paragraph tokens as blank
+// lines.
+func joinWords(words []string) string {
+	return join(words, false)
+}
+
+// WriteText writes the words separated by spaces.
+func WriteText(w io.Writer, words []string) error {
+	_, err := fmt.Fprintln(w, joinWords(words))
+	return err
+}
+
+// attachesLeft reports whether a punctuation token is written without a
+// space before it, and attachesRight whether the next token follows it
+// without a space.
+func attachesLeft(tok string) bool {
+	return tokenClass(tok) == ClassPunctuation && strings.IndexByte("([{", tok[0]) < 0
+}
+
+func attachesRight(tok string) bool {
+	return strings.IndexByte("([{", tok[len(tok)-1]) >= 0 && tokenClass(tok) == ClassPunctuation
+}
+
+// detokenize joins words like WriteText, but puts punctuation tokens
+// directly next to the words they belong to.
+func detokenize(words []string) string {
+	return join(words, true)
+}
+
+// WritePretty writes the words joined by detokenize on one line.
+func WritePretty(w io.Writer, words []string) error {
+	_, err := fmt.Fprintln(w, detokenize(words))
+	return err
+}
+
+// HasPunctTokens reports whether the chain knows punctuation-only tokens,
+// i.e. whether its corpus was tokenized with punctuation split off.
+func (c *Chain) HasPunctTokens() bool {
+	c.materialize()
+	for _, suf := range c.chain {
+		for _, s := range suf {
+			if tokenClass(s.word) == ClassPunctuation && s.word != `""` {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteSSML writes the words as an SSML document with one <s> element per
+// sentence.
+func WriteSSML(w io.Writer, words []string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<speak><p>")
+	open := false
+	for _, word := range words {
+		if word == ParagraphToken {
+			if open {
+				bw.WriteString("</s>")
+				open = false
+			}
+			bw.WriteString("</p><p>")
+			continue
+		}
+		if !open {
+			bw.WriteString("<s>")
+			open = true
+		} else {
+			bw.WriteByte(' ')
+		}
+		xml.EscapeText(bw, []byte(word))
+		if endsSentence(word) {
+			bw.WriteString("</s>")
+			open = false
+		}
+	}
+	if open {
+		bw.WriteString("</s>")
+	}
+	bw.WriteString("</p></speak>\n")
+	return bw.Flush()
+}
+
+// WriteTokensJSON writes the words as a TokenList.
+func WriteTokensJSON(w io.Writer, words []string) error {
+	list := TokenList{Tokens: make([]Token, len(words))}
+	for i, word := range words {
+		list.Tokens[i] = Token{word, tokenClass(word)}
+		if word == ParagraphToken {
+			list.Tokens[i].Text = "\n\n"
+		}
+	}
+	return writeJSON(w, list)
+}
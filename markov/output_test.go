@@ -0,0 +1,57 @@
+package markov
+
+import (
+	"bytes"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with the file testdata/name, or rewrites the
+// file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed; if that was meant, rerun with -update and review the diff.\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// TestOutputFormatsGolden pins the encodings of a seeded generation from a
+// model with punctuation tokens and paragraph breaks.
+func TestOutputFormatsGolden(t *testing.T) {
+	c := newChain(1)
+	words := strings.Fields(`the cat sat , and the dog ran . P the dog said " no " & sat . P the cat ran < home > .`)
+	for i, w := range words {
+		if w == "P" {
+			words[i] = ParagraphToken
+		}
+	}
+	addWords(c, words)
+	words = c.GenerateWords(40, GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+	for name, encode := range map[string]func(*bytes.Buffer, []string) error{
+		"generate.ssml.golden":        func(b *bytes.Buffer, w []string) error { return WriteSSML(b, w) },
+		"generate.tokens.json.golden": func(b *bytes.Buffer, w []string) error { return WriteTokensJSON(b, w) },
+	} {
+		var b bytes.Buffer
+		if err := encode(&b, words); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, name, b.Bytes())
+	}
+}
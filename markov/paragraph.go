@@ -0,0 +1,141 @@
+package markov
+
+import (
+	"bufio"
+	"math"
+	"math/rand"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scanWordsAndParagraphs returns a bufio.SplitFunc that splits like
+// bufio.ScanWords and also yields ParagraphToken wherever a blank line
+// separates two words. Blank lines before the first word are ignored.
+func scanWordsAndParagraphs() bufio.SplitFunc {
+	started := false
+	newlines := 0 // newlines in the white space before the next word
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		start := 0
+		for start < len(data) {
+			r, width := utf8.DecodeRune(data[start:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			if r == '\n' {
+				newlines++
+			}
+			start += width
+		}
+		if start == len(data) {
+			return start, nil, nil
+		}
+		if started && newlines >= 2 {
+			newlines = 0
+			return start, []byte(ParagraphToken), nil
+		}
+		newlines = 0
+		// Unlike bufio.ScanWords, leave the white space after the word
+		// alone: its newlines count towards the next blank line.
+		for end := start; end < len(data); {
+			r, width := utf8.DecodeRune(data[end:])
+			if unicode.IsSpace(r) {
+				started = true
+				return end, data[start:end], nil
+			}
+			end += width
+		}
+		if atEOF {
+			started = true
+			return len(data), data[start:], nil
+		}
+		return start, nil, nil
+	}
+}
+
+// countParagraph records a paragraph of n words in the paragraph length
+// distribution of c.
+func (c *Chain) countParagraph(n int) {
+	if n <= 0 {
+		return
+	}
+	if c.paragraphLengths == nil {
+		c.paragraphLengths = make(map[int]int)
+	}
+	c.paragraphLengths[n]++
+}
+
+// Bias applied to ParagraphToken by paragraphPlanner: its weight doubles
+// every paragraphSpread-th of the target length, within maxParagraphBias
+// of the unbiased weight either way.
+const (
+	paragraphSpread  = 4
+	maxParagraphBias = 64
+)
+
+// paragraphPlanner steers generation towards paragraphs whose lengths
+// follow the distribution recorded by Build. It draws a target length for
+// each paragraph and multiplies the weight of ParagraphToken by a factor
+// that grows smoothly as the paragraph approaches and passes the target.
+type paragraphPlanner struct {
+	lengths []int // distinct paragraph lengths, increasing
+	cum     []int // cumulative counts of lengths
+	target  int
+	count   int // words in the current paragraph
+}
+
+// newParagraphPlanner returns a planner for the lengths recorded in c, or
+// nil if c has none.
+func (c *Chain) newParagraphPlanner(r *rand.Rand) *paragraphPlanner {
+	if len(c.paragraphLengths) == 0 {
+		return nil
+	}
+	pl := &paragraphPlanner{}
+	for n := range c.paragraphLengths {
+		pl.lengths = append(pl.lengths, n)
+	}
+	sort.Ints(pl.lengths)
+	total := 0
+	for _, n := range pl.lengths {
+		total += c.paragraphLengths[n]
+		pl.cum = append(pl.cum, total)
+	}
+	pl.draw(r)
+	return pl
+}
+
+// draw starts a new paragraph with a freshly sampled target length.
+func (pl *paragraphPlanner) draw(r *rand.Rand) {
+	i := sort.SearchInts(pl.cum, r.Intn(pl.cum[len(pl.cum)-1])+1)
+	pl.target = pl.lengths[i]
+	pl.count = 0
+}
+
+// bias adjusts the weights w of choices in place.
+func (pl *paragraphPlanner) bias(w []int, choices []Suffix) {
+	at := -1
+	for i, s := range choices {
+		if s.word == ParagraphToken {
+			at = i
+		}
+	}
+	if at < 0 {
+		return
+	}
+	spread := math.Max(1, float64(pl.target)/paragraphSpread)
+	f := math.Exp2(float64(pl.count-pl.target) / spread)
+	f = math.Max(1.0/maxParagraphBias, math.Min(maxParagraphBias, f))
+	for i := range w {
+		w[i] *= maxParagraphBias
+	}
+	w[at] = int(float64(w[at]) * f)
+}
+
+// advance accounts for the word just generated.
+func (pl *paragraphPlanner) advance(word string, r *rand.Rand) {
+	if word == ParagraphToken {
+		pl.draw(r)
+		return
+	}
+	pl.count++
+}
@@ -0,0 +1,107 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// paragraphCorpus returns n paragraphs of one to eight sentences of three
+// to seven words each, counting the sentence end "end.", whose lengths are
+// spread evenly rather than geometrically, as a chain would make them.
+func paragraphCorpus(n int) string {
+	r := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		for j := 1 + r.Intn(8); j > 0; j-- {
+			for k := 2 + r.Intn(5); k > 0; k-- {
+				b.WriteString("w")
+				b.WriteByte(byte('a' + r.Intn(20)))
+				b.WriteByte(' ')
+			}
+			b.WriteString("end. ")
+		}
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// paragraphLengths returns the lengths of the paragraphs of words, leaving
+// out the last one, which the word limit cut short.
+func paragraphLengths(words []string) map[int]int {
+	lengths := make(map[int]int)
+	n := 0
+	for _, w := range words {
+		if w == ParagraphToken {
+			lengths[n]++
+			n = 0
+			continue
+		}
+		n++
+	}
+	return lengths
+}
+
+// quartileShares returns the shares of the lengths of h that fall in each
+// of the four ranges cut by cuts.
+func quartileShares(h map[int]int, cuts [3]int) [4]float64 {
+	var shares [4]float64
+	total := 0
+	for n, k := range h {
+		shares[sort.SearchInts(cuts[:], n)] += float64(k)
+		total += k
+	}
+	for i := range shares {
+		shares[i] /= float64(total)
+	}
+	return shares
+}
+
+// TestParagraphLengths checks that ParagraphLengths brings the lengths of
+// generated paragraphs close to those of the corpus. Without it, every
+// sentence end is as likely to end a paragraph, so that lengths spread
+// geometrically, with many paragraphs much shorter and longer than any of
+// the corpus.
+func TestParagraphLengths(t *testing.T) {
+	c := newChain(1)
+	opts := BuildOptions{Paragraphs: true, NoEndToken: true}
+	if _, err := c.BuildReaderOpts("corpus", strings.NewReader(paragraphCorpus(400)), opts); err != nil {
+		t.Fatal(err)
+	}
+	// Compare histograms over the quartiles of the corpus lengths, where
+	// the corpus has about a quarter of its paragraphs each.
+	var lengths []int
+	for n, k := range c.paragraphLengths {
+		for ; k > 0; k-- {
+			lengths = append(lengths, n)
+		}
+	}
+	sort.Ints(lengths)
+	q := len(lengths) / 4
+	cuts := [3]int{lengths[q], lengths[2*q], lengths[3*q]}
+	want := quartileShares(c.paragraphLengths, cuts)
+	// distance is the total variation distance of the histograms: the
+	// share of paragraphs that would have to change range for them to
+	// agree.
+	distance := func(planned bool) ([4]float64, float64) {
+		words := c.GenerateWords(50000, GenerateOptions{Rand: rand.New(rand.NewSource(1)), ParagraphLengths: planned})
+		got := quartileShares(paragraphLengths(words), cuts)
+		d := 0.0
+		for i := range got {
+			d += math.Abs(got[i] - want[i])
+		}
+		return got, d / 2
+	}
+	plainShares, plain := distance(false)
+	plannedShares, planned := distance(true)
+	t.Logf("shares of the quartiles %v of the corpus lengths: corpus %.2f, unplanned %.2f, planned %.2f", cuts, want, plainShares, plannedShares)
+	// A coarse bound: the planner is soft, and overshoots somewhat.
+	if planned > 0.1 {
+		t.Errorf("planned paragraph lengths are %.3f off those of the corpus, want at most 0.1", planned)
+	}
+	if plain < planned+0.03 {
+		t.Errorf("planning paragraphs brought their lengths from %.3f off the corpus only to %.3f", plain, planned)
+	}
+}
@@ -0,0 +1,196 @@
+package markov
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// maxBridgeWords and maxBridgeStates bound the search for a bridge
+// between two chunks of GenerateParallel.
+const (
+	maxBridgeWords  = 8
+	maxBridgeStates = 20000
+)
+
+// GenerateParallel generates n words in k chunks at the same time.
+// Every chunk starts at a prefix picked at random from the chain; the end
+// of one chunk is joined to the start of the next by the shortest sequence
+// of transitions leading from one to the other, or by a ParagraphToken
+// when no short bridge exists. The result is good bulk text, but it is not
+// distributed like text sampled in one sequence. Bridges longer or shorter
+// than the start they lead to are made up for at the end, so that the
+// text has n words unless a chunk comes to a dead end. A chain no chunk
+// generates a word from gives nil, as GenerateWords does. A budget of MaxBytes or
+// MaxRunes cannot be split between chunks, so with one it generates in one
+// sequence.
+func (c *Chain) GenerateParallel(n, k int, opts GenerateOptions) []string {
+	if k <= 1 || n < 2*k || newBudget(opts) != nil {
+		return c.GenerateWords(n, opts)
+	}
+	c.materialize()
+	defer c.beginRead()()
+	r := orGlobal(opts.Rand)
+	starts := c.chunkStarts(k, r)
+
+	// Each chunk gets its own generator, seeded in order from r, so a
+	// seeded run stays reproducible despite the goroutines.
+	type chunk struct {
+		words  []string
+		end    Prefix
+		bridge []string // to the start of the next chunk, see bridge
+	}
+	chunks := make([]chunk, k)
+	var wg sync.WaitGroup
+	for i := range chunks {
+		size := n / k
+		if i == k-1 {
+			size = n - (k-1)*(n/k)
+		}
+		cr := rand.New(rand.NewSource(r.Int63()))
+		wg.Add(1)
+		go func(i, size int, cr *rand.Rand) {
+			defer wg.Done()
+			p := append(Prefix(nil), starts[i]...)
+			// The words of the start are written before the chunk, but
+			// for the empty slots of the start state.
+			chunks[i].words = c.generate(nil, p, size-len(c.startWords(p)), opts, cr)
+			chunks[i].end = p
+			// Searching the bridges is as costly as generating the
+			// chunks, so every goroutine searches its own.
+			if i < k-1 {
+				chunks[i].bridge = c.bridge(p, starts[i+1])
+			}
+		}(i, size, cr)
+	}
+	wg.Wait()
+
+	// Chunks of no words, from a dead end or an empty chain, are left out
+	// with the separators around them; a bridge only joins a chunk to the
+	// one written before it.
+	var words []string
+	last := -1
+	for i, ch := range chunks {
+		lead, sep := c.startWords(starts[i]), i > 0
+		if i > 0 && last == i-1 && chunks[i-1].bridge != nil {
+			lead, sep = chunks[i-1].bridge, false
+		}
+		if len(lead) == 0 && len(ch.words) == 0 {
+			continue
+		}
+		if sep && last >= 0 {
+			words = append(words, ParagraphToken)
+		}
+		words = c.appendFilled(words, lead, r)
+		words = append(words, ch.words...)
+		last = i
+	}
+	if last < 0 {
+		return nil
+	}
+	// Bridges need not be as long as the start they lead to: one into a
+	// start overlapping the end of the chunk before is shorter.
+	if len(words) > n {
+		words = words[:n]
+	} else if len(words) < n {
+		words = append(words, c.generate(nil, chunks[k-1].end, n-len(words), opts, r)...)
+	}
+	c.restoreCase(words, r)
+	return words
+}
+
+// chunkStarts picks k prefixes to start chunks from. The first chunk starts
+// where Generate does; the others start at random prefixes not containing
+// empty slots.
+func (c *Chain) chunkStarts(k int, r *rand.Rand) []Prefix {
+	keys := c.interiorKeys()
+	starts := []Prefix{c.startPrefix()}
+	for len(starts) < k {
+		if len(keys) == 0 {
+			starts = append(starts, c.startPrefix())
+			continue
+		}
+		starts = append(starts, splitKey(keys[r.Intn(len(keys))]))
+	}
+	return starts
+}
+
+// interiorKeys returns the prefixes of c that contain no empty slot, in
+// increasing order, computed on first use and kept until the chain
+// changes. Callers must not modify it.
+func (c *Chain) interiorKeys() []string {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.interior == nil {
+		keys := []string{}
+		for _, key := range sortedKeys(c.chain) {
+			if !strings.Contains(keySep+key+keySep, keySep+keySep) {
+				keys = append(keys, key)
+			}
+		}
+		c.interior = keys
+	}
+	return c.interior
+}
+
+// startWords returns the words of a start prefix that belong in the output.
+func (c *Chain) startWords(p Prefix) []string {
+	var words []string
+	for _, w := range p {
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// appendFilled appends raw chain words to words, filling placeholders.
+func (c *Chain) appendFilled(words, raw []string, r *rand.Rand) []string {
+	for _, w := range raw {
+		words = append(words, c.fill(w, r))
+	}
+	return words
+}
+
+// bridge returns the shortest word sequence that leads from prefix from to
+// prefix to through observed transitions, or nil if there is none within
+// maxBridgeWords words. The sequence ends with the words of to.
+func (c *Chain) bridge(from, to Prefix) []string {
+	type state struct {
+		key  string
+		prev int
+		word string
+	}
+	target := to.key()
+	states := []state{{key: from.key(), prev: -1}}
+	seen := map[string]bool{states[0].key: true}
+	for head, depth, levelEnd := 0, 0, 1; head < len(states) && depth < maxBridgeWords; depth++ {
+		for ; head < levelEnd; head++ {
+			cur := states[head]
+			p := splitKey(cur.key)
+			for _, s := range c.chain[cur.key] {
+				next := c.shiftedKey(p, s.word)
+				if seen[next] {
+					continue
+				}
+				seen[next] = true
+				states = append(states, state{next, head, s.word})
+				if next == target {
+					var path []string
+					for i := len(states) - 1; states[i].prev >= 0; i = states[i].prev {
+						path = append(path, states[i].word)
+					}
+					for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+						path[i], path[j] = path[j], path[i]
+					}
+					return path
+				}
+				if len(states) >= maxBridgeStates {
+					return nil
+				}
+			}
+		}
+		levelEnd = len(states)
+	}
+	return nil
+}
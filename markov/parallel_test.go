@@ -0,0 +1,104 @@
+package markov
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGenerateParallelLength(t *testing.T) {
+	c := newChain(2)
+	addWords(c, strings.Fields(strings.Repeat("a b c d e a b c f ", 4)+"a b"))
+	for _, k := range []int{2, 3, 4} {
+		for _, n := range []int{8, 25, 100} {
+			words := c.GenerateParallel(n, k, GenerateOptions{Rand: rand.New(rand.NewSource(int64(n * k)))})
+			if len(words) != n {
+				t.Errorf("GenerateParallel(%d, %d) = %d words %q", n, k, len(words), words)
+			}
+		}
+	}
+}
+
+// TestGenerateParallelSeams checks every transition of texts generated in
+// chunks: each word must follow the words before it in the chain, but
+// where a paragraph break joins two chunks no bridge was found for. The
+// corpus has two parts with no transition between them, so that some
+// seams cannot be bridged.
+func TestGenerateParallelSeams(t *testing.T) {
+	c := newChain(2)
+	addWords(c, strings.Fields(strings.Repeat("a b c d e a b c f ", 4)+"a b"))
+	addWords(c, strings.Fields(strings.Repeat("x y z x y w ", 4)+"x y"))
+	breaks := 0
+	for seed := int64(1); seed <= 50; seed++ {
+		words := c.GenerateParallel(60, 4, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		if len(words) != 60 {
+			t.Errorf("seed %d: %d words, want 60", seed, len(words))
+		}
+		// The words of a chunk start after a break need no predecessor.
+		free := c.prefixLen
+		for i, w := range words {
+			switch {
+			case w == ParagraphToken:
+				breaks++
+				free = c.prefixLen + 1
+			case free > 0:
+			case !c.follows(Prefix(words[i-c.prefixLen:i]), w):
+				t.Errorf("seed %d: %q does not follow %q in %q", seed, w, words[i-c.prefixLen:i], words)
+			}
+			free--
+		}
+	}
+	if breaks == 0 {
+		t.Error("no seam was joined by a paragraph break")
+	}
+}
+
+// TestGenerateParallelEmpty checks that a chain no chunk generates words
+// from gives nil, and that chunks starting at a dead end are left out
+// without a paragraph break for them.
+func TestGenerateParallelEmpty(t *testing.T) {
+	if words := newChain(2).GenerateParallel(40, 4, GenerateOptions{}); words != nil {
+		t.Errorf("GenerateParallel on an empty chain = %q, want nil", words)
+	}
+
+	// The start state has no suffixes, so the first chunk is empty.
+	c := newChain(2)
+	c.add(Prefix{"x", "y"}.key(), "z", 1)
+	for seed := int64(1); seed <= 10; seed++ {
+		words := c.GenerateParallel(40, 4, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		if len(words) == 0 || words[0] == ParagraphToken || words[len(words)-1] == ParagraphToken {
+			t.Errorf("seed %d: %q, want words without a break at either end", seed, words)
+		}
+		for i := 1; i < len(words); i++ {
+			if words[i] == ParagraphToken && words[i-1] == ParagraphToken {
+				t.Errorf("seed %d: %q has a break around an empty chunk", seed, words)
+			}
+		}
+	}
+}
+
+// follows reports whether word follows p in c.
+func (c *Chain) follows(p Prefix, word string) bool {
+	for _, s := range c.Suffixes(p) {
+		if s.word == word {
+			return true
+		}
+	}
+	return false
+}
+
+// BenchmarkGenerateParallel generates 20,000 words in 1 to 8 chunks. Every
+// chunk and the bridge after it is one goroutine, so on as many cores as
+// chunks the time should fall almost in proportion; compare with -cpu.
+func BenchmarkGenerateParallel(b *testing.B) {
+	c := synthChain(b, 200000)
+	for _, k := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("chunks=%d", k), func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < b.N; i++ {
+				c.GenerateParallel(20000, k, GenerateOptions{Rand: r})
+			}
+		})
+	}
+}
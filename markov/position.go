@@ -0,0 +1,81 @@
+package markov
+
+import (
+	"math"
+	"strconv"
+)
+
+// PositionBuckets is the number of buckets of a position histogram.
+const PositionBuckets = 10
+
+// positionHist counts the occurrences of a prefix by relative position in
+// its document: bucket i covers the i-th tenth. Counts saturate at the
+// maximum of a uint16 to keep the histograms of big models small.
+type positionHist [PositionBuckets]uint16
+
+// addPosition counts n occurrences of key in bucket b.
+func (c *Chain) addPosition(key string, b, n int) {
+	if c.positions == nil {
+		c.positions = make(map[string]positionHist)
+	}
+	h := c.positions[key]
+	if m := int(h[b]) + n; m < math.MaxUint16 {
+		h[b] = uint16(m)
+	} else {
+		h[b] = math.MaxUint16
+	}
+	c.positions[key] = h
+}
+
+// mergePositions adds the histogram h to that of key.
+func (c *Chain) mergePositions(key string, h positionHist) {
+	for b, n := range h {
+		if n > 0 {
+			c.addPosition(key, b, int(n))
+		}
+	}
+}
+
+// HasPositions reports whether c was built with BuildOptions.Positions.
+func (c *Chain) HasPositions() bool {
+	return c.positions != nil
+}
+
+// PositionHistogram returns how often prefix occurred in each tenth of its
+// documents, from the first to the last. It is all zeros for prefixes the
+// chain does not know and for chains built without
+// BuildOptions.Positions.
+func (c *Chain) PositionHistogram(prefix []string) [PositionBuckets]int {
+	var out [PositionBuckets]int
+	for b, n := range c.positions[Prefix(prefix).key()] {
+		out[b] = int(n)
+	}
+	return out
+}
+
+// positionRecord returns the fields of the model file record for the
+// histogram of key.
+func positionRecord(key string, h positionHist) []string {
+	fields := append([]string{"position"}, splitKey(key)...)
+	for _, n := range h {
+		fields = append(fields, strconv.Itoa(int(n)))
+	}
+	return fields
+}
+
+// readPositionRecord stores a position record, given its fields after the
+// record name.
+func (c *Chain) readPositionRecord(fields []string) {
+	if len(fields) != c.prefixLen+PositionBuckets {
+		return
+	}
+	var h positionHist
+	for b := range h {
+		n, err := strconv.ParseUint(fields[c.prefixLen+b], 10, 16)
+		if err != nil {
+			return
+		}
+		h[b] = uint16(n)
+	}
+	c.mergePositions(Prefix(fields[:c.prefixLen]).key(), h)
+}
@@ -0,0 +1,99 @@
+package markov
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// positionDoc returns a document of n words that opens with "once upon",
+// closes with "the end." and has n-4 words of "la" between.
+func positionDoc(n int) string {
+	return "once upon " + strings.Repeat("la ", n-4) + "the end."
+}
+
+// TestPositionHistogram builds a chain from documents with an opening, a
+// closing and a uniform filler, and checks where each occurs.
+func TestPositionHistogram(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, doc := range []string{positionDoc(20), positionDoc(20), positionDoc(20)} {
+		name := filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(name, []byte(doc), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	c := newChain(1)
+	if _, err := c.BuildOpts(files, BuildOptions{Positions: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.HasPositions() {
+		t.Fatal("a chain built with Positions has no histograms")
+	}
+	// Each document has 21 tokens with its EndToken, and the prefix
+	// before token j falls in bucket j*10/21.
+	for _, tt := range []struct {
+		prefix string
+		want   [PositionBuckets]int
+	}{
+		{"once", [PositionBuckets]int{3, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"upon", [PositionBuckets]int{3, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"la", [PositionBuckets]int{0, 6, 6, 6, 6, 6, 6, 6, 6, 0}},
+		{"the", [PositionBuckets]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 3}},
+		{"end.", [PositionBuckets]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 3}},
+		{"unknown", [PositionBuckets]int{}},
+	} {
+		if got := c.PositionHistogram([]string{tt.prefix}); got != tt.want {
+			t.Errorf("PositionHistogram(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	// Positions are relative: in a document twice as long, the opening
+	// and the closing stay in the first and the last bucket.
+	long := newChain(1)
+	if _, err := long.BuildReaderOpts("long.txt", strings.NewReader(positionDoc(40)), BuildOptions{Positions: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := long.PositionHistogram([]string{"once"}); got[0] != 1 {
+		t.Errorf("in a long document, once occurs at %v, want the first bucket", got)
+	}
+	if got := long.PositionHistogram([]string{"end."}); got[PositionBuckets-1] != 1 {
+		t.Errorf("in a long document, end. occurs at %v, want the last bucket", got)
+	}
+
+	// The histograms survive every format.
+	for name, roundTrip := range formats {
+		back, err := roundTrip(c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, want := back.PositionHistogram([]string{"la"}), c.PositionHistogram([]string{"la"}); got != want {
+			t.Errorf("%s: histogram of la read back as %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestPositionHistogramAbsent checks that a chain built without Positions
+// has no histograms, and writes no records of them.
+func TestPositionHistogramAbsent(t *testing.T) {
+	c := newChain(1)
+	if _, err := c.BuildReaderOpts("doc.txt", strings.NewReader(positionDoc(20)), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.HasPositions() {
+		t.Error("a chain built without Positions has histograms")
+	}
+	if got := c.PositionHistogram([]string{"once"}); got != ([PositionBuckets]int{}) {
+		t.Errorf("PositionHistogram(once) = %v, want all zeros", got)
+	}
+	var b bytes.Buffer
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "position") {
+		t.Errorf("the model file of a chain without histograms has position records:\n%s", b.String())
+	}
+}
@@ -0,0 +1,71 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MissingCapability is a generation option the model has no data for.
+type MissingCapability struct {
+	Option string // the option requested, as a generate flag
+	Needs  string // what the model lacks
+	Fix    string // how to build a model that has it
+}
+
+func (m MissingCapability) String() string {
+	return fmt.Sprintf("%s needs %s (%s)", m.Option, m.Needs, m.Fix)
+}
+
+// PreflightError lists the requested generation options that would
+// silently do nothing with a model.
+type PreflightError struct {
+	Missing []MissingCapability
+}
+
+func (e *PreflightError) Error() string {
+	msgs := make([]string, len(e.Missing))
+	for i, m := range e.Missing {
+		msgs[i] = m.String()
+	}
+	return "model lacks data for the options given: " + strings.Join(msgs, "; ")
+}
+
+// Preflight checks that c has the data every option of opts relies on,
+// and returns a *PreflightError listing each one it lacks. Generation
+// itself never fails on such options, it just ignores them, so callers
+// that want to know should call Preflight first, or PreflightLenient to
+// be told without failing.
+func (c *Chain) Preflight(opts GenerateOptions) error {
+	if missing := c.missingCapabilities(opts); missing != nil {
+		return &PreflightError{missing}
+	}
+	return nil
+}
+
+// PreflightLenient is the lenient mode of Preflight: it passes a message
+// for each option of opts that c has no data for to warn, and generation
+// goes on ignoring those options.
+func (c *Chain) PreflightLenient(opts GenerateOptions, warn func(string)) {
+	for _, m := range c.missingCapabilities(opts) {
+		warn(m.String())
+	}
+}
+
+// missingCapabilities returns the options of opts that c has no data for,
+// in the order of the fields of GenerateOptions.
+func (c *Chain) missingCapabilities(opts GenerateOptions) []MissingCapability {
+	var missing []MissingCapability
+	if opts.ParagraphLengths && len(c.paragraphLengths) == 0 {
+		missing = append(missing, MissingCapability{"-paragraph-lengths", "paragraph length statistics", "build with read -paragraphs"})
+	}
+	if opts.SentenceStart && !c.HasSentenceStarts() {
+		missing = append(missing, MissingCapability{"-sentence-start", "recorded sentence starts", "build with read -sentence-starts"})
+	}
+	if opts.Backoff && c.prefixLen < 2 {
+		missing = append(missing, MissingCapability{"-backoff", "prefixes of two words or more to shorten", "build with a prefix length of 2 or more"})
+	}
+	if opts.Smooth && c.Smoothing() == 0 {
+		missing = append(missing, MissingCapability{"-smoothing", "a smoothing alpha", "set a positive one with SetSmoothing"})
+	}
+	return missing
+}
@@ -0,0 +1,80 @@
+package markov
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	build := func(opts BuildOptions) *Chain {
+		c := newChain(1)
+		if _, err := c.BuildReaderOpts("", strings.NewReader("one line.\n\nanother line."), opts); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	plain, paragraphs := build(BuildOptions{}), build(BuildOptions{Paragraphs: true})
+
+	err := plain.Preflight(GenerateOptions{ParagraphLengths: true})
+	var pe *PreflightError
+	if !errors.As(err, &pe) || len(pe.Missing) != 1 || pe.Missing[0].Option != "-paragraph-lengths" {
+		t.Fatalf("Preflight of -paragraph-lengths without paragraphs = %v, want it named", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "-paragraph-lengths") || !strings.Contains(msg, "read -paragraphs") {
+		t.Errorf("error %q does not name the option and the build flag providing it", msg)
+	}
+	if err := paragraphs.Preflight(GenerateOptions{ParagraphLengths: true}); err != nil {
+		t.Errorf("Preflight with paragraph lengths recorded = %v", err)
+	}
+	if err := plain.Preflight(GenerateOptions{Temperature: 0.5, TopK: 3}); err != nil {
+		t.Errorf("Preflight of options any model supports = %v", err)
+	}
+}
+
+// TestPreflightMismatches has a row for every option Preflight checks: the
+// model lacking its data fails naming the option and the fix, in lenient
+// mode only warns, and the model having it passes.
+func TestPreflightMismatches(t *testing.T) {
+	build := func(prefixLen int, opts BuildOptions) *Chain {
+		c := newChain(prefixLen)
+		if _, err := c.BuildReaderOpts("", strings.NewReader("one line.\n\nanother line."), opts); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	smoothed := build(1, BuildOptions{})
+	if err := smoothed.SetSmoothing(1); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		opts         GenerateOptions
+		lacking, has *Chain
+		option, fix  string
+	}{
+		{GenerateOptions{ParagraphLengths: true}, build(1, BuildOptions{}), build(1, BuildOptions{Paragraphs: true}), "-paragraph-lengths", "read -paragraphs"},
+		{GenerateOptions{SentenceStart: true}, build(1, BuildOptions{}), build(1, BuildOptions{SentenceStarts: true}), "-sentence-start", "read -sentence-starts"},
+		{GenerateOptions{Backoff: true}, build(1, BuildOptions{}), build(2, BuildOptions{}), "-backoff", "prefix length of 2"},
+		{GenerateOptions{Smooth: true}, build(1, BuildOptions{}), smoothed, "-smoothing", "SetSmoothing"},
+	} {
+		err := tt.lacking.Preflight(tt.opts)
+		var pe *PreflightError
+		if !errors.As(err, &pe) || len(pe.Missing) != 1 || pe.Missing[0].Option != tt.option || !strings.Contains(err.Error(), tt.fix) {
+			t.Errorf("%s: Preflight = %v, want an error naming it and %q", tt.option, err, tt.fix)
+		}
+		var warnings []string
+		tt.lacking.PreflightLenient(tt.opts, func(msg string) { warnings = append(warnings, msg) })
+		if len(warnings) != 1 || !strings.HasPrefix(warnings[0], tt.option+" needs") {
+			t.Errorf("%s: PreflightLenient warned %q, want one warning naming it", tt.option, warnings)
+		}
+		if err := tt.has.Preflight(tt.opts); err != nil {
+			t.Errorf("%s: Preflight of a model with the data = %v", tt.option, err)
+		}
+	}
+
+	all := GenerateOptions{ParagraphLengths: true, SentenceStart: true, Backoff: true, Smooth: true}
+	var pe *PreflightError
+	if err := build(1, BuildOptions{}).Preflight(all); !errors.As(err, &pe) || len(pe.Missing) != 4 {
+		t.Errorf("Preflight of every option on a plain model = %v, want all four in one error", err)
+	}
+}
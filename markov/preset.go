@@ -0,0 +1,44 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SetPreset stores a named list of command-line flags for generating from
+// c, such as "-start-weight=0.5", which WriteFreTable saves with the
+// model. The package does not interpret the flags; it is up to the
+// program reading them back with Preset. Names and flags must not contain
+// white space. A preset without flags removes the preset.
+func (c *Chain) SetPreset(name string, flags []string) error {
+	defer c.beginWrite()()
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("bad preset name %q", name)
+	}
+	for _, f := range flags {
+		if f == "" || strings.IndexFunc(f, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("preset %s: flag %q is empty or contains white space", name, f)
+		}
+	}
+	if len(flags) == 0 {
+		delete(c.presets, name)
+		return nil
+	}
+	if c.presets == nil {
+		c.presets = make(map[string][]string)
+	}
+	c.presets[name] = append([]string(nil), flags...)
+	return nil
+}
+
+// Preset returns the flags stored under name by SetPreset.
+func (c *Chain) Preset(name string) ([]string, bool) {
+	flags, ok := c.presets[name]
+	return append([]string(nil), flags...), ok
+}
+
+// Presets returns the names of the presets of c in increasing order.
+func (c *Chain) Presets() []string {
+	return sortedKeys(c.presets)
+}
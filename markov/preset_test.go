@@ -0,0 +1,56 @@
+package markov
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	c := TinyModel()
+	if err := c.SetPreset("casual", []string{"-start-weight=0.5", "-pretty"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPreset("exact", []string{"-greedy"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		flags []string
+	}{
+		{"", []string{"-pretty"}},
+		{"two words", []string{"-pretty"}},
+		{"bad", []string{"-match=a b"}},
+		{"bad", []string{""}},
+	} {
+		if err := c.SetPreset(tt.name, tt.flags); err == nil {
+			t.Errorf("SetPreset(%q, %q) succeeded", tt.name, tt.flags)
+		}
+	}
+
+	for name, roundTrip := range formats {
+		back, err := roundTrip(c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := back.Presets(); !reflect.DeepEqual(got, []string{"casual", "exact"}) {
+			t.Errorf("%s: presets %q read back, want casual and exact", name, got)
+		}
+		if flags, ok := back.Preset("casual"); !ok || !reflect.DeepEqual(flags, []string{"-start-weight=0.5", "-pretty"}) {
+			t.Errorf("%s: preset casual read back as %q", name, flags)
+		}
+	}
+
+	// The flags returned are a copy.
+	flags, _ := c.Preset("exact")
+	flags[0] = "-pretty"
+	if flags, _ := c.Preset("exact"); flags[0] != "-greedy" {
+		t.Errorf("changing the flags of Preset changed the preset")
+	}
+	// A preset without flags is removed.
+	if err := c.SetPreset("exact", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Preset("exact"); ok || !reflect.DeepEqual(c.Presets(), []string{"casual"}) {
+		t.Errorf("presets %q after removing exact, want casual", c.Presets())
+	}
+}
@@ -0,0 +1,71 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadWordFrequencies reads a word frequency list with one "word<TAB>count"
+// pair per line. Blank lines are skipped and counts of repeated words are
+// summed.
+func ReadWordFrequencies(r io.Reader) (map[string]int, error) {
+	freq := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected word<TAB>count, got %q", line, text)
+		}
+		word := fields[0]
+		if word == "" || strings.ContainsAny(word, " \t\n\r\v\f"+keySep) {
+			return nil, fmt.Errorf("line %d: word %q is empty or contains white space or a NUL byte", line, word)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("line %d: expected non-negative integer count, got %q", line, fields[1])
+		}
+		freq[word] += n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return freq, nil
+}
+
+// ReadWordFrequencyFile is ReadWordFrequencies on the named file.
+func ReadWordFrequencyFile(name string) (map[string]int, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	freq, err := ReadWordFrequencies(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return freq, nil
+}
+
+// SetUnigramPrior attaches a unigram distribution to c. Whenever generation
+// reaches a prefix c has no suffixes for, the next word is drawn from the
+// prior instead of stopping. The prior is saved with the model; a nil or
+// empty map removes it.
+func (c *Chain) SetUnigramPrior(freq map[string]int) {
+	defer c.beginWrite()()
+	c.prior = nil
+	for word, n := range freq {
+		if n > 0 {
+			c.prior = append(c.prior, Suffix{word, n})
+		}
+	}
+	sort.Slice(c.prior, func(i, j int) bool { return c.prior[i].word < c.prior[j].word })
+}
@@ -0,0 +1,41 @@
+package markov
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadWordFrequencies(t *testing.T) {
+	freq, err := ReadWordFrequencies(strings.NewReader("the\t10\n\nof\t 4\r\nthe\t2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(freq) != 2 || freq["the"] != 12 || freq["of"] != 4 {
+		t.Errorf("ReadWordFrequencies = %v, want the 12 and of 4", freq)
+	}
+	for _, bad := range []string{"the 10\n", "the\t-1\n", "the\tmany\n", "\t3\n", "a b\t3\n", "the\t1\t2\n"} {
+		if _, err := ReadWordFrequencies(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("ReadWordFrequencies(%q) = %v, want an error on line 1", bad, err)
+		}
+	}
+}
+
+// TestPriorRoundTrip checks that a prior attached to a model is saved with
+// it and still answers for unknown prefixes after loading, in every format.
+func TestPriorRoundTrip(t *testing.T) {
+	c := newChain(1)
+	addWords(c, []string{"a", "b", "a", "c"})
+	c.SetUnigramPrior(map[string]int{"b": 1, "x": 3})
+	for name, roundTrip := range formats {
+		got, err := roundTrip(c)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if p := got.Probability([]string{"never"}, "x"); p != 0.75 {
+			t.Errorf("%s: P(x | never) = %v after loading, want 0.75 from the prior", name, p)
+		}
+		if p := got.Probability([]string{"a"}, "b"); p != 0.5 {
+			t.Errorf("%s: P(b | a) = %v after loading, want 0.5 from the chain", name, p)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package markov
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// SuffixProbability is a word that may follow a prefix, see Distribution.
+type SuffixProbability struct {
+	Word        string  `json:"word"`
+	Count       int     `json:"count"` // times it followed the prefix in the corpus
+	Probability float64 `json:"probability"`
+}
+
+// Probability returns the probability that word follows prefix in c: the
+// frequency of word after prefix over the total frequency of its
+// suffixes, smoothed if SetSmoothing set an alpha. Words never seen after
+// prefix get 0, or with smoothing the share alpha gives every word of the
+// vocabulary; words outside the vocabulary are never smoothed. For a prefix c
+// does not know the word is drawn from the unigram prior, see
+// SetUnigramPrior, as generation draws it, and its probability is that of
+// the prior, smoothed the same way. Without a prior it returns NaN, with or
+// without smoothing, so that an unknown context can be told from an
+// impossible word with math.IsNaN. Prefix is read as GenerateFrom reads
+// its seed: its last prefix-length words count, and fewer words are the
+// start of a document. Callers of a chain opened with OpenFreTableMmap
+// should check Err afterwards, as after generating.
+func (c *Chain) Probability(prefix []string, word string) float64 {
+	c.materialize() // recorded for Err
+	defer c.beginRead()()
+	_, suf := c.context(prefix)
+	if len(suf) == 0 {
+		suf = c.prior
+	}
+	if len(suf) == 0 {
+		return math.NaN()
+	}
+	if c.caseStats != nil {
+		word = strings.ToLower(word)
+	}
+	return c.probability(suf, word)
+}
+
+// probability is Probability for the suffixes suf of a known prefix.
+func (c *Chain) probability(suf []Suffix, word string) float64 {
+	total, count := 0, 0
+	for _, s := range suf {
+		total += s.frequency
+		if s.word == word {
+			count = s.frequency
+		}
+	}
+	if c.smoothing == 0 {
+		return float64(count) / float64(total)
+	}
+	vocab := c.cachedVocabulary()
+	denom := float64(total) + c.smoothing*float64(len(vocab))
+	if _, ok := vocab[word]; !ok {
+		return float64(count) / denom // ParagraphToken, which is not a word
+	}
+	return (float64(count) + c.smoothing) / denom
+}
+
+// Distribution returns every word that may follow prefix, read as by
+// Probability, with its count and probability, most probable first and in
+// byte order among equals. With smoothing that is the whole vocabulary,
+// the words never seen after prefix with a count of 0. For a prefix c does
+// not know it is the unigram prior, with the counts of the prior, and nil
+// if c has no prior either. Err is to be checked as after Probability.
+func (c *Chain) Distribution(prefix []string) []SuffixProbability {
+	c.materialize() // recorded for Err
+	defer c.beginRead()()
+	_, suf := c.context(prefix)
+	if len(suf) == 0 {
+		suf = c.prior
+	}
+	if len(suf) == 0 {
+		return nil
+	}
+	total := 0
+	for _, s := range suf {
+		total += s.frequency
+	}
+	out := make([]SuffixProbability, 0, len(suf))
+	denom, alpha := float64(total), c.smoothing
+	var vocab map[string]int
+	if alpha > 0 {
+		vocab = c.cachedVocabulary()
+		denom += alpha * float64(len(vocab))
+	}
+	seen := make(map[string]bool, len(suf))
+	for _, s := range suf {
+		n := float64(s.frequency)
+		if _, ok := vocab[s.word]; ok {
+			n += alpha
+		}
+		out = append(out, SuffixProbability{s.word, s.frequency, n / denom})
+		seen[s.word] = true
+	}
+	if alpha > 0 {
+		for w := range vocab {
+			if !seen[w] {
+				out = append(out, SuffixProbability{w, 0, alpha / denom})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Probability != out[j].Probability {
+			return out[i].Probability > out[j].Probability
+		}
+		return out[i].Word < out[j].Word
+	})
+	return out
+}
+
+// cachedVocabulary returns Vocabulary, computed on first use and kept
+// until the chain changes. Callers must not modify it.
+func (c *Chain) cachedVocabulary() map[string]int {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.vocab == nil {
+		c.vocab = c.vocabulary()
+	}
+	return c.vocab
+}
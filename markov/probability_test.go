@@ -0,0 +1,48 @@
+package markov
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestProbability(t *testing.T) {
+	c := newChain(1)
+	addWords(c, []string{"a", "b", "a", "c", "a", "b"})
+
+	// A known prefix.
+	if got := c.Probability([]string{"a"}, "b"); got != 2.0/3 {
+		t.Errorf("P(b | a) = %v, want 2/3", got)
+	}
+	if got := c.Probability([]string{"a"}, "a"); got != 0 {
+		t.Errorf("P(a | a) = %v, want 0", got)
+	}
+	want := []SuffixProbability{{"b", 2, 2.0 / 3}, {"c", 1, 1.0 / 3}}
+	if got := c.Distribution([]string{"a"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Distribution(a) = %v, want %v", got, want)
+	}
+
+	// An unknown prefix without a prior.
+	if got := c.Probability([]string{"zzz"}, "b"); !math.IsNaN(got) {
+		t.Errorf("P(b | zzz) = %v without a prior, want NaN", got)
+	}
+	if got := c.Distribution([]string{"zzz"}); got != nil {
+		t.Errorf("Distribution(zzz) = %v without a prior, want nil", got)
+	}
+
+	// An unknown prefix with a prior.
+	c.SetUnigramPrior(map[string]int{"b": 1, "x": 3})
+	if got := c.Probability([]string{"zzz"}, "x"); got != 0.75 {
+		t.Errorf("P(x | zzz) = %v with a prior, want 0.75", got)
+	}
+	if got := c.Probability([]string{"zzz"}, "c"); got != 0 {
+		t.Errorf("P(c | zzz) = %v with a prior, want 0", got)
+	}
+	want = []SuffixProbability{{"x", 3, 0.75}, {"b", 1, 0.25}}
+	if got := c.Distribution([]string{"zzz"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Distribution(zzz) = %v with a prior, want %v", got, want)
+	}
+	if got := c.Probability([]string{"a"}, "b"); got != 2.0/3 {
+		t.Errorf("P(b | a) = %v with a prior, want 2/3 still", got)
+	}
+}
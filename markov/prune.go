@@ -0,0 +1,118 @@
+package markov
+
+import "strings"
+
+// PruneReport tells what Prune or PruneShare removed.
+type PruneReport struct {
+	SuffixEntries int `json:"suffix_entries"` // suffix entries dropped
+	Tokens        int `json:"tokens"`         // sum of their frequencies
+	Prefixes      int `json:"prefixes"`       // prefixes left without suffixes and deleted
+}
+
+// Prune removes the suffix entries of c seen fewer than minCount times,
+// such as the one-off typos that make up much of a model built from a big
+// informal corpus, and deletes the prefixes left without suffixes.
+// Generation still works on the pruned chain, if with more dead ends; when
+// even the start state is gone it starts at a random prefix instead.
+func (c *Chain) Prune(minCount int) PruneReport {
+	return c.prune(func(s Suffix, total int) bool { return s.frequency < minCount })
+}
+
+// PruneShare is like Prune but removes the suffix entries whose share of
+// the total frequency of their prefix is below share.
+func (c *Chain) PruneShare(share float64) PruneReport {
+	return c.prune(func(s Suffix, total int) bool { return float64(s.frequency) < share*float64(total) })
+}
+
+// prune removes the suffix entries drop selects, given the total frequency
+// of their prefix.
+func (c *Chain) prune(drop func(s Suffix, total int) bool) PruneReport {
+	var rep PruneReport
+	c.materialize()
+	defer c.beginWrite()()
+	for _, key := range sortedKeys(c.chain) {
+		suf := c.chain[key]
+		total := 0
+		for _, s := range suf {
+			total += s.frequency
+		}
+		kept := suf[:0]
+		for _, s := range suf {
+			if drop(s, total) {
+				rep.SuffixEntries++
+				rep.Tokens += s.frequency
+			} else {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == len(suf) {
+			continue
+		}
+		if len(kept) == 0 {
+			c.removeKey(key)
+			rep.Prefixes++
+		} else {
+			c.chain[key] = kept
+			c.dropIndexes()
+		}
+	}
+	return rep
+}
+
+// DeletePrefix removes prefix, its words separated by single spaces, and
+// all its suffixes from c, so that generation reaching it stops there as
+// at any other dead end. It reports whether c had the prefix.
+func (c *Chain) DeletePrefix(prefix string) bool {
+	c.materialize()
+	defer c.beginWrite()()
+	key, ok := c.findKey(prefix)
+	if !ok {
+		return false
+	}
+	c.removeKey(key)
+	return true
+}
+
+// DeleteSuffix removes word from the suffixes of prefix and reports whether
+// it was there. Removing the last suffix of a prefix removes the prefix.
+func (c *Chain) DeleteSuffix(prefix, word string) bool {
+	c.materialize()
+	defer c.beginWrite()()
+	key, ok := c.findKey(prefix)
+	if !ok {
+		return false
+	}
+	suf := c.chain[key]
+	for i, s := range suf {
+		if s.word == word {
+			if len(suf) == 1 {
+				c.removeKey(key)
+			} else {
+				c.chain[key] = append(suf[:i:i], suf[i+1:]...)
+				c.dropIndexes()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// findKey returns the key of c of prefix, its words separated by single
+// spaces and empty slots spelled as empty strings or as in model files,
+// `""`.
+func (c *Chain) findKey(prefix string) (string, bool) {
+	for _, key := range []string{Prefix(strings.Split(prefix, " ")).key(), emptySlotKey(prefix)} {
+		if _, ok := c.chain[key]; ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// removeKey deletes key and everything recorded about it.
+func (c *Chain) removeKey(key string) {
+	delete(c.chain, key)
+	delete(c.positions, key)
+	delete(c.starts, key)
+	c.dropIndexes()
+}
@@ -0,0 +1,134 @@
+package markov
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	c := TinyModel()
+	// Only "sat on the" is seen twice.
+	if got, want := c.Prune(2), (PruneReport{SuffixEntries: 14, Tokens: 14, Prefixes: 12}); got != want {
+		t.Errorf("Prune(2) = %+v, want %+v", got, want)
+	}
+	if got := c.Suffixes(Prefix{"sat", "on"}); len(c.chain) != 1 || !reflect.DeepEqual(got, []Suffix{{"the", 2}}) {
+		t.Errorf("Prune(2) left %v", c.chain)
+	}
+	// The start state is gone, and generation starts at the prefix left.
+	for seed := int64(1); seed <= 5; seed++ {
+		words, res := c.GenerateWordsChecked(10, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		if strings.Join(words, " ") != "sat on the" || res.Stop != StopDeadEnd {
+			t.Errorf("seed %d: generated %q, stopping at %s, want sat on the, stopping at %s", seed, words, res.Stop, StopDeadEnd)
+		}
+	}
+	if again := c.Prune(2); again != (PruneReport{}) {
+		t.Errorf("pruning twice removed %+v", again)
+	}
+
+	// ran. and sat share the cat, and mat. and cat. on the, half each.
+	c = TinyModel()
+	if got, want := c.PruneShare(0.5), (PruneReport{}); got != want {
+		t.Errorf("PruneShare(0.5) = %+v, want %+v", got, want)
+	}
+	if got, want := c.PruneShare(0.6), (PruneReport{SuffixEntries: 4, Tokens: 4, Prefixes: 2}); got != want {
+		t.Errorf("PruneShare(0.6) = %+v, want %+v", got, want)
+	}
+	if c.Suffixes(Prefix{"the", "cat"}) != nil || c.Suffixes(Prefix{"on", "the"}) != nil {
+		t.Errorf("PruneShare(0.6) left the prefixes of evenly split suffixes")
+	}
+	checkRoundTrip(t, c, "")
+}
+
+func TestDeleteSuffix(t *testing.T) {
+	c := TinyModel()
+	for _, tt := range []struct {
+		prefix, word string
+		deleted      bool
+		left         []Suffix // the suffixes of "the cat" after the call
+	}{
+		{"the cat", "sat", true, []Suffix{{"ran.", 1}}},
+		{"the cat", "sat", false, []Suffix{{"ran.", 1}}},
+		{"the dog", "ran.", false, []Suffix{{"ran.", 1}}},
+		{"the cat", "ran.", true, nil},
+	} {
+		if got := c.DeleteSuffix(tt.prefix, tt.word); got != tt.deleted {
+			t.Errorf("DeleteSuffix(%q, %q) = %v, want %v", tt.prefix, tt.word, got, tt.deleted)
+		}
+		if got := c.Suffixes(Prefix{"the", "cat"}); !reflect.DeepEqual(got, tt.left) {
+			t.Errorf("after DeleteSuffix(%q, %q) the cat has %v, want %v", tt.prefix, tt.word, got, tt.left)
+		}
+	}
+	if _, ok := c.chain[Prefix{"the", "cat"}.key()]; ok {
+		t.Errorf("the prefix outlived its last suffix")
+	}
+
+	// Empty slots are spelled either way.
+	for _, prefix := range []string{` the`, `"" the`} {
+		c := TinyModel()
+		if !c.DeleteSuffix(prefix, "cat") || c.Suffixes(Prefix{"", "the"}) != nil {
+			t.Errorf("DeleteSuffix(%q, cat) left %v", prefix, c.Suffixes(Prefix{"", "the"}))
+		}
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	c := newChain(2)
+	opts := BuildOptions{Positions: true, SentenceStarts: true}
+	if _, err := c.BuildReaderOpts("tiny.txt", strings.NewReader(tinyCorpus), opts); err != nil {
+		t.Fatal(err)
+	}
+	key := Prefix{"the", "dog"}.key()
+	if _, ok := c.positions[key]; !ok || c.starts[key] == 0 {
+		t.Fatalf("the build recorded no positions or sentence starts of the dog")
+	}
+	if !c.DeletePrefix("the dog") {
+		t.Fatal("DeletePrefix(the dog) = false, want true")
+	}
+	if c.DeletePrefix("the dog") || c.DeletePrefix("no such") {
+		t.Errorf("DeletePrefix of a missing prefix reported a deletion")
+	}
+	_, inChain := c.chain[key]
+	_, inPositions := c.positions[key]
+	_, inStarts := c.starts[key]
+	if inChain || inPositions || inStarts {
+		t.Errorf("the deleted prefix is still recorded: chain %v, positions %v, starts %v", inChain, inPositions, inStarts)
+	}
+
+	// Generation reaching the deleted context stops there; with a single
+	// way left to reach it, every text ends at it.
+	c.DeleteSuffix("the cat", "ran.")
+	c.DeleteSuffix("on the", "cat.")
+	for i := 0; i < 20; i++ {
+		words, res := c.GenerateWordsChecked(50, GenerateOptions{})
+		if strings.Join(words, " ") != "the cat sat on the mat. the dog" || res.Stop != StopDeadEnd {
+			t.Fatalf("generated %q, stopping at %s, want the cat sat on the mat. the dog, stopping at %s", words, res.Stop, StopDeadEnd)
+		}
+	}
+	if _, err := c.GenerateFrom([]string{"the", "dog"}, 5); !errors.Is(err, ErrUnknownPrefix) {
+		t.Errorf("GenerateFrom the deleted prefix: %v, want ErrUnknownPrefix", err)
+	}
+
+	// No prefix is written without suffixes, and the edited chain reads
+	// back as it is.
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			t.Errorf("line %d of the model is empty:\n%s", i+1, buf.String())
+		}
+	}
+	for name, roundTrip := range formats {
+		got, err := roundTrip(c)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if !reflect.DeepEqual(got.chain, c.chain) {
+			t.Errorf("%s: the edited chain reads back as %v, want %v", name, got.chain, c.chain)
+		}
+	}
+}
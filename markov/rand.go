@@ -0,0 +1,41 @@
+package markov
+
+import "math/rand"
+
+// globalSource is a rand.Source drawing from the math/rand top-level
+// functions, which are safe for concurrent use.
+type globalSource struct{}
+
+func (globalSource) Int63() int64    { return rand.Int63() }
+func (globalSource) Uint64() uint64  { return rand.Uint64() }
+func (globalSource) Seed(seed int64) {}
+
+// globalRand is used wherever options leave their Rand unset.
+var globalRand = rand.New(globalSource{})
+
+// orGlobal returns r, or globalRand when r is nil. Every random decision
+// of the package takes its *rand.Rand from an options struct through here,
+// so that one seeded generator reproduces a whole run.
+func orGlobal(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+	return globalRand
+}
+
+// splitMix is a rand.Source of the SplitMix64 generator. Unlike the source
+// of rand.NewSource, which fills a table of 607 numbers, it is seeded in
+// no time, for the generators of the samples of a batch, which draw a few
+// hundred numbers each.
+type splitMix struct{ state uint64 }
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+func (s *splitMix) Int63() int64    { return int64(s.Uint64() >> 1) }
+func (s *splitMix) Seed(seed int64) { s.state = uint64(seed) }
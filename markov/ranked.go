@@ -0,0 +1,88 @@
+package markov
+
+// rankedChoices holds the top suffixes of every prefix, see
+// PrecomputeChoices.
+type rankedChoices struct {
+	k      int
+	totals map[string]int      // total frequency of all suffixes of a prefix
+	top    map[string][]Suffix // at most k suffixes, most frequent first
+}
+
+// PrecomputeChoices ranks the k most frequent suffixes of every prefix of
+// c once, so that Session.Choices with a limit of at most k reads them off
+// instead of ranking every suffix of the prefix on each call; on prefixes
+// with many thousands of suffixes that is the difference between a slice
+// read and a pass over all of them. Larger limits, unknown prefixes and
+// AvoidDeadEnds, which changes the weights, are still ranked on demand.
+// The ranking is dropped by any change to c. Every model format saves k,
+// and a chain read back ranks its suffixes again on the first call that
+// needs them. k <= 0 drops the ranking as well.
+func (c *Chain) PrecomputeChoices(k int) {
+	c.materialize()
+	defer c.beginWrite()()
+	c.ranked, c.rankK = nil, 0
+	if k > 0 {
+		c.ranked, c.rankK = c.rank(k), k
+	}
+}
+
+// rank ranks the k most frequent suffixes of every prefix of c.
+func (c *Chain) rank(k int) *rankedChoices {
+	r := &rankedChoices{k: k, totals: make(map[string]int, len(c.chain)), top: make(map[string][]Suffix, len(c.chain))}
+	for key, suf := range c.chain {
+		top := newTopN(k, moreFrequent)
+		total := 0
+		for _, s := range suf {
+			total += s.frequency
+			top.push(s)
+		}
+		r.totals[key] = total
+		r.top[key] = top.sorted()
+	}
+	return r
+}
+
+// rankedTable returns the ranking of PrecomputeChoices, ranking the
+// suffixes first in a chain read from a model file that saved k.
+func (c *Chain) rankedTable() *rankedChoices {
+	if c.rankK <= 0 {
+		return nil
+	}
+	c.materialize()
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.ranked == nil {
+		c.ranked = c.rank(c.rankK)
+	}
+	return c.ranked
+}
+
+// moreFrequent orders suffixes by decreasing frequency, then by word, the
+// order of Session.Choices.
+func moreFrequent(a, b Suffix) bool {
+	if a.frequency != b.frequency {
+		return a.frequency > b.frequency
+	}
+	return a.word < b.word
+}
+
+// rankedPredictions returns the at most limit predictions for key from the
+// precomputed ranking, and false if the ranking cannot answer.
+func (c *Chain) rankedPredictions(key string, limit int) ([]Prediction, bool) {
+	r := c.rankedTable()
+	if r == nil || limit < 0 || limit > r.k {
+		return nil, false
+	}
+	top, ok := r.top[key]
+	if !ok {
+		return nil, false
+	}
+	if limit > len(top) {
+		limit = len(top)
+	}
+	out := make([]Prediction, limit)
+	for i, s := range top[:limit] {
+		out[i] = Prediction{s.word, float64(s.frequency) / float64(r.totals[key])}
+	}
+	return out, true
+}
@@ -0,0 +1,113 @@
+package markov
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestPrecomputeChoices checks that the precomputed ranking answers
+// Choices exactly as ranking on demand does, at every prefix of a chain.
+func TestPrecomputeChoices(t *testing.T) {
+	c := synthChain(t, 20000)
+	onDemand := c.NewSession(GenerateOptions{})
+	precomputed := c.NewSession(GenerateOptions{})
+	const k = 5
+	want := make(map[string][][]Prediction)
+	for key := range c.chain {
+		onDemand.p = splitKey(key)
+		for _, limit := range []int{1, 3, k, k + 1, -1} {
+			want[key] = append(want[key], onDemand.Choices(limit))
+		}
+	}
+
+	c.PrecomputeChoices(k)
+	if c.ranked == nil {
+		t.Fatal("PrecomputeChoices left no ranking")
+	}
+	for key := range c.chain {
+		precomputed.p = splitKey(key)
+		for i, limit := range []int{1, 3, k, k + 1, -1} {
+			if got := precomputed.Choices(limit); !reflect.DeepEqual(got, want[key][i]) {
+				t.Fatalf("Choices(%d) at %q = %v, ranked on demand %v", limit, splitKey(key), got, want[key][i])
+			}
+		}
+	}
+
+	// A change to the chain drops the ranking, which would be stale.
+	c.add(c.startPrefix().key(), "new", 1000)
+	if c.ranked != nil {
+		t.Errorf("adding to the chain kept the precomputed ranking")
+	}
+	precomputed.p = c.startPrefix()
+	if got := precomputed.Choices(1); len(got) != 1 || got[0].Word != "new" {
+		t.Errorf("Choices(1) after the change = %v, want new", got)
+	}
+	c.PrecomputeChoices(0)
+	if c.ranked != nil {
+		t.Errorf("PrecomputeChoices(0) kept the ranking")
+	}
+}
+
+// TestPrecomputeChoicesRoundTrip checks that every format saves the k of
+// the ranking, and that the chain read back ranks again when Choices
+// first needs it, answering as the chain written did.
+func TestPrecomputeChoicesRoundTrip(t *testing.T) {
+	c := synthChain(t, 5000)
+	const k = 3
+	c.PrecomputeChoices(k)
+	p := c.startPrefix()
+	want := c.NewSession(GenerateOptions{}).Choices(k)
+	for name, roundTrip := range formats {
+		read, err := roundTrip(c)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if read.rankK != k || read.ranked != nil {
+			t.Errorf("%s: read back k %d and a ranking %v, want k %d ranked when needed", name, read.rankK, read.ranked != nil, k)
+		}
+		s := read.NewSession(GenerateOptions{})
+		s.p = p
+		if got := s.Choices(k); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Choices(%d) = %v, want %v", name, k, got, want)
+		}
+		if read.ranked == nil || read.ranked.k != k {
+			t.Errorf("%s: Choices did not rank the chain read back", name)
+		}
+	}
+
+	// Without a ranking nothing is saved.
+	c.PrecomputeChoices(0)
+	read, err := formats["gob"](c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.rankK != 0 {
+		t.Errorf("a chain without a ranking read back with k %d", read.rankK)
+	}
+}
+
+// BenchmarkChoices ranks the top 10 of a prefix with 100000 suffixes, on
+// demand and precomputed.
+func BenchmarkChoices(b *testing.B) {
+	c := newChain(1)
+	r := rand.New(rand.NewSource(1))
+	key := Prefix{"x"}.key()
+	for i := 0; i < 100000; i++ {
+		c.chain[key] = append(c.chain[key], Suffix{fmt.Sprint("w", i), r.Intn(100) + 1})
+	}
+	for _, k := range []int{0, 10} {
+		b.Run(fmt.Sprintf("precompute=%d", k), func(b *testing.B) {
+			c.PrecomputeChoices(k)
+			s := c.NewSession(GenerateOptions{})
+			s.p = Prefix{"x"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Choices(10)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package markov
+
+import "fmt"
+
+// RemapTokens returns a copy of c with f applied to every word of every
+// prefix and suffix. Entries that become equal are merged by summing their
+// frequencies, so remapping with strings.ToLower folds "The" and "the"
+// into one word. Empty slots and the paragraph and end tokens are never
+// passed to f. The copy records the transformation under name,
+// which ends up in its model file.
+//
+// f must map every word to a word a chain can hold: not empty, which would
+// read as an empty slot, nor a sentinel such as EndToken, nor holding a
+// NUL byte. RemapTokens fails on the first word it does not, and if c
+// cannot be loaded.
+func (c *Chain) RemapTokens(name string, f func(string) string) (*Chain, error) {
+	if err := c.materialize(); err != nil {
+		return nil, err
+	}
+	defer c.beginRead()()
+	var bad error
+	mapWord := func(w string) string {
+		if w == "" || w == ParagraphToken || w == EndToken {
+			return w
+		}
+		m := f(w)
+		if bad == nil {
+			switch {
+			case m == "":
+				bad = fmt.Errorf("%s: %q maps to the empty word", name, w)
+			case m == ParagraphToken || m == EndToken:
+				bad = fmt.Errorf("%s: %q maps to a sentinel token", name, w)
+			case checkWord(m) != nil:
+				bad = fmt.Errorf("%s: %q maps to %q, which contains a NUL byte", name, w, m)
+			}
+		}
+		return m
+	}
+
+	out := newChain(c.prefixLen)
+	for _, key := range sortedKeys(c.chain) {
+		words := splitKey(key)
+		for i, w := range words {
+			words[i] = mapWord(w)
+		}
+		newKey := words.key()
+		for _, s := range c.chain[key] {
+			out.add(newKey, mapWord(s.word), s.frequency)
+		}
+	}
+
+	if len(c.prior) > 0 {
+		prior := make(map[string]int)
+		for _, s := range c.prior {
+			prior[mapWord(s.word)] += s.frequency
+		}
+		out.SetUnigramPrior(prior)
+	}
+	for class, items := range c.reservoirs {
+		if out.reservoirs == nil {
+			out.reservoirs = make(map[string][]string)
+		}
+		for _, item := range items {
+			out.reservoirs[class] = append(out.reservoirs[class], mapWord(item))
+		}
+	}
+	for _, word := range sortedKeys(c.caseStats) {
+		if out.caseStats == nil {
+			out.caseStats = make(map[string]caseStats)
+		}
+		s, t := c.caseStats[word], out.caseStats[mapWord(word)]
+		out.caseStats[mapWord(word)] = caseStats{s.initial + t.initial, s.initialCaps + t.initialCaps, s.mid + t.mid, s.midCaps + t.midCaps}
+	}
+	for n, count := range c.paragraphLengths {
+		if out.paragraphLengths == nil {
+			out.paragraphLengths = make(map[int]int)
+		}
+		out.paragraphLengths[n] = count
+	}
+	for _, key := range sortedKeys(c.positions) {
+		words := splitKey(key)
+		for i, w := range words {
+			words[i] = mapWord(w)
+		}
+		out.mergePositions(words.key(), c.positions[key])
+	}
+	for _, key := range sortedKeys(c.starts) {
+		words := splitKey(key)
+		for i, w := range words {
+			words[i] = mapWord(w)
+		}
+		out.addStart(words.key(), c.starts[key])
+	}
+	out.transforms = append(append(out.transforms, c.transforms...), name)
+	for name, flags := range c.presets {
+		out.SetPreset(name, flags)
+	}
+	if bad != nil {
+		return nil, bad
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package markov
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRemapTokensMerges(t *testing.T) {
+	c := newChain(1)
+	addWords(c, []string{"The", "cat", "the", "cat", "THE", "dog"})
+	out, err := c.RemapTokens("lowercase", strings.ToLower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Suffix{{"cat", 2}, {"dog", 1}}
+	got := out.Suffixes(Prefix{"the"})
+	sort.Slice(got, func(i, j int) bool { return got[i].word < got[j].word })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suffixes of the = %v, want %v", got, want)
+	}
+	if got := out.Suffixes(Prefix{"The"}); len(got) != 0 {
+		t.Errorf("The still has suffixes %v", got)
+	}
+	if got := out.Vocabulary()["the"]; got != 3 {
+		t.Errorf("the counted %d times, want 3", got)
+	}
+	if !reflect.DeepEqual(out.transforms, []string{"lowercase"}) {
+		t.Errorf("transforms %v, want lowercase", out.transforms)
+	}
+}
+
+func TestRemapTokensRejects(t *testing.T) {
+	c := newChain(2)
+	addWords(c, []string{"a", "b", "drop", "c"})
+	for name, f := range map[string]func(string) string{
+		"empty":    func(w string) string { return strings.TrimPrefix(w, "drop") },
+		"sentinel": func(w string) string { return strings.ReplaceAll(w, "drop", EndToken) },
+		"nul":      func(w string) string { return strings.ReplaceAll(w, "drop", "dr\x00op") },
+	} {
+		if out, err := c.RemapTokens(name, f); err == nil {
+			t.Errorf("%s: remapped to %v, want an error", name, out.chain)
+		}
+	}
+}
+
+// TestRemapTokensRoundTrip checks that words with white space, which only
+// a remapping can produce, survive the model file.
+func TestRemapTokensRoundTrip(t *testing.T) {
+	c := newChain(2)
+	addWords(c, []string{"new_york", "is", "big", "new_york", "\"quoted\"", EndToken})
+	out, err := c.RemapTokens("spaces", func(w string) string { return strings.ReplaceAll(w, "_", " ") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := out.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read := new(Chain)
+	if _, err := read.ReadFrom(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.chain, out.chain) {
+		t.Errorf("read back %v, want %v", read.chain, out.chain)
+	}
+	if got := read.Suffixes(Prefix{"new york", "is"}); len(got) != 1 || got[0].word != "big" {
+		t.Errorf("suffixes of new york is = %v, want big", got)
+	}
+}
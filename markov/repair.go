@@ -0,0 +1,219 @@
+package markov
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RepairSummary describes what RepairFreTable did to a model file.
+type RepairSummary struct {
+	PrefixLen     int `json:"prefix_len"`
+	Lines         int `json:"lines"`
+	Prefixes      int `json:"prefixes"`
+	SuffixEntries int `json:"suffix_entries"`
+	Repaired      int `json:"repaired"` // problems fixed in place
+	Dropped       int `json:"dropped"`  // lines, records or suffixes left out
+}
+
+// knownRecords are the extension records readRecord understands.
+var knownRecords = map[string]bool{
+	"reservoir": true, "prior": true, "case": true, "transform": true, "paragraph": true, "position": true,
+	"preset": true, "start": true, "meta": true, "source": true, "offset": true,
+}
+
+// RepairFreTable reads a possibly damaged model in the format written by
+// WriteFreTable and reconstructs the most plausible chain from it. Every
+// repair is passed to report. The heuristics are, in order:
+//
+//   - A first line that is neither a header nor a positive prefix length
+//     is taken to be a table line, and the prefix length is inferred as
+//     the longest run of "" empty-slot sentinels that starts a line (the
+//     start state of the chain is all sentinels). Headers of format
+//     versions newer than FormatVersion are not damage: they fail with
+//     ErrUnsupportedVersion.
+//   - Carriage returns and trailing spaces are ignored.
+//   - Empty fields within the prefix, left where an empty slot was written
+//     as nothing, become the "" sentinel; lines shorter than the prefix are
+//     dropped.
+//   - In the suffix list, empty fields (doubled spaces) are skipped. A word
+//     not followed by a positive frequency is dropped and parsing resumes
+//     at the next field; so is a suffix that is the "" sentinel itself.
+//   - Lines for the same prefix are merged, as are suffixes listed twice,
+//     by adding up their frequencies. Lines left without suffixes are
+//     dropped.
+//   - Malformed and unknown extension records are dropped, as are lines
+//     with a word that is badly escaped, in files of version 3 on.
+//   - The checksum record is left out without being checked, as the
+//     repaired chain is written with a checksum of its own.
+//
+// The returned error is only set when r cannot be read or no prefix length
+// can be made out at all.
+func RepairFreTable(r io.Reader, report func(Problem)) (*Chain, RepairSummary, error) {
+	var sum RepairSummary
+	var off int64
+	repaired := func(line int, format string, args ...interface{}) {
+		sum.Repaired++
+		report(Problem{off, line, fmt.Sprintf(format, args...)})
+	}
+	dropped := func(line int, format string, args ...interface{}) {
+		sum.Dropped++
+		report(Problem{off, line, fmt.Sprintf(format, args...)})
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, sum, err
+	}
+	sum.Lines = len(lines)
+
+	first, version := 1, 0
+	if len(lines) > 0 {
+		n, v, err := parseHeader(lines[0])
+		if errors.Is(err, ErrUnsupportedVersion) {
+			return nil, sum, err // not damage: a newer gomark wrote it
+		}
+		if err == nil {
+			sum.PrefixLen, version = n, v
+		}
+	}
+	if sum.PrefixLen == 0 {
+		first = 0
+		for _, line := range lines {
+			n := 0
+			for _, f := range strings.Fields(line) {
+				if f != `""` {
+					break
+				}
+				n++
+			}
+			if n > sum.PrefixLen {
+				sum.PrefixLen = n
+			}
+		}
+		if sum.PrefixLen == 0 {
+			return nil, sum, fmt.Errorf("no prefix length: the header is missing and no line starts with the \"\" sentinel")
+		}
+		repaired(1, "missing header, inferred prefix length %d", sum.PrefixLen)
+	}
+
+	c := newChain(sum.PrefixLen)
+	for i, line := range lines {
+		if i < first {
+			off += int64(len(line)) + 1
+			continue
+		}
+		lineNo := i + 1
+		if isChecksumRecord(line) {
+			off += int64(len(line)) + 1
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			fields, err := readFields(line, version, sum.PrefixLen)
+			switch {
+			case err != nil:
+				dropped(lineNo, "%v", err)
+			case len(fields) == 0 || !knownRecords[fields[0]]:
+				dropped(lineNo, "unknown extension record")
+			default:
+				if err := c.readRecord(fields); err != nil {
+					dropped(lineNo, "%v", err)
+				}
+			}
+			off += int64(len(line)) + 1
+			continue
+		}
+		c.repairTableLine(strings.TrimRight(line, "\r "), version, lineNo, repaired, dropped)
+		off += int64(len(line)) + 1
+	}
+
+	sum.Prefixes = len(c.chain)
+	for _, suf := range c.chain {
+		sum.SuffixEntries += len(suf)
+	}
+	return c, sum, nil
+}
+
+// repairTableLine adds what can be salvaged from a table line to c.
+func (c *Chain) repairTableLine(line string, version, lineNo int, repaired, dropped func(int, string, ...interface{})) {
+	if line == "" {
+		return
+	}
+	fields := strings.Split(line, " ")
+	if len(fields) < c.prefixLen {
+		dropped(lineNo, "line shorter than the prefix")
+		return
+	}
+	prefix := fields[:c.prefixLen]
+	for j, w := range prefix {
+		if w == "" {
+			repaired(lineNo, "empty prefix word %d taken as the \"\" sentinel", j+1)
+			continue
+		}
+		var err error
+		if prefix[j], err = readWord(w, version); err != nil {
+			dropped(lineNo, "%v", err)
+			return
+		}
+	}
+	key := Prefix(prefix).key()
+	if _, ok := c.chain[key]; ok {
+		repaired(lineNo, "merged with an earlier line for the same prefix")
+	}
+
+	var rest []string
+	for _, f := range fields[c.prefixLen:] {
+		if f != "" {
+			rest = append(rest, f)
+		}
+	}
+	if len(rest) < len(fields)-c.prefixLen {
+		repaired(lineNo, "skipped empty fields")
+	}
+	added := false
+	for k := 0; k < len(rest); {
+		word := rest[k]
+		freq := 0
+		if k+1 < len(rest) {
+			freq, _ = strconv.Atoi(rest[k+1])
+		}
+		if freq <= 0 {
+			dropped(lineNo, "suffix %q has no valid frequency", word)
+			k++
+			continue
+		}
+		k += 2
+		if word == `""` {
+			dropped(lineNo, "suffix is the \"\" sentinel")
+			continue
+		}
+		if version >= escapedVersion {
+			var err error
+			if word, err = unescapeWord(word); err != nil {
+				dropped(lineNo, "%v", err)
+				continue
+			}
+		}
+		for _, s := range c.chain[key] {
+			if s.word == word {
+				repaired(lineNo, "merged suffix %q listed twice", word)
+				break
+			}
+		}
+		c.add(key, word, freq)
+		added = true
+	}
+	if !added {
+		if _, ok := c.chain[key]; !ok {
+			dropped(lineNo, "no usable suffix")
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package markov
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The fixtures in testdata/repair were written by WriteFreTable as it was
+// before model files had a version, which spelled empty prefix slots as
+// `""` and ended every line with a space:
+//
+//   - legacy-prefix3.model is `read 3` of a.txt and b.txt.
+//   - concatenated.model is `read 1` of a.txt followed by `read 1` of
+//     b.txt, as if two models had been joined with cat.
+//   - headerless-crlf.model is legacy-prefix3.model with its header line
+//     lost and CRLF line ends.
+//   - huck-excerpt.model is the header and a few lines of huck.model, two
+//     of them with sentinels before the byte order mark of the book.
+
+// repairFixture repairs the named fixture, returning the problems reported.
+func repairFixture(t *testing.T, name string) (*Chain, RepairSummary, []string) {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "repair", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var problems []string
+	c, sum, err := RepairFreTable(f, func(p Problem) { problems = append(problems, p.String()) })
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return c, sum, problems
+}
+
+// legacyBuild builds the fixture corpora named as the legacy writer's
+// chains were built: every file from the start state, with no EndToken.
+func legacyBuild(t *testing.T, prefixLen int, names ...string) *Chain {
+	t.Helper()
+	c := newChain(prefixLen)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", "repair", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.BuildReaderOpts(name, bytes.NewReader(data), BuildOptions{NoEndToken: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestRepairLegacyFixtures(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		want     *Chain
+		sum      RepairSummary
+		problems []string // a substring of every problem reported, in order
+	}{
+		{
+			fixture: "legacy-prefix3.model",
+			want:    legacyBuild(t, 3, "a.txt", "b.txt"),
+			sum:     RepairSummary{PrefixLen: 3, Lines: 21, Prefixes: 20, SuffixEntries: 22},
+		},
+		{
+			fixture: "headerless-crlf.model",
+			want:    legacyBuild(t, 3, "a.txt", "b.txt"),
+			sum:     RepairSummary{PrefixLen: 3, Lines: 20, Prefixes: 20, SuffixEntries: 22, Repaired: 1},
+			problems: []string{
+				"line 1): missing header, inferred prefix length 3",
+			},
+		},
+		{
+			fixture: "concatenated.model",
+			want:    legacyBuild(t, 1, "a.txt", "b.txt"),
+			sum:     RepairSummary{PrefixLen: 1, Lines: 16, Prefixes: 13, SuffixEntries: 19, Repaired: 1, Dropped: 1},
+			problems: []string{
+				"line 9): no usable suffix",
+				"line 12): merged with an earlier line for the same prefix",
+			},
+		},
+	}
+	for _, tt := range tests {
+		c, sum, problems := repairFixture(t, tt.fixture)
+		if sum != tt.sum {
+			t.Errorf("%s: summary %+v, want %+v", tt.fixture, sum, tt.sum)
+		}
+		if len(problems) != len(tt.problems) {
+			t.Errorf("%s: reported %q, want %d problems", tt.fixture, problems, len(tt.problems))
+		} else {
+			for i, p := range problems {
+				if !strings.Contains(p, tt.problems[i]) {
+					t.Errorf("%s: problem %d is %q, want it to mention %q", tt.fixture, i+1, p, tt.problems[i])
+				}
+			}
+		}
+		// The build also records metadata, which legacy files had none of.
+		if !reflect.DeepEqual(c.chain, tt.want.chain) {
+			t.Errorf("%s: the repaired chain differs from a build of its corpus", tt.fixture)
+		}
+	}
+}
+
+// TestRepairSentinels checks that the `""` the legacy writer spelled empty
+// prefix slots with become empty slots again, so that generation starts
+// where the book does, rather than prefixes of literal quote words.
+func TestRepairSentinels(t *testing.T) {
+	c, sum, problems := repairFixture(t, "huck-excerpt.model")
+	if want := (RepairSummary{PrefixLen: 2, Lines: 11, Prefixes: 10, SuffixEntries: 19}); sum != want {
+		t.Errorf("summary %+v, want %+v", sum, want)
+	}
+	if problems != nil {
+		t.Errorf("reported %q, want nothing", problems)
+	}
+	const bom = "\uFEFF"
+	for _, tt := range []struct {
+		prefix Prefix
+		want   string
+	}{
+		{Prefix{"", ""}, bom},
+		{Prefix{"", bom}, "The"},
+	} {
+		if got := c.Suffixes(tt.prefix); len(got) != 1 || got[0].Word() != tt.want {
+			t.Errorf("suffixes of %q = %v, want %q", tt.prefix, got, tt.want)
+		}
+	}
+	if got := c.Suffixes(Prefix{`""`, `""`}); got != nil {
+		t.Errorf("the sentinels were read as words: suffixes %v", got)
+	}
+	if got := c.GenerateWords(2, GenerateOptions{}); strings.Join(got, " ") != bom+" The" {
+		t.Errorf("generated %q, want the start of the book", got)
+	}
+}
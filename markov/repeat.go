@@ -0,0 +1,98 @@
+package markov
+
+import (
+	"math/rand"
+)
+
+// RepeatAction is what a RepeatGuard does about a loop.
+type RepeatAction string
+
+const (
+	RepeatStop     RepeatAction = "stop"     // end the text
+	RepeatResample RepeatAction = "resample" // draw among the suffixes not closing the loop
+	RepeatRestart  RepeatAction = "restart"  // go on from a random prefix
+)
+
+// DefaultRepeatWindow is the window of a RepeatGuard that leaves it zero.
+const DefaultRepeatWindow = 100
+
+// RepeatGuard breaks the loops a chain can fall into, such as "of the
+// terms of the terms of the terms": when a word would bring generation to
+// a prefix it has already been at Limit times within the last Window
+// words, Action is taken. Resample draws among the other suffixes and
+// stops when there are none; Restart jumps to a random prefix without
+// emitting it, at most once per word asked for.
+type RepeatGuard struct {
+	Window int
+	Limit  int
+	Action RepeatAction
+}
+
+// repeatTracker counts the prefixes of the last words generated.
+type repeatTracker struct {
+	RepeatGuard
+	recent []string // ring of the last Window prefix keys
+	next   int
+	counts map[string]int
+}
+
+// newRepeatTracker returns a tracker for g, or nil if g is off.
+func newRepeatTracker(g *RepeatGuard) *repeatTracker {
+	if g == nil || g.Limit <= 0 {
+		return nil
+	}
+	t := &repeatTracker{RepeatGuard: *g, counts: make(map[string]int)}
+	if t.Window <= 0 {
+		t.Window = DefaultRepeatWindow
+	}
+	if t.Action == "" {
+		t.Action = RepeatStop
+	}
+	return t
+}
+
+// loops reports whether reaching key would exceed the limit.
+func (t *repeatTracker) loops(key string) bool {
+	return t.counts[key] >= t.Limit
+}
+
+// push records that generation reached key.
+func (t *repeatTracker) push(key string) {
+	if len(t.recent) < t.Window {
+		t.recent = append(t.recent, key)
+	} else {
+		old := t.recent[t.next]
+		if t.counts[old]--; t.counts[old] == 0 {
+			delete(t.counts, old)
+		}
+		t.recent[t.next] = key
+		t.next = (t.next + 1) % t.Window
+	}
+	t.counts[key]++
+}
+
+// exclude zeroes the weights of the choices following p that would close
+// a loop and reports whether any choice is left.
+func (t *repeatTracker) exclude(c *Chain, p Prefix, choices []Suffix, weights []int) bool {
+	left := false
+	for i, s := range choices {
+		if weights[i] > 0 && t.loops(c.shiftedKey(p, s.word)) {
+			weights[i] = 0
+		}
+		left = left || weights[i] > 0
+	}
+	return left
+}
+
+// restartPrefix returns a random prefix to go on from, from keys, which
+// it fills in on first use; false if the chain has none.
+func (c *Chain) restartPrefix(keys *[]string, r *rand.Rand) (Prefix, bool) {
+	if *keys == nil {
+		c.materialize()
+		*keys = c.interiorKeys()
+	}
+	if len(*keys) == 0 {
+		return nil, false
+	}
+	return splitKey((*keys)[r.Intn(len(*keys))]), true
+}
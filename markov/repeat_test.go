@@ -0,0 +1,60 @@
+package markov
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// loopCorpus cycles through "of the terms" twice as often as it leaves the
+// loop.
+var loopCorpus = strings.Repeat("subject to the terms of the terms of the terms of the agreement. ", 5)
+
+// maxTrigram returns the trigram of words that occurs most often, and how
+// often.
+func maxTrigram(words []string) (string, int) {
+	counts := make(map[string]int)
+	top, most := "", 0
+	for i := 0; i+3 <= len(words); i++ {
+		tri := strings.Join(words[i:i+3], " ")
+		if counts[tri]++; counts[tri] > most {
+			top, most = tri, counts[tri]
+		}
+	}
+	return top, most
+}
+
+func TestRepeatGuard(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader(loopCorpus), BuildOptions{NoEndToken: true}); err != nil {
+		t.Fatal(err)
+	}
+	const limit = 2
+	if tri, n := maxTrigram(c.GenerateWords(DefaultRepeatWindow, GenerateOptions{Greedy: true})); n <= limit {
+		t.Fatalf("unguarded greedy text repeats %q only %d times; the corpus should make it loop", tri, n)
+	}
+	for _, action := range []RepeatAction{RepeatStop, RepeatResample, RepeatRestart} {
+		for _, greedy := range []bool{true, false} {
+			for seed := int64(1); seed <= 10; seed++ {
+				opts := GenerateOptions{
+					Greedy: greedy,
+					Repeat: &RepeatGuard{Limit: limit, Action: action},
+					Rand:   rand.New(rand.NewSource(seed)),
+				}
+				name := fmt.Sprintf("%s, greedy %v, seed %d", action, greedy, seed)
+				words := c.GenerateWords(DefaultRepeatWindow, opts)
+				if len(words) == 0 {
+					t.Fatalf("%s: no words generated", name)
+				}
+				if tri, n := maxTrigram(words); n > limit {
+					t.Errorf("%s: %q occurs %d times, want at most %d:\n%s", name, tri, n, limit, strings.Join(words, " "))
+				}
+				// Resampling takes the way out of the loop, however rare.
+				if action == RepeatResample && !strings.Contains(strings.Join(words, " "), "of the agreement.") {
+					t.Errorf("%s: the text never left the loop:\n%s", name, strings.Join(words, " "))
+				}
+			}
+		}
+	}
+}
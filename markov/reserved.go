@@ -0,0 +1,121 @@
+package markov
+
+import "fmt"
+
+// ReservedToken is a literal that has a meaning of its own in the token
+// namespace of a chain, so it cannot also stand for a word of the corpus.
+type ReservedToken struct {
+	Name    string `json:"name"`
+	Literal string `json:"literal"`
+	Purpose string `json:"purpose"`
+	// Option names the option whose models use the token; "always" for
+	// tokens every model contains.
+	Option string `json:"introduced_by"`
+}
+
+// ReservedTokens returns every reserved token, the start sentinel first and
+// the class placeholders in the order of their names.
+func ReservedTokens() []ReservedToken {
+	tokens := []ReservedToken{
+		{"start", `""`, "fills the prefix slots before the first word of a document in model files", "always"},
+		{"paragraph", ParagraphToken, "marks a paragraph break", "read -paragraphs"},
+		{"end", EndToken, "marks the end of a document", "read, unless -no-end-token"},
+	}
+	for _, class := range sortedKeys(Classifiers) {
+		tokens = append(tokens, ReservedToken{class, placeholder(class),
+			"stands for one of the " + class + " originals kept in the model", "read -classify " + class})
+	}
+	return tokens
+}
+
+// reservedLiterals maps the literal of every reserved token to its name.
+func reservedLiterals() map[string]string {
+	m := make(map[string]string)
+	for _, t := range ReservedTokens() {
+		m[t.Literal] = t.Name
+	}
+	return m
+}
+
+// SentinelUse reports how often a model uses a reserved token.
+type SentinelUse struct {
+	ReservedToken
+	// Uses is the total frequency of the transitions emitting the token,
+	// or for the start token of those leaving the start state.
+	Uses int `json:"uses"`
+	// Collision is set when the token occurs where the model has no data
+	// for the option that reserves it, which means it was a word of the
+	// corpus: a paragraph break in a model without paragraph records or a
+	// placeholder without originals. A word `""` of the corpus is not the
+	// start token, which is empty in a chain and only spelled `""` in
+	// model files, where the word is escaped.
+	Collision bool `json:"collision"`
+}
+
+// SentinelUsage returns the reserved tokens that c uses, in the order of
+// ReservedTokens.
+func (c *Chain) SentinelUsage() []SentinelUse {
+	c.materialize()
+	start := c.startPrefix().key()
+	uses := make(map[string]int)
+	starts := 0
+	for key, suf := range c.chain {
+		isStart := key == start
+		for _, s := range suf {
+			uses[s.word] += s.frequency
+			if isStart {
+				starts += s.frequency
+			}
+		}
+	}
+
+	var out []SentinelUse
+	for _, t := range ReservedTokens() {
+		u := SentinelUse{ReservedToken: t, Uses: uses[t.Literal]}
+		switch t.Name {
+		case "start":
+			u.Uses = starts
+		case "paragraph":
+			u.Collision = u.Uses > 0 && len(c.paragraphLengths) == 0
+		case "end":
+			// The word scanner never produces it, so it is always Build's.
+		default:
+			u.Collision = u.Uses > 0 && len(c.reservoirs[t.Name]) == 0
+		}
+		if u.Uses > 0 {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// collisionCounter counts the corpus words of a build that are spelled
+// like a reserved token.
+type collisionCounter struct {
+	literals map[string]string
+	counts   map[string]int
+}
+
+func newCollisionCounter() *collisionCounter {
+	return &collisionCounter{literals: reservedLiterals(), counts: make(map[string]int)}
+}
+
+// add counts raw if it is spelled like a reserved token. Paragraph breaks
+// are not counted, the word scanner only produces them for blank lines,
+// and neither are ends, which it never produces, or `""`, which model files
+// escape, see SentinelUse.
+func (cc *collisionCounter) add(raw string) {
+	if _, ok := cc.literals[raw]; ok && raw != ParagraphToken && raw != EndToken && raw != `""` {
+		cc.counts[raw]++
+	}
+}
+
+// warnings returns a warning for every reserved literal seen.
+func (cc *collisionCounter) warnings() []string {
+	var out []string
+	for _, lit := range sortedKeys(cc.counts) {
+		out = append(out, fmt.Sprintf("the corpus contains %q %d times, which is the reserved %s token; the model cannot tell them apart",
+			lit, cc.counts[lit], cc.literals[lit]))
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package markov
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/reserved/legacy-url.model was written from legacy-url.txt by
+// gomark before token classes, when "<url>" was a word like any other.
+// It must never be regenerated.
+
+func TestSentinelUsageLegacy(t *testing.T) {
+	c, err := ReadFreTable(filepath.Join("testdata", "reserved", "legacy-url.model"))
+	var cerr *ChecksumError
+	if err != nil && !(errors.As(err, &cerr) && cerr.Missing) {
+		t.Fatal(err)
+	}
+	uses := make(map[string]SentinelUse)
+	for _, u := range c.SentinelUsage() {
+		uses[u.Name] = u
+	}
+	if len(uses) != 2 {
+		t.Errorf("SentinelUsage = %+v, want start and url", c.SentinelUsage())
+	}
+	if u := uses["start"]; u.Uses != 1 || u.Collision {
+		t.Errorf("start token: %+v, want 1 use and no collision", u)
+	}
+	if u := uses["url"]; u.Literal != "<url>" || u.Uses != 2 || !u.Collision {
+		t.Errorf("url token: %+v, want 2 uses of <url> colliding", u)
+	}
+}
+
+func TestReservedCollisions(t *testing.T) {
+	corpus := filepath.Join("testdata", "reserved", "legacy-url.txt")
+
+	// Built now, the corpus word is counted and warned about.
+	c := newChain(2)
+	report, err := c.BuildOpts([]string{corpus}, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warned := false
+	for _, w := range report.Warnings {
+		warned = warned || strings.Contains(w, `"<url>" 2 times`)
+	}
+	if !warned {
+		t.Errorf("warnings %q, want one about <url> 2 times", report.Warnings)
+	}
+
+	// With urls classified, the placeholder has originals, and is no
+	// collision.
+	c = newChain(2)
+	doc := "see https://example.com for the cat."
+	if _, err := c.BuildReaderOpts("doc.txt", strings.NewReader(doc), BuildOptions{Classifiers: []TokenClassifier{URLClassifier}}); err != nil {
+		t.Fatal(err)
+	}
+	urls := 0
+	for _, u := range c.SentinelUsage() {
+		if u.Collision {
+			t.Errorf("a classified model reports a collision: %+v", u)
+		}
+		if u.Name == "url" {
+			urls = u.Uses
+		}
+	}
+	if urls != 1 {
+		t.Errorf("the classified model uses <url> %d times, want 1", urls)
+	}
+
+	// Every reserved token has a literal of its own.
+	seen := make(map[string]bool)
+	for _, tok := range ReservedTokens() {
+		if seen[tok.Literal] {
+			t.Errorf("reserved token %+v has the literal of another", tok)
+		}
+		seen[tok.Literal] = true
+	}
+}
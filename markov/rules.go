@@ -0,0 +1,174 @@
+package markov
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rules are style rules applied while generating, without retraining, see
+// GenerateOptions.Rules. At every step the rules for the last word of the
+// prefix are applied to the candidate suffixes in this order: FORBID drops
+// a candidate, BOOST multiplies its weight, then the next word is drawn
+// and REPLACE rewrites it in the output; the prefix still moves on by the
+// word drawn. When FORBID would leave no candidate it is ignored for that
+// step, and a pair that is both forbidden and boosted is forbidden.
+type Rules struct {
+	forbid  map[rulePair]bool
+	boost   map[rulePair]float64
+	replace map[string]string
+}
+
+// rulePair is a word and a suffix following it.
+type rulePair struct{ prev, next string }
+
+// ReadRules reads rules with one rule per line:
+//
+//	FORBID word next
+//	BOOST word next factor
+//	REPLACE old new
+//
+// Blank lines and lines starting with # are skipped. Boosts of the same
+// pair multiply; replacing the same word twice is an error.
+func ReadRules(r io.Reader) (*Rules, error) {
+	rules := &Rules{make(map[rulePair]bool), make(map[rulePair]float64), make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch op := strings.ToUpper(fields[0]); {
+		case op == "FORBID" && len(fields) == 3:
+			rules.forbid[rulePair{fields[1], fields[2]}] = true
+		case op == "BOOST" && len(fields) == 4:
+			f, err := strconv.ParseFloat(fields[3], 64)
+			if err != nil || f <= 0 {
+				return nil, fmt.Errorf("line %d: expected positive boost factor, got %q", line, fields[3])
+			}
+			pair := rulePair{fields[1], fields[2]}
+			if old, ok := rules.boost[pair]; ok {
+				f *= old
+			}
+			rules.boost[pair] = f
+		case op == "REPLACE" && len(fields) == 3:
+			if old, ok := rules.replace[fields[1]]; ok && old != fields[2] {
+				return nil, fmt.Errorf("line %d: %q is already replaced by %q", line, fields[1], old)
+			}
+			rules.replace[fields[1]] = fields[2]
+		case op == "FORBID" || op == "REPLACE":
+			return nil, fmt.Errorf("line %d: %s needs two words, got %d", line, op, len(fields)-1)
+		case op == "BOOST":
+			return nil, fmt.Errorf("line %d: BOOST needs two words and a factor, got %d fields", line, len(fields)-1)
+		default:
+			return nil, fmt.Errorf("line %d: unknown rule %q, want FORBID, BOOST or REPLACE", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ReadRulesFile is ReadRules on the named file.
+func ReadRulesFile(name string) (*Rules, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	rules, err := ReadRules(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return rules, nil
+}
+
+// UnknownWords returns, in increasing order, the words the rules match on
+// that c never generates, so that callers can report such rules once
+// rather than wonder why they never fire. Replacement words are not
+// checked.
+func (r *Rules) UnknownWords(c *Chain) []string {
+	vocab := c.Vocabulary()
+	for _, s := range c.prior {
+		vocab[s.word] += s.frequency
+	}
+	unknown := make(map[string]bool)
+	check := func(w string) {
+		if vocab[w] == 0 {
+			unknown[w] = true
+		}
+	}
+	for p := range r.forbid {
+		check(p.prev)
+		check(p.next)
+	}
+	for p := range r.boost {
+		check(p.prev)
+		check(p.next)
+	}
+	for w := range r.replace {
+		check(w)
+	}
+	words := make([]string, 0, len(unknown))
+	for w := range unknown {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// apply applies the FORBID and BOOST rules for the choices following p to
+// weights in place. It returns the boost factor of every choice, or nil if
+// no boost applies.
+func (r *Rules) apply(p Prefix, choices []Suffix, weights []int) []float64 {
+	if r == nil || len(p) == 0 {
+		return nil
+	}
+	prev := p[len(p)-1]
+	forbidden, allowed := 0, 0
+	for i, s := range choices {
+		if weights[i] > 0 {
+			if r.forbid[rulePair{prev, s.word}] {
+				forbidden++
+			} else {
+				allowed++
+			}
+		}
+	}
+	if forbidden > 0 && allowed > 0 {
+		for i, s := range choices {
+			if r.forbid[rulePair{prev, s.word}] {
+				weights[i] = 0
+			}
+		}
+	}
+	var factors []float64
+	for i, s := range choices {
+		if f, ok := r.boost[rulePair{prev, s.word}]; ok && weights[i] > 0 {
+			if factors == nil {
+				factors = make([]float64, len(choices))
+				for j := range factors {
+					factors[j] = 1
+				}
+			}
+			factors[i] = f
+		}
+	}
+	return factors
+}
+
+// rewrite returns the word to emit for word.
+func (r *Rules) rewrite(word string) string {
+	if r == nil {
+		return word
+	}
+	if w, ok := r.replace[word]; ok {
+		return w
+	}
+	return word
+}
@@ -0,0 +1,119 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRules(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(`# style rules
+FORBID very unique
+
+boost the cat 2
+BOOST the cat 1.5
+REPLACE utilize use
+REPLACE utilize use
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Rules{
+		forbid:  map[rulePair]bool{{"very", "unique"}: true},
+		boost:   map[rulePair]float64{{"the", "cat"}: 3},
+		replace: map[string]string{"utilize": "use"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ReadRules = %+v, want %+v", rules, want)
+	}
+
+	for _, tt := range []struct{ rules, want string }{
+		{"FORBID very", `line 1: FORBID needs two words, got 1`},
+		{"# comment\nREPLACE a b c", `line 2: REPLACE needs two words, got 3`},
+		{"BOOST the cat", `line 1: BOOST needs two words and a factor, got 2 fields`},
+		{"BOOST the cat 0", `line 1: expected positive boost factor, got "0"`},
+		{"BOOST the cat x", `line 1: expected positive boost factor, got "x"`},
+		{"PREFER the cat", `line 1: unknown rule "PREFER", want FORBID, BOOST or REPLACE`},
+		{"REPLACE a b\n\nREPLACE a c", `line 3: "a" is already replaced by "b"`},
+	} {
+		if _, err := ReadRules(strings.NewReader(tt.rules)); err == nil || err.Error() != tt.want {
+			t.Errorf("ReadRules(%q) = %v, want %s", tt.rules, err, tt.want)
+		}
+	}
+}
+
+// rulesFor parses rules, failing the test on errors.
+func rulesFor(t *testing.T, text string) *Rules {
+	t.Helper()
+	rules, err := ReadRules(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+// TestRulesApply checks the probability TinyModel draws mat. and cat.
+// with after "on the", 1/2 each without rules, under rules of every kind.
+func TestRulesApply(t *testing.T) {
+	c := TinyModel()
+	for _, tt := range []struct {
+		rules    string
+		mat, cat float64 // 0 for never drawn
+	}{
+		{"", 0.5, 0.5},
+		{"FORBID the mat.", 0, 1},
+		{"BOOST the mat. 3", 0.75, 0.25},
+		{"BOOST the mat. 3\nBOOST the cat. 3", 0.5, 0.5},
+		// Forbidding beats boosting.
+		{"BOOST the cat. 5\nFORBID the cat.", 1, 0},
+		// A forbid leaving nothing is ignored.
+		{"FORBID the mat.\nFORBID the cat.", 0.5, 0.5},
+		// Rules match on the last word of the prefix only.
+		{"FORBID on mat.", 0.5, 0.5},
+	} {
+		opts := GenerateOptions{Rand: rand.New(rand.NewSource(1)), Rules: rulesFor(t, tt.rules)}
+		p := c.startPrefix()
+		seen := false
+		for i := 0; i < 20; i++ {
+			for _, tok := range c.GenerateAnnotated(50, opts) {
+				if p.String() == (Prefix{"on", "the"}).String() {
+					seen = true
+					want := map[string]float64{"mat.": tt.mat, "cat.": tt.cat}[tok.Text]
+					if math.Abs(tok.Probability-want) > 1e-9 {
+						t.Errorf("rules %q: %s drawn after on the with probability %v, want %v", tt.rules, tok.Text, tok.Probability, want)
+					}
+				}
+				p.Shift(tok.Text)
+			}
+			p = c.startPrefix()
+		}
+		if !seen {
+			t.Errorf("rules %q: never drew after on the", tt.rules)
+		}
+	}
+}
+
+// TestRulesReplace checks that REPLACE rewrites the word drawn in the
+// output only: the chain moves on from the word drawn, and FORBID and
+// BOOST rules match the words drawn, not their replacements.
+func TestRulesReplace(t *testing.T) {
+	c := TinyModel()
+	rules := rulesFor(t, "REPLACE cat. kitten.\nFORBID kitten. the\nFORBID the mat.")
+	for seed := int64(1); seed <= 10; seed++ {
+		plain := c.GenerateWords(30, GenerateOptions{Rand: rand.New(rand.NewSource(seed)), Rules: rulesFor(t, "FORBID the mat.")})
+		got := c.GenerateWords(30, GenerateOptions{Rand: rand.New(rand.NewSource(seed)), Rules: rules})
+		for i, w := range plain {
+			if w == "cat." {
+				plain[i] = "kitten."
+			}
+		}
+		if !reflect.DeepEqual(got, plain) {
+			t.Errorf("seed %d: %q, want %q", seed, got, plain)
+		}
+	}
+	if got := rules.UnknownWords(c); !reflect.DeepEqual(got, []string{"kitten."}) {
+		t.Errorf("UnknownWords = %q, want kitten.", got)
+	}
+}
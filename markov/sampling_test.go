@@ -0,0 +1,198 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/sampletest"
+)
+
+// samplingChain returns a chain of prefix length 1 in which a, b and c
+// follow x once, twice and three times.
+func samplingChain() *Chain {
+	c := newChain(1)
+	for word, n := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		c.add(Prefix{"x"}.key(), word, n)
+	}
+	return c
+}
+
+// drawAfter returns a sampler of the word c generates after seed with
+// opts.
+func drawAfter(t *testing.T, c *Chain, seed []string, opts GenerateOptions) func(*rand.Rand) string {
+	return func(r *rand.Rand) string {
+		opts.Rand = r
+		text, err := c.GenerateFromOpts(seed, 1, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		words := strings.Fields(text)
+		return words[len(words)-1]
+	}
+}
+
+// normalize returns weights divided by their sum.
+func normalize(weights map[string]float64) map[string]float64 {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	out := make(map[string]float64, len(weights))
+	for word, w := range weights {
+		out[word] = w / total
+	}
+	return out
+}
+
+func TestSamplingDistributions(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader("BOOST x a 4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forbid, err := ReadRules(strings.NewReader("FORBID x c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts GenerateOptions
+		want map[string]float64 // weights
+	}{
+		{"counts", GenerateOptions{}, map[string]float64{"a": 1, "b": 2, "c": 3}},
+		{"temperature 0.5", GenerateOptions{Temperature: 0.5}, map[string]float64{"a": 1, "b": 4, "c": 9}},
+		{"temperature 2", GenerateOptions{Temperature: 2}, map[string]float64{"a": 1, "b": math.Sqrt2, "c": math.Sqrt(3)}},
+		{"top-k 2", GenerateOptions{TopK: 2}, map[string]float64{"b": 2, "c": 3}},
+		{"top-p 0.6", GenerateOptions{TopP: 0.6}, map[string]float64{"b": 2, "c": 3}},
+		{"boost", GenerateOptions{Rules: rules}, map[string]float64{"a": 4, "b": 2, "c": 3}},
+		{"forbid", GenerateOptions{Rules: forbid}, map[string]float64{"a": 1, "b": 2}},
+	}
+	c := samplingChain()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampletest.Check(t, drawAfter(t, c, []string{"x"}, tt.opts), normalize(tt.want), sampletest.Options{})
+		})
+	}
+}
+
+func TestSamplingSmoothing(t *testing.T) {
+	c := samplingChain()
+	if err := c.SetSmoothing(1); err != nil {
+		t.Fatal(err)
+	}
+	// Every word of the vocabulary, x too, counts once more after x.
+	want := normalize(map[string]float64{"a": 2, "b": 3, "c": 4, "x": 1})
+	sampletest.Check(t, drawAfter(t, c, []string{"x"}, GenerateOptions{Smooth: true}), want, sampletest.Options{})
+	// Without Smooth generation draws from the counts alone.
+	want = normalize(map[string]float64{"a": 1, "b": 2, "c": 3})
+	sampletest.Check(t, drawAfter(t, c, []string{"x"}, GenerateOptions{}), want, sampletest.Options{})
+}
+
+// TestTopKGreedy checks that TopK 1 generates the text Greedy does, for
+// every seed, and that ties at the boundary of TopK go to the first words
+// in byte order.
+func TestTopKGreedy(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader(benchCorpus(5000))); err != nil {
+		t.Fatal(err)
+	}
+	greedy := c.GenerateWords(200, GenerateOptions{Greedy: true})
+	if len(greedy) != 200 {
+		t.Fatalf("Greedy generated %d words, want 200", len(greedy))
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		got := c.GenerateWords(200, GenerateOptions{TopK: 1, Rand: rand.New(rand.NewSource(seed))})
+		if !reflect.DeepEqual(got, greedy) {
+			t.Fatalf("seed %d: TopK 1 generated\n%q\nwant the greedy\n%q", seed, got, greedy)
+		}
+	}
+
+	tied := newChain(1)
+	for word, n := range map[string]int{"d": 1, "c": 2, "b": 2, "a": 2} {
+		tied.add(Prefix{"x"}.key(), word, n)
+	}
+	for _, tt := range []struct {
+		k    int
+		want map[string]float64
+	}{
+		{1, map[string]float64{"a": 1}},
+		{2, map[string]float64{"a": 0.5, "b": 0.5}},
+		{3, map[string]float64{"a": 1, "b": 1, "c": 1}},
+	} {
+		t.Run(fmt.Sprint("top-k ", tt.k), func(t *testing.T) {
+			sampletest.Check(t, drawAfter(t, tied, []string{"x"}, GenerateOptions{TopK: tt.k}), normalize(tt.want), sampletest.Options{})
+		})
+	}
+}
+
+// TestTopP checks nucleus sampling on suffixes of known counts, after TopK when
+// both are set, and that TopP 1 draws exactly as without it.
+func TestTopP(t *testing.T) {
+	c := samplingChain()
+	for _, tt := range []struct {
+		name string
+		opts GenerateOptions
+		want map[string]float64 // weights
+	}{
+		{"top-p 0.5", GenerateOptions{TopP: 0.5}, map[string]float64{"c": 3}},
+		{"top-p 0.51", GenerateOptions{TopP: 0.51}, map[string]float64{"b": 2, "c": 3}},
+		{"top-p 0.9", GenerateOptions{TopP: 0.9}, map[string]float64{"a": 1, "b": 2, "c": 3}},
+		// Of b and c, which TopK leaves, c alone makes up 0.6; TopP first
+		// would have kept both.
+		{"top-k 2, top-p 0.6", GenerateOptions{TopK: 2, TopP: 0.6}, map[string]float64{"c": 3}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sampletest.Check(t, drawAfter(t, c, []string{"x"}, tt.opts), normalize(tt.want), sampletest.Options{})
+		})
+	}
+
+	built := newChain(2)
+	if _, err := built.BuildReader(strings.NewReader(benchCorpus(5000))); err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		plain := built.GenerateWords(100, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		got := built.GenerateWords(100, GenerateOptions{TopP: 1, Rand: rand.New(rand.NewSource(seed))})
+		if !reflect.DeepEqual(got, plain) {
+			t.Fatalf("seed %d: TopP 1 generated\n%q\nwant as without it\n%q", seed, got, plain)
+		}
+	}
+}
+
+// TestGreedy checks that Greedy picks the most frequent suffix, the first
+// in byte order among equals, stops as sampling does, and generates the
+// same text whatever the seed, also with a RepeatResample guard.
+func TestGreedy(t *testing.T) {
+	loop := newChain(2)
+	if _, err := loop.BuildReaderOpts("", strings.NewReader(loopCorpus), BuildOptions{NoEndToken: true}); err != nil {
+		t.Fatal(err)
+	}
+	guard := &RepeatGuard{Limit: 2, Action: RepeatResample}
+	for _, tt := range []struct {
+		name string
+		c    *Chain
+		n    int
+		opts GenerateOptions
+		want string
+		stop StopReason
+	}{
+		// ran. and sat follow the cat once each.
+		{"tie", TinyModel(), 20, GenerateOptions{}, "the cat ran.", StopEnd},
+		{"limit", TinyModel(), 2, GenerateOptions{}, "the cat", StopLimit},
+		{"budget", TinyModel(), 20, GenerateOptions{MaxBytes: 8}, "the cat", StopBudget},
+		{"loop", loop, 12, GenerateOptions{}, "subject to the terms of the terms of the terms of the", StopLimit},
+		{"guarded loop", loop, 100, GenerateOptions{Repeat: guard}, "subject to the terms of the terms of the agreement. subject to the", StopRepeat},
+	} {
+		for seed := int64(1); seed <= 5; seed++ {
+			opts := tt.opts
+			opts.Greedy, opts.Rand = true, rand.New(rand.NewSource(seed))
+			words, res := tt.c.GenerateWordsChecked(tt.n, opts)
+			if got := strings.Join(words, " "); got != tt.want || res.Stop != tt.stop {
+				t.Errorf("%s, seed %d: generated %q, stopping at %s, want %q, stopping at %s", tt.name, seed, got, res.Stop, tt.want, tt.stop)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package markov
+
+import (
+	"io"
+	"math"
+	"strings"
+)
+
+// Score measures how well the text read from r fits c, for picking the
+// model a sample is closest to or ranking generated candidates. The text
+// is split into words as Build splits it, with the default BuildOptions,
+// and every word is scored by its Probability after the prefix the words
+// before it end in, starting from the start of a document. logProb is the
+// sum of the natural logarithms of those probabilities and perplexity is
+// e to the minus mean of them: 1 for text c predicts with certainty,
+// higher the more surprising the text is on average.
+//
+// Probabilities are those generation draws from: after a prefix c does
+// not know, that of the unigram prior, see SetUnigramPrior, if c has one.
+// Transitions c gives no probability, where the word never followed the
+// words before it nor is in the prior that stands in for them, are left
+// out of both and counted in oovCount. Smoothing, see SetSmoothing, gives
+// every transition between words of the vocabulary a probability, those
+// after prefixes c does not know and without a prior one over the size of
+// the vocabulary, so that only words c has never seen are left out.
+// perplexity is NaN if no word could be scored. Words are lower-cased for
+// case-folded chains; tokens Build would have classified, such as URLs,
+// are not.
+func (c *Chain) Score(r io.Reader) (logProb, perplexity float64, oovCount int, err error) {
+	tokens, _, err := readTokens("", r, BuildOptions{}, make(interner), &BuildReport{})
+	if err != nil {
+		return 0, math.NaN(), 0, err
+	}
+	c.materialize()
+	defer c.beginRead()()
+	vocab := c.cachedVocabulary()
+	p := c.startPrefix()
+	scored := 0
+	for _, w := range tokens {
+		if c.caseStats != nil {
+			w = strings.ToLower(w)
+		}
+		suf := c.lookup(p.key())
+		if len(suf) == 0 {
+			suf = c.prior
+		}
+		var prob float64
+		if len(suf) > 0 {
+			prob = c.probability(suf, w)
+		} else if _, ok := vocab[w]; ok && c.smoothing > 0 {
+			prob = 1 / float64(len(vocab))
+		}
+		if prob > 0 {
+			logProb += math.Log(prob)
+			scored++
+		} else {
+			oovCount++
+		}
+		p.Shift(w)
+	}
+	if scored == 0 {
+		return 0, math.NaN(), oovCount, nil
+	}
+	return logProb, math.Exp(-logProb / float64(scored)), oovCount, nil
+}
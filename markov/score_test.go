@@ -0,0 +1,61 @@
+package markov
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestScoreTrainingText(t *testing.T) {
+	text := "the cat sat on the mat . the dog sat on the cat ."
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader(text), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, perplexity, oov, err := c.Score(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oov != 0 {
+		t.Errorf("training text has %d OOV transitions, want 0", oov)
+	}
+	if perplexity < 1 || perplexity > 1.5 {
+		t.Errorf("perplexity of the training text = %v, want close to 1", perplexity)
+	}
+	_, _, oov, err = c.Score(strings.NewReader("the mat sat on the dog ."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oov == 0 {
+		t.Errorf("text unlike the training text has no OOV transitions")
+	}
+}
+
+func TestScorePrior(t *testing.T) {
+	c := newChain(1)
+	addWords(c, []string{"a", "b"})
+	logProb, _, oov, err := c.Score(strings.NewReader("a b x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oov != 1 || logProb != 0 {
+		t.Errorf("without a prior: log probability %v, %d OOV, want 0 and 1", logProb, oov)
+	}
+
+	// The prior stands in after b, which c knows no suffixes of, and
+	// draws x there with probability 3/4.
+	c.SetUnigramPrior(map[string]int{"x": 3, "y": 1})
+	logProb, perplexity, oov, err := c.Score(strings.NewReader("a b x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oov != 0 {
+		t.Errorf("with a prior: %d OOV transitions, want 0", oov)
+	}
+	if want := math.Log(0.75); math.Abs(logProb-want) > 1e-12 {
+		t.Errorf("with a prior: log probability %v, want %v", logProb, want)
+	}
+	if want := math.Exp(-math.Log(0.75) / 3); math.Abs(perplexity-want) > 1e-12 {
+		t.Errorf("with a prior: perplexity %v, want %v", perplexity, want)
+	}
+}
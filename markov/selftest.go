@@ -0,0 +1,236 @@
+package markov
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// selftestSeed and selftestOutput pin a seeded generation from TinyModel.
+const (
+	selftestSeed   = 3
+	selftestOutput = "the cat sat on the cat. the cat sat on the mat. the dog sat on the mat. the dog"
+)
+
+// SelfTestStep is a step of SelfTest and how it went.
+type SelfTestStep struct {
+	Name    string        `json:"name"`
+	Pass    bool          `json:"pass"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+	Error   string        `json:"error,omitempty"` // why it failed
+}
+
+// SelfTestReport lists the steps of SelfTest in the order they ran.
+type SelfTestReport struct {
+	Steps  []SelfTestStep `json:"steps"`
+	Failed int            `json:"failed"` // steps that did not pass
+}
+
+// Err returns an error counting the failed steps, or nil if all passed.
+func (r *SelfTestReport) Err() error {
+	if r.Failed > 0 {
+		return fmt.Errorf("selftest: %d steps failed", r.Failed)
+	}
+	return nil
+}
+
+// SelfTest runs the whole pipeline on the tiny corpus, see RunSelfTest,
+// writing PASS or FAIL with the time taken for every step to w, and
+// returns an error if any step failed.
+func SelfTest(w io.Writer) error {
+	rep, err := RunSelfTest()
+	if err != nil {
+		return err
+	}
+	for _, st := range rep.Steps {
+		status := "PASS"
+		if !st.Pass {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s %-26s %8s", status, st.Name, st.Elapsed.Round(time.Microsecond))
+		if st.Error != "" {
+			fmt.Fprintf(w, "  %s", st.Error)
+		}
+		fmt.Fprintln(w)
+	}
+	return rep.Err()
+}
+
+// RunSelfTest runs the whole pipeline on the tiny corpus in a temporary
+// directory: it builds the model, loads it as text and mapped, reads it
+// back from every format and compressed, checking each has the hash of
+// TinyModel, and checks its stats, validation, memory estimate and a seeded
+// generation. Failed steps are reported, not returned; the error is for a
+// temporary directory that could not be made.
+func RunSelfTest() (*SelfTestReport, error) {
+	dir, err := os.MkdirTemp("", "mark-selftest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	corpus := filepath.Join(dir, "tiny.txt")
+	model := filepath.Join(dir, "tiny.model")
+
+	rep := new(SelfTestReport)
+	step := func(name string, f func() error) {
+		start := time.Now()
+		err := f()
+		st := SelfTestStep{Name: name, Pass: err == nil, Elapsed: time.Since(start)}
+		if err != nil {
+			st.Error = err.Error()
+			rep.Failed++
+		}
+		rep.Steps = append(rep.Steps, st)
+	}
+	// The metadata, which names the temporary corpus file, is left out.
+	wantHash := func(c *Chain) error {
+		meta := c.meta
+		c.meta = metadata{}
+		h := c.Hash()
+		c.meta = meta
+		if h != TinyModelHash {
+			return fmt.Errorf("hash %s, want %s", h, TinyModelHash)
+		}
+		return nil
+	}
+
+	step("build", func() error {
+		if err := os.WriteFile(corpus, []byte(tinyCorpus+"\n"), 0o644); err != nil {
+			return err
+		}
+		c := newChain(2)
+		report, err := c.Build([]string{corpus})
+		if err != nil {
+			return err
+		}
+		if report.Prefixes != 13 {
+			return fmt.Errorf("%d prefixes, want 13", report.Prefixes)
+		}
+		if md := c.Metadata(); len(md.Sources) != 1 || md.Tokens != 15 {
+			return fmt.Errorf("metadata lists %d sources of %d tokens, want 1 of 15", len(md.Sources), md.Tokens)
+		}
+		if err := wantHash(c); err != nil {
+			return err
+		}
+		return c.WriteFreTable(model)
+	})
+	step("load text", func() error {
+		c, err := ReadFreTable(model)
+		if err != nil {
+			return err
+		}
+		return wantHash(c)
+	})
+	step("load mmap", func() error {
+		c, err := OpenFreTableMmap(model)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		return wantHash(c)
+	})
+	step("validate", func() error {
+		in, err := os.Open(model)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		var first error
+		sum, err := ValidateStream(in, func(p Problem) {
+			if first == nil {
+				first = fmt.Errorf("%s", p)
+			}
+		})
+		if err != nil || first != nil {
+			return firstError(err, first)
+		}
+		c, err := ReadFreTable(model)
+		if err != nil {
+			return err
+		}
+		if n := c.Unreachable(); n > 0 || sum.Prefixes != 13 {
+			return fmt.Errorf("%d prefixes, %d unreachable", sum.Prefixes, n)
+		}
+		return nil
+	})
+	// Every format, and a text model compressed with gzip, must read back
+	// to the model of the same hash.
+	for _, f := range []struct {
+		name, file string
+		write      func(*Chain, string) error
+	}{
+		{FormatJSON, "tiny.json", (*Chain).WriteJSONFile},
+		{FormatGob, "tiny.gob", (*Chain).WriteGobFile},
+		{FormatMsgpack, "tiny.msgpack", (*Chain).WriteMsgpackFile},
+		{"gzip", "tiny.model.gz", (*Chain).WriteFreTable},
+	} {
+		step("round trip "+f.name, func() error {
+			c, err := ReadFreTable(model)
+			if err != nil {
+				return err
+			}
+			name := filepath.Join(dir, f.file)
+			if err := f.write(c, name); err != nil {
+				return err
+			}
+			m, err := OpenModel(name, OpenOptions{})
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+			return wantHash(m.Chain)
+		})
+	}
+	step("stats", func() error {
+		c, err := ReadFreTable(model)
+		if err != nil {
+			return err
+		}
+		got, want := c.Stats(), TinyModel().Stats()
+		if got.Prefixes != 13 || got.Tokens != 15 || !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("stats %+v, want %+v", got, want)
+		}
+		return nil
+	})
+	step("memory estimate", func() error {
+		c, err := ReadFreTable(model)
+		if err != nil {
+			return err
+		}
+		est, err := EstimateModelFile(model)
+		if err != nil {
+			return err
+		}
+		if mem := c.EstimateMemory(); mem <= 0 || est <= 0 {
+			return fmt.Errorf("estimates %d and %d bytes", mem, est)
+		}
+		return nil
+	})
+	step("seeded generation", func() error {
+		c, err := ReadFreTable(model)
+		if err != nil {
+			return err
+		}
+		got := joinWords(c.GenerateWords(20, GenerateOptions{Rand: rand.New(rand.NewSource(selftestSeed))}))
+		if got != selftestOutput {
+			return fmt.Errorf("got %q, want %q", got, selftestOutput)
+		}
+		return nil
+	})
+
+	return rep, nil
+}
+
+// firstError returns the first of errs that is not nil.
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
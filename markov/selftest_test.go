@@ -0,0 +1,28 @@
+package markov
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	var out bytes.Buffer
+	if err := SelfTest(&out); err != nil {
+		t.Errorf("SelfTest: %v\n%s", err, out.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("SelfTest reported %d steps, want every step on a line:\n%s", len(lines), out.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "PASS ") {
+			t.Errorf("step %q did not pass", line)
+		}
+	}
+	for _, step := range []string{"round trip json", "round trip gob", "round trip msgpack", "round trip gzip", "stats", "seeded generation"} {
+		if !strings.Contains(out.String(), step) {
+			t.Errorf("SelfTest did not run %q:\n%s", step, out.String())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package markov
+
+import "strings"
+
+// ParagraphToken stands for a paragraph break in generated text. It is a
+// form feed, which the word scanner treats as white space, so it can never
+// be mistaken for a word of the corpus.
+const ParagraphToken = "\f"
+
+// EndToken marks the end of a document: Build counts it after the last
+// word of every input, see BuildOptions.NoEndToken, and generation stops
+// when it draws it, without writing it out. Like ParagraphToken it is a
+// white space character, a vertical tab, so no word of a corpus can be
+// spelled like it, and model files store it as is.
+const EndToken = "\v"
+
+// endsSentence reports whether tok ends a sentence, i.e. ends with '.', '!'
+// or '?' possibly followed by closing quotes or brackets.
+func endsSentence(tok string) bool {
+	t := strings.TrimRight(tok, `"')]}`)
+	return t != "" && strings.IndexByte(".!?", t[len(t)-1]) >= 0
+}
+
+// SentenceWordCap is how many words past the limit GenerateOptions.
+// CompleteSentence may add while looking for the end of the sentence.
+const SentenceWordCap = 50
+
+// sentenceEnd reports whether generation may stop after tok when
+// sentences are to be completed.
+func sentenceEnd(tok string) bool {
+	return tok == ParagraphToken || endsSentence(tok)
+}
+
+// trimSentence returns words up to and including the last word that ends a
+// sentence after the first start words, or all of them if none does.
+func trimSentence(words []string, start int) []string {
+	for i := len(words) - 1; i >= start; i-- {
+		if sentenceEnd(words[i]) {
+			return words[:i+1]
+		}
+	}
+	return words
+}
+
+// startsSentence reports whether tokens[k:], the prefix Build just
+// reached, begins a sentence, for BuildOptions.SentenceStarts: k is the
+// start of its block, which begins at index block, or follows a sentence
+// or paragraph end, and the prefix holds only words.
+func startsSentence(tokens []string, block, k int) bool {
+	if k < block || k > block && !sentenceEnd(tokens[k-1]) {
+		return false
+	}
+	for _, w := range tokens[k:] {
+		if w == ParagraphToken || w == EndToken {
+			return false
+		}
+	}
+	return true
+}
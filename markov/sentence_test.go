@@ -0,0 +1,42 @@
+package markov
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCompleteSentence(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader("alpha beta gamma delta epsilon zeta. eta theta iota."), BuildOptions{NoEndToken: true}); err != nil {
+		t.Fatal(err)
+	}
+	long := make([]string, 100)
+	for i := range long {
+		long[i] = fmt.Sprint("w", i)
+	}
+	endless := newChain(2)
+	if _, err := endless.BuildReaderOpts("", strings.NewReader(strings.Join(long, " ")), BuildOptions{NoEndToken: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		c    *Chain
+		n    int
+		opts GenerateOptions
+		want string
+	}{
+		{"plain", c, 3, GenerateOptions{}, "alpha beta gamma"},
+		{"complete", c, 3, GenerateOptions{CompleteSentence: true}, "alpha beta gamma delta epsilon zeta."},
+		{"complete at an end", c, 6, GenerateOptions{CompleteSentence: true}, "alpha beta gamma delta epsilon zeta."},
+		{"complete at a dead end", c, 7, GenerateOptions{CompleteSentence: true}, "alpha beta gamma delta epsilon zeta. eta theta iota."},
+		{"trim", c, 8, GenerateOptions{TrimSentence: true}, "alpha beta gamma delta epsilon zeta."},
+		{"trim without an end", c, 3, GenerateOptions{TrimSentence: true}, "alpha beta gamma"},
+		{"both", c, 8, GenerateOptions{CompleteSentence: true, TrimSentence: true}, "alpha beta gamma delta epsilon zeta. eta theta iota."},
+		{"cap", endless, 3, GenerateOptions{CompleteSentence: true}, strings.Join(long[:3+SentenceWordCap], " ")},
+	} {
+		if got := strings.Join(tt.c.GenerateWords(tt.n, tt.opts), " "); got != tt.want {
+			t.Errorf("%s: generated %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
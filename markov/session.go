@@ -0,0 +1,178 @@
+package markov
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotContinuation is returned by Session.Accept for a word that cannot
+// follow the current context.
+var ErrNotContinuation = errors.New("word does not continue the text")
+
+// Session generates text in turns, keeping the context between calls, for
+// chat-like use: words of the other party are fed in with Feed and the
+// chain continues from there with Continue.
+//
+// A Session only holds its current prefix and shares the chain, so it is
+// cheap to create. Sessions on the same chain may be used concurrently as
+// long as nothing modifies the chain and each session has its own Rand
+// (or none); a single Session must not be used concurrently.
+type Session struct {
+	c       *Chain
+	opts    GenerateOptions
+	p       Prefix
+	initial bool // whether the next word starts a sentence
+}
+
+// Prediction is a possible next word with its probability.
+type Prediction struct {
+	Word        string  `json:"word"`
+	Probability float64 `json:"probability"`
+}
+
+// Result is the outcome of a generation step.
+type Result struct {
+	Words []string // the words generated, in order
+	Text  string   // Words joined for display
+}
+
+// NewSession returns a session starting where Generate starts, drawing
+// with opts. A lazily opened chain is loaded completely first, so that
+// sessions never modify it.
+func (c *Chain) NewSession(opts GenerateOptions) *Session {
+	c.materialize()
+	s := &Session{c: c, opts: opts}
+	s.Reset()
+	return s
+}
+
+// Reset returns the session to the start state.
+func (s *Session) Reset() {
+	s.p = s.c.startPrefix()
+	s.initial = true
+}
+
+// FeedResult describes the context a Session was left in by Feed.
+type FeedResult struct {
+	// ContextStart is the index of the first of the tokens fed that is
+	// part of the context Continue draws from now; len(tokens) minus the
+	// prefix length, or 0 for short feeds that leave part of the start
+	// state in the context.
+	ContextStart int `json:"context_start"`
+	// Known is whether the chain knows the context. If not, Continue falls
+	// back to the unigram prior, if the chain has one, or fails with
+	// ErrDeadEnd.
+	Known bool `json:"known"`
+	// Unknown counts the tokens fed after a context the chain does not
+	// know, that is how often the chain lost track of the text fed.
+	Unknown int `json:"unknown"`
+}
+
+// Feed advances the session through tokens as if the chain had generated
+// them, without training the chain, and reports on the context reached.
+// Tokens need not be known to the chain, and all of them are fed, so that
+// a long seed such as a whole pasted paragraph leaves the session where
+// that paragraph ends.
+func (s *Session) Feed(tokens []string) FeedResult {
+	defer s.c.beginRead()()
+	var res FeedResult
+	for _, tok := range tokens {
+		if s.c.caseStats != nil {
+			tok = strings.ToLower(tok)
+		}
+		if len(s.c.lookup(s.p.key())) == 0 {
+			res.Unknown++
+		}
+		s.p.Shift(tok)
+		s.initial = endsSentence(tok)
+	}
+	if res.ContextStart = len(tokens) - len(s.p); res.ContextStart < 0 {
+		res.ContextStart = 0
+	}
+	res.Known = len(s.c.lookup(s.p.key())) > 0
+	return res
+}
+
+// Continue generates at most n words from the current state and advances
+// the state past them. It fails with ErrEmptyModel or ErrDeadEnd if not a
+// single word can be generated.
+func (s *Session) Continue(n int) (Result, error) {
+	c := s.c
+	defer c.beginRead()()
+	r := orGlobal(s.opts.Rand)
+	words := c.generate(nil, s.p, n, s.opts, r)
+	if len(words) == 0 {
+		if c.IsEmpty() {
+			return Result{}, ErrEmptyModel
+		}
+		return Result{}, ErrDeadEnd
+	}
+	c.restoreCaseFrom(words, s.initial, r)
+	s.initial = endsSentence(words[len(words)-1])
+	return Result{Words: words, Text: joinWords(words)}, nil
+}
+
+// choices returns the candidates for the next word and their weights, as
+// the next call to Continue would draw from them.
+func (s *Session) choices() ([]Suffix, []int) {
+	choices := s.c.lookup(s.p.key())
+	if len(choices) == 0 {
+		choices = s.c.prior
+	}
+	return choices, s.c.weights(s.p, choices, s.opts, nil)
+}
+
+// Choices returns the at most limit most probable next words, most
+// probable first, with the probabilities Continue would draw them with.
+// The words are spelled as in the chain: lower case in a case-folded
+// chain, and class placeholders such as <url> unfilled. See
+// Chain.PrecomputeChoices for making this fast on prefixes with very many
+// suffixes.
+func (s *Session) Choices(limit int) []Prediction {
+	defer s.c.beginRead()()
+	if !s.opts.AvoidDeadEnds {
+		if top, ok := s.c.rankedPredictions(s.p.key(), limit); ok {
+			return top
+		}
+	}
+	choices, weights := s.choices()
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if limit < 0 {
+		limit = len(choices)
+	}
+	top := newTopN(limit, func(a, b Prediction) bool {
+		if a.Probability != b.Probability {
+			return a.Probability > b.Probability
+		}
+		return a.Word < b.Word
+	})
+	for i, sfx := range choices {
+		if weights[i] > 0 {
+			top.push(Prediction{sfx.word, float64(weights[i]) / float64(total)})
+		}
+	}
+	return top.sorted()
+}
+
+// Accept advances the session by word if Continue could have generated
+// it next, and fails with ErrNotContinuation otherwise. To force a word
+// that does not follow, use Feed.
+func (s *Session) Accept(word string) error {
+	defer s.c.beginRead()()
+	if s.c.caseStats != nil {
+		word = strings.ToLower(word)
+	}
+	choices, weights := s.choices()
+	for i, sfx := range choices {
+		if sfx.word == word && weights[i] > 0 {
+			s.p.Shift(word)
+			s.initial = endsSentence(word)
+			return nil
+		}
+	}
+	return fmt.Errorf("%q after %q: %w", word, s.p.String(), ErrNotContinuation)
+}
@@ -0,0 +1,142 @@
+package markov
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSessionTurns plays a conversation with a session on TinyModel: the
+// chain speaks, the user cuts in, and the chain picks up from the user's
+// words rather than its own.
+func TestSessionTurns(t *testing.T) {
+	s := TinyModel().NewSession(GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+
+	// Every document starts with "the", and after "the cat" comes "sat" or
+	// "ran." only.
+	res, err := s.Continue(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Words, []string{"the"}) || res.Text != "the" {
+		t.Fatalf("first turn = %q (%q), want [the]", res.Words, res.Text)
+	}
+
+	// The user takes over and says "dog sat": the session follows the
+	// user, as the chain never says "the dog" before "sat on".
+	if got, want := s.Feed([]string{"dog", "sat"}), (FeedResult{ContextStart: 0, Known: true}); got != want {
+		t.Errorf("Feed(dog sat) = %+v, want %+v", got, want)
+	}
+	res, err = s.Continue(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"on", "the"}; !reflect.DeepEqual(res.Words, want) {
+		t.Errorf("after the user's turn = %q, want %q", res.Words, want)
+	}
+	if got, want := s.Choices(-1), []Prediction{{"cat.", 0.5}, {"mat.", 0.5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("choices after %q = %v, want %v", "on the", got, want)
+	}
+
+	// The user picks a word from the choices, and may not pick one that
+	// does not follow; a rejected word leaves the state alone.
+	if err := s.Accept("dog"); !errors.Is(err, ErrNotContinuation) {
+		t.Errorf("Accept(dog) after %q = %v, want ErrNotContinuation", "on the", err)
+	}
+	if err := s.Accept("cat."); err != nil {
+		t.Fatalf("Accept(cat.) = %v", err)
+	}
+
+	// The chain carries on into the next sentence from the user's word, up
+	// to the end of the document, after which there is nothing to say.
+	res, err = s.Continue(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"the", "cat"}; !reflect.DeepEqual(res.Words, want) {
+		t.Errorf("after accepting cat. = %q, want %q", res.Words, want)
+	}
+	if err := s.Accept("ran."); err != nil {
+		t.Fatalf("Accept(ran.) = %v", err)
+	}
+	if _, err := s.Continue(5); !errors.Is(err, ErrDeadEnd) {
+		t.Errorf("Continue after the end of a document = %v, want ErrDeadEnd", err)
+	}
+
+	// A feed longer than the prefix keeps only its last words as context,
+	// and counts the words the chain lost track at.
+	got := s.Feed(strings.Fields("a zebra said the cat"))
+	if want := (FeedResult{ContextStart: 3, Known: true, Unknown: 4}); got != want {
+		t.Errorf("Feed(a zebra said the cat) = %+v, want %+v", got, want)
+	}
+	if got, want := s.Choices(-1), []Prediction{{"ran.", 0.5}, {"sat", 0.5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("choices after %q = %v, want %v", "the cat", got, want)
+	}
+	if got := s.Feed([]string{"zebra"}); got.Known {
+		t.Errorf("Feed(zebra) = %+v, want an unknown context", got)
+	}
+	if _, err := s.Continue(1); !errors.Is(err, ErrDeadEnd) {
+		t.Errorf("Continue after an unknown context = %v, want ErrDeadEnd", err)
+	}
+
+	// Reset starts over, and a long turn runs to the end of a document.
+	s.Reset()
+	res, err = s.Continue(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Words) == 0 || res.Words[0] != "the" || !endsSentence(res.Words[len(res.Words)-1]) {
+		t.Errorf("turn after Reset = %q, want a whole document", res.Text)
+	}
+	if res.Text != joinWords(res.Words) {
+		t.Errorf("Text = %q, want the words joined: %q", res.Text, joinWords(res.Words))
+	}
+}
+
+// TestSessionSteering scripts a user steering generation word by word:
+// looking at the choices, picking one, letting the chain go on, and
+// forcing a word the chain would never pick.
+func TestSessionSteering(t *testing.T) {
+	s := TinyModel().NewSession(GenerateOptions{Rand: rand.New(rand.NewSource(1))})
+	var text []string
+	pick := func(limit, i int) {
+		t.Helper()
+		choices := s.Choices(limit)
+		if i >= len(choices) {
+			t.Fatalf("after %q: choice %d of %v", text, i, choices)
+		}
+		if err := s.Accept(choices[i].Word); err != nil {
+			t.Fatalf("after %q: Accept(%q) = %v", text, choices[i].Word, err)
+		}
+		text = append(text, choices[i].Word)
+	}
+	pick(5, 0) // the
+	pick(5, 0) // cat
+	if got, want := s.Choices(1), []Prediction{{"ran.", 0.5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("best choice after %q = %v, want %v", text, got, want)
+	}
+	pick(5, 1) // sat, the second best
+	res, err := s.Continue(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text = append(text, res.Words...)
+	pick(5, 1) // mat.
+	if err := s.Accept("cat"); !errors.Is(err, ErrNotContinuation) {
+		t.Errorf("Accept(cat) after %q = %v, want ErrNotContinuation", text, err)
+	}
+	// Forcing words goes through Feed.
+	s.Feed([]string{"so", "the", "cat"})
+	text = append(text, "so", "the", "cat")
+	pick(5, 1) // sat
+
+	if got, want := joinWords(text), "the cat sat on the mat. so the cat sat"; got != want {
+		t.Errorf("steered text = %q, want %q", got, want)
+	}
+	// The session is where the text leaves it.
+	if got, want := s.Choices(-1), []Prediction{{"on", 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("choices after %q = %v, want %v", text, got, want)
+	}
+}
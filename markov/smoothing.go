@@ -0,0 +1,96 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// SetSmoothing sets the additive smoothing of c: every word of the
+// vocabulary counts alpha more times after every prefix than the corpus
+// had it there, so that words never seen after a prefix get a small
+// probability rather than none. Alpha 1 is add-one or Laplace smoothing;
+// 0, the default, turns smoothing off and leaves every probability as the
+// counts give it. Probability and Distribution report smoothed
+// probabilities, and generation draws from them with
+// GenerateOptions.Smooth. It fails for negative alpha.
+func (c *Chain) SetSmoothing(alpha float64) error {
+	if alpha < 0 || math.IsNaN(alpha) || math.IsInf(alpha, 0) {
+		return fmt.Errorf("smoothing alpha %v is not a non-negative number", alpha)
+	}
+	defer c.beginWrite()()
+	c.smoothing = alpha
+	return nil
+}
+
+// Smoothing returns the alpha set by SetSmoothing.
+func (c *Chain) Smoothing() float64 {
+	defer c.beginRead()()
+	return c.smoothing
+}
+
+// smoother draws the next word from the distribution smoothed by the
+// alpha of the chain, see GenerateOptions.Smooth.
+type smoother struct {
+	alpha    float64
+	vocab    []string // in byte order
+	suffixes []Suffix // vocab with frequency 1, the choices after unknown prefixes
+}
+
+// newSmoother returns the smoother generation with opts uses, or nil if
+// it draws without smoothing.
+func (c *Chain) newSmoother(opts GenerateOptions) *smoother {
+	if !opts.Smooth || c.smoothing <= 0 {
+		return nil
+	}
+	c.materialize()
+	s := &smoother{alpha: c.smoothing, vocab: sortedKeys(c.cachedVocabulary())}
+	if len(s.vocab) == 0 {
+		return nil
+	}
+	for _, w := range s.vocab {
+		s.suffixes = append(s.suffixes, Suffix{w, 1})
+	}
+	return s
+}
+
+// draw returns the word to generate given that next was drawn from the
+// distribution probs over choices, whose weights were weights times
+// factors: with the share of the total the smoothing adds, a word drawn
+// uniformly from the vocabulary replaces next. If observe is set it also
+// returns the smoothed distribution, over the choices followed by the
+// words of the vocabulary not among them, and the index of the word in it.
+func (s *smoother) draw(next string, weights []int, factors []float64, choices []Suffix, probs []float64, r *rand.Rand, observe bool) (string, []float64, int) {
+	var total float64
+	for i, w := range weights {
+		if factors != nil {
+			total += float64(w) * factors[i]
+		} else {
+			total += float64(w)
+		}
+	}
+	mass := s.alpha * float64(len(s.vocab))
+	lambda := mass / (total + mass)
+	if r.Float64() < lambda {
+		next = s.vocab[r.Intn(len(s.vocab))]
+	}
+	if !observe {
+		return next, nil, 0
+	}
+	mixed := make([]float64, len(choices), len(choices)+len(s.vocab))
+	index := make(map[string]int, len(choices)+len(s.vocab))
+	for i, ch := range choices {
+		mixed[i] = (1 - lambda) * probs[i]
+		index[ch.word] = i
+	}
+	for _, w := range s.vocab {
+		i, ok := index[w]
+		if !ok {
+			i = len(mixed)
+			index[w] = i
+			mixed = append(mixed, 0)
+		}
+		mixed[i] += lambda / float64(len(s.vocab))
+	}
+	return next, mixed, index[next]
+}
@@ -0,0 +1,56 @@
+package markov
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSmoothingSumsToOne checks that the probabilities after every prefix
+// of a chain, and after one it does not know, add up to 1 with and without
+// smoothing, and that alpha 0 leaves them as the counts give them.
+func TestSmoothingSumsToOne(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader(tinyCorpus)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddText(strings.NewReader(benchCorpus(300))); err != nil {
+		t.Fatal(err)
+	}
+	c.SetUnigramPrior(map[string]int{"the": 3, "wordaa": 1})
+	var prefixes [][]string
+	for _, key := range sortedKeys(c.chain) {
+		prefixes = append(prefixes, splitKey(key))
+	}
+	prefixes = append(prefixes, []string{"no", "such"})
+	plain := make([][]SuffixProbability, len(prefixes))
+	for i, p := range prefixes {
+		plain[i] = c.Distribution(p)
+	}
+
+	for _, alpha := range []float64{0, 0.01, 1, 50} {
+		if err := c.SetSmoothing(alpha); err != nil {
+			t.Fatal(err)
+		}
+		for i, p := range prefixes {
+			dist := c.Distribution(p)
+			if alpha == 0 && !reflect.DeepEqual(dist, plain[i]) {
+				t.Fatalf("alpha 0: Distribution(%q) = %v, want %v as without smoothing", p, dist, plain[i])
+			}
+			sum := 0.0
+			for _, s := range dist {
+				sum += s.Probability
+				if got := c.Probability(p, s.Word); got != s.Probability {
+					t.Fatalf("alpha %v: P(%s | %q) = %v, Distribution says %v", alpha, s.Word, p, got, s.Probability)
+				}
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("alpha %v: the probabilities after %q add up to %v", alpha, p, sum)
+			}
+		}
+	}
+	if err := c.SetSmoothing(-1); err == nil {
+		t.Error("SetSmoothing(-1) succeeded, want an error")
+	}
+}
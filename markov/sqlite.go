@@ -0,0 +1,148 @@
+package markov
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// SQLiteStore is a Store in an SQLite database opened with database/sql,
+// for chains too big for memory. The counts are rows (prefix, word, count)
+// of the table gomark_suffixes, whose primary key (prefix, word) indexes
+// them by prefix, and the prefix length is kept in gomark_meta. Prefixes
+// are their words joined by NUL bytes, stored as BLOBs. Adds are batched
+// in a transaction that Flush commits, as a transaction per row would be
+// very slow.
+//
+// This package registers no driver: the program opening the database
+// imports one, such as modernc.org/sqlite.
+type SQLiteStore struct {
+	db        *sql.DB
+	prefixLen int
+	tx        *sql.Tx   // of the adds not flushed yet
+	add       *sql.Stmt // prepared within tx
+	get       *sql.Stmt
+}
+
+// The statements of SQLiteStore.
+const (
+	sqliteCreateMeta     = `CREATE TABLE IF NOT EXISTS gomark_meta (key TEXT PRIMARY KEY, value TEXT NOT NULL)`
+	sqliteCreateSuffixes = `CREATE TABLE IF NOT EXISTS gomark_suffixes (prefix BLOB NOT NULL, word TEXT NOT NULL, count INTEGER NOT NULL, PRIMARY KEY (prefix, word))`
+	sqliteGetPrefixLen   = `SELECT value FROM gomark_meta WHERE key = 'prefix_len'`
+	sqliteSetPrefixLen   = `INSERT INTO gomark_meta (key, value) VALUES ('prefix_len', ?)`
+	sqliteAdd            = `INSERT INTO gomark_suffixes (prefix, word, count) VALUES (?, ?, ?) ON CONFLICT (prefix, word) DO UPDATE SET count = count + excluded.count`
+	sqliteSuffixes       = `SELECT word, count FROM gomark_suffixes WHERE prefix = ? ORDER BY rowid`
+)
+
+// OpenSQLiteStore returns the store in db, creating its tables if they do
+// not exist yet. prefixLen must be that of an existing store; 0 takes the
+// prefix length of the store, which must then exist.
+func OpenSQLiteStore(db *sql.DB, prefixLen int) (*SQLiteStore, error) {
+	for _, stmt := range []string{sqliteCreateMeta, sqliteCreateSuffixes} {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	var value string
+	err := db.QueryRow(sqliteGetPrefixLen).Scan(&value)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && prefixLen == 0:
+		return nil, fmt.Errorf("store is empty: build it first")
+	case errors.Is(err, sql.ErrNoRows):
+		if _, err := NewChain(prefixLen); err != nil {
+			return nil, err
+		}
+		if _, err := db.Exec(sqliteSetPrefixLen, strconv.Itoa(prefixLen)); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("store has prefix length %q", value)
+		}
+		if prefixLen != 0 && n != prefixLen {
+			return nil, fmt.Errorf("store has prefixes of %d words, not %d", n, prefixLen)
+		}
+		prefixLen = n
+	}
+	get, err := db.Prepare(sqliteSuffixes)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db, prefixLen: prefixLen, get: get}, nil
+}
+
+// PrefixLen returns the number of words of the prefixes of s.
+func (s *SQLiteStore) PrefixLen() int { return s.prefixLen }
+
+// Add adds n to the count of word after prefix, in a transaction that
+// Flush commits.
+func (s *SQLiteStore) Add(prefix Prefix, word string, n int) error {
+	if len(prefix) != s.prefixLen {
+		return fmt.Errorf("prefix %q is not of %d words", prefix.String(), s.prefixLen)
+	}
+	if err := checkWord(word); err != nil {
+		return err
+	}
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		add, err := tx.Prepare(sqliteAdd)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		s.tx, s.add = tx, add
+	}
+	_, err := s.add.Exec([]byte(prefix.key()), word, n)
+	return err
+}
+
+// Suffixes returns the words seen after prefix with their counts, those
+// not flushed yet too.
+func (s *SQLiteStore) Suffixes(prefix Prefix) ([]Suffix, error) {
+	get := s.get
+	if s.tx != nil {
+		get = s.tx.Stmt(s.get)
+	}
+	rows, err := get.Query([]byte(prefix.key()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Suffix
+	for rows.Next() {
+		var suf Suffix
+		if err := rows.Scan(&suf.word, &suf.frequency); err != nil {
+			return nil, err
+		}
+		if err := checkWord(suf.word); err != nil {
+			return nil, err
+		}
+		if suf.frequency <= 0 {
+			return nil, fmt.Errorf("prefix %q: expected positive frequency for %q, got %d", prefix.String(), suf.word, suf.frequency)
+		}
+		out = append(out, suf)
+	}
+	return out, rows.Err()
+}
+
+// Flush commits the adds since the last Flush.
+func (s *SQLiteStore) Flush() error {
+	if s.tx == nil {
+		return nil
+	}
+	tx := s.tx
+	s.tx, s.add = nil, nil
+	return tx.Commit()
+}
+
+// Close flushes s and releases its statements; the database stays open.
+func (s *SQLiteStore) Close() error {
+	return errors.Join(s.Flush(), s.get.Close())
+}
@@ -0,0 +1,272 @@
+package markov
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// chainStore is a Chain as a Store, for comparing stores with chains.
+type chainStore struct{ c *Chain }
+
+func (s chainStore) PrefixLen() int { return s.c.PrefixLen() }
+
+func (s chainStore) Add(prefix Prefix, word string, n int) error {
+	defer s.c.beginWrite()()
+	s.c.add(prefix.key(), word, n)
+	return nil
+}
+
+func (s chainStore) Suffixes(prefix Prefix) ([]Suffix, error) { return s.c.Suffixes(prefix), nil }
+
+func (s chainStore) Flush() error { return nil }
+
+// fakeSQLite is a database/sql driver that understands the statements of
+// SQLiteStore and nothing else, for testing it without an SQLite driver.
+// Every name opens a database of its own, kept until the test ends.
+type fakeSQLite struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+type fakeDB struct {
+	meta     map[string]string
+	suffixes map[string][]Suffix // by prefix, in rowid order
+}
+
+func init() {
+	sql.Register("gomark-fake-sqlite", &fakeSQLite{dbs: make(map[string]*fakeDB)})
+}
+
+func (d *fakeSQLite) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db := d.dbs[name]
+	if db == nil {
+		db = &fakeDB{meta: make(map[string]string), suffixes: make(map[string][]Suffix)}
+		d.dbs[name] = db
+	}
+	return &fakeConn{d, db}, nil
+}
+
+type fakeConn struct {
+	d  *fakeSQLite
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	switch query {
+	case sqliteCreateMeta, sqliteCreateSuffixes, sqliteGetPrefixLen, sqliteSetPrefixLen, sqliteAdd, sqliteSuffixes:
+		return &fakeStmt{c, query}, nil
+	}
+	return nil, fmt.Errorf("fake SQLite does not know %q", query)
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	db := s.c.db
+	switch s.query {
+	case sqliteSetPrefixLen:
+		db.meta["prefix_len"] = args[0].(string)
+	case sqliteAdd:
+		key, word, n := string(args[0].([]byte)), args[1].(string), int(args[2].(int64))
+		suf := db.suffixes[key]
+		for i := range suf {
+			if suf[i].word == word {
+				suf[i].frequency += n
+				return driver.RowsAffected(1), nil
+			}
+		}
+		db.suffixes[key] = append(suf, Suffix{word, n})
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	rows := &fakeRows{cols: []string{"word", "count"}}
+	switch s.query {
+	case sqliteGetPrefixLen:
+		if v, ok := s.c.db.meta["prefix_len"]; ok {
+			rows.cols, rows.rows = []string{"value"}, [][]driver.Value{{v}}
+		}
+	case sqliteSuffixes:
+		for _, suf := range s.c.db.suffixes[string(args[0].([]byte))] {
+			rows.rows = append(rows.rows, []driver.Value{suf.word, int64(suf.frequency)})
+		}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// openTestStore opens a store of prefixLen words in a fresh fake database.
+func openTestStore(t testing.TB, prefixLen int) (*sql.DB, *SQLiteStore) {
+	t.Helper()
+	db, err := sql.Open("gomark-fake-sqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	s, err := OpenSQLiteStore(db, prefixLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, s
+}
+
+func TestSQLiteStoreBuild(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, text := range []string{tinyCorpus, "the cat slept on the mat.", tinyCorpus} {
+		name := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		if err := os.WriteFile(name, []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	c := newChain(2)
+	if _, err := c.Build(files); err != nil {
+		t.Fatal(err)
+	}
+	db, s := openTestStore(t, 2)
+	report, err := BuildStore(s, files, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 3 || report.Tokens != c.Stats().Tokens {
+		t.Errorf("report of %d files and %d tokens, want 3 and %d", len(report.Files), report.Tokens, c.Stats().Tokens)
+	}
+	for _, key := range sortedKeys(c.chain) {
+		got, err := s.Suffixes(splitKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := c.chain[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("suffixes of %q = %v in the store, %v in the chain", splitKey(key).String(), got, want)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store opens again with its prefix length, and only with it.
+	if _, err := OpenSQLiteStore(db, 3); err == nil {
+		t.Error("a store of prefix length 2 opened with 3")
+	}
+	s, err = OpenSQLiteStore(db, 0)
+	if err != nil || s.PrefixLen() != 2 {
+		t.Fatalf("reopened store has prefix length %v, %v; want 2", s, err)
+	}
+
+	// Generation draws from the same counts as from the chain.
+	for seed := int64(1); seed <= 5; seed++ {
+		got, err := GenerateStore(s, 50, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := GenerateStore(chainStore{c}, 50, rand.New(rand.NewSource(seed)))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("seed %d: store generates %q, chain %q", seed, got, want)
+		}
+	}
+}
+
+func TestSQLiteStoreEmpty(t *testing.T) {
+	db, err := sql.Open("gomark-fake-sqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSQLiteStore(db, 0); err == nil {
+		t.Error("an empty store opened without a prefix length")
+	}
+	_, s := openTestStore(t, 1)
+	if err := s.Add(Prefix{"a", "b"}, "c", 1); err == nil {
+		t.Error("a prefix of 2 words was added to a store of 1")
+	}
+	if words, err := GenerateStore(s, 10, nil); err != nil || len(words) != 0 {
+		t.Errorf("empty store generated %q, %v", words, err)
+	}
+}
+
+// BenchmarkGenerateStore measures the time per generated word from a chain
+// and from a store on the fake driver, which costs what the store itself
+// does without the database.
+func BenchmarkGenerateStore(b *testing.B) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader(benchCorpus(20000))); err != nil {
+		b.Fatal(err)
+	}
+	db, err := sql.Open("gomark-fake-sqlite", b.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	s, err := OpenSQLiteStore(db, 2)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, key := range sortedKeys(c.chain) {
+		for _, suf := range c.chain[key] {
+			if err := s.Add(splitKey(key), suf.word, suf.frequency); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	if err := s.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	for name, store := range map[string]Store{"chain": chainStore{c}, "store": s} {
+		b.Run(name, func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			words := 0
+			for i := 0; i < b.N; i++ {
+				out, err := GenerateStore(store, 100, r)
+				if err != nil {
+					b.Fatal(err)
+				}
+				words += len(out)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(max(words, 1)), "ns/word")
+		})
+	}
+}
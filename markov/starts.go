@@ -0,0 +1,78 @@
+package markov
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// addStart counts n more sentences beginning with the prefix key.
+func (c *Chain) addStart(key string, n int) {
+	if c.starts == nil {
+		c.starts = make(map[string]int)
+	}
+	c.starts[key] += n
+}
+
+// HasSentenceStarts reports whether c was built with
+// BuildOptions.SentenceStarts.
+func (c *Chain) HasSentenceStarts() bool {
+	return c.starts != nil
+}
+
+// SentenceStarts returns how many sentences of the corpus began with each
+// prefix, by prefix key, for chains built with BuildOptions.SentenceStarts.
+func (c *Chain) SentenceStarts() map[string]int {
+	defer c.beginRead()()
+	out := make(map[string]int, len(c.starts))
+	for key, n := range c.starts {
+		out[key] = n
+	}
+	return out
+}
+
+// sentenceStart draws a prefix to start generation at from the recorded
+// sentence starts, by how often they began a sentence, for
+// GenerateOptions.SentenceStart. Starts the chain no longer has suffixes
+// for, after pruning, are skipped. It returns false if none is left.
+func (c *Chain) sentenceStart(r *rand.Rand) (Prefix, bool) {
+	if len(c.starts) == 0 {
+		return nil, false
+	}
+	c.materialize()
+	var keys []string
+	sum := 0
+	for _, key := range sortedKeys(c.starts) {
+		if len(c.lookup(key)) > 0 {
+			keys = append(keys, key)
+			sum += c.starts[key]
+		}
+	}
+	if sum == 0 {
+		return nil, false
+	}
+	x := r.Intn(sum)
+	for _, key := range keys {
+		if x < c.starts[key] {
+			return splitKey(key), true
+		}
+		x -= c.starts[key]
+	}
+	return nil, false
+}
+
+// startRecord returns the fields of the model file record for the
+// sentence start key.
+func startRecord(key string, n int) []string {
+	return append(append([]string{"start"}, splitKey(key)...), strconv.Itoa(n))
+}
+
+// readStartRecord stores a start record, given its fields after the
+// record name.
+func (c *Chain) readStartRecord(fields []string) {
+	if len(fields) != c.prefixLen+1 {
+		return
+	}
+	if n, err := strconv.Atoi(fields[c.prefixLen]); err == nil && n > 0 {
+		c.addStart(Prefix(fields[:c.prefixLen]).key(), n)
+	}
+}
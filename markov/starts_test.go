@@ -0,0 +1,60 @@
+package markov
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/sampletest"
+)
+
+// TestSentenceStart records the sentence starts of a corpus, checks that
+// SentenceStart draws the first words from them by how often they began a
+// sentence, and that every format keeps them.
+func TestSentenceStart(t *testing.T) {
+	c := newChain(2)
+	text := "chapter one. the cat sat! the dog ran? the cat slept."
+	if _, err := c.BuildReaderOpts("", strings.NewReader(text), BuildOptions{SentenceStarts: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		Prefix{"chapter", "one."}.key(): 1,
+		Prefix{"the", "cat"}.key():      2,
+		Prefix{"the", "dog"}.key():      1,
+	}
+	if got := c.SentenceStarts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SentenceStarts = %q, want %q", got, want)
+	}
+
+	opening := func(c *Chain, opts GenerateOptions) func(*rand.Rand) string {
+		return func(r *rand.Rand) string {
+			opts.Rand = r
+			return strings.Join(c.GenerateWords(2, opts), " ")
+		}
+	}
+	sampletest.Check(t, opening(c, GenerateOptions{SentenceStart: true}),
+		normalize(map[string]float64{"chapter one.": 1, "the cat": 2, "the dog": 1}), sampletest.Options{})
+	sampletest.Check(t, opening(c, GenerateOptions{}), map[string]float64{"chapter one.": 1}, sampletest.Options{N: 100})
+
+	for name, roundTrip := range formats {
+		read, err := roundTrip(c)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := read.SentenceStarts(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read back the sentence starts %q, want %q", name, got, want)
+		}
+	}
+
+	// Without recorded starts, and once they are deleted, generation
+	// starts at the start state.
+	plain := TinyModel()
+	if plain.HasSentenceStarts() || plain.GenerateWords(1, GenerateOptions{SentenceStart: true})[0] != "the" {
+		t.Error("SentenceStart changed the start of a chain without sentence starts")
+	}
+	c.DeletePrefix("the cat")
+	c.DeletePrefix("the dog")
+	sampletest.Check(t, opening(c, GenerateOptions{SentenceStart: true}), map[string]float64{"chapter one.": 1}, sampletest.Options{N: 100})
+}
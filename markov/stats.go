@@ -0,0 +1,143 @@
+package markov
+
+import (
+	"sort"
+)
+
+// Stats summarizes the size of a chain, see Chain.Stats.
+type Stats struct {
+	Prefixes      int `json:"prefixes"`       // distinct prefixes
+	SuffixEntries int `json:"suffix_entries"` // prefix and suffix pairs
+	Tokens        int `json:"tokens"`         // sum of all suffix frequencies but of EndToken
+	// MeanSuffixes is the average number of suffix entries per prefix.
+	MeanSuffixes float64 `json:"mean_suffixes"`
+	// MaxFanOut is the prefix with the most suffix entries, the first in
+	// byte order among equals, and MaxFanOutSuffixes their number.
+	MaxFanOut         Prefix `json:"max_fan_out"`
+	MaxFanOutSuffixes int    `json:"max_fan_out_suffixes"`
+}
+
+// Stats returns the size of c, counted the same way for chains built in
+// memory and chains read from a model file. Tokens counts the words of the
+// corpus as BuildReport does: EndToken, which Build adds at the end of
+// every document, is not one, ParagraphToken is. For a chain opened with
+// OpenFreTableMmap that cannot be loaded in full it counts what could be,
+// and Err reports why.
+func (c *Chain) Stats() Stats {
+	c.materialize() // recorded for Err
+	defer c.beginRead()()
+	var st Stats
+	for _, key := range sortedKeys(c.chain) {
+		suf := c.chain[key]
+		st.Prefixes++
+		st.SuffixEntries += len(suf)
+		for _, s := range suf {
+			if s.word != EndToken {
+				st.Tokens += s.frequency
+			}
+		}
+		if len(suf) > st.MaxFanOutSuffixes {
+			st.MaxFanOut = sentinelPrefix(key)
+			st.MaxFanOutSuffixes = len(suf)
+		}
+	}
+	if st.Prefixes > 0 {
+		st.MeanSuffixes = float64(st.SuffixEntries) / float64(st.Prefixes)
+	}
+	return st
+}
+
+// PrefixCount is a prefix with how often it was followed by any suffix.
+type PrefixCount struct {
+	Prefix   Prefix `json:"prefix"`
+	Tokens   int    `json:"tokens"`   // total frequency of its suffixes
+	Suffixes int    `json:"suffixes"` // number of its suffix entries
+}
+
+// TopPrefixes returns the at most n prefixes of c with the highest total
+// suffix frequency, most frequent first and in byte order among equals.
+// Empty slots, such as those of the start state, are spelled `""` as in
+// model files. For a chain that cannot be loaded in full it is as for
+// Stats.
+func (c *Chain) TopPrefixes(n int) []PrefixCount {
+	c.materialize() // recorded for Err
+	defer c.beginRead()()
+	type counted struct {
+		key string
+		PrefixCount
+	}
+	top := newTopN(n, func(a, b counted) bool {
+		if a.Tokens != b.Tokens {
+			return a.Tokens > b.Tokens
+		}
+		return a.key < b.key
+	})
+	for key, suf := range c.chain {
+		pc := counted{key, PrefixCount{Suffixes: len(suf)}}
+		for _, s := range suf {
+			pc.Tokens += s.frequency
+		}
+		top.push(pc)
+	}
+	sorted := top.sorted()
+	out := make([]PrefixCount, len(sorted))
+	for i, pc := range sorted {
+		out[i] = pc.PrefixCount
+		out[i].Prefix = sentinelPrefix(pc.key)
+	}
+	return out
+}
+
+// WordCount is a word of the vocabulary with how often it occurs.
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// Vocabulary returns every distinct word of c with its total frequency as
+// a suffix, summed over all prefixes. Words that only occur inside
+// prefixes, as after DeletePrefix or Prune, are included with a count of
+// 0. The start token `""`, empty slots and ParagraphToken are not words
+// and never included; class placeholders such as <url> are. For a chain
+// that cannot be loaded in full it is as for Stats.
+func (c *Chain) Vocabulary() map[string]int {
+	c.materialize() // recorded for Err
+	defer c.beginRead()()
+	return c.vocabulary()
+}
+
+// vocabulary is Vocabulary for callers holding the lock.
+func (c *Chain) vocabulary() map[string]int {
+	vocab := make(map[string]int)
+	for key, suf := range c.chain {
+		for _, s := range suf {
+			vocab[s.word] += s.frequency
+		}
+		for _, w := range splitKey(key) {
+			if _, ok := vocab[w]; !ok {
+				vocab[w] = 0
+			}
+		}
+	}
+	delete(vocab, "")
+	delete(vocab, ParagraphToken)
+	delete(vocab, EndToken)
+	return vocab
+}
+
+// VocabularyByCount returns Vocabulary as a list, most frequent first and
+// in byte order among equals.
+func (c *Chain) VocabularyByCount() []WordCount {
+	vocab := c.Vocabulary()
+	out := make([]WordCount, 0, len(vocab))
+	for w, n := range vocab {
+		out = append(out, WordCount{w, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Word < out[j].Word
+	})
+	return out
+}
@@ -0,0 +1,158 @@
+package markov
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStatsTokensMatchBuildReport(t *testing.T) {
+	corpus := "the cat sat.\n\nthe dog sat on the cat.\n\nend"
+	for name, opts := range map[string]BuildOptions{
+		"plain":          {},
+		"paragraphs":     {Paragraphs: true},
+		"end paragraphs": {EndParagraphs: true},
+		"no end token":   {NoEndToken: true},
+	} {
+		c := newChain(2)
+		report, err := c.BuildReaderOpts("a", strings.NewReader(corpus), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := c.BuildReaderOpts("b", strings.NewReader("a second document"), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st, want := c.Stats(), report.Tokens+second.Tokens; st.Tokens != want {
+			t.Errorf("%s: Stats counts %d tokens, the builds reported %d", name, st.Tokens, want)
+		}
+	}
+}
+
+func TestStatsRecordsLoadError(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReaderOpts("", strings.NewReader(tinyCorpus), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "model.txt")
+	if err := c.WriteFreTable(name); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	last := len(lines) - 3 // the last table line, before the checksum
+	lines[last] = strings.TrimSuffix(lines[last], " ") + "x "
+	if err := os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := OpenFreTableMmap(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lazy.Close()
+	lazy.Stats()
+	if lazy.Err() == nil {
+		t.Errorf("Stats of a damaged model left Err nil")
+	}
+}
+
+// TestStatsExample checks Stats on the example of the package comment,
+// built and read back from its model file.
+func TestStatsExample(t *testing.T) {
+	const text = "I am not a number! I am a free man!"
+	for _, tt := range []struct {
+		opts BuildOptions
+		want Stats
+	}{
+		// The table of the package comment.
+		{BuildOptions{NoEndToken: true}, Stats{9, 10, 10, 10.0 / 9, Prefix{"I", "am"}, 2}},
+		// With the end of the document after "free man!".
+		{BuildOptions{}, Stats{10, 11, 10, 11.0 / 10, Prefix{"I", "am"}, 2}},
+	} {
+		c := newChain(2)
+		if _, err := c.BuildReaderOpts("", strings.NewReader(text), tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(t.TempDir(), "model.txt")
+		if err := c.WriteFreTable(name); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := ReadFreTable(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for from, c := range map[string]*Chain{"built": c, "loaded": loaded} {
+			if got := c.Stats(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s with %+v: Stats = %+v, want %+v", from, tt.opts, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestTopPrefixes(t *testing.T) {
+	c := newChain(2)
+	if _, err := c.BuildReader(strings.NewReader("the cat sat. the cat ran. the dog sat.")); err != nil {
+		t.Fatal(err)
+	}
+	// "the cat" is followed twice, every other prefix once; among those the
+	// start state comes first in byte order, spelled as in model files.
+	want := []PrefixCount{
+		{Prefix{"the", "cat"}, 2, 2},
+		{Prefix{`""`, `""`}, 1, 1},
+		{Prefix{`""`, "the"}, 1, 1},
+		{Prefix{"cat", "ran."}, 1, 1},
+	}
+	if got := c.TopPrefixes(4); !reflect.DeepEqual(got, want) {
+		t.Errorf("TopPrefixes(4) = %v, want %v", got, want)
+	}
+	all := c.TopPrefixes(100)
+	if len(all) != len(c.chain) || !reflect.DeepEqual(all[:4], want) {
+		t.Errorf("TopPrefixes(100) = %v, want all %d prefixes starting with %v", all, len(c.chain), want)
+	}
+	if last := all[len(all)-1]; !reflect.DeepEqual(last, PrefixCount{Prefix{"the", "dog"}, 1, 1}) {
+		t.Errorf("the last of all prefixes is %v, want the dog", last)
+	}
+	for _, n := range []int{0, -1} {
+		if got := c.TopPrefixes(n); len(got) != 0 {
+			t.Errorf("TopPrefixes(%d) = %v, want none", n, got)
+		}
+	}
+	if got := newChain(2).TopPrefixes(5); len(got) != 0 {
+		t.Errorf("TopPrefixes of an empty chain = %v", got)
+	}
+}
+
+func TestVocabulary(t *testing.T) {
+	c := TinyModel()
+	// dog is left inside the prefix "the dog" only.
+	c.DeleteSuffix("mat. the", "dog")
+	want := []WordCount{{"the", 5}, {"cat", 2}, {"on", 2}, {"sat", 2}, {"cat.", 1}, {"mat.", 1}, {"ran.", 1}, {"dog", 0}}
+	if got := c.VocabularyByCount(); !reflect.DeepEqual(got, want) {
+		t.Errorf("VocabularyByCount = %v, want %v", got, want)
+	}
+	vocab := c.Vocabulary()
+	if len(vocab) != len(want) {
+		t.Errorf("Vocabulary has %d words, want %d: %v", len(vocab), len(want), vocab)
+	}
+	for _, w := range want {
+		if n, ok := vocab[w.Word]; !ok || n != w.Count {
+			t.Errorf("Vocabulary()[%q] = %d, %v, want %d", w.Word, n, ok, w.Count)
+		}
+	}
+
+	// Empty slots, paragraph breaks and document ends are not words; the
+	// word `""` is.
+	c = newChain(1)
+	if _, err := c.BuildReaderOpts("", strings.NewReader("\"\" is it.\n\nyes."), BuildOptions{Paragraphs: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Vocabulary(), map[string]int{`""`: 1, "is": 1, "it.": 1, "yes.": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vocabulary = %v, want %v", got, want)
+	}
+}
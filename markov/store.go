@@ -0,0 +1,104 @@
+package markov
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// Store keeps the counts of a chain somewhere other than in the memory of
+// a Chain, such as a database, for corpora whose chain does not fit in
+// memory: how often every word followed every prefix, and nothing else.
+// BuildStore fills a store and GenerateStore generates text from one; see
+// SQLiteStore. A Store need not be safe for concurrent use.
+type Store interface {
+	// PrefixLen returns the number of words of the prefixes of the store.
+	PrefixLen() int
+	// Add adds n to the count of word after prefix.
+	Add(prefix Prefix, word string, n int) error
+	// Suffixes returns the words seen after prefix with their counts, in
+	// the order they were first added, or nil if there are none.
+	Suffixes(prefix Prefix) ([]Suffix, error)
+	// Flush makes everything added so far durable.
+	Flush() error
+}
+
+// BuildStore adds the named input files to s as Build adds them to a
+// chain, with opts, and flushes it. Every file is built into a chain of
+// its own, which is then added to s, so that memory use grows with the
+// largest file rather than the corpus. Only the counts are kept: the
+// records of a model, such as the capitalization BuildOptions.Lowercase
+// records, are dropped. Files that cannot be read are left out and come
+// back in a *BuildError, as for Build; the report sums the files, but its
+// Prefixes and SuffixEntries count those of every file anew.
+func BuildStore(s Store, inputFiles []string, opts BuildOptions) (BuildReport, error) {
+	var report BuildReport
+	var failed []*FileError
+	for _, name := range inputFiles {
+		c, err := NewChain(s.PrefixLen())
+		if err != nil {
+			return report, err
+		}
+		rep, err := c.BuildOpts([]string{name}, opts)
+		report.Files = append(report.Files, rep.Files...)
+		report.Tokens += rep.Tokens
+		report.Prefixes += rep.Prefixes
+		report.SuffixEntries += rep.SuffixEntries
+		var buildErr *BuildError
+		if errors.As(err, &buildErr) {
+			failed = append(failed, buildErr.Files...)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		for _, key := range sortedKeys(c.chain) {
+			for _, suf := range c.chain[key] {
+				if err := s.Add(splitKey(key), suf.word, suf.frequency); err != nil {
+					return report, err
+				}
+			}
+		}
+		// Flush every file, so that a failure loses one file at most.
+		if err := s.Flush(); err != nil {
+			return report, err
+		}
+	}
+	if failed != nil {
+		return report, &BuildError{failed}
+	}
+	return report, nil
+}
+
+// GenerateStore returns at most n words generated from s, drawn by their
+// counts from the start of a document, as Generate draws them from a
+// chain without any options. It stops early at the end of a document or
+// where s has no suffixes. r nil means the global source.
+func GenerateStore(s Store, n int, r *rand.Rand) ([]string, error) {
+	r = orGlobal(r)
+	p := make(Prefix, s.PrefixLen())
+	var words []string
+	for len(words) < n {
+		suf, err := s.Suffixes(p)
+		if err != nil {
+			return words, err
+		}
+		total := 0
+		for _, x := range suf {
+			total += x.frequency
+		}
+		if total <= 0 {
+			break
+		}
+		i, x := 0, r.Intn(total)
+		for ; x >= suf[i].frequency; i++ {
+			x -= suf[i].frequency
+		}
+		word := suf[i].word
+		if word == EndToken {
+			break
+		}
+		words = append(words, word)
+		p.Shift(word)
+	}
+	return words, nil
+}
@@ -0,0 +1,64 @@
+package markov
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+)
+
+// GenerateTo writes at most n generated words to w as they are drawn,
+// separated like WriteText separates them but without its final newline,
+// and returns how many it wrote. Nothing but the current prefix is kept
+// in memory, so outputs of any length can be piped straight to a file or
+// network connection. Output is buffered in blocks of a few kilobytes and
+// the first error of w stops generation and is returned; the count then
+// includes the words of the block w failed on.
+func (c *Chain) GenerateTo(w io.Writer, n int) (int, error) {
+	return c.GenerateToOpts(w, n, GenerateOptions{})
+}
+
+// GenerateToOpts is like GenerateTo but samples according to opts, and
+// with opts.Pretty separates the words as WritePretty does.
+// TrimSentence cannot take back words already written and is ignored.
+// Capitalization of case-folded models is decided word by word, so the
+// text differs from GenerateWords with the same seed for those models.
+func (c *Chain) GenerateToOpts(w io.Writer, n int, opts GenerateOptions) (int, error) {
+	defer c.beginRead()()
+	r := orGlobal(opts.Rand)
+	bw := bufio.NewWriter(w)
+	s := &streamer{c: c, j: joiner{w: bw, pretty: opts.Pretty}, r: r, initial: true}
+	p, words := c.startState(n, opts, r)
+	for _, word := range words {
+		if !s.emit(word) {
+			return s.n, s.err
+		}
+	}
+	opts.emit = s.emit
+	c.generate(words, p, n-len(words), opts, r)
+	if s.err != nil {
+		return s.n, s.err
+	}
+	return s.n, bw.Flush()
+}
+
+// streamer writes the words of GenerateToOpts.
+type streamer struct {
+	c       *Chain
+	j       joiner
+	r       *rand.Rand
+	initial bool // whether the next word starts a sentence
+	n       int  // words written
+	err     error
+}
+
+// emit writes word and reports whether to go on.
+func (s *streamer) emit(word string) bool {
+	w := []string{word}
+	s.c.restoreCaseFrom(w, s.initial, s.r)
+	s.initial = word == ParagraphToken && s.initial || endsSentence(word)
+	if s.err = s.j.write(w[0]); s.err != nil {
+		return false
+	}
+	s.n++
+	return true
+}
@@ -0,0 +1,81 @@
+package markov
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const punctCorpus = "the cat sat , on the mat . the dog ( a big one ) sat on the cat ; the end ."
+
+// punctCorpora are corpora with punctuation split off, attaching to the
+// left and to the right, with blank lines between paragraphs and with
+// capitals a case-folded model has to restore.
+var punctCorpora = []struct {
+	name string
+	text string
+	opts BuildOptions
+}{
+	{"punctuation", punctCorpus, BuildOptions{}},
+	{"brackets", "she said ( twice ) : \" no ! \" , and [ then ] left ... why ? { ok } ; fine .", BuildOptions{}},
+	{"paragraphs", "first , a line .\n\nthen ( another ) one !\n\n\" quoted \" , it said ; done .", BuildOptions{Paragraphs: true, NoEndToken: true}},
+	{"lowercase", "Paris is big . In Paris , we ate ( a lot ) . We left Paris ! Then London , too .", BuildOptions{Lowercase: true}},
+	{"unicode", "« bonjour » , dit-il — puis : « au revoir ! » … fin .", BuildOptions{}},
+}
+
+// TestGenerateToMatchesBuffered checks that streamed text is the text
+// WriteText and WritePretty write for the same seed, but for their newline.
+// Case-folded models capitalize as they stream, drawing in another order
+// than GenerateWords; for them the pretty stream must be the plain stream
+// of the same seed detokenized.
+func TestGenerateToMatchesBuffered(t *testing.T) {
+	for _, corpus := range punctCorpora {
+		for _, prefixLen := range []int{1, 2} {
+			c := newChain(prefixLen)
+			if _, err := c.BuildReaderOpts("", strings.NewReader(corpus.text), corpus.opts); err != nil {
+				t.Fatal(err)
+			}
+			for seed := int64(1); seed <= 20; seed++ {
+				stream := func(pretty bool) (string, int) {
+					var b bytes.Buffer
+					n, err := c.GenerateToOpts(&b, 40, GenerateOptions{Rand: rand.New(rand.NewSource(seed)), Pretty: pretty})
+					if err != nil {
+						t.Fatal(err)
+					}
+					return b.String(), n
+				}
+				plain, n := stream(false)
+				pretty, prettyN := stream(true)
+				var words []string
+				if corpus.opts.Lowercase {
+					words = strings.Fields(plain)
+				} else {
+					words = c.GenerateWords(40, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+				}
+				var wantPlain, wantPretty bytes.Buffer
+				WriteText(&wantPlain, words)
+				WritePretty(&wantPretty, words)
+				if n != len(words) || prettyN != len(words) || plain+"\n" != wantPlain.String() || pretty+"\n" != wantPretty.String() {
+					t.Errorf("%s, prefix length %d, seed %d: streamed %d and %d words\n%q\n%q\nwant %d words\n%q\n%q",
+						corpus.name, prefixLen, seed, n, prettyN, plain, pretty, len(words), wantPlain.String(), wantPretty.String())
+				}
+			}
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestGenerateToWriterError(t *testing.T) {
+	c := newChain(1)
+	if _, err := c.BuildReaderOpts("", strings.NewReader(punctCorpus), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GenerateTo(failingWriter{}, 100000); err == nil || err.Error() != "disk full" {
+		t.Errorf("got error %v, want disk full", err)
+	}
+}
@@ -0,0 +1,49 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// stripHeader returns the rest of in after the first skipLines lines and,
+// if until is set, after the first remaining line matching until. It also
+// returns the number of words removed and whether until matched; when it
+// does not, nothing but the skipped lines is removed.
+func stripHeader(in io.Reader, skipLines int, until *regexp.Regexp) (io.Reader, int, bool, error) {
+	br := bufio.NewReaderSize(in, 64*1024)
+	stripped := 0
+	for i := 0; i < skipLines; i++ {
+		line, err := br.ReadBytes('\n')
+		stripped += len(bytes.Fields(line))
+		if err == io.EOF {
+			return bytes.NewReader(nil), stripped, until == nil, nil
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+	}
+	if until == nil {
+		return br, stripped, true, nil
+	}
+
+	// The header has to be held back until it is known whether the
+	// delimiter comes at all.
+	var header bytes.Buffer
+	words := 0
+	for {
+		line, err := br.ReadBytes('\n')
+		header.Write(line)
+		words += len(bytes.Fields(line))
+		if until.Match(bytes.TrimRight(line, "\r\n")) {
+			return br, stripped + words, true, nil
+		}
+		if err == io.EOF {
+			return &header, stripped, false, nil
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package markov
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// gutenbergDoc is a text with a license header in capitals, as the texts
+// of Project Gutenberg have one.
+const gutenbergDoc = `THE PROJECT GUTENBERG EBOOK OF TOM SAWYER
+RELEASED UNDER THE LICENSE
+*** START OF THE PROJECT GUTENBERG EBOOK TOM SAWYER ***
+tom! no answer.
+the old lady pulled her spectacles down.
+`
+
+// chainWords returns every word of the prefixes and suffixes of c.
+func chainWords(c *Chain) map[string]bool {
+	words := make(map[string]bool)
+	for key, suf := range c.chain {
+		for _, w := range splitKey(key) {
+			words[w] = true
+		}
+		for _, s := range suf {
+			words[s.word] = true
+		}
+	}
+	return words
+}
+
+func TestStripHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     BuildOptions
+		stripped int
+		warning  bool
+	}{
+		{"until", BuildOptions{StripHeaderUntil: regexp.MustCompile(`START OF(.*)`)}, 21, false},
+		{"lines", BuildOptions{SkipLines: 3}, 21, false},
+		{"tokens", BuildOptions{SkipTokens: 21}, 21, false},
+		{"lines and tokens", BuildOptions{SkipLines: 2, SkipTokens: 10}, 21, false},
+		{"lines then until", BuildOptions{SkipLines: 1, StripHeaderUntil: regexp.MustCompile(`\*\*\*$`)}, 21, false},
+		{"no match", BuildOptions{StripHeaderUntil: regexp.MustCompile(`END OF`)}, 0, true},
+	}
+	for _, tt := range tests {
+		c := newChain(2)
+		report, err := c.BuildReaderOpts("tom.txt", strings.NewReader(gutenbergDoc), tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := report.Files[0].Stripped; got != tt.stripped {
+			t.Errorf("%s: %d tokens stripped, want %d", tt.name, got, tt.stripped)
+		}
+		warned := false
+		for _, w := range report.Warnings {
+			warned = warned || strings.Contains(w, "nothing stripped")
+		}
+		if warned != tt.warning {
+			t.Errorf("%s: warnings %q, want one about the missing delimiter: %v", tt.name, report.Warnings, tt.warning)
+		}
+		words := chainWords(c)
+		if !words["tom!"] || !words["spectacles"] {
+			t.Errorf("%s: the text after the header is missing from the chain", tt.name)
+		}
+		// The header is all capitals, and the text has none.
+		for w := range words {
+			if w != EndToken && strings.ToLower(w) != w && !tt.warning {
+				t.Errorf("%s: header word %q is in the chain", tt.name, w)
+			}
+		}
+		if tt.warning && !words["LICENSE"] {
+			t.Errorf("%s: a file without the delimiter lost its header", tt.name)
+		}
+		// Generation starts where the text does.
+		if got := c.Suffixes(c.startPrefix()); !tt.warning && (len(got) != 1 || got[0].word != "tom!") {
+			t.Errorf("%s: the text starts with %v, want tom!", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package markov
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/synthcorpus"
+)
+
+// synthCorpus returns a corpus of the given number of tokens with the
+// other parameters of synthcorpus.DefaultOptions, for benchmarks.
+func synthCorpus(tb testing.TB, tokens int) []byte {
+	tb.Helper()
+	opts := synthcorpus.DefaultOptions()
+	opts.Tokens = tokens
+	var buf bytes.Buffer
+	if err := synthcorpus.Write(&buf, opts); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// synthChain returns a chain of prefix length 2 built from synthCorpus.
+func synthChain(tb testing.TB, tokens int) *Chain {
+	tb.Helper()
+	c := newChain(2)
+	if _, err := c.BuildReader(bytes.NewReader(synthCorpus(tb, tokens))); err != nil {
+		tb.Fatal(err)
+	}
+	return c
+}
+
+func BenchmarkBuildSynth(b *testing.B) {
+	corpus := synthCorpus(b, 200000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(corpus)))
+	for i := 0; i < b.N; i++ {
+		c := newChain(2)
+		if _, err := c.BuildReader(bytes.NewReader(corpus)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateSynth(b *testing.B) {
+	c := synthChain(b, 200000)
+	opts := GenerateOptions{Rand: rand.New(rand.NewSource(1))}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GenerateWords(100, opts)
+	}
+}
@@ -0,0 +1,142 @@
+package markov
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Bounds of the adaptive temperature when GenerateOptions leaves them zero.
+const (
+	DefaultMinTemperature = 0.25
+	DefaultMaxTemperature = 4
+)
+
+// tempered reports whether opts reshape the distribution of every draw.
+func (opts GenerateOptions) tempered() bool {
+	return opts.TargetEntropy > 0 || opts.Temperature > 0 && opts.Temperature != 1
+}
+
+// temperatureFor returns the temperature to draw from weights with: the
+// fixed Temperature, or with TargetEntropy the one within the bounds whose
+// tempered distribution has an entropy closest to the target.
+func (opts GenerateOptions) temperatureFor(weights []float64) float64 {
+	if opts.TargetEntropy <= 0 {
+		if opts.Temperature > 0 {
+			return opts.Temperature
+		}
+		return 1
+	}
+	lo, hi := opts.MinTemperature, opts.MaxTemperature
+	if lo <= 0 {
+		lo = DefaultMinTemperature
+	}
+	if hi <= 0 {
+		hi = DefaultMaxTemperature
+	}
+	if hi < lo {
+		hi = lo
+	}
+	positive := 0
+	for _, w := range weights {
+		if w > 0 {
+			positive++
+		}
+	}
+	if positive < 2 {
+		return 1 // there is no choice to warm or cool
+	}
+	// The entropy grows with the temperature, so bisect on its logarithm.
+	if entropyBits(temper(weights, lo)) >= opts.TargetEntropy {
+		return lo
+	}
+	if entropyBits(temper(weights, hi)) <= opts.TargetEntropy {
+		return hi
+	}
+	a, b := math.Log(lo), math.Log(hi)
+	for i := 0; i < 40; i++ {
+		m := (a + b) / 2
+		if entropyBits(temper(weights, math.Exp(m))) < opts.TargetEntropy {
+			a = m
+		} else {
+			b = m
+		}
+	}
+	return math.Exp((a + b) / 2)
+}
+
+// temper returns the probabilities of weights raised to the power 1/t.
+func temper(weights []float64, t float64) []float64 {
+	p := make([]float64, len(weights))
+	max := math.Inf(-1)
+	for i, w := range weights {
+		if w > 0 {
+			p[i] = math.Log(w) / t
+			max = math.Max(max, p[i])
+		}
+	}
+	var total float64
+	for i, w := range weights {
+		if w > 0 {
+			p[i] = math.Exp(p[i] - max)
+			total += p[i]
+		} else {
+			p[i] = 0
+		}
+	}
+	for i := range p {
+		p[i] /= total
+	}
+	return p
+}
+
+// entropyBits returns the entropy in bits of the distribution p.
+func entropyBits(p []float64) float64 {
+	var h float64
+	for _, x := range p {
+		if x > 0 {
+			h -= x * math.Log2(x)
+		}
+	}
+	return math.Max(0, h)
+}
+
+// floatWeights returns weights as floats, multiplied by factors if any.
+func floatWeights(weights []int, factors []float64) []float64 {
+	w := make([]float64, len(weights))
+	for i, x := range weights {
+		w[i] = float64(x)
+		if factors != nil {
+			w[i] *= factors[i]
+		}
+	}
+	return w
+}
+
+// argmax returns the index of the highest of weights, the choice with the
+// smallest word among equals.
+func argmax(weights []float64, choices []Suffix) int {
+	best := 0
+	for i, w := range weights {
+		if w > weights[best] || w == weights[best] && choices[i].word < choices[best].word {
+			best = i
+		}
+	}
+	return best
+}
+
+// drawTempered returns the index drawn from the probabilities p.
+func drawTempered(p []float64, r *rand.Rand) int {
+	u := r.Float64()
+	last := 0
+	for i, x := range p {
+		if x <= 0 {
+			continue
+		}
+		if u < x {
+			return i
+		}
+		u -= x
+		last = i
+	}
+	return last // rounding left u just above the total
+}
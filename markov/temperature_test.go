@@ -0,0 +1,120 @@
+package markov
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/xiaoxulv/go_mark/internal/sampletest"
+)
+
+// peakedFlatChain returns a chain with prefixes of one word that cycles
+// through a peaked prefix, "peak", almost always followed by "a", and a
+// flat one, "flat", followed by eight words about equally often.
+func peakedFlatChain() *Chain {
+	c := newChain(1)
+	c.add(c.startPrefix().key(), "peak", 1)
+	for word, n := range map[string]int{"a": 97, "b": 1, "c": 1, "d": 1} {
+		c.add(Prefix{"peak"}.key(), word, n)
+		c.add(Prefix{word}.key(), "flat", 1)
+	}
+	for i := 0; i < 8; i++ {
+		w := fmt.Sprint("w", i)
+		c.add(Prefix{"flat"}.key(), w, 12-i)
+		c.add(Prefix{w}.key(), "peak", 1)
+	}
+	return c
+}
+
+// TestAdaptiveTemperature checks that aiming at an entropy warms the
+// peaked prefix and cools the flat one, compared with drawing at the
+// counts, and that the annotations show the temperature of every step.
+func TestAdaptiveTemperature(t *testing.T) {
+	c := peakedFlatChain()
+	const target = 1.5
+	// The entropy of the counts after each prefix.
+	baseline := make(map[string]float64)
+	for _, p := range []string{"peak", "flat"} {
+		var w []float64
+		for _, s := range c.Suffixes(Prefix{p}) {
+			w = append(w, float64(s.frequency))
+		}
+		baseline[p] = entropyBits(temper(w, 1))
+	}
+
+	tokens := c.GenerateAnnotated(200, GenerateOptions{Rand: rand.New(rand.NewSource(1)), TargetEntropy: target})
+	seen := make(map[string]int)
+	prev := ""
+	for i, tok := range tokens {
+		switch prev {
+		case "peak":
+			if tok.Temperature <= 1 || tok.Entropy <= baseline[prev] {
+				t.Errorf("token %d after peak: temperature %v, entropy %v; want warmer than 1 and more than %v", i, tok.Temperature, tok.Entropy, baseline[prev])
+			}
+			if math.Abs(tok.Entropy-target) > 1e-6 {
+				t.Errorf("token %d after peak: entropy %v, want the target %v", i, tok.Entropy, target)
+			}
+		case "flat":
+			// Even the coolest temperature allowed leaves more entropy
+			// than the target, so it is chosen.
+			if tok.Temperature != DefaultMinTemperature || tok.Entropy >= baseline[prev] {
+				t.Errorf("token %d after flat: temperature %v, entropy %v; want %v and less than %v", i, tok.Temperature, tok.Entropy, DefaultMinTemperature, baseline[prev])
+			}
+		default:
+			// Without a choice there is nothing to temper.
+			if tok.Temperature != 1 {
+				t.Errorf("token %d after %q: temperature %v, want 1", i, prev, tok.Temperature)
+			}
+		}
+		seen[prev]++
+		prev = tok.Text
+	}
+	if seen["peak"] == 0 || seen["flat"] == 0 {
+		t.Fatalf("the text never left peak and flat: %v", seen)
+	}
+
+	// A fixed temperature applies everywhere, however peaked.
+	for _, tok := range c.GenerateAnnotated(50, GenerateOptions{Rand: rand.New(rand.NewSource(1)), Temperature: 0.5}) {
+		if tok.Temperature != 0.5 {
+			t.Fatalf("token %q drawn at temperature %v, want 0.5", tok.Text, tok.Temperature)
+		}
+	}
+}
+
+// TestTemperatureEdges checks that Temperature 1, and 0, draw exactly as
+// without a temperature, that a temperature near 0 is greedy, and that
+// huge counts reshape without overflowing.
+func TestTemperatureEdges(t *testing.T) {
+	c := peakedFlatChain()
+	for seed := int64(1); seed <= 20; seed++ {
+		plain := c.GenerateWords(50, GenerateOptions{Rand: rand.New(rand.NewSource(seed))})
+		for _, temp := range []float64{0, 1} {
+			got := c.GenerateWords(50, GenerateOptions{Temperature: temp, Rand: rand.New(rand.NewSource(seed))})
+			if !reflect.DeepEqual(got, plain) {
+				t.Fatalf("seed %d: temperature %v generated\n%q\nwant as without one\n%q", seed, temp, got, plain)
+			}
+		}
+		greedy := c.GenerateWords(50, GenerateOptions{Greedy: true})
+		if got := c.GenerateWords(50, GenerateOptions{Temperature: 1e-6, Rand: rand.New(rand.NewSource(seed))}); !reflect.DeepEqual(got, greedy) {
+			t.Fatalf("seed %d: temperature 1e-6 generated\n%q\nwant the greedy\n%q", seed, got, greedy)
+		}
+	}
+
+	huge := newChain(1)
+	huge.add(Prefix{"x"}.key(), "a", 1<<50)
+	huge.add(Prefix{"x"}.key(), "b", 1<<51)
+	for _, tt := range []struct {
+		temp float64
+		want map[string]float64
+	}{
+		{0.5, map[string]float64{"a": 1, "b": 4}},
+		{0.01, map[string]float64{"b": 1}},
+		{100, map[string]float64{"a": 1, "b": math.Pow(2, 0.01)}},
+	} {
+		t.Run(fmt.Sprint("temperature ", tt.temp), func(t *testing.T) {
+			sampletest.Check(t, drawAfter(t, huge, []string{"x"}, GenerateOptions{Temperature: tt.temp}), normalize(tt.want), sampletest.Options{})
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package markov
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// tinyCorpus is the text TinyModel is built from.
+const tinyCorpus = "the cat sat on the mat. the dog sat on the cat. the cat ran."
+
+// TinyModelHash is TinyModel().Hash(). It changes only when the model
+// file format does; update it deliberately, together with the format.
+const TinyModelHash = "526dc4e20538a23243dfd1314f6e000713d091aa00ba6def49b8df64e7e19ba4"
+
+// TinyModel returns a small, fixed chain for examples, demos and checks:
+// prefix length 2, built from the fifteen words of
+//
+//	the cat sat on the mat. the dog sat on the cat. the cat ran.
+//
+// as Build builds it from a file holding that text. Its thirteen prefixes
+// are the two start states, spelled `"" ""` and `"" the` in model files,
+// and the word pairs of the text.
+// "the cat" is followed by "sat" once and "ran." once, "sat on" by "the"
+// twice, "on the" by "mat." and "cat." once each, every other prefix by a
+// single word, and "cat ran." by EndToken, so generation ends after
+// "ran.". Every call returns a new chain.
+func TinyModel() *Chain {
+	c := newChain(2)
+	p := c.startPrefix()
+	for _, word := range append(strings.Fields(tinyCorpus), EndToken) {
+		c.add(p.key(), word, 1)
+		p.Shift(word)
+	}
+	return c
+}
+
+// Hash returns a hex SHA-256 digest of the model file of c, so that two
+// chains have the same hash exactly when WriteFreTable writes the same
+// bytes for them.
+func (c *Chain) Hash() string {
+	h := sha256.New()
+	c.WriteTo(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package markov
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestTinyModelHash fails whenever the model file of TinyModel changes. If
+// that is intended, as with a new format version, update TinyModelHash in
+// the same change, so that review sees it.
+func TestTinyModelHash(t *testing.T) {
+	if got := TinyModel().Hash(); got != TinyModelHash {
+		t.Errorf("TinyModel hashes to %s, want TinyModelHash %s", got, TinyModelHash)
+	}
+}
+
+// TestTinyModel checks the structure TinyModel documents.
+func TestTinyModel(t *testing.T) {
+	c := TinyModel()
+	if c.PrefixLen() != 2 || len(c.chain) != 13 {
+		t.Errorf("TinyModel has prefix length %d and %d prefixes, want 2 and 13", c.PrefixLen(), len(c.chain))
+	}
+	for _, tt := range []struct {
+		prefix Prefix
+		want   []Suffix
+	}{
+		{Prefix{"", ""}, []Suffix{{"the", 1}}},
+		{Prefix{"", "the"}, []Suffix{{"cat", 1}}},
+		{Prefix{"the", "cat"}, []Suffix{{"sat", 1}, {"ran.", 1}}},
+		{Prefix{"sat", "on"}, []Suffix{{"the", 2}}},
+		{Prefix{"on", "the"}, []Suffix{{"mat.", 1}, {"cat.", 1}}},
+		{Prefix{"cat", "ran."}, []Suffix{{EndToken, 1}}},
+	} {
+		if got := c.Suffixes(tt.prefix); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suffixes of %q = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	// Built from a file of its text, the table is the same; the build
+	// only adds metadata.
+	built := newChain(2)
+	if _, err := built.BuildReaderOpts("tiny.txt", strings.NewReader(tinyCorpus), BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(built.chain, c.chain) {
+		t.Errorf("TinyModel differs from a build of its text")
+	}
+
+	// Every call returns a chain of its own.
+	if err := c.AddText(strings.NewReader("the cat slept.")); err != nil {
+		t.Fatal(err)
+	}
+	if TinyModel().Hash() != TinyModelHash {
+		t.Errorf("training one TinyModel changed the next")
+	}
+}
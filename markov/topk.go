@@ -0,0 +1,47 @@
+package markov
+
+import "sort"
+
+// truncate zeroes the weights of the choices outside the k best and then
+// outside the smallest set of best ones holding a share p of the remaining
+// weight, ranked by weight times factor (if any) and then by word. k <= 0
+// and p outside (0, 1) leave the choices alone.
+func truncate(weights []int, factors []float64, choices []Suffix, k int, p float64) {
+	if k <= 0 && (p <= 0 || p >= 1) {
+		return
+	}
+	fw := floatWeights(weights, factors)
+	order := make([]int, 0, len(weights))
+	for i, w := range fw {
+		if w > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if fw[i] != fw[j] {
+			return fw[i] > fw[j]
+		}
+		return choices[i].word < choices[j].word
+	})
+	keep := len(order)
+	if k > 0 && k < keep {
+		keep = k
+	}
+	if p > 0 && p < 1 {
+		var total float64
+		for _, i := range order[:keep] {
+			total += fw[i]
+		}
+		var mass float64
+		for n, i := range order[:keep] {
+			if mass += fw[i]; mass >= p*total {
+				keep = n + 1
+				break
+			}
+		}
+	}
+	for _, i := range order[keep:] {
+		weights[i] = 0
+	}
+}
@@ -0,0 +1,64 @@
+package markov
+
+import "sort"
+
+// topN selects the n best of a stream of items in O(n) memory, for the
+// "top n" lists of huge models that should not sort everything. better
+// must be a strict total order, so that the result is the same as the
+// first n items of a full sort.
+type topN[T any] struct {
+	n      int
+	better func(a, b T) bool
+	heap   []T // the worst item kept at the root
+}
+
+func newTopN[T any](n int, better func(a, b T) bool) *topN[T] {
+	if n < 0 {
+		n = 0
+	}
+	return &topN[T]{n: n, better: better}
+}
+
+// push offers x.
+func (t *topN[T]) push(x T) {
+	h := t.heap
+	if len(h) < t.n {
+		h = append(h, x)
+		for i := len(h) - 1; i > 0; {
+			parent := (i - 1) / 2
+			if !t.better(h[parent], h[i]) {
+				break
+			}
+			h[parent], h[i] = h[i], h[parent]
+			i = parent
+		}
+		t.heap = h
+		return
+	}
+	if t.n == 0 || !t.better(x, h[0]) {
+		return
+	}
+	h[0] = x
+	for i := 0; ; {
+		worst, l, r := i, 2*i+1, 2*i+2
+		if l < len(h) && t.better(h[worst], h[l]) {
+			worst = l
+		}
+		if r < len(h) && t.better(h[worst], h[r]) {
+			worst = r
+		}
+		if worst == i {
+			break
+		}
+		h[i], h[worst] = h[worst], h[i]
+		i = worst
+	}
+}
+
+// sorted returns the items kept, best first. The selector must not be
+// used afterwards.
+func (t *topN[T]) sorted() []T {
+	h := t.heap
+	sort.Slice(h, func(i, j int) bool { return t.better(h[i], h[j]) })
+	return h
+}
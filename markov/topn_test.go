@@ -0,0 +1,99 @@
+package markov
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestTopN checks that the selector keeps the same items, in the same
+// order, as the first n of a full sort, with many ties.
+func TestTopN(t *testing.T) {
+	type item struct{ count, id int }
+	better := func(a, b item) bool {
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return a.id < b.id
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{0, 1, 7, 100, 1000} {
+		items := make([]item, size)
+		for i := range items {
+			items[i] = item{r.Intn(10), i}
+		}
+		r.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		sorted := append([]item(nil), items...)
+		sort.Slice(sorted, func(i, j int) bool { return better(sorted[i], sorted[j]) })
+		for _, n := range []int{-1, 0, 1, 2, 25, size, size + 5} {
+			top := newTopN(n, better)
+			for _, x := range items {
+				top.push(x)
+			}
+			want := sorted[:max(0, min(n, size))]
+			if got := top.sorted(); len(got) != len(want) || (len(got) > 0 && !reflect.DeepEqual(got, want)) {
+				t.Errorf("top %d of %d = %v, want %v", n, size, got, want)
+			}
+		}
+	}
+}
+
+// TestTopPrefixesFullSort checks TopPrefixes against a full sort of every
+// prefix of a chain.
+func TestTopPrefixesFullSort(t *testing.T) {
+	c := synthChain(t, 20000)
+	var all []PrefixCount
+	for _, key := range sortedKeys(c.chain) {
+		pc := PrefixCount{Prefix: sentinelPrefix(key), Suffixes: len(c.chain[key])}
+		for _, s := range c.chain[key] {
+			pc.Tokens += s.frequency
+		}
+		all = append(all, pc)
+	}
+	// sortedKeys is in byte order, so a stable sort by count breaks ties
+	// as TopPrefixes does.
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Tokens > all[j].Tokens })
+	for _, n := range []int{1, 25, 1000} {
+		if got := c.TopPrefixes(n); !reflect.DeepEqual(got, all[:n]) {
+			t.Errorf("TopPrefixes(%d) differs from a full sort", n)
+		}
+	}
+}
+
+// TestTopNAllocs checks that the memory of a selection does not grow with
+// the number of items offered.
+func TestTopNAllocs(t *testing.T) {
+	allocs := func(items int) float64 {
+		return testing.AllocsPerRun(10, func() {
+			top := newTopN(25, func(a, b int) bool { return a > b })
+			for i := 0; i < items; i++ {
+				top.push(i)
+			}
+			top.sorted()
+		})
+	}
+	if small, large := allocs(100), allocs(100000); large > small {
+		t.Errorf("top 25 of 100000 items allocates %v times, of 100 items %v times", large, small)
+	}
+}
+
+// BenchmarkTopN selects the top 25 of a million items; the allocations
+// reported are those of the selector alone, and do not grow with the
+// number of items.
+func BenchmarkTopN(b *testing.B) {
+	counts := make([]int, 1_000_000)
+	r := rand.New(rand.NewSource(1))
+	for i := range counts {
+		counts[i] = r.Intn(1000)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		top := newTopN(25, func(a, b int) bool { return a > b })
+		for _, n := range counts {
+			top.push(n)
+		}
+		top.sorted()
+	}
+}
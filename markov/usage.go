@@ -0,0 +1,24 @@
+package markov
+
+// A Chain is safe for concurrent use: any number of goroutines may
+// generate from it, look up suffixes or write it out at the same time,
+// while calls that modify it (Build, AddText, Merge, Prune, ...) wait for
+// them and run alone. Generation never modifies the chain; the caches some
+// readers fill in on demand, the lazily parsed lines of a chain opened
+// with OpenFreTableMmap and the prefix index of NearestPrefixes, are
+// guarded separately. A Session or a *rand.Rand passed in GenerateOptions
+// belongs to one goroutine at a time.
+
+// beginRead marks the start of a read-only call; the returned function
+// marks its end.
+func (c *Chain) beginRead() func() {
+	c.mu.RLock()
+	return c.mu.RUnlock
+}
+
+// beginWrite marks the start of a mutating call; the returned function
+// marks its end.
+func (c *Chain) beginWrite() func() {
+	c.mu.Lock()
+	return c.mu.Unlock
+}
@@ -0,0 +1,260 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Problem is a defect found in a model file.
+type Problem struct {
+	Offset int64  `json:"offset"` // byte offset of the start of the line
+	Line   int    `json:"line"`
+	Msg    string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("offset %d (line %d): %s", p.Offset, p.Line, p.Msg)
+}
+
+// ValidateSummary describes a model file checked by ValidateStream.
+type ValidateSummary struct {
+	PrefixLen     int   `json:"prefix_len"`
+	Version       int   `json:"version"` // of the format, see Chain.Version
+	Lines         int   `json:"lines"`
+	Prefixes      int   `json:"prefixes"`
+	SuffixEntries int   `json:"suffix_entries"`
+	Bytes         int64 `json:"bytes"`
+	Problems      int   `json:"problems"`
+	// Unchecked is set for files of versions before 4, which have no
+	// checksum record, so that damage cut at a line boundary goes
+	// unnoticed; ReadFreTable returns them with a *ChecksumError whose
+	// Missing is set.
+	Unchecked bool `json:"unchecked,omitempty"`
+}
+
+// ValidateStream checks a model in the format written by WriteFreTable line
+// by line without building a chain, so memory use is bounded by the longest
+// line. It checks the header, the field count of every line, that
+// frequencies are positive integers, or probabilities with the decimals the
+// header gives, see Chain.WriteProbabilities, that no suffix repeats within a line
+// and that extension records are well formed. Like ReadFreTable it sums
+// the lines and checks the checksum record against them: a file of
+// version 4 or later without one is a problem, while older files, which
+// never had one, are only marked Unchecked. Each problem is passed to
+// report; the returned error is only set when r cannot be read.
+func ValidateStream(r io.Reader, report func(Problem)) (ValidateSummary, error) {
+	var sum ValidateSummary
+	problem := func(off int64, line int, format string, args ...interface{}) {
+		sum.Problems++
+		report(Problem{off, line, fmt.Sprintf(format, args...)})
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	var off int64
+	seen := make(map[string]bool)
+	decimals := 0          // of a model of probabilities
+	crc := crc32.NewIEEE() // of the lines before the checksum record
+	checked := false
+	for {
+		raw, err := br.ReadBytes('\n')
+		if len(raw) == 0 && err != nil {
+			if err != io.EOF {
+				return sum, err
+			}
+			break
+		}
+		sum.Lines++
+		lineOff := off
+		off += int64(len(raw))
+		line := bytes.TrimSuffix(raw, []byte("\n"))
+		if checked {
+			if len(line) > 0 {
+				problem(lineOff, sum.Lines, "line follows the checksum record")
+			}
+			continue
+		}
+		if sum.Lines > 1 && isChecksumRecord(string(line)) {
+			if msg := checkChecksum(string(line), crc.Sum32()); msg != "" {
+				problem(lineOff, sum.Lines, "%s", msg)
+			}
+			checked = true
+			continue
+		}
+		crc.Write(line)
+		crc.Write(newline)
+
+		if sum.Lines == 1 {
+			n, version, perr := parseHeader(string(line))
+			if perr != nil {
+				problem(lineOff, 1, "%v", perr)
+				return sum, nil
+			}
+			sum.PrefixLen, sum.Version = n, version
+			if decimals, err = headerDecimals(string(line)); err != nil {
+				problem(lineOff, 1, "%v", err)
+				return sum, nil
+			}
+			continue
+		}
+		if len(line) > 0 && line[0] == '\t' {
+			fields, err := readFields(string(line), sum.Version, sum.PrefixLen)
+			if err != nil {
+				problem(lineOff, sum.Lines, "%v", err)
+				continue
+			}
+			if msg := checkRecord(fields); msg != "" {
+				problem(lineOff, sum.Lines, "%s", msg)
+			}
+			continue
+		}
+
+		words := strings.Split(strings.TrimSuffix(string(line), " "), " ")
+		if len(words) < sum.PrefixLen+2 || (len(words)-sum.PrefixLen)%2 != 0 {
+			problem(lineOff, sum.Lines, "expected %d prefix words followed by word/frequency pairs, got %d fields", sum.PrefixLen, len(words))
+			continue
+		}
+		sum.Prefixes++
+		if sum.Version >= escapedVersion {
+			if err := unescapeFields(words); err != nil {
+				problem(lineOff, sum.Lines, "%v", err)
+			}
+		}
+		for k := range seen {
+			delete(seen, k)
+		}
+		for i := sum.PrefixLen; i+1 < len(words); i += 2 {
+			sum.SuffixEntries++
+			word, freq := words[i], words[i+1]
+			if word == "" {
+				problem(lineOff, sum.Lines, "empty suffix word")
+			}
+			if f, err := parseFrequency(freq, decimals); err != nil || f <= 0 {
+				problem(lineOff, sum.Lines, "expected positive frequency for %q, got %q", word, freq)
+			}
+			if seen[word] {
+				problem(lineOff, sum.Lines, "suffix %q listed twice", word)
+			}
+			seen[word] = true
+		}
+	}
+	sum.Bytes = off
+	if sum.Lines == 0 {
+		problem(0, 0, "file is empty")
+		return sum, nil
+	}
+	if !checked {
+		if msg := missingChecksum(sum.Version); msg != "" {
+			problem(off, sum.Lines, "%s", msg)
+		} else {
+			sum.Unchecked = true
+		}
+	}
+	return sum, nil
+}
+
+// checkRecord returns what is wrong with an extension record, or "".
+// Unknown records are accepted, as ReadFreTable ignores them.
+func checkRecord(fields []string) string {
+	if len(fields) == 0 {
+		return "empty extension record"
+	}
+	for _, f := range fields {
+		if checkWord(f) != nil {
+			return fmt.Sprintf("field %q contains a NUL byte", f)
+		}
+	}
+	isInt := func(s string) bool { _, err := strconv.Atoi(s); return err == nil }
+	switch fields[0] {
+	case "reservoir":
+		if len(fields) < 2 {
+			return "reservoir record without a class"
+		}
+	case "prior":
+		if len(fields) != 3 || !isInt(fields[2]) {
+			return "prior record must be: prior word frequency"
+		}
+	case "case":
+		if len(fields) == 1 {
+			return ""
+		}
+		if len(fields) != 6 || !isInt(fields[2]) || !isInt(fields[3]) || !isInt(fields[4]) || !isInt(fields[5]) {
+			return "case record must be: case word initial initialCaps mid midCaps"
+		}
+	case "transform":
+		if len(fields) != 2 {
+			return "transform record must be: transform name"
+		}
+	case "preset":
+		if len(fields) < 3 {
+			return "preset record must be: preset name flag..."
+		}
+	case "choices":
+		if len(fields) != 2 || !isInt(fields[1]) {
+			return "choices record must be: choices k"
+		}
+	case "position":
+		if len(fields) < 2+PositionBuckets {
+			return "position record must be: position prefix... followed by 10 counts"
+		}
+		for _, f := range fields[len(fields)-PositionBuckets:] {
+			if n, err := strconv.ParseUint(f, 10, 16); err != nil || n > 1<<16-1 {
+				return "position record must be: position prefix... followed by 10 counts"
+			}
+		}
+	case "start":
+		if len(fields) < 3 || !isInt(fields[len(fields)-1]) {
+			return "start record must be: start prefix... count"
+		}
+	case "paragraph":
+		if len(fields) != 3 || !isInt(fields[1]) || !isInt(fields[2]) {
+			return "paragraph record must be: paragraph length count"
+		}
+	case "meta":
+		return checkMetaRecord(fields)
+	case "source":
+		if len(fields) != 3 || !isInt(fields[2]) {
+			return "source record must be: source name tokens"
+		}
+	case "offset":
+		if len(fields) != 3 {
+			return "offset record must be: offset name bytes"
+		}
+		if n, err := strconv.ParseInt(fields[2], 10, 64); err != nil || n < 0 {
+			return "offset must be a count of bytes"
+		}
+	}
+	return ""
+}
+
+// Unreachable returns the number of prefixes of c that generation can
+// never reach from the start state.
+func (c *Chain) Unreachable() int {
+	c.materialize()
+	start := c.startPrefix()
+	seen := map[string]bool{start.key(): true}
+	queue := []string{start.key()}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		p := splitKey(key)
+		for _, s := range c.chain[key] {
+			next := c.shiftedKey(p, s.word)
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	n := 0
+	for key := range c.chain {
+		if !seen[key] {
+			n++
+		}
+	}
+	return n
+}
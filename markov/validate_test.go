@@ -0,0 +1,116 @@
+package markov
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestValidateStream(t *testing.T) {
+	var model bytes.Buffer
+	if _, err := TinyModel().WriteTo(&model); err != nil {
+		t.Fatal(err)
+	}
+	var problems []Problem
+	sum, err := ValidateStream(bytes.NewReader(model.Bytes()), func(p Problem) { problems = append(problems, p) })
+	if err != nil || problems != nil {
+		t.Fatalf("TinyModel: %v, %v", problems, err)
+	}
+	want := ValidateSummary{PrefixLen: 2, Version: 4, Lines: 15, Prefixes: 13, SuffixEntries: 15, Bytes: int64(model.Len())}
+	if sum != want {
+		t.Errorf("summary %+v, want %+v", sum, want)
+	}
+
+	good := model.String()
+	lines := strings.SplitAfter(good, "\n")
+	// edit replaces old by new in good and sums the result again, so that
+	// only the edit is wrong with it.
+	edit := func(old, new string) string {
+		body := strings.Replace(strings.Join(lines[:len(lines)-2], ""), old, new, 1)
+		return body + checksumRecord(crc32.ChecksumIEEE([]byte(body))) + "\n"
+	}
+	for _, tt := range []struct {
+		name, model string
+		line        int
+		want        string
+	}{
+		{"empty", "", 0, "file is empty"},
+		{"header", "prefix two\n", 1, ""},
+		{"fields", edit("cat sat on 1 \n", "cat sat on \n"), 5, "word/frequency pairs"},
+		{"zero", edit("cat sat on 1 \n", "cat sat on 0 \n"), 5, `positive frequency for "on"`},
+		{"twice", edit("cat sat on 1 \n", "cat sat on 1 on 2 \n"), 5, `suffix "on" listed twice`},
+		{"escape", edit("cat sat on 1 \n", "cat sat o%zz 1 \n"), 5, "%zz"},
+		{"record", edit("the mat. the 1 \n", "the mat. the 1 \n\toffset x\n"), 15, "offset"},
+		{"truncated", strings.Join(lines[:8], ""), 8, "no checksum record"},
+		{"corrupted", strings.Replace(good, "cat sat on 1 \n", "cat sat on 2 \n", 1), 15, "does not match"},
+		{"after checksum", good + "the cat 1 \n", 16, "follows the checksum record"},
+		{"checksum", strings.Replace(good, "checksum crc32 ", "checksum crc32 x", 1), 15, "malformed checksum"},
+	} {
+		var got []Problem
+		sum, err := ValidateStream(strings.NewReader(tt.model), func(p Problem) { got = append(got, p) })
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != 1 || sum.Problems != 1 || got[0].Line != tt.line || !strings.Contains(got[0].Msg, tt.want) {
+			t.Errorf("%s: problems %v, want one on line %d about %q", tt.name, got, tt.line, tt.want)
+		}
+		if sum.Unchecked {
+			t.Errorf("%s: a version 4 file is marked unchecked", tt.name)
+		}
+	}
+
+	// Files from before checksums are only marked unchecked.
+	old, err := os.Open(filepath.Join("testdata", "versions", "v3.model"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	sum, err = ValidateStream(old, func(p Problem) { t.Errorf("v3.model: %s", p) })
+	if err != nil || !sum.Unchecked {
+		t.Errorf("v3.model: %+v, %v, want it unchecked", sum, err)
+	}
+}
+
+// TestValidateStreamLarge validates a model of a million prefixes, written
+// on the fly rather than stored, and checks that the heap never grew
+// anywhere near the size of the model.
+func TestValidateStreamLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("validates 40 MB")
+	}
+	const prefixes = 1000000
+	r, w := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(w)
+		crc := crc32.NewIEEE()
+		mw := io.MultiWriter(bw, crc)
+		io.WriteString(mw, "GOMARK v4 prefix=2\n")
+		for i := 0; i < prefixes; i++ {
+			fmt.Fprintf(mw, "w%d x%d a 3 b%d 1 \n", i, i%1000, i%7)
+		}
+		fmt.Fprintln(bw, checksumRecord(crc.Sum32()))
+		bw.Flush()
+		w.Close()
+	}()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	sum, err := ValidateStream(r, func(p Problem) { t.Error(p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Prefixes != prefixes || sum.SuffixEntries != 2*prefixes {
+		t.Errorf("summary %+v, want %d prefixes of 2 suffixes", sum, prefixes)
+	}
+	runtime.ReadMemStats(&after)
+	if grown := int64(after.HeapSys) - int64(before.HeapSys); grown > 8<<20 {
+		t.Errorf("heap grew by %s validating %s", formatBytes(grown), formatBytes(sum.Bytes))
+	}
+}
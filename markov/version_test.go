@@ -0,0 +1,118 @@
+package markov
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The files in testdata/versions are the model of versions.txt, built with
+// Positions and SentenceStarts, as written by every format version.
+// v1.model to v3.model were written by gomark before each next version,
+// and must never be regenerated; v4.model adds the build metadata.
+
+// versionsChain returns the chain every version fixture holds.
+func versionsChain(t *testing.T) *Chain {
+	t.Helper()
+	c := newChain(2)
+	if _, err := c.BuildOpts([]string{filepath.Join("testdata", "versions", "versions.txt")}, BuildOptions{Positions: true, SentenceStarts: true}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestReadVersions(t *testing.T) {
+	want := versionsChain(t)
+	for v := 1; v <= FormatVersion; v++ {
+		name := filepath.Join("testdata", "versions", fmt.Sprintf("v%d.model", v))
+		c, err := ReadFreTable(name)
+		// Files from before checksums are read along with a note saying so.
+		var cerr *ChecksumError
+		if v < checksumVersion && errors.As(err, &cerr) && cerr.Missing {
+			err = nil
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if c.Version() != v {
+			t.Errorf("%s: Version() = %d, want %d", name, c.Version(), v)
+		}
+		if !reflect.DeepEqual(c.chain, want.chain) {
+			t.Errorf("%s: the table differs from a build of versions.txt", name)
+		}
+		if !reflect.DeepEqual(c.positions, want.positions) || !reflect.DeepEqual(c.starts, want.starts) {
+			t.Errorf("%s: the extension records differ from a build of versions.txt", name)
+		}
+		if got := c.Suffixes(Prefix{"rose", "50%"}); len(got) != 1 || got[0].word != "and" {
+			t.Errorf("%s: suffixes of rose 50%% = %v, want [and]", name, got)
+		}
+
+		// Written again, every version is the current one, and the same.
+		var b bytes.Buffer
+		if _, err := c.WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if header := strings.SplitN(b.String(), "\n", 2)[0]; header != want.header() {
+			t.Errorf("%s: migrated header %q, want %q", name, header, want.header())
+		}
+		back := new(Chain)
+		if _, err := back.ReadFrom(&b); err != nil {
+			t.Errorf("%s: reading the migrated file: %v", name, err)
+			continue
+		}
+		if back.Version() != FormatVersion || !reflect.DeepEqual(back.chain, c.chain) || !reflect.DeepEqual(back.positions, c.positions) {
+			t.Errorf("%s: the migrated file reads back differently", name)
+		}
+	}
+}
+
+func TestReadNewerVersion(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "versions", fmt.Sprintf("v%d.model", FormatVersion)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer := fmt.Sprintf("%s v%d prefix=2", headerMagic, FormatVersion+1)
+	_, body, _ := strings.Cut(string(data), "\n")
+	_, err = new(Chain).ReadFrom(strings.NewReader(newer + "\n" + body))
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("reading a v%d file: %v, want ErrUnsupportedVersion", FormatVersion+1, err)
+	}
+	if want := fmt.Sprintf("v%d", FormatVersion+1); !strings.Contains(err.Error(), want) {
+		t.Errorf("the error %q does not name the version %s", err, want)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	for _, tt := range []struct {
+		line               string
+		prefixLen, version int
+		ok                 bool
+	}{
+		{"2", 2, 1, true},
+		{" 3 \r", 3, 1, true},
+		{"GOMARK v2 prefix=2", 2, 2, true},
+		{fmt.Sprintf("GOMARK v%d decimals=2 prefix=3", FormatVersion), 3, FormatVersion, true},
+		{"0", 0, 0, false},
+		{"", 0, 0, false},
+		{"GOMARK", 0, 0, false},
+		{"GOMARK2 prefix=2", 0, 0, false},
+		{"gomark v2 prefix=2", 0, 0, false},
+		{"GOMARK vx prefix=2", 0, 0, false},
+		{"GOMARK v1 prefix=2", 0, 0, false},
+		{"GOMARK v2 prefix=0", 0, 0, false},
+		{"GOMARK v2 prefix=two", 0, 0, false},
+		{"GOMARK v2", 0, 0, false},
+		{fmt.Sprintf("GOMARK v%d prefix=2", FormatVersion+1), 0, FormatVersion + 1, false},
+	} {
+		prefixLen, version, err := parseHeader(tt.line)
+		if prefixLen != tt.prefixLen || version != tt.version || (err == nil) != tt.ok {
+			t.Errorf("parseHeader(%q) = %d, %d, %v, want %d, %d, ok %v", tt.line, prefixLen, version, err, tt.prefixLen, tt.version, tt.ok)
+		}
+	}
+}